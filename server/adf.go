@@ -0,0 +1,400 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// adfNode is a node of an Atlassian Document Format document, which Jira Cloud's v3 API
+// uses for rich text such as issue descriptions and comment bodies.
+// See https://developer.atlassian.com/cloud/jira/platform/apis/document/structure/
+type adfNode struct {
+	Version int                    `json:"version,omitempty"`
+	Type    string                 `json:"type"`
+	Text    string                 `json:"text,omitempty"`
+	Attrs   map[string]interface{} `json:"attrs,omitempty"`
+	Marks   []adfMark              `json:"marks,omitempty"`
+	Content []*adfNode             `json:"content,omitempty"`
+}
+
+type adfMark struct {
+	Type  string                 `json:"type"`
+	Attrs map[string]interface{} `json:"attrs,omitempty"`
+}
+
+var adfPanelIcons = map[string]string{
+	"info":    ":information_source:",
+	"note":    ":memo:",
+	"tip":     ":bulb:",
+	"success": ":white_check_mark:",
+	"warning": ":warning:",
+	"error":   ":no_entry:",
+}
+
+// parseADFDocument returns the document if text holds a serialized ADF document.
+func parseADFDocument(text string) (*adfNode, bool) {
+	trimmed := strings.TrimSpace(text)
+	if !strings.HasPrefix(trimmed, "{") || !strings.Contains(trimmed, `"doc"`) {
+		return nil, false
+	}
+
+	doc := &adfNode{}
+	if err := json.Unmarshal([]byte(trimmed), doc); err != nil || doc.Type != "doc" {
+		return nil, false
+	}
+	return doc, true
+}
+
+// adfToMarkdown converts an ADF document into Mattermost markdown. Mentions are rendered
+// in Jira's [~accountid:...] form so they can be resolved by replaceJiraAccountIds.
+func adfToMarkdown(doc *adfNode) string {
+	return strings.TrimSpace(renderADFBlocks(doc.Content, "\n\n"))
+}
+
+func renderADFBlocks(nodes []*adfNode, sep string) string {
+	blocks := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		if node == nil {
+			continue
+		}
+		if block := renderADFBlock(node); block != "" {
+			blocks = append(blocks, block)
+		}
+	}
+	return strings.Join(blocks, sep)
+}
+
+func renderADFBlock(node *adfNode) string {
+	switch node.Type {
+	case "paragraph":
+		return renderADFInline(node.Content)
+
+	case "heading":
+		level := adfAttrInt(node.Attrs, "level", 1)
+		if level < 1 || level > 6 {
+			level = 1
+		}
+		return strings.Repeat("#", level) + " " + renderADFInline(node.Content)
+
+	case "bulletList":
+		items := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			items = append(items, renderADFListItem(item, "* "))
+		}
+		return strings.Join(items, "\n")
+
+	case "orderedList":
+		order := adfAttrInt(node.Attrs, "order", 1)
+		items := make([]string, 0, len(node.Content))
+		for i, item := range node.Content {
+			items = append(items, renderADFListItem(item, fmt.Sprintf("%d. ", order+i)))
+		}
+		return strings.Join(items, "\n")
+
+	case "taskList":
+		items := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			marker := "- [ ] "
+			if adfAttrString(item.Attrs, "state") == "DONE" {
+				marker = "- [x] "
+			}
+			items = append(items, renderADFListItem(item, marker))
+		}
+		return strings.Join(items, "\n")
+
+	case "decisionList":
+		items := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			items = append(items, renderADFListItem(item, "* :heavy_check_mark: "))
+		}
+		return strings.Join(items, "\n")
+
+	case "codeBlock":
+		var sb strings.Builder
+		for _, child := range node.Content {
+			sb.WriteString(child.Text)
+		}
+		return "```" + adfAttrString(node.Attrs, "language") + "\n" + sb.String() + "\n```"
+
+	case "blockquote":
+		return prefixLines(renderADFBlocks(node.Content, "\n\n"), "> ")
+
+	case "panel":
+		content := renderADFBlocks(node.Content, "\n\n")
+		if icon := adfPanelIcons[adfAttrString(node.Attrs, "panelType")]; icon != "" {
+			content = icon + " " + content
+		}
+		return prefixLines(content, "> ")
+
+	case "expand", "nestedExpand":
+		content := renderADFBlocks(node.Content, "\n\n")
+		if title := adfAttrString(node.Attrs, "title"); title != "" {
+			content = "**" + title + "**\n" + content
+		}
+		return content
+
+	case "rule":
+		return "---"
+
+	case "table":
+		return renderADFTable(node)
+
+	case "mediaSingle", "mediaGroup":
+		media := make([]string, 0, len(node.Content))
+		for _, child := range node.Content {
+			if child.Type == "media" {
+				media = append(media, renderADFMedia(child))
+			}
+		}
+		return strings.Join(media, "\n")
+
+	case "media", "mediaInline":
+		return renderADFMedia(node)
+
+	case "blockCard", "embedCard":
+		return adfAttrString(node.Attrs, "url")
+
+	case "layoutSection", "layoutColumn", "bodiedExtension", "doc":
+		return renderADFBlocks(node.Content, "\n\n")
+	}
+
+	// Anything else is either an inline node used at block level or an
+	// unknown node, whose text content is still worth showing.
+	return renderADFInline([]*adfNode{node})
+}
+
+// renderADFListItem renders the blocks of a list item, indenting everything after the
+// first line so nested lists and paragraphs stay inside the item.
+func renderADFListItem(item *adfNode, marker string) string {
+	var content string
+	switch item.Type {
+	case "taskItem", "decisionItem":
+		content = renderADFInline(item.Content)
+	default:
+		content = renderADFBlocks(item.Content, "\n")
+	}
+
+	indent := strings.Repeat(" ", len(marker))
+	if strings.HasPrefix(marker, "- [") {
+		indent = "  "
+	}
+	return marker + strings.ReplaceAll(content, "\n", "\n"+indent)
+}
+
+func renderADFTable(node *adfNode) string {
+	var rows []string
+	columns := 0
+	for _, row := range node.Content {
+		cells := make([]string, 0, len(row.Content))
+		for _, cell := range row.Content {
+			text := renderADFBlocks(cell.Content, " ")
+			text = strings.ReplaceAll(text, "\n", " ")
+			cells = append(cells, strings.ReplaceAll(text, "|", `\|`))
+		}
+		if len(cells) > columns {
+			columns = len(cells)
+		}
+		rows = append(rows, "| "+strings.Join(cells, " | ")+" |")
+	}
+	if len(rows) == 0 {
+		return ""
+	}
+
+	// Markdown tables require a header row, so the first row always acts as one.
+	separator := "|" + strings.Repeat(" --- |", columns)
+	return strings.Join(append([]string{rows[0], separator}, rows[1:]...), "\n")
+}
+
+// renderADFMedia renders an attachment reference. Jira media is only reachable with Jira
+// credentials, so files are shown by name unless they are external images.
+func renderADFMedia(node *adfNode) string {
+	name := adfAttrString(node.Attrs, "alt")
+	if adfAttrString(node.Attrs, "type") == "external" {
+		return "![" + name + "](" + adfAttrString(node.Attrs, "url") + ")"
+	}
+	if name == "" {
+		name = "attachment"
+	}
+	return ":paperclip: " + name
+}
+
+func renderADFInline(nodes []*adfNode) string {
+	var sb strings.Builder
+	for _, node := range nodes {
+		if node == nil {
+			continue
+		}
+
+		switch node.Type {
+		case "text":
+			sb.WriteString(renderADFText(node))
+		case "hardBreak":
+			sb.WriteString("\n")
+		case "mention":
+			if id := adfAttrString(node.Attrs, "id"); id != "" {
+				sb.WriteString("[~accountid:" + id + "]")
+			} else {
+				sb.WriteString(adfAttrString(node.Attrs, "text"))
+			}
+		case "emoji":
+			shortName := adfAttrString(node.Attrs, "shortName")
+			if text := adfAttrString(node.Attrs, "text"); text != "" && !strings.HasPrefix(shortName, ":") {
+				shortName = text
+			}
+			sb.WriteString(shortName)
+		case "inlineCard":
+			sb.WriteString(adfAttrString(node.Attrs, "url"))
+		case "status":
+			sb.WriteString("`" + adfAttrString(node.Attrs, "text") + "`")
+		case "date":
+			sb.WriteString(renderADFDate(adfAttrString(node.Attrs, "timestamp")))
+		case "mediaInline":
+			sb.WriteString(renderADFMedia(node))
+		case "placeholder":
+		default:
+			if node.Text != "" {
+				sb.WriteString(node.Text)
+			} else if len(node.Content) > 0 {
+				sb.WriteString(renderADFBlocks(node.Content, "\n\n"))
+			}
+		}
+	}
+	return sb.String()
+}
+
+func renderADFText(node *adfNode) string {
+	text := node.Text
+	link := ""
+	for _, mark := range node.Marks {
+		if mark.Type == "code" {
+			text = "`" + text + "`"
+			break
+		}
+	}
+
+	for _, mark := range node.Marks {
+		switch mark.Type {
+		case "strong":
+			text = "**" + text + "**"
+		case "em":
+			text = "_" + text + "_"
+		case "strike":
+			text = "~~" + text + "~~"
+		case "link":
+			link = adfAttrString(mark.Attrs, "href")
+		}
+	}
+
+	if link != "" {
+		text = "[" + text + "](" + link + ")"
+	}
+	return text
+}
+
+// renderADFDate renders a date node, whose timestamp is in milliseconds since the epoch.
+func renderADFDate(timestamp string) string {
+	ms, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return timestamp
+	}
+	return time.UnixMilli(ms).UTC().Format("2006-01-02")
+}
+
+func adfAttrString(attrs map[string]interface{}, key string) string {
+	switch v := attrs[key].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func adfAttrInt(attrs map[string]interface{}, key string, defaultValue int) int {
+	switch v := attrs[key].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	}
+	return defaultValue
+}
+
+// appendADFText appends plain text paragraphs to a serialized ADF document.
+func appendADFText(body string, paragraphs ...string) string {
+	doc, ok := parseADFDocument(body)
+	if !ok {
+		return body
+	}
+	for _, text := range paragraphs {
+		doc.Content = append(doc.Content, &adfNode{
+			Type:    "paragraph",
+			Content: []*adfNode{{Type: "text", Text: text}},
+		})
+	}
+	bb, err := json.Marshal(doc)
+	if err != nil {
+		return body
+	}
+	return string(bb)
+}
+
+// normalizeADFWebhook re-encodes ADF documents found in a webhook payload as JSON strings.
+// go-jira models descriptions and comment bodies as strings, so payloads carrying ADF
+// objects would otherwise fail to decode. preProcessText renders the documents later on.
+func normalizeADFWebhook(bb []byte) ([]byte, bool) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(bb, &raw); err != nil {
+		return bb, false
+	}
+
+	changed := false
+	if issue, ok := raw["issue"].(map[string]interface{}); ok {
+		if fields, ok := issue["fields"].(map[string]interface{}); ok {
+			changed = stringifyADFField(fields, "description") || changed
+			if comments, ok := fields["comment"].(map[string]interface{}); ok {
+				list, _ := comments["comments"].([]interface{})
+				for _, c := range list {
+					if comment, ok := c.(map[string]interface{}); ok {
+						changed = stringifyADFField(comment, "body") || changed
+					}
+				}
+			}
+		}
+	}
+	if comment, ok := raw["comment"].(map[string]interface{}); ok {
+		changed = stringifyADFField(comment, "body") || changed
+	}
+
+	if !changed {
+		return bb, false
+	}
+
+	normalized, err := json.Marshal(raw)
+	if err != nil {
+		return bb, false
+	}
+	return normalized, true
+}
+
+func stringifyADFField(m map[string]interface{}, key string) bool {
+	doc, ok := m[key].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	bb, err := json.Marshal(doc)
+	if err != nil {
+		return false
+	}
+	m[key] = string(bb)
+	return true
+}
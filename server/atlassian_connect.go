@@ -129,6 +129,7 @@ func (p *Plugin) processACInstalled(w http.ResponseWriter, r *http.Request, rawP
 	if err != nil {
 		return respondErr(w, http.StatusInternalServerError, err)
 	}
+	p.audit(ci.SetupWizardUserID, AuditInstanceInstall, instanceID, asc.BaseURL, "atlassian connect")
 
 	if ci.SetupRoutingSecret != "" {
 		if err := p.instanceStore.DeletePendingCloudSetupRoute(types.ID(ci.SetupRoutingSecret)); err != nil {
@@ -0,0 +1,462 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/mattermost/mattermost-plugin-jira/server/utils/types"
+)
+
+const (
+	prefixAudit   = "audit_" // + hour, like audit_2024-03-10T15
+	auditIndexKey = "auditindex"
+
+	auditDayLayout       = "2006-01-02"
+	auditHourLayout      = "2006-01-02T15"
+	auditRetentionDays   = 90
+	auditRetentionJobKey = "audit_retention"
+	auditRetentionPeriod = 24 * time.Hour
+	auditDefaultDays     = 7
+	auditMaxListedEvents = 50
+
+	// The plugin API can write to the server audit log since this version
+	minServerVersionForAuditLog = "10.10.0"
+)
+
+// Audited actions
+const (
+	AuditInstanceInstall     = "instance_install"
+	AuditInstanceUninstall   = "instance_uninstall"
+	AuditInstanceRestore     = "instance_restore"
+	AuditInstanceMigrate     = "instance_migrate"
+	AuditAdminFsck           = "admin_fsck"
+	AuditInstanceBot         = "instance_bot"
+	AuditInstanceAlias       = "instance_alias"
+	AuditInstanceUnalias     = "instance_unalias"
+	AuditSubscriptionCreate  = "subscription_create"
+	AuditSubscriptionEdit    = "subscription_edit"
+	AuditSubscriptionDelete  = "subscription_delete"
+	AuditTemplateCreate      = "template_create"
+	AuditTemplateEdit        = "template_edit"
+	AuditTemplateDelete      = "template_delete"
+	AuditUserConnect         = "user_connect"
+	AuditUserDisconnect      = "user_disconnect"
+	AuditIssueCreate         = "issue_create"
+	AuditIssueTransition     = "issue_transition"
+	AuditIssueAssign         = "issue_assign"
+	AuditIssueUnassign       = "issue_unassign"
+	AuditIssueBulk           = "issue_bulk"
+	AuditIssueEdit           = "issue_edit"
+	AuditMentionMappingsEdit = "mention_mappings_edit"
+	AuditIncidentRuleEdit    = "incident_rule_edit"
+
+	AuditSubscriptionPermissionsEdit = "subscription_permissions_edit"
+)
+
+// AuditEntry records an administrative or user action. The actor is the
+// Mattermost user ID, empty for actions of Jira, like Atlassian Connect
+// installs.
+type AuditEntry struct {
+	Timestamp  int64    `json:"timestamp"`
+	ActorID    string   `json:"actor_id,omitempty"`
+	Action     string   `json:"action"`
+	Target     string   `json:"target"`
+	InstanceID types.ID `json:"instance_id,omitempty"`
+	Details    string   `json:"details,omitempty"`
+}
+
+// AuditStore is append-only: entries are grouped in a KV value per hour, and
+// the hours are listed in an index. The hours older than the retention are
+// deleted by a scheduled job.
+type AuditStore interface {
+	AppendAuditEntry(entry *AuditEntry) error
+	LoadAuditEntries(since, until time.Time) ([]AuditEntry, error)
+	DeleteAuditEntriesBefore(cutoff time.Time) error
+}
+
+func auditKey(hour time.Time) string {
+	return prefixAudit + hour.UTC().Format(auditHourLayout)
+}
+
+func (store store) AppendAuditEntry(entry *AuditEntry) error {
+	at := time.UnixMilli(entry.Timestamp)
+	firstOfHour := false
+	err := store.plugin.client.KV.SetAtomicWithRetries(auditKey(at), func(initialBytes []byte) (interface{}, error) {
+		var entries []AuditEntry
+		if len(initialBytes) > 0 {
+			if err := json.Unmarshal(initialBytes, &entries); err != nil {
+				return nil, err
+			}
+		}
+		firstOfHour = len(entries) == 0
+		return append(entries, *entry), nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to store the audit entry")
+	}
+	if !firstOfHour {
+		return nil
+	}
+
+	hour := at.UTC().Format(auditHourLayout)
+	err = store.updateAuditIndex(func(hours []string) []string {
+		i := sort.SearchStrings(hours, hour)
+		if i < len(hours) && hours[i] == hour {
+			return hours
+		}
+		return append(hours[:i], append([]string{hour}, hours[i:]...)...)
+	})
+	return errors.Wrap(err, "failed to index the audit entry")
+}
+
+func (store store) LoadAuditEntries(since, until time.Time) ([]AuditEntry, error) {
+	var hours []string
+	if err := store.plugin.client.KV.Get(auditIndexKey, &hours); err != nil {
+		return nil, errors.Wrap(err, "failed to load the audit index")
+	}
+
+	first := since.UTC().Format(auditHourLayout)
+	last := until.UTC().Format(auditHourLayout)
+	var result []AuditEntry
+	for _, hour := range hours {
+		if hour < first || hour > last {
+			continue
+		}
+		var entries []AuditEntry
+		if err := store.plugin.client.KV.Get(prefixAudit+hour, &entries); err != nil {
+			return nil, errors.Wrapf(err, "failed to load the audit entries of %s", hour)
+		}
+		for _, entry := range entries {
+			if entry.Timestamp >= since.UnixMilli() && entry.Timestamp <= until.UnixMilli() {
+				result = append(result, entry)
+			}
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Timestamp < result[j].Timestamp
+	})
+	return result, nil
+}
+
+// DeleteAuditEntriesBefore deletes the hours of entries before the cutoff.
+// All the audit keys are swept, not only the indexed ones, so that an entry
+// that failed to be indexed is deleted too.
+func (store store) DeleteAuditEntriesBefore(cutoff time.Time) error {
+	oldest := cutoff.UTC().Format(auditHourLayout)
+	var toDelete []string
+	for i := 0; ; i++ {
+		keys, err := store.plugin.client.KV.ListKeys(i, listPerPage)
+		if err != nil {
+			return errors.Wrap(err, "failed to list the audit keys")
+		}
+		for _, key := range keys {
+			if strings.HasPrefix(key, prefixAudit) && strings.TrimPrefix(key, prefixAudit) < oldest {
+				toDelete = append(toDelete, key)
+			}
+		}
+		if len(keys) < listPerPage {
+			break
+		}
+	}
+
+	err := store.updateAuditIndex(func(hours []string) []string {
+		return hours[sort.SearchStrings(hours, oldest):]
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to update the audit index")
+	}
+
+	for _, key := range toDelete {
+		if err := store.plugin.client.KV.Delete(key); err != nil {
+			return errors.Wrapf(err, "failed to delete %s", key)
+		}
+	}
+	return nil
+}
+
+// updateAuditIndex atomically replaces the sorted hours of the index.
+func (store store) updateAuditIndex(update func(hours []string) []string) error {
+	return store.plugin.client.KV.SetAtomicWithRetries(auditIndexKey, func(initialBytes []byte) (interface{}, error) {
+		var hours []string
+		if len(initialBytes) > 0 {
+			if err := json.Unmarshal(initialBytes, &hours); err != nil {
+				return nil, err
+			}
+		}
+		return update(hours), nil
+	})
+}
+
+// runAuditRetention deletes the audit entries older than the retention. It
+// is scheduled on one server of the cluster.
+func (p *Plugin) runAuditRetention() {
+	cutoff := time.Now().AddDate(0, 0, -auditRetentionDays)
+	if err := p.auditStore.DeleteAuditEntriesBefore(cutoff); err != nil {
+		p.client.Log.Warn("Failed to delete the expired audit entries", "error", err.Error())
+	}
+}
+
+// audit records an action. Failing to record it is logged, but does not fail
+// the action.
+func (p *Plugin) audit(actorID string, action string, instanceID types.ID, target string, details string) {
+	if p.auditStore == nil {
+		return
+	}
+
+	entry := &AuditEntry{
+		Timestamp:  model.GetMillis(),
+		ActorID:    actorID,
+		Action:     action,
+		Target:     target,
+		InstanceID: instanceID,
+		Details:    details,
+	}
+	if err := p.auditStore.AppendAuditEntry(entry); err != nil {
+		p.client.Log.Warn("Failed to record the audit entry", "action", action, "target", target, "error", err.Error())
+	}
+
+	if p.serverHasAuditLog() {
+		rec := &model.AuditRecord{
+			EventName: "jira_" + action,
+			Status:    model.AuditStatusSuccess,
+			Actor:     model.AuditEventActor{UserId: actorID},
+			EventData: model.AuditEventData{
+				ObjectType: "jira",
+				Parameters: map[string]any{
+					"target":      target,
+					"instance_id": instanceID.String(),
+					"details":     details,
+				},
+			},
+		}
+		p.API.LogAuditRec(rec)
+	}
+}
+
+func (p *Plugin) serverHasAuditLog() bool {
+	p.auditLogOnce.Do(func() {
+		current, err := semver.Parse(p.API.GetServerVersion())
+		if err != nil {
+			return
+		}
+		p.auditLogSupported = current.GTE(semver.MustParse(minServerVersionForAuditLog))
+	})
+	return p.auditLogSupported
+}
+
+type auditQuery struct {
+	since      time.Time
+	until      time.Time
+	action     string
+	actorID    string
+	instanceID types.ID
+	target     string
+	format     string
+}
+
+func (q auditQuery) matches(entry AuditEntry) bool {
+	return (q.action == "" || strings.HasPrefix(entry.Action, q.action)) &&
+		(q.actorID == "" || entry.ActorID == q.actorID) &&
+		(q.instanceID == "" || entry.InstanceID == q.instanceID) &&
+		(q.target == "" || strings.Contains(strings.ToLower(entry.Target), strings.ToLower(q.target)))
+}
+
+const auditUsage = "Please use `/jira audit [days=<n>] [action=<action>] [actor=<@username>] [instance=<jiraURL>] [target=<text>] [format=csv|json]`. " +
+	"`action` can be a prefix like `subscription` or `issue`, and `format` exports the entries as a file sent to you."
+
+func (p *Plugin) parseAuditQuery(args []string, now time.Time) (*auditQuery, error) {
+	q := &auditQuery{
+		since: now.AddDate(0, 0, -auditDefaultDays),
+		until: now,
+	}
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || value == "" {
+			return nil, errors.Errorf("`%s` is not valid", arg)
+		}
+		switch strings.ToLower(key) {
+		case "days":
+			days, err := strconv.Atoi(value)
+			if err != nil || days < 1 || days > auditRetentionDays {
+				return nil, errors.Errorf("days must be a number from 1 to %d", auditRetentionDays)
+			}
+			q.since = now.AddDate(0, 0, -days)
+		case "action":
+			q.action = strings.ToLower(value)
+		case "actor":
+			user, err := p.client.User.GetByUsername(strings.TrimPrefix(value, "@"))
+			if err != nil {
+				return nil, errors.Errorf("user %s not found", value)
+			}
+			q.actorID = user.Id
+		case "instance":
+			instances, err := p.instanceStore.LoadInstances()
+			if err != nil {
+				return nil, err
+			}
+			q.instanceID = types.ID(value)
+			if instance := instances.getByAlias(value); instance != nil {
+				q.instanceID = instance.InstanceID
+			}
+		case "target":
+			q.target = value
+		case "format":
+			q.format = strings.ToLower(value)
+			if q.format != "csv" && q.format != "json" {
+				return nil, errors.New("format must be `csv` or `json`")
+			}
+		default:
+			return nil, errors.Errorf("`%s` is not a known filter", key)
+		}
+	}
+	return q, nil
+}
+
+func executeAudit(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	authorized, err := authorizedSysAdmin(p, header.UserId)
+	if err != nil {
+		return p.responsef(header, "%v", err)
+	}
+	if !authorized {
+		return p.responsef(header, "`/jira audit` can only be run by a system administrator.")
+	}
+	if p.auditStore == nil {
+		return p.responsef(header, "The audit log is not available.")
+	}
+
+	q, err := p.parseAuditQuery(args, time.Now())
+	if err != nil {
+		return p.responsef(header, "%v. %s", err, auditUsage)
+	}
+
+	all, err := p.auditStore.LoadAuditEntries(q.since, q.until)
+	if err != nil {
+		return p.responsef(header, "%v", err)
+	}
+	var entries []AuditEntry
+	for _, entry := range all {
+		if q.matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) == 0 {
+		return p.responsef(header, "No audit entries found since %s.", q.since.Format(auditDayLayout))
+	}
+
+	usernames := p.auditUsernames(entries)
+	if q.format != "" {
+		if err = p.exportAuditEntries(header.UserId, entries, usernames, q.format); err != nil {
+			return p.responsef(header, "Failed to export the audit entries. Error: %v.", err)
+		}
+		return p.responsef(header, "Sent %d audit entries as a %s file in your direct messages with the Jira bot.", len(entries), strings.ToUpper(q.format))
+	}
+
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "Audit entries since %s:\n", q.since.Format(auditDayLayout))
+	listed := entries
+	if len(listed) > auditMaxListedEvents {
+		listed = listed[len(listed)-auditMaxListedEvents:]
+		fmt.Fprintf(sb, "Showing the last %d of %d entries, use `format=csv` to export all of them.\n", auditMaxListedEvents, len(entries))
+	}
+	for _, entry := range listed {
+		fmt.Fprintf(sb, "* %s %s `%s` %s", time.UnixMilli(entry.Timestamp).UTC().Format("2006-01-02 15:04:05"), usernames[entry.ActorID], entry.Action, entry.Target)
+		if entry.InstanceID != "" {
+			fmt.Fprintf(sb, " on %s", entry.InstanceID)
+		}
+		if entry.Details != "" {
+			fmt.Fprintf(sb, " (%s)", entry.Details)
+		}
+		sb.WriteString("\n")
+	}
+	return p.response(header, sb.String())
+}
+
+// auditUsernames returns the @usernames of the actors, by user ID.
+func (p *Plugin) auditUsernames(entries []AuditEntry) map[string]string {
+	usernames := map[string]string{"": "Jira"}
+	for _, entry := range entries {
+		if _, ok := usernames[entry.ActorID]; ok {
+			continue
+		}
+		usernames[entry.ActorID] = entry.ActorID
+		if user, err := p.client.User.Get(entry.ActorID); err == nil {
+			usernames[entry.ActorID] = "@" + user.Username
+		}
+	}
+	return usernames
+}
+
+func formatAuditEntries(entries []AuditEntry, usernames map[string]string, format string) ([]byte, error) {
+	if format == "json" {
+		return json.MarshalIndent(entries, "", "  ")
+	}
+
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	_ = w.Write([]string{"timestamp", "actor_id", "actor", "action", "target", "instance_id", "details"})
+	for _, entry := range entries {
+		_ = w.Write([]string{
+			time.UnixMilli(entry.Timestamp).UTC().Format(time.RFC3339),
+			entry.ActorID,
+			usernames[entry.ActorID],
+			entry.Action,
+			entry.Target,
+			entry.InstanceID.String(),
+			entry.Details,
+		})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// exportAuditEntries sends the entries as a file in the direct channel of the
+// administrator with the bot, so that they are not shared in the channel the
+// command was run in.
+func (p *Plugin) exportAuditEntries(userID string, entries []AuditEntry, usernames map[string]string, format string) error {
+	data, err := formatAuditEntries(entries, usernames, format)
+	if err != nil {
+		return err
+	}
+
+	fileName := fmt.Sprintf("jira-audit-%s.%s", time.Now().UTC().Format(auditDayLayout), format)
+	return p.sendFileToUser(userID, fileName, fmt.Sprintf("Jira audit log export, %d entries.", len(entries)), data)
+}
+
+// sendFileToUser posts a file in the direct channel of the user with the bot.
+func (p *Plugin) sendFileToUser(userID, fileName, message string, data []byte) error {
+	channel, err := p.client.Channel.GetDirect(userID, p.getUserID())
+	if err != nil {
+		return err
+	}
+
+	fileInfo, err := p.client.File.Upload(bytes.NewReader(data), fileName, channel.Id)
+	if err != nil {
+		return err
+	}
+
+	return p.client.Post.CreatePost(&model.Post{
+		UserId:    p.getUserID(),
+		ChannelId: channel.Id,
+		Message:   message,
+		FileIds:   []string{fileInfo.Id},
+	})
+}
+
+func subscriptionAuditDetails(subscriptionID, channelID string) string {
+	return fmt.Sprintf("id: %s, channel: %s", subscriptionID, channelID)
+}
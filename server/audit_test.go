@@ -0,0 +1,163 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendAuditEntry(t *testing.T) {
+	api := &plugintest.API{}
+	p := setupTestPlugin(api)
+	kv := map[string][]byte{}
+	mockKVMap(api, kv)
+	store := NewStore(p)
+
+	at := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	first := &AuditEntry{Timestamp: at.UnixMilli(), ActorID: "user1", Action: AuditSubscriptionCreate, Target: "Bugs"}
+	second := &AuditEntry{Timestamp: at.Add(time.Minute).UnixMilli(), Action: AuditUserConnect, Target: "Jane"}
+	earlier := &AuditEntry{Timestamp: at.Add(-time.Hour).UnixMilli(), Action: AuditIssueCreate, Target: "TEST-1"}
+	require.NoError(t, store.AppendAuditEntry(first))
+	require.NoError(t, store.AppendAuditEntry(second))
+	require.NoError(t, store.AppendAuditEntry(earlier))
+
+	var entries []AuditEntry
+	require.NoError(t, json.Unmarshal(kv["audit_2024-03-10T12"], &entries))
+	assert.Equal(t, []AuditEntry{*first, *second}, entries)
+	assert.Equal(t, `["2024-03-10T11","2024-03-10T12"]`, string(kv[auditIndexKey]))
+}
+
+func TestLoadAuditEntries(t *testing.T) {
+	api := &plugintest.API{}
+	p := setupTestPlugin(api)
+	kv := map[string][]byte{}
+	mockKVMap(api, kv)
+	store := NewStore(p)
+
+	day1 := time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+	tooOld := AuditEntry{Timestamp: day1.Add(time.Hour).UnixMilli(), Action: AuditIssueCreate}
+	first := AuditEntry{Timestamp: day1.Add(20 * time.Hour).UnixMilli(), Action: AuditIssueAssign}
+	second := AuditEntry{Timestamp: day2.Add(time.Hour).UnixMilli(), Action: AuditIssueUnassign}
+	tooNew := AuditEntry{Timestamp: day2.Add(11 * time.Hour).UnixMilli(), Action: AuditIssueEdit}
+	for _, entry := range []AuditEntry{tooOld, first, second, tooNew} {
+		require.NoError(t, store.AppendAuditEntry(&entry))
+	}
+
+	entries, err := store.LoadAuditEntries(day1.Add(12*time.Hour), day2.Add(10*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, []AuditEntry{first, second}, entries)
+}
+
+func TestDeleteAuditEntriesBefore(t *testing.T) {
+	api := &plugintest.API{}
+	p := setupTestPlugin(api)
+	kv := map[string][]byte{}
+	mockKVMap(api, kv)
+	api.On("KVList", mock.Anything, mock.Anything).Return(func(page, perPage int) []string {
+		var keys []string
+		for key := range kv {
+			keys = append(keys, key)
+		}
+		return keys
+	}, nil)
+	store := NewStore(p)
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, at := range []time.Time{now.AddDate(0, 0, -100), now.AddDate(0, 0, -91), now.AddDate(0, 0, -1)} {
+		require.NoError(t, store.AppendAuditEntry(&AuditEntry{Timestamp: at.UnixMilli(), Action: AuditIssueCreate}))
+	}
+	// Not in the index
+	kv["audit_2024-01-01T00"] = []byte("[]")
+	kv["other_key"] = []byte("{}")
+
+	require.NoError(t, store.DeleteAuditEntriesBefore(now.AddDate(0, 0, -auditRetentionDays)))
+	var keys []string
+	for key := range kv {
+		keys = append(keys, key)
+	}
+	assert.ElementsMatch(t, []string{"audit_2024-05-31T12", auditIndexKey, "other_key"}, keys)
+	assert.Equal(t, `["2024-05-31T12"]`, string(kv[auditIndexKey]))
+}
+
+func TestParseAuditQuery(t *testing.T) {
+	api := &plugintest.API{}
+	p := setupTestPlugin(api)
+	api.On("GetUserByUsername", "jane").Return(&model.User{Id: "jane-id", Username: "jane"}, nil)
+	api.On("GetUserByUsername", "nobody").Return(nil, &model.AppError{Message: "not found"})
+
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	q, err := p.parseAuditQuery(nil, now)
+	require.NoError(t, err)
+	assert.Equal(t, now.AddDate(0, 0, -auditDefaultDays), q.since)
+	assert.Equal(t, now, q.until)
+
+	q, err = p.parseAuditQuery([]string{"days=30", "action=Subscription", "actor=@jane", "target=bugs", "format=csv"}, now)
+	require.NoError(t, err)
+	assert.Equal(t, now.AddDate(0, 0, -30), q.since)
+	assert.Equal(t, "jane-id", q.actorID)
+	assert.Equal(t, "csv", q.format)
+
+	assert.True(t, q.matches(AuditEntry{ActorID: "jane-id", Action: AuditSubscriptionEdit, Target: "Open Bugs"}))
+	assert.False(t, q.matches(AuditEntry{ActorID: "jane-id", Action: AuditTemplateEdit, Target: "Open Bugs"}))
+	assert.False(t, q.matches(AuditEntry{ActorID: "bob-id", Action: AuditSubscriptionEdit, Target: "Open Bugs"}))
+	assert.False(t, q.matches(AuditEntry{ActorID: "jane-id", Action: AuditSubscriptionEdit, Target: "Releases"}))
+
+	for _, args := range [][]string{
+		{"days=0"},
+		{"days=91"},
+		{"format=xml"},
+		{"actor=@nobody"},
+		{"color=red"},
+		{"action"},
+	} {
+		_, err = p.parseAuditQuery(args, now)
+		assert.Error(t, err, args)
+	}
+}
+
+func TestFormatAuditEntries(t *testing.T) {
+	at := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	entries := []AuditEntry{
+		{Timestamp: at.UnixMilli(), ActorID: "jane-id", Action: AuditIssueTransition, Target: "TEST-1", InstanceID: testInstance1.InstanceID, Details: "to: Done, really"},
+		{Timestamp: at.UnixMilli(), Action: AuditInstanceInstall, Target: "https://test.atlassian.net"},
+	}
+	usernames := map[string]string{"": "Jira", "jane-id": "@jane"}
+
+	data, err := formatAuditEntries(entries, usernames, "csv")
+	require.NoError(t, err)
+	assert.Equal(t, "timestamp,actor_id,actor,action,target,instance_id,details\n"+
+		"2024-03-10T12:00:00Z,jane-id,@jane,issue_transition,TEST-1,https://jiraurl1.com,\"to: Done, really\"\n"+
+		"2024-03-10T12:00:00Z,,Jira,instance_install,https://test.atlassian.net,,\n", string(data))
+
+	data, err = formatAuditEntries(entries, usernames, "json")
+	require.NoError(t, err)
+	var decoded []AuditEntry
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, entries, decoded)
+}
+
+func TestServerHasAuditLog(t *testing.T) {
+	for version, expected := range map[string]bool{
+		"10.9.3":  false,
+		"10.10.0": true,
+		"11.0.1":  true,
+		"invalid": false,
+	} {
+		api := &plugintest.API{}
+		p := setupTestPlugin(api)
+		api.On("GetServerVersion").Return(version).Once()
+		assert.Equal(t, expected, p.serverHasAuditLog(), version)
+		assert.Equal(t, expected, p.serverHasAuditLog(), version)
+	}
+}
@@ -0,0 +1,533 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+
+	"github.com/mattermost/mattermost-plugin-jira/server/utils/types"
+)
+
+const (
+	bulkKeyPrefix = "bulk_"
+
+	// A bulk operation must be confirmed within this time
+	bulkConfirmExpiry = 15 * time.Minute
+
+	bulkMaxIssues       = 100
+	bulkMaxListedIssues = 20
+
+	// Jira is called at most once per interval, and after a rate limit
+	// response the call is retried with a doubling delay
+	bulkRequestInterval   = 250 * time.Millisecond
+	bulkRateLimitDelay    = 2 * time.Second
+	bulkRateLimitRetries  = 3
+	bulkMaxListedFailures = 20
+)
+
+// Bulk actions
+const (
+	BulkAssign      = "assign"
+	BulkTransition  = "transition"
+	BulkLabelAdd    = "label add"
+	BulkLabelRemove = "label remove"
+	BulkPriority    = "priority"
+	BulkComment     = "comment"
+)
+
+const bulkUsage = "Usage: `/jira bulk assign|transition|priority|comment <value> --jql \"<query>\"` or `/jira bulk label add|remove <label> --jql \"<query>\"`."
+
+// BulkOperation is an action to run on the issues matching a JQL query. It
+// is stored until the user confirms or cancels it in the dialog.
+type BulkOperation struct {
+	ID               string     `json:"id"`
+	MattermostUserID string     `json:"mattermost_user_id"`
+	InstanceID       types.ID   `json:"instance_id"`
+	ChannelID        string     `json:"channel_id"`
+	RootID           string     `json:"root_id,omitempty"`
+	Action           string     `json:"action"`
+	Value            string     `json:"value"`
+	Assignee         *jira.User `json:"assignee,omitempty"`
+	JQL              string     `json:"jql"`
+	IssueKeys        []string   `json:"issue_keys"`
+}
+
+type bulkFailure struct {
+	IssueKey string
+	Error    string
+
+	// The transition screen has required fields, which can't be set in bulk
+	RequiredFields bool
+}
+
+func bulkOperationKey(id string) string {
+	return bulkKeyPrefix + id
+}
+
+// parseBulkArgs splits the arguments of `/jira bulk` into the action, its
+// value and the JQL query. The query may be quoted.
+func parseBulkArgs(args []string) (action, value, jql string, err error) {
+	jqlAt := -1
+	for i, arg := range args {
+		if arg == "--jql" {
+			jqlAt = i
+			break
+		}
+	}
+	if jqlAt < 0 || jqlAt == len(args)-1 {
+		return "", "", "", errors.New("please specify the issues with `--jql \"<query>\"`")
+	}
+	jql = strings.TrimSpace(strings.Join(args[jqlAt+1:], " "))
+	if len(jql) >= 2 && (jql[0] == '"' || jql[0] == '\'') && jql[len(jql)-1] == jql[0] {
+		jql = jql[1 : len(jql)-1]
+	}
+
+	args = args[:jqlAt]
+	if len(args) == 0 {
+		return "", "", "", errors.New("please specify the action")
+	}
+	action = strings.ToLower(args[0])
+	args = args[1:]
+	if action == "label" {
+		if len(args) == 0 || (args[0] != "add" && args[0] != "remove") {
+			return "", "", "", errors.New("please specify `label add` or `label remove`")
+		}
+		action += " " + args[0]
+		args = args[1:]
+	}
+
+	value = strings.Join(args, " ")
+	switch action {
+	case BulkAssign, BulkTransition, BulkPriority, BulkComment:
+	case BulkLabelAdd, BulkLabelRemove:
+		if len(args) != 1 {
+			return "", "", "", errors.New("labels can not contain spaces")
+		}
+	default:
+		return "", "", "", errors.Errorf("`%s` is not a bulk action", action)
+	}
+	if value == "" {
+		return "", "", "", errors.Errorf("please specify the value to %s", action)
+	}
+	return action, value, jql, nil
+}
+
+func (op *BulkOperation) describe() string {
+	switch op.Action {
+	case BulkAssign:
+		name := op.Value
+		if op.Assignee != nil && op.Assignee.DisplayName != "" {
+			name = op.Assignee.DisplayName
+		}
+		return "assign to " + name
+	case BulkTransition:
+		return "transition to " + op.Value
+	case BulkLabelAdd:
+		return "add label " + op.Value
+	case BulkLabelRemove:
+		return "remove label " + op.Value
+	case BulkPriority:
+		return "set priority to " + op.Value
+	case BulkComment:
+		return "comment"
+	}
+	return op.Action
+}
+
+func executeBulk(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	user, instance, args, err := p.loadFlagUserInstance(header.UserId, args)
+	if err != nil {
+		return p.responsef(header, "Failed to load your connection to Jira. Error: %v.", err)
+	}
+	action, value, jql, err := parseBulkArgs(args)
+	if err != nil {
+		return p.responsef(header, "%v. %s", err, bulkUsage)
+	}
+
+	connection, err := p.userStore.LoadConnection(instance.GetID(), user.MattermostUserID)
+	if err != nil {
+		return p.responsef(header, "Failed to load your connection to Jira. Error: %v.", err)
+	}
+	client, err := instance.GetClient(connection)
+	if err != nil {
+		return p.responsef(header, "Failed to load your connection to Jira. Error: %v.", err)
+	}
+
+	issues, err := searchBulkIssues(client, jql)
+	if errors.Is(err, errBulkTooManyIssues) {
+		return p.responsef(header, "More than %d issues match `%s`, please narrow the query.", bulkMaxIssues, jql)
+	}
+	if err != nil {
+		return p.responsef(header, "Failed to search for issues. Error: %v.", err)
+	}
+	if len(issues) == 0 {
+		return p.responsef(header, "No issues match `%s`.", jql)
+	}
+
+	op := &BulkOperation{
+		ID:               model.NewId(),
+		MattermostUserID: header.UserId,
+		InstanceID:       instance.GetID(),
+		ChannelID:        header.ChannelId,
+		RootID:           header.RootId,
+		Action:           action,
+		Value:            value,
+		JQL:              jql,
+	}
+	for _, issue := range issues {
+		op.IssueKeys = append(op.IssueKeys, issue.Key)
+	}
+	if action == BulkAssign {
+		op.Assignee, err = p.findBulkAssignee(client, connection, instance.GetID(), header.UserMentions, op.IssueKeys[0], value)
+		if err != nil {
+			return p.responsef(header, "%v", err)
+		}
+	}
+
+	if _, err = p.client.KV.Set(bulkOperationKey(op.ID), op, pluginapi.SetExpiry(bulkConfirmExpiry)); err != nil {
+		return p.responsef(header, "Failed to store the bulk operation. Error: %v.", err)
+	}
+
+	err = p.client.Frontend.OpenInteractiveDialog(model.OpenDialogRequest{
+		TriggerId: header.TriggerId,
+		URL:       fmt.Sprintf("/plugins/%s%s%s", manifest.Id, routeAPI, routeBulkAction),
+		Dialog:    *makeBulkConfirmDialog(op, issues, instance.GetJiraBaseURL()),
+	})
+	if err != nil {
+		return p.responsef(header, "Failed to open the confirmation dialog. Error: %v.", err)
+	}
+	return &model.CommandResponse{}
+}
+
+// findBulkAssignee returns the Jira user to assign the issues to: the user
+// running the command for "me", a mentioned Mattermost user, or the only
+// user assignable to the first issue matching the search.
+func (p *Plugin) findBulkAssignee(client Client, connection *Connection, instanceID types.ID, mentions model.UserMentionMap, issueKey, search string) (*jira.User, error) {
+	var assignee jira.User
+	switch {
+	case strings.EqualFold(search, "me"):
+		assignee = connection.User
+	case strings.HasPrefix(search, "@"):
+		user, err := p.GetJiraUserFromMentions(instanceID, mentions, search)
+		if err != nil {
+			return nil, err
+		}
+		assignee = *user
+	default:
+		if len(search) < MinUserSearchQueryLength {
+			return nil, errors.Errorf("`%s` contains less than %v characters", search, MinUserSearchQueryLength)
+		}
+		users, err := client.SearchUsersAssignableToIssue(issueKey, search, 10)
+		if err != nil {
+			return nil, err
+		}
+		if len(users) != 1 {
+			return nil, errors.Errorf("`%s` matches %d users, please specify a unique assignee", search, len(users))
+		}
+		assignee = users[0]
+	}
+
+	// The account ID and the username are mutually exclusive, see AssignIssue
+	if assignee.AccountID != "" {
+		assignee.Name = ""
+	}
+	return &assignee, nil
+}
+
+// makeBulkConfirmDialog returns the dialog that lists the issues the
+// operation is about to change, for the user to confirm.
+func makeBulkConfirmDialog(op *BulkOperation, issues []jira.Issue, jiraBaseURL string) *model.Dialog {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "**%s** the %d issues matching `%s`:\n", op.describe(), len(issues), op.JQL)
+	for i, issue := range issues {
+		if i == bulkMaxListedIssues {
+			fmt.Fprintf(sb, "* and %d more\n", len(issues)-bulkMaxListedIssues)
+			break
+		}
+		status := ""
+		if issue.Fields != nil && issue.Fields.Status != nil {
+			status = " (" + issue.Fields.Status.Name + ")"
+		}
+		summary := ""
+		if issue.Fields != nil {
+			summary = " " + issue.Fields.Summary
+		}
+		fmt.Fprintf(sb, "* [%s](%s/browse/%s)%s%s\n", issue.Key, jiraBaseURL, issue.Key, summary, status)
+	}
+	if op.Action == BulkComment {
+		fmt.Fprintf(sb, "\nComment:\n> %s\n", op.Value)
+	}
+
+	return &model.Dialog{
+		CallbackId:       op.ID,
+		Title:            "Bulk change issues",
+		IntroductionText: sb.String(),
+		SubmitLabel:      "Confirm",
+		NotifyOnCancel:   true,
+		State:            op.ID,
+	}
+}
+
+// claimBulkOperation deletes the stored operation of the user, and returns
+// it. The operation is compared when it is deleted, so that it is only
+// claimed once, and nil is returned if it expired or was already claimed.
+func (p *Plugin) claimBulkOperation(id, mattermostUserID string) (*BulkOperation, error) {
+	var data []byte
+	if err := p.client.KV.Get(bulkOperationKey(id), &data); err != nil {
+		return nil, errors.WithMessage(err, "failed to load the bulk operation")
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var op *BulkOperation
+	if err := json.Unmarshal(data, &op); err != nil {
+		return nil, errors.WithMessage(err, "failed to read the bulk operation")
+	}
+	if op.MattermostUserID != mattermostUserID {
+		return nil, errors.New("the bulk operation belongs to another user")
+	}
+
+	claimed, err := p.client.KV.Set(bulkOperationKey(id), nil, pluginapi.SetAtomic(data))
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to claim the bulk operation")
+	}
+	if !claimed {
+		return nil, nil
+	}
+	return op, nil
+}
+
+func (p *Plugin) httpBulkAction(w http.ResponseWriter, r *http.Request) (int, error) {
+	mattermostUserID := r.Header.Get("Mattermost-User-Id")
+	var request model.SubmitDialogRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return respondErr(w, http.StatusBadRequest, errors.WithMessage(err, "failed to decode the dialog submission"))
+	}
+	if request.UserId != mattermostUserID {
+		return respondErr(w, http.StatusForbidden, errors.New("the dialog was submitted by another user"))
+	}
+
+	// A canceled operation is claimed too, so that it can't be run anymore
+	op, err := p.claimBulkOperation(request.State, mattermostUserID)
+	if request.Cancelled {
+		return http.StatusOK, nil
+	}
+	if err != nil {
+		return respondJSON(w, &model.SubmitDialogResponse{Error: err.Error()})
+	}
+	if op == nil {
+		return respondJSON(w, &model.SubmitDialogResponse{
+			Error: "This bulk operation has expired or already ran, please run the command again.",
+		})
+	}
+
+	go p.runBulkOperation(op)
+	return respondJSON(w, &model.SubmitDialogResponse{})
+}
+
+// runBulkOperation runs a confirmed operation with the Jira connection of
+// the user, so that the Jira permissions of the user apply, and posts a
+// summary of the results in the channel.
+func (p *Plugin) runBulkOperation(op *BulkOperation) {
+	botUserID := p.getBotUserID(op.InstanceID)
+	client, instance, _, err := p.getClient(op.InstanceID, types.ID(op.MattermostUserID))
+	if err != nil {
+		p.client.Post.SendEphemeralPost(op.MattermostUserID, &model.Post{
+			UserId:    botUserID,
+			ChannelId: op.ChannelID,
+			RootId:    op.RootID,
+			Message:   fmt.Sprintf("Failed to load your connection to Jira. Error: %v.", err),
+		})
+		return
+	}
+
+	failures := p.applyBulkOperation(client, instance, op)
+	succeeded := len(op.IssueKeys) - len(failures)
+	p.audit(op.MattermostUserID, AuditIssueBulk, op.InstanceID, op.JQL,
+		fmt.Sprintf("%s, %d of %d issues", op.describe(), succeeded, len(op.IssueKeys)))
+
+	message := bulkSummary(op, failures, instance.GetJiraBaseURL())
+	if user, appErr := p.client.User.Get(op.MattermostUserID); appErr == nil {
+		message = fmt.Sprintf("@%s ran a bulk operation on the issues matching `%s`.\n%s", user.Username, op.JQL, message)
+	}
+	err = p.client.Post.CreatePost(&model.Post{
+		UserId:    botUserID,
+		ChannelId: op.ChannelID,
+		RootId:    op.RootID,
+		Message:   message,
+	})
+	if err != nil {
+		p.client.Log.Warn("Failed to post the summary of a bulk operation", "error", err.Error())
+	}
+}
+
+// applyBulkOperation applies the operation to the issues one at a time, and
+// returns the failures.
+func (p *Plugin) applyBulkOperation(client Client, instance Instance, op *BulkOperation) []bulkFailure {
+	value := op.Value
+	if op.Action == BulkComment {
+		value = p.markdownToJiraText(instance, op.Value)
+	}
+
+	var failures []bulkFailure
+	for i, issueKey := range op.IssueKeys {
+		if i > 0 {
+			time.Sleep(bulkRequestInterval)
+		}
+
+		err := applyBulkAction(client, op.Action, value, op.Assignee, issueKey)
+		delay := bulkRateLimitDelay
+		for retry := 0; StatusCode(err) == http.StatusTooManyRequests && retry < bulkRateLimitRetries; retry++ {
+			time.Sleep(delay)
+			delay *= 2
+			err = applyBulkAction(client, op.Action, value, op.Assignee, issueKey)
+		}
+		if err == nil {
+			continue
+		}
+		if errors.Is(err, errBulkRequiredFields) {
+			failures = append(failures, bulkFailure{IssueKey: issueKey, Error: err.Error(), RequiredFields: true})
+			continue
+		}
+
+		message := err.Error()
+		switch StatusCode(err) {
+		case http.StatusForbidden, http.StatusUnauthorized:
+			message = "you do not have the permission to change this issue"
+		case http.StatusNotFound:
+			message = "the issue was not found"
+		}
+		failures = append(failures, bulkFailure{IssueKey: issueKey, Error: strings.TrimSpace(message)})
+	}
+	return failures
+}
+
+var errBulkTooManyIssues = errors.New("too many issues match the query")
+
+// searchBulkIssues returns the issues that match the query, or
+// errBulkTooManyIssues if there are more than bulkMaxIssues. The pages are
+// followed, as Jira Cloud may return fewer issues per page than asked for.
+func searchBulkIssues(client Client, jql string) ([]jira.Issue, error) {
+	var issues []jira.Issue
+	pageToken := ""
+	for {
+		page, err := client.SearchIssuesPage(jql, &jira.SearchOptions{
+			MaxResults: bulkMaxIssues + 1 - len(issues),
+			Fields:     []string{"key", "summary", "status"},
+		}, pageToken)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, page.Issues...)
+		if len(issues) > bulkMaxIssues {
+			return nil, errBulkTooManyIssues
+		}
+		if page.NextPageToken == "" || len(page.Issues) == 0 {
+			return issues, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+var errBulkRequiredFields = errors.New("the transition screen has required fields")
+
+// applyBulkAction applies the action to an issue. The value of a comment is
+// already converted for Jira.
+func applyBulkAction(client Client, action, value string, assignee *jira.User, issueKey string) error {
+	switch action {
+	case BulkAssign:
+		return client.UpdateAssignee(issueKey, assignee)
+	case BulkTransition:
+		transitions, err := client.GetTransitions(issueKey)
+		if err != nil {
+			return err
+		}
+		transition, err := findTransition(transitions, value)
+		if err != nil {
+			return err
+		}
+		fields, err := needsFieldValues(client, issueKey, transition)
+		if err != nil {
+			return err
+		}
+		if fields != nil {
+			return errBulkRequiredFields
+		}
+		return client.DoTransition(issueKey, transition.ID)
+	case BulkLabelAdd, BulkLabelRemove:
+		operation := "add"
+		if action == BulkLabelRemove {
+			operation = "remove"
+		}
+		return client.UpdateIssue(issueKey, map[string]interface{}{
+			"update": map[string]interface{}{
+				"labels": []map[string]string{{operation: value}},
+			},
+		})
+	case BulkPriority:
+		return client.UpdateIssue(issueKey, map[string]interface{}{
+			"fields": map[string]interface{}{
+				"priority": map[string]string{"name": value},
+			},
+		})
+	case BulkComment:
+		_, err := client.AddComment(issueKey, &jira.Comment{Body: value})
+		return err
+	}
+	return errors.Errorf("unknown bulk action %q", action)
+}
+
+// bulkSummary lists the failures, and the issues whose transition needs
+// fields to be filled in separately, as they have to be transitioned in Jira.
+func bulkSummary(op *BulkOperation, failures []bulkFailure, jiraBaseURL string) string {
+	succeeded := len(op.IssueKeys) - len(failures)
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "Bulk operation finished: %s, %d of %d issues succeeded", op.describe(), succeeded, len(op.IssueKeys))
+	if len(failures) == 0 {
+		sb.WriteString(".")
+		return sb.String()
+	}
+	sb.WriteString(".")
+
+	var failed, requiredFields []bulkFailure
+	for _, failure := range failures {
+		if failure.RequiredFields {
+			requiredFields = append(requiredFields, failure)
+		} else {
+			failed = append(failed, failure)
+		}
+	}
+	if len(failed) > 0 {
+		sb.WriteString(" Failed:\n")
+		for i, failure := range failed {
+			if i == bulkMaxListedFailures {
+				fmt.Fprintf(sb, "* and %d more\n", len(failed)-bulkMaxListedFailures)
+				break
+			}
+			fmt.Fprintf(sb, "* %s: %s\n", failure.IssueKey, failure.Error)
+		}
+	}
+	if len(requiredFields) > 0 {
+		fmt.Fprintf(sb, "\nThe transition to %s requires fields to be filled in, please transition these issues in Jira:\n", op.Value)
+		for i, failure := range requiredFields {
+			if i == bulkMaxListedFailures {
+				fmt.Fprintf(sb, "* and %d more\n", len(requiredFields)-bulkMaxListedFailures)
+				break
+			}
+			fmt.Fprintf(sb, "* [%s](%s/browse/%s)\n", failure.IssueKey, jiraBaseURL, failure.IssueKey)
+		}
+	}
+	return sb.String()
+}
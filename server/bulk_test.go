@@ -0,0 +1,251 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type bulkTestClient struct {
+	testClient
+	updates     map[string]map[string]interface{}
+	comments    map[string]string
+	transitions []string
+}
+
+func (client *bulkTestClient) AddComment(issueKey string, comment *jira.Comment) (*jira.Comment, error) {
+	client.comments[issueKey] = comment.Body
+	return comment, nil
+}
+
+func (client *bulkTestClient) GetTransitions(issueKey string) ([]jira.Transition, error) {
+	if issueKey == "BUG-2" || issueKey == "BUG-3" {
+		return []jira.Transition{{ID: "3", To: jira.Status{Name: "Done"}, Fields: map[string]jira.TransitionField{"resolution": {Required: true}}}}, nil
+	}
+	return client.testClient.GetTransitions(issueKey)
+}
+
+func (client *bulkTestClient) GetTransitionFields(issueKey, transitionID string) (map[string]*EditMetaField, error) {
+	// The resolution of BUG-3 has a default value
+	return map[string]*EditMetaField{
+		"resolution": {Name: "Resolution", Required: true, HasDefaultValue: issueKey == "BUG-3"},
+	}, nil
+}
+
+func (client *bulkTestClient) DoTransition(issueKey, transitionID string) error {
+	client.transitions = append(client.transitions, issueKey)
+	return nil
+}
+
+func (client *bulkTestClient) UpdateIssue(issueKey string, data map[string]interface{}) error {
+	if issueKey == noPermissionsIssueKey {
+		return RESTError{errors.New("forbidden"), http.StatusForbidden}
+	}
+	client.updates[issueKey] = data
+	return nil
+}
+
+func TestParseBulkArgs(t *testing.T) {
+	for name, tc := range map[string]struct {
+		args           []string
+		expectedAction string
+		expectedValue  string
+		expectedJQL    string
+		expectedError  string
+	}{
+		"assign": {
+			args:           []string{"assign", "@jane", "--jql", `"project`, "=", `BUG"`},
+			expectedAction: BulkAssign,
+			expectedValue:  "@jane",
+			expectedJQL:    "project = BUG",
+		},
+		"transition with spaces": {
+			args:           []string{"transition", "In", "Progress", "--jql", "assignee=currentUser()"},
+			expectedAction: BulkTransition,
+			expectedValue:  "In Progress",
+			expectedJQL:    "assignee=currentUser()",
+		},
+		"label add": {
+			args:           []string{"label", "add", "triaged", "--jql", "'labels", "is", "EMPTY'"},
+			expectedAction: BulkLabelAdd,
+			expectedValue:  "triaged",
+			expectedJQL:    "labels is EMPTY",
+		},
+		"label with spaces": {
+			args:          []string{"label", "remove", "two", "words", "--jql", "project=BUG"},
+			expectedError: "labels can not contain spaces",
+		},
+		"label without operation": {
+			args:          []string{"label", "triaged", "--jql", "project=BUG"},
+			expectedError: "please specify `label add` or `label remove`",
+		},
+		"missing jql": {
+			args:          []string{"priority", "High"},
+			expectedError: "please specify the issues with `--jql \"<query>\"`",
+		},
+		"missing value": {
+			args:          []string{"comment", "--jql", "project=BUG"},
+			expectedError: "please specify the value to comment",
+		},
+		"unknown action": {
+			args:          []string{"delete", "--jql", "project=BUG"},
+			expectedError: "`delete` is not a bulk action",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			action, value, jql, err := parseBulkArgs(tc.args)
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedAction, action)
+			assert.Equal(t, tc.expectedValue, value)
+			assert.Equal(t, tc.expectedJQL, jql)
+		})
+	}
+}
+
+func TestApplyBulkOperation(t *testing.T) {
+	p := setupTestPlugin(&plugintest.API{})
+
+	t.Run("label", func(t *testing.T) {
+		client := &bulkTestClient{updates: map[string]map[string]interface{}{}}
+		op := &BulkOperation{Action: BulkLabelRemove, Value: "triaged", IssueKeys: []string{"BUG-1", noPermissionsIssueKey}}
+
+		failures := p.applyBulkOperation(client, testInstance1, op)
+		assert.Equal(t, []bulkFailure{{IssueKey: noPermissionsIssueKey, Error: "you do not have the permission to change this issue"}}, failures)
+		assert.Equal(t, map[string]interface{}{
+			"update": map[string]interface{}{
+				"labels": []map[string]string{{"remove": "triaged"}},
+			},
+		}, client.updates["BUG-1"])
+		assert.Equal(t, "Bulk operation finished: remove label triaged, 1 of 2 issues succeeded. Failed:\n* "+noPermissionsIssueKey+": you do not have the permission to change this issue\n", bulkSummary(op, failures, "https://jira.example.com"))
+	})
+
+	t.Run("transition", func(t *testing.T) {
+		client := &bulkTestClient{}
+		op := &BulkOperation{Action: BulkTransition, Value: "testing", IssueKeys: []string{"BUG-1", noPermissionsIssueKey}}
+
+		failures := p.applyBulkOperation(client, testInstance1, op)
+		require.Len(t, failures, 1)
+		assert.Equal(t, noPermissionsIssueKey, failures[0].IssueKey)
+		assert.Contains(t, failures[0].Error, "is not a valid state")
+	})
+
+	t.Run("transition with required fields", func(t *testing.T) {
+		client := &bulkTestClient{}
+		op := &BulkOperation{Action: BulkTransition, Value: "done", IssueKeys: []string{"BUG-2"}}
+
+		failures := p.applyBulkOperation(client, testInstance1, op)
+		assert.Equal(t, []bulkFailure{{IssueKey: "BUG-2", Error: errBulkRequiredFields.Error(), RequiredFields: true}}, failures)
+		assert.Equal(t, "Bulk operation finished: transition to done, 0 of 1 issues succeeded.\n"+
+			"The transition to done requires fields to be filled in, please transition these issues in Jira:\n"+
+			"* [BUG-2](https://jira.example.com/browse/BUG-2)\n", bulkSummary(op, failures, "https://jira.example.com"))
+	})
+
+	t.Run("transition with required fields that have a default value", func(t *testing.T) {
+		client := &bulkTestClient{}
+		op := &BulkOperation{Action: BulkTransition, Value: "done", IssueKeys: []string{"BUG-2", "BUG-3"}}
+
+		failures := p.applyBulkOperation(client, testInstance1, op)
+		assert.Equal(t, []bulkFailure{{IssueKey: "BUG-2", Error: errBulkRequiredFields.Error(), RequiredFields: true}}, failures)
+		assert.Equal(t, []string{"BUG-3"}, client.transitions)
+	})
+
+	t.Run("comment", func(t *testing.T) {
+		api := &plugintest.API{}
+		p := setupTestPlugin(api)
+		api.On("GetUserByUsername", "jane").Return(nil, &model.AppError{Message: "not found"})
+		client := &bulkTestClient{comments: map[string]string{}}
+		op := &BulkOperation{Action: BulkComment, Value: "Closing **stale** issues, cc @jane", IssueKeys: []string{"BUG-1"}}
+		assert.Empty(t, p.applyBulkOperation(client, testInstance1, op))
+		assert.Equal(t, "Closing *stale* issues, cc @jane", client.comments["BUG-1"])
+		assert.Equal(t, "Bulk operation finished: comment, 1 of 1 issues succeeded.", bulkSummary(op, nil, "https://jira.example.com"))
+	})
+}
+
+func TestClaimBulkOperation(t *testing.T) {
+	api := &plugintest.API{}
+	p := setupTestPlugin(api)
+	kv := map[string][]byte{}
+	mockKVMap(api, kv)
+	op := &BulkOperation{ID: "op1", MattermostUserID: "user1", Action: BulkComment, Value: "Closing", IssueKeys: []string{"BUG-1"}}
+	_, err := p.client.KV.Set(bulkOperationKey(op.ID), op)
+	require.NoError(t, err)
+
+	_, err = p.claimBulkOperation(op.ID, "user2")
+	assert.EqualError(t, err, "the bulk operation belongs to another user")
+	require.Contains(t, kv, bulkOperationKey(op.ID))
+
+	claimed, err := p.claimBulkOperation(op.ID, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, op, claimed)
+	assert.NotContains(t, kv, bulkOperationKey(op.ID))
+
+	// A second confirmation does not run the operation again
+	claimed, err = p.claimBulkOperation(op.ID, "user1")
+	require.NoError(t, err)
+	assert.Nil(t, claimed)
+}
+
+func TestFindTransition(t *testing.T) {
+	transitions := []jira.Transition{
+		{ID: "1", To: jira.Status{Name: "To Do"}},
+		{ID: "2", To: jira.Status{Name: "In Progress"}},
+		{ID: "3", To: jira.Status{Name: "In Testing"}},
+	}
+
+	transition, err := findTransition(transitions, "inprogress")
+	require.NoError(t, err)
+	assert.Equal(t, "2", transition.ID)
+
+	_, err = findTransition(transitions, "in")
+	assert.EqualError(t, err, `please be more specific, "in" matched several states: "In Progress, In Testing"`)
+
+	_, err = findTransition(transitions, "done")
+	assert.EqualError(t, err, `"done" is not a valid state. Please use one of: "To Do, In Progress, In Testing"`)
+}
+
+// bulkSearchTestClient returns at most 40 issues per page, fewer than asked
+// for, like Jira Cloud can.
+type bulkSearchTestClient struct {
+	testClient
+	total int
+}
+
+func (client bulkSearchTestClient) SearchIssuesPage(jql string, options *jira.SearchOptions, pageToken string) (*SearchPage, error) {
+	startAt, _ := strconv.Atoi(pageToken)
+	page := &SearchPage{}
+	for i := startAt; i < client.total && i < startAt+min(40, options.MaxResults); i++ {
+		page.Issues = append(page.Issues, jira.Issue{Key: fmt.Sprintf("BUG-%d", i+1)})
+	}
+	if next := startAt + len(page.Issues); next < client.total {
+		page.NextPageToken = strconv.Itoa(next)
+	}
+	return page, nil
+}
+
+func TestSearchBulkIssues(t *testing.T) {
+	issues, err := searchBulkIssues(bulkSearchTestClient{total: 90}, "")
+	require.NoError(t, err)
+	assert.Len(t, issues, 90)
+
+	issues, err = searchBulkIssues(bulkSearchTestClient{total: bulkMaxIssues}, "")
+	require.NoError(t, err)
+	assert.Len(t, issues, bulkMaxIssues)
+
+	_, err = searchBulkIssues(bulkSearchTestClient{total: 150}, "")
+	assert.ErrorIs(t, err, errBulkTooManyIssues)
+}
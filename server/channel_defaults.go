@@ -0,0 +1,260 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/mattermost/mattermost-plugin-jira/server/utils/types"
+)
+
+const channelDefaultsKeyPrefix = "channel_defaults_"
+
+// ChannelDefaults bind a channel to a Jira instance and project. They take precedence over
+// the user's saved field values for issues created or searched for in the channel.
+type ChannelDefaults struct {
+	InstanceID types.ID `json:"instance_id"`
+	ProjectKey string   `json:"project_key"`
+	IssueType  string   `json:"issue_type,omitempty"`
+	Labels     []string `json:"labels,omitempty"`
+	Components []string `json:"components,omitempty"`
+}
+
+// loadChannelDefaults returns the defaults of the channel, or nil if it has none.
+func (p *Plugin) loadChannelDefaults(channelID string) (*ChannelDefaults, error) {
+	var defaults *ChannelDefaults
+	err := p.client.KV.Get(hashkey(channelDefaultsKeyPrefix, channelID), &defaults)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to load defaults of channel "+channelID)
+	}
+	if defaults == nil || defaults.ProjectKey == "" {
+		return nil, nil
+	}
+	return defaults, nil
+}
+
+func (p *Plugin) storeChannelDefaults(channelID string, defaults *ChannelDefaults) error {
+	_, err := p.client.KV.Set(hashkey(channelDefaultsKeyPrefix, channelID), defaults)
+	if err != nil {
+		return errors.WithMessage(err, "failed to store defaults of channel "+channelID)
+	}
+	return nil
+}
+
+func (p *Plugin) deleteChannelDefaults(channelID string) error {
+	err := p.client.KV.Delete(hashkey(channelDefaultsKeyPrefix, channelID))
+	if err != nil {
+		return errors.WithMessage(err, "failed to delete defaults of channel "+channelID)
+	}
+	return nil
+}
+
+// channelDefaultsForInstance returns the defaults of the channel if they apply to the instance.
+func (p *Plugin) channelDefaultsForInstance(channelID string, instanceID types.ID) *ChannelDefaults {
+	if channelID == "" {
+		return nil
+	}
+	defaults, err := p.loadChannelDefaults(channelID)
+	if err != nil {
+		p.client.Log.Debug("Failed to load channel defaults", "channelID", channelID, "error", err.Error())
+		return nil
+	}
+	if defaults == nil || defaults.InstanceID != instanceID {
+		return nil
+	}
+	return defaults
+}
+
+// channelDefaultsForUser returns the defaults of the channel if they apply to
+// the instance, and the user can read the channel.
+func (p *Plugin) channelDefaultsForUser(channelID, mattermostUserID string, instanceID types.ID) *ChannelDefaults {
+	if channelID == "" || !p.client.User.HasPermissionToChannel(mattermostUserID, channelID, model.PermissionReadChannel) {
+		return nil
+	}
+	return p.channelDefaultsForInstance(channelID, instanceID)
+}
+
+func (defaults *ChannelDefaults) SavedFieldValues() *SavedFieldValues {
+	return &SavedFieldValues{
+		ProjectKey: defaults.ProjectKey,
+		IssueType:  defaults.IssueType,
+	}
+}
+
+// applyToIssueFields fills in the default labels and components of the channel, unless
+// the issue is for another project or already has some.
+func (defaults *ChannelDefaults) applyToIssueFields(fields *jira.IssueFields) {
+	if fields == nil || fields.Project.Key != defaults.ProjectKey {
+		return
+	}
+	if len(fields.Labels) == 0 && len(defaults.Labels) > 0 {
+		fields.Labels = append([]string{}, defaults.Labels...)
+	}
+	if len(fields.Components) == 0 {
+		for _, name := range defaults.Components {
+			fields.Components = append(fields.Components, &jira.Component{Name: name})
+		}
+	}
+}
+
+func (defaults *ChannelDefaults) String() string {
+	s := fmt.Sprintf("* Instance: %s\n* Project: `%s`\n", defaults.InstanceID, defaults.ProjectKey)
+	if defaults.IssueType != "" {
+		s += fmt.Sprintf("* Issue type ID: `%s`\n", defaults.IssueType)
+	}
+	if len(defaults.Labels) > 0 {
+		s += fmt.Sprintf("* Labels: `%s`\n", strings.Join(defaults.Labels, "`, `"))
+	}
+	if len(defaults.Components) > 0 {
+		s += fmt.Sprintf("* Components: `%s`\n", strings.Join(defaults.Components, "`, `"))
+	}
+	return s
+}
+
+func (p *Plugin) httpGetChannelDefaults(w http.ResponseWriter, r *http.Request) (int, error) {
+	mattermostUserID := r.Header.Get("Mattermost-User-Id")
+	channelID := r.FormValue("channel_id")
+	if channelID == "" {
+		return respondErr(w, http.StatusBadRequest, errors.New("channel_id is required"))
+	}
+
+	if _, err := p.client.Channel.GetMember(channelID, mattermostUserID); err != nil {
+		return respondErr(w, http.StatusForbidden, errors.New("User does not have access to this channel"))
+	}
+
+	defaults, err := p.loadChannelDefaults(channelID)
+	if err != nil {
+		return respondErr(w, http.StatusInternalServerError, err)
+	}
+	if defaults == nil {
+		defaults = &ChannelDefaults{}
+	}
+	return respondJSON(w, defaults)
+}
+
+const channelConfigUsage = "Please use `/jira channel config project=<project-key> [issuetype=<name>] [labels=<a,b>] [components=<a,b>] [--instance=<jiraURL>]`, " +
+	"`/jira channel config clear` or `/jira channel config` to show the current configuration."
+
+func executeChannelConfig(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	if len(args) == 0 {
+		defaults, err := p.loadChannelDefaults(header.ChannelId)
+		if err != nil {
+			return p.responsef(header, "%v", err)
+		}
+		if defaults == nil {
+			return p.responsef(header, "This channel has no default Jira project. %s", channelConfigUsage)
+		}
+		return p.responsef(header, "Jira defaults of this channel:\n%s", defaults)
+	}
+
+	if len(args) == 1 && args[0] == "clear" {
+		defaults, err := p.loadChannelDefaults(header.ChannelId)
+		if err != nil {
+			return p.responsef(header, "%v", err)
+		}
+		if defaults == nil {
+			return p.responsef(header, "This channel has no default Jira project.")
+		}
+		if err = p.hasPermissionToManageSubscription(defaults.InstanceID, header.UserId, header.ChannelId); err != nil {
+			return p.responsef(header, "You are not allowed to configure the Jira defaults of this channel: %v.", err)
+		}
+		if err = p.deleteChannelDefaults(header.ChannelId); err != nil {
+			return p.responsef(header, "%v", err)
+		}
+		return p.responsef(header, "Cleared the Jira defaults of this channel.")
+	}
+
+	_, instance, args, err := p.loadFlagUserInstance(header.UserId, args)
+	if err != nil {
+		return p.responsef(header, "Failed to identify the Jira instance. Error: %v.", err)
+	}
+	if err = p.hasPermissionToManageSubscription(instance.GetID(), header.UserId, header.ChannelId); err != nil {
+		return p.responsef(header, "You are not allowed to configure the Jira defaults of this channel: %v.", err)
+	}
+
+	defaults := &ChannelDefaults{
+		InstanceID: instance.GetID(),
+	}
+	issueTypeName := ""
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || value == "" {
+			return p.responsef(header, "`%s` is not valid. %s", arg, channelConfigUsage)
+		}
+		switch strings.ToLower(key) {
+		case "project":
+			defaults.ProjectKey = strings.ToUpper(value)
+		case "issuetype":
+			issueTypeName = value
+		case "labels":
+			defaults.Labels = splitCommaList(value)
+		case "components":
+			defaults.Components = splitCommaList(value)
+		default:
+			return p.responsef(header, "`%s` is not a known setting. %s", key, channelConfigUsage)
+		}
+	}
+	if defaults.ProjectKey == "" {
+		return p.responsef(header, "A project is required. %s", channelConfigUsage)
+	}
+
+	client, _, _, err := p.getClient(instance.GetID(), types.ID(header.UserId))
+	if err != nil {
+		return p.responsef(header, "%v", err)
+	}
+	project, err := client.GetProject(defaults.ProjectKey)
+	if err != nil {
+		return p.responsef(header, "Failed to get project `%s`. Error: %v.", defaults.ProjectKey, err)
+	}
+
+	if issueTypeName != "" {
+		for _, issueType := range project.IssueTypes {
+			if strings.EqualFold(issueType.Name, issueTypeName) {
+				defaults.IssueType = issueType.ID
+				break
+			}
+		}
+		if defaults.IssueType == "" {
+			return p.responsef(header, "Project `%s` has no issue type named `%s`.", project.Key, issueTypeName)
+		}
+	}
+
+	for i, name := range defaults.Components {
+		found := false
+		for _, component := range project.Components {
+			if strings.EqualFold(component.Name, name) {
+				defaults.Components[i] = component.Name
+				found = true
+				break
+			}
+		}
+		if !found {
+			return p.responsef(header, "Project `%s` has no component named `%s`.", project.Key, name)
+		}
+	}
+
+	defaults.ProjectKey = project.Key
+	if err = p.storeChannelDefaults(header.ChannelId, defaults); err != nil {
+		return p.responsef(header, "%v", err)
+	}
+	return p.responsef(header, "Saved the Jira defaults of this channel:\n%s", defaults)
+}
+
+func splitCommaList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
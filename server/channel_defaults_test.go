@@ -0,0 +1,137 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelDefaultsApplyToIssueFields(t *testing.T) {
+	defaults := &ChannelDefaults{
+		ProjectKey: "TEST",
+		Labels:     []string{"support"},
+		Components: []string{"API"},
+	}
+
+	for name, tc := range map[string]struct {
+		fields             *jira.IssueFields
+		expectedLabels     []string
+		expectedComponents []*jira.Component
+	}{
+		"defaults are applied": {
+			fields:             &jira.IssueFields{Project: jira.Project{Key: "TEST"}},
+			expectedLabels:     []string{"support"},
+			expectedComponents: []*jira.Component{{Name: "API"}},
+		},
+		"values set by the user are kept": {
+			fields: &jira.IssueFields{
+				Project:    jira.Project{Key: "TEST"},
+				Labels:     []string{"bug"},
+				Components: []*jira.Component{{Name: "UI"}},
+			},
+			expectedLabels:     []string{"bug"},
+			expectedComponents: []*jira.Component{{Name: "UI"}},
+		},
+		"other projects are left alone": {
+			fields: &jira.IssueFields{Project: jira.Project{Key: "OTHER"}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			defaults.applyToIssueFields(tc.fields)
+			assert.Equal(t, tc.expectedLabels, tc.fields.Labels)
+			assert.Equal(t, tc.expectedComponents, tc.fields.Components)
+		})
+	}
+}
+
+func TestExecuteChannelConfig(t *testing.T) {
+	stored, err := json.Marshal(&ChannelDefaults{InstanceID: testInstance1.InstanceID, ProjectKey: "TEST", Labels: []string{"support"}})
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		args            []string
+		storedDefaults  []byte
+		expectStore     bool
+		expectedMessage string
+	}{
+		"show without defaults": {
+			expectedMessage: "This channel has no default Jira project.",
+		},
+		"show defaults": {
+			storedDefaults:  stored,
+			expectedMessage: "* Project: `TEST`\n* Labels: `support`\n",
+		},
+		"set defaults": {
+			args:            []string{"project=test", "labels=support,triage"},
+			expectStore:     true,
+			expectedMessage: "Saved the Jira defaults of this channel:\n* Instance: https://jiraurl1.com\n* Project: `TEST`\n* Labels: `support`, `triage`\n",
+		},
+		"missing project": {
+			args:            []string{"labels=support"},
+			expectedMessage: "A project is required.",
+		},
+		"unknown setting": {
+			args:            []string{"project=TEST", "priority=High"},
+			expectedMessage: "`priority` is not a known setting.",
+		},
+		"unknown issue type": {
+			args:            []string{"project=TEST", "issuetype=Bug"},
+			expectedMessage: "Project `TEST` has no issue type named `Bug`.",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			api := &plugintest.API{}
+			p := setupTestPlugin(api)
+			p.updateConfig(func(conf *config) {
+				conf.RolesAllowedToEditJiraSubscriptions = "users"
+			})
+
+			api.On("KVGet", hashkey(channelDefaultsKeyPrefix, "channel")).Return(tc.storedDefaults, (*model.AppError)(nil)).Maybe()
+			api.On("KVGet", keyWithInstanceID(testInstance1.InstanceID, subscriptionPermissionsKey)).Return(nil, (*model.AppError)(nil)).Maybe()
+			api.On("KVSetWithOptions", hashkey(channelDefaultsKeyPrefix, "channel"), mock.Anything, mock.Anything).Return(true, (*model.AppError)(nil)).Maybe()
+
+			message := ""
+			api.On("SendEphemeralPost", "connected_user", mock.AnythingOfType("*model.Post")).Run(func(args mock.Arguments) {
+				message = args.Get(1).(*model.Post).Message
+			}).Return(&model.Post{})
+
+			executeChannelConfig(p, nil, &model.CommandArgs{UserId: "connected_user", ChannelId: "channel"}, tc.args...)
+
+			assert.Contains(t, message, tc.expectedMessage)
+			if tc.expectStore {
+				api.AssertNumberOfCalls(t, "KVSetWithOptions", 1)
+			} else {
+				api.AssertNotCalled(t, "KVSetWithOptions", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestChannelDefaultsForUser(t *testing.T) {
+	api := &plugintest.API{}
+	p := setupTestPlugin(api)
+	stored, err := json.Marshal(&ChannelDefaults{InstanceID: testInstance1.InstanceID, ProjectKey: "TEST"})
+	require.NoError(t, err)
+	api.On("KVGet", hashkey(channelDefaultsKeyPrefix, "channel")).Return(stored, (*model.AppError)(nil))
+	api.On("HasPermissionToChannel", "member", "channel", model.PermissionReadChannel).Return(true)
+	api.On("HasPermissionToChannel", "outsider", "channel", model.PermissionReadChannel).Return(false)
+
+	defaults := p.channelDefaultsForUser("channel", "member", testInstance1.InstanceID)
+	require.NotNil(t, defaults)
+	assert.Equal(t, "TEST", defaults.ProjectKey)
+	assert.Nil(t, p.channelDefaultsForUser("channel", "outsider", testInstance1.InstanceID))
+	assert.Nil(t, p.channelDefaultsForUser("channel", "member", testInstance2.InstanceID))
+}
+
+func TestEscapeJQLString(t *testing.T) {
+	assert.Equal(t, `A \"quoted\" \\ value`, escapeJQLString(`A "quoted" \ value`))
+}
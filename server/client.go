@@ -67,6 +67,7 @@ type ProjectService interface {
 // SearchService is the interface for search-related APIs.
 type SearchService interface {
 	SearchIssues(jql string, options *jira.SearchOptions) ([]jira.Issue, error)
+	SearchIssuesPage(jql string, options *jira.SearchOptions, pageToken string) (*SearchPage, error)
 	SearchUsersAssignableToIssue(issueKey, query string, maxResults int) ([]jira.User, error)
 	SearchUsersAssignableInProject(projectKey, query string, maxResults int) ([]jira.User, error)
 	SearchAutoCompleteFields(params map[string]string) (*AutoCompleteResult, error)
@@ -82,10 +83,18 @@ type IssueService interface {
 	AddAttachment(mmClient pluginapi.Client, issueKey, fileID string, maxSize types.ByteSize) (mattermostName, jiraName, mime string, err error)
 	AddComment(issueKey string, comment *jira.Comment) (*jira.Comment, error)
 	DoTransition(issueKey, transitionID string) error
+	DoTransitionWithFields(issueKey, transitionID string, data map[string]interface{}) error
 	GetCreateMetaInfo(api plugin.API, options *jira.GetQueryOptions) (*jira.CreateMetaInfo, error)
+	GetEditMeta(issueKey string) (map[string]*EditMetaField, error)
 	GetTransitions(issueKey string) ([]jira.Transition, error)
+	GetTransitionFields(issueKey, transitionID string) (map[string]*EditMetaField, error)
 	UpdateAssignee(issueKey string, user *jira.User) error
 	UpdateComment(issueKey string, comment *jira.Comment) (*jira.Comment, error)
+	UpdateIssue(issueKey string, data map[string]interface{}) error
+
+	GetServiceDesks() ([]ServiceDesk, error)
+	GetRequestTypes(serviceDeskID string) ([]RequestType, error)
+	CreateCustomerRequest(request *jira.Request) (*jira.Request, error)
 }
 
 // JiraClient is the common implementation of most Jira APIs, except those that are
@@ -118,6 +127,25 @@ func (client JiraClient) RESTGet(endpoint string, params map[string]string, dest
 	return err
 }
 
+// RESTSend calls a specified HTTP endpoint with the given method and a JSON body. endpoint
+// follows the same rules as in RESTGet.
+func (client JiraClient) RESTSend(method, endpoint string, body, dest interface{}) error {
+	endpointURL, err := endpointURL(endpoint)
+	if err != nil {
+		return err
+	}
+	req, err := client.Jira.NewRequest(method, endpointURL, body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Jira.Do(req, dest)
+	if err != nil {
+		err = userFriendlyJiraError(resp, err)
+	}
+	return err
+}
+
 // RESTGetRaw calls a specified HTTP endpoint with a GET method using the raw path as-is,
 // without prepending /rest/api/. Use this for non-standard API paths like the Agile API.
 func (client JiraClient) RESTGetRaw(rawPath string, params map[string]string, dest interface{}) error {
@@ -274,6 +302,76 @@ func (client JiraClient) CreateIssue(issue *jira.Issue) (*jira.Issue, error) {
 	return created, nil
 }
 
+// GetServiceDesks returns all the Jira Service Management service desks visible to the user.
+func (client JiraClient) GetServiceDesks() ([]ServiceDesk, error) {
+	var serviceDesks []ServiceDesk
+	for start := 0; ; {
+		page := struct {
+			Values     []ServiceDesk `json:"values"`
+			IsLastPage bool          `json:"isLastPage"`
+		}{}
+		err := client.serviceDeskGet("servicedesk", start, &page)
+		if err != nil {
+			return nil, err
+		}
+		serviceDesks = append(serviceDesks, page.Values...)
+		if page.IsLastPage || len(page.Values) == 0 {
+			return serviceDesks, nil
+		}
+		start += len(page.Values)
+	}
+}
+
+// GetRequestTypes returns the customer request types of a service desk.
+func (client JiraClient) GetRequestTypes(serviceDeskID string) ([]RequestType, error) {
+	var requestTypes []RequestType
+	for start := 0; ; {
+		page := struct {
+			Values     []RequestType `json:"values"`
+			IsLastPage bool          `json:"isLastPage"`
+		}{}
+		err := client.serviceDeskGet("servicedesk/"+url.PathEscape(serviceDeskID)+"/requesttype", start, &page)
+		if err != nil {
+			return nil, err
+		}
+		requestTypes = append(requestTypes, page.Values...)
+		if page.IsLastPage || len(page.Values) == 0 {
+			return requestTypes, nil
+		}
+		start += len(page.Values)
+	}
+}
+
+// CreateCustomerRequest raises a new customer request in a service desk.
+func (client JiraClient) CreateCustomerRequest(request *jira.Request) (*jira.Request, error) {
+	created, resp, err := client.Jira.Request.Create("", nil, request)
+	if err != nil {
+		return nil, userFriendlyJiraError(resp, err)
+	}
+	return created, nil
+}
+
+// serviceDeskGet gets a page of results from the Jira Service Management API, which lives
+// outside of the /rest/api tree the other endpoints use.
+func (client JiraClient) serviceDeskGet(apiPath string, start int, dest interface{}) error {
+	req, err := client.Jira.NewRequest("GET", "rest/servicedeskapi/"+apiPath, nil)
+	if err != nil {
+		return err
+	}
+	// Some of the endpoints are still marked as experimental on Jira Data Center.
+	req.Header.Set("X-ExperimentalApi", "opt-in")
+	q := req.URL.Query()
+	q.Add("start", strconv.Itoa(start))
+	q.Add("limit", "100")
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := client.Jira.Do(req, dest)
+	if err != nil {
+		return userFriendlyJiraError(resp, err)
+	}
+	return nil
+}
+
 // UpdateAssignee changes the user assigned to an issue.
 func (client JiraClient) UpdateAssignee(issueKey string, user *jira.User) error {
 	resp, err := client.Jira.Issue.UpdateAssignee(issueKey, user)
@@ -283,6 +381,27 @@ func (client JiraClient) UpdateAssignee(issueKey string, user *jira.User) error
 	return err
 }
 
+// UpdateIssue edits an issue. The data holds the "fields" to set and the
+// "update" operations, like adding a label, of the Jira edit issue API.
+func (client JiraClient) UpdateIssue(issueKey string, data map[string]interface{}) error {
+	resp, err := client.Jira.Issue.UpdateIssue(issueKey, data)
+	if err != nil {
+		return userFriendlyJiraError(resp, err)
+	}
+	return nil
+}
+
+// GetEditMeta returns the fields of an issue the user can edit, by field ID.
+func (client JiraClient) GetEditMeta(issueKey string) (map[string]*EditMetaField, error) {
+	var meta struct {
+		Fields map[string]*EditMetaField `json:"fields"`
+	}
+	if err := client.RESTGet(fmt.Sprintf("2/issue/%s/editmeta", issueKey), nil, &meta); err != nil {
+		return nil, err
+	}
+	return meta.Fields, nil
+}
+
 // AddComment adds a comment to an issue.
 func (client JiraClient) AddComment(issueKey string, comment *jira.Comment) (*jira.Comment, error) {
 	added, resp, err := client.Jira.Issue.AddComment(issueKey, comment)
@@ -305,14 +424,52 @@ func (client JiraClient) UpdateComment(issueKey string, comment *jira.Comment) (
 func (client JiraClient) SearchIssues(jql string, options *jira.SearchOptions) ([]jira.Issue, error) {
 	found, resp, err := client.Jira.Issue.Search(jql, options)
 	if err != nil {
-		if resp != nil && (resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusUnauthorized) {
-			return nil, errors.New("not authorized to search issues")
-		}
-		return nil, userFriendlyJiraError(resp, err)
+		return nil, searchError(resp, err)
 	}
 	return found, nil
 }
 
+// SearchPage is a page of search results. NextPageToken continues the search,
+// it is empty on the last page.
+type SearchPage struct {
+	Issues        []jira.Issue
+	NextPageToken string
+}
+
+// SearchIssuesPage searches the page of issues that starts at the page token,
+// which is the index of its first issue. options.StartAt is ignored.
+func (client JiraClient) SearchIssuesPage(jql string, options *jira.SearchOptions, pageToken string) (*SearchPage, error) {
+	opts := jira.SearchOptions{}
+	if options != nil {
+		opts = *options
+	}
+	opts.StartAt = 0
+	if pageToken != "" {
+		startAt, err := strconv.Atoi(pageToken)
+		if err != nil {
+			return nil, errors.Errorf("invalid page token %q", pageToken)
+		}
+		opts.StartAt = startAt
+	}
+
+	found, resp, err := client.Jira.Issue.Search(jql, &opts)
+	if err != nil {
+		return nil, searchError(resp, err)
+	}
+	page := &SearchPage{Issues: found}
+	if next := opts.StartAt + len(found); len(found) > 0 && next < resp.Total {
+		page.NextPageToken = strconv.Itoa(next)
+	}
+	return page, nil
+}
+
+func searchError(resp *jira.Response, err error) error {
+	if resp != nil && (resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusUnauthorized) {
+		return errors.New("not authorized to search issues")
+	}
+	return userFriendlyJiraError(resp, err)
+}
+
 type Result struct {
 	Value       string `json:"value"`
 	DisplayName string `json:"displayName"`
@@ -366,6 +523,46 @@ func (client JiraClient) DoTransition(issueKey, transitionID string) error {
 	return nil
 }
 
+// DoTransitionWithFields executes a transition with a screen. The data holds
+// the "fields" and the "update" operations to submit with it, as in UpdateIssue.
+func (client JiraClient) DoTransitionWithFields(issueKey, transitionID string, data map[string]interface{}) error {
+	payload := map[string]interface{}{
+		"transition": map[string]string{"id": transitionID},
+	}
+	for k, v := range data {
+		payload[k] = v
+	}
+	resp, err := client.Jira.Issue.DoTransitionWithPayload(issueKey, payload)
+	if err != nil {
+		return userFriendlyJiraError(resp, err)
+	}
+	return nil
+}
+
+// GetTransitionFields returns the fields of the screen of a transition, by
+// field ID.
+func (client JiraClient) GetTransitionFields(issueKey, transitionID string) (map[string]*EditMetaField, error) {
+	var result struct {
+		Transitions []struct {
+			ID     string                    `json:"id"`
+			Fields map[string]*EditMetaField `json:"fields"`
+		} `json:"transitions"`
+	}
+	params := map[string]string{
+		"expand":       "transitions.fields",
+		"transitionId": transitionID,
+	}
+	if err := client.RESTGet(fmt.Sprintf("2/issue/%s/transitions", issueKey), params, &result); err != nil {
+		return nil, err
+	}
+	for _, transition := range result.Transitions {
+		if transition.ID == transitionID {
+			return transition.Fields, nil
+		}
+	}
+	return nil, errors.Errorf("transition %s is not available for %s", transitionID, issueKey)
+}
+
 // AddAttachment uploads a file attachment
 func (client JiraClient) AddAttachment(mmClient pluginapi.Client, issueKey, fileID string, maxSize types.ByteSize) (
 	mattermostName, jiraName, mime string, err error) {
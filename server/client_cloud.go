@@ -6,6 +6,7 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"strconv"
 	"strings"
 
@@ -154,8 +155,20 @@ func (client jiraCloudClient) ListProjectStatuses(projectID string) ([]*IssueTyp
 // /rest/api/2/search/jql endpoint, since /rest/api/2/search is being removed
 // from Jira Cloud.
 func (client jiraCloudClient) SearchIssues(jql string, options *jira.SearchOptions) ([]jira.Issue, error) {
+	page, err := client.SearchIssuesPage(jql, options, "")
+	if err != nil {
+		return nil, err
+	}
+	return page.Issues, nil
+}
+
+// SearchIssuesPage pages with the nextPageToken of /rest/api/2/search/jql,
+// which ignores options.StartAt.
+func (client jiraCloudClient) SearchIssuesPage(jql string, options *jira.SearchOptions, pageToken string) (*SearchPage, error) {
 	type searchResult struct {
-		Issues []jira.Issue `json:"issues"`
+		Issues        []jira.Issue `json:"issues"`
+		NextPageToken string       `json:"nextPageToken"`
+		IsLast        bool         `json:"isLast"`
 	}
 
 	params := map[string]string{
@@ -169,11 +182,206 @@ func (client jiraCloudClient) SearchIssues(jql string, options *jira.SearchOptio
 			params["fields"] = strings.Join(options.Fields, ",")
 		}
 	}
+	if pageToken != "" {
+		params["nextPageToken"] = pageToken
+	}
 
 	var result searchResult
 	err := client.RESTGet("2/search/jql", params, &result)
 	if err != nil {
 		return nil, err
 	}
-	return result.Issues, nil
+	page := &SearchPage{Issues: result.Issues}
+	if !result.IsLast {
+		page.NextPageToken = result.NextPageToken
+	}
+	return page, nil
+}
+
+// adfComment is a comment as returned by the v3 API, where the body is an ADF document.
+type adfComment struct {
+	ID           string                  `json:"id,omitempty"`
+	Self         string                  `json:"self,omitempty"`
+	Author       jira.User               `json:"author,omitempty"`
+	Body         json.RawMessage         `json:"body,omitempty"`
+	UpdateAuthor jira.User               `json:"updateAuthor,omitempty"`
+	Updated      string                  `json:"updated,omitempty"`
+	Created      string                  `json:"created,omitempty"`
+	Visibility   *jira.CommentVisibility `json:"visibility,omitempty"`
+}
+
+// AddComment uses the v3 API for comments written in ADF, see markdownToJiraText.
+func (client jiraCloudClient) AddComment(issueKey string, comment *jira.Comment) (*jira.Comment, error) {
+	if _, ok := parseADFDocument(comment.Body); !ok {
+		return client.JiraClient.AddComment(issueKey, comment)
+	}
+	return client.sendADFComment(http.MethodPost, fmt.Sprintf("3/issue/%s/comment", issueKey), comment)
+}
+
+// UpdateComment uses the v3 API for comments written in ADF, see markdownToJiraText.
+func (client jiraCloudClient) UpdateComment(issueKey string, comment *jira.Comment) (*jira.Comment, error) {
+	if _, ok := parseADFDocument(comment.Body); !ok {
+		return client.JiraClient.UpdateComment(issueKey, comment)
+	}
+	return client.sendADFComment(http.MethodPut, fmt.Sprintf("3/issue/%s/comment/%s", issueKey, comment.ID), comment)
+}
+
+func (client jiraCloudClient) sendADFComment(method, endpoint string, comment *jira.Comment) (*jira.Comment, error) {
+	in := adfComment{
+		Body: json.RawMessage(comment.Body),
+	}
+	if comment.Visibility.Type != "" {
+		in.Visibility = &comment.Visibility
+	}
+
+	out := adfComment{}
+	if err := client.RESTSend(method, endpoint, &in, &out); err != nil {
+		return nil, err
+	}
+
+	return &jira.Comment{
+		ID:           out.ID,
+		Self:         out.Self,
+		Author:       out.Author,
+		Body:         string(out.Body),
+		UpdateAuthor: out.UpdateAuthor,
+		Updated:      out.Updated,
+		Created:      out.Created,
+		Visibility:   comment.Visibility,
+	}, nil
+}
+
+// DoTransitionWithFields uses the v3 API when the comment or the fields are
+// written in ADF, see markdownToJiraText.
+func (client jiraCloudClient) DoTransitionWithFields(issueKey, transitionID string, data map[string]interface{}) error {
+	hasADF := false
+	toADF := func(value interface{}) interface{} {
+		if text, ok := value.(string); ok {
+			if _, ok = parseADFDocument(text); ok {
+				hasADF = true
+				return json.RawMessage(text)
+			}
+		}
+		return value
+	}
+
+	payload := map[string]interface{}{
+		"transition": map[string]string{"id": transitionID},
+	}
+	if fields, ok := data["fields"].(map[string]interface{}); ok {
+		adfFields := map[string]interface{}{}
+		for id, value := range fields {
+			adfFields[id] = toADF(value)
+		}
+		payload["fields"] = adfFields
+	}
+	if update, ok := data["update"].(map[string]interface{}); ok {
+		adfUpdate := map[string]interface{}{}
+		for id, operations := range update {
+			adfUpdate[id] = operations
+			comments, ok := operations.([]map[string]interface{})
+			if !ok {
+				continue
+			}
+			var adfComments []map[string]interface{}
+			for _, comment := range comments {
+				add, ok := comment["add"].(map[string]string)
+				if !ok {
+					adfComments = append(adfComments, comment)
+					continue
+				}
+				adfComments = append(adfComments, map[string]interface{}{
+					"add": map[string]interface{}{"body": toADF(add["body"])},
+				})
+			}
+			adfUpdate[id] = adfComments
+		}
+		payload["update"] = adfUpdate
+	}
+	if !hasADF {
+		return client.JiraClient.DoTransitionWithFields(issueKey, transitionID, data)
+	}
+	return client.RESTSend(http.MethodPost, fmt.Sprintf("3/issue/%s/transitions", issueKey), payload, nil)
+}
+
+// errDescriptionNotSet is returned with the created issue when its
+// description written in ADF was rejected. The issue keeps the description in
+// wiki markup.
+var errDescriptionNotSet = errors.New("the description could not be set in rich text")
+
+// CreateIssue creates the issue with the v2 API, where the other rich-text
+// fields are plain strings, and then sets the description written in ADF with
+// the v3 API, see markdownToJiraText. The issue is created with the
+// description in wiki markup first, in case it is a required field.
+func (client jiraCloudClient) CreateIssue(issue *jira.Issue) (*jira.Issue, error) {
+	if issue.Fields == nil {
+		return client.JiraClient.CreateIssue(issue)
+	}
+	doc, ok := parseADFDocument(issue.Fields.Description)
+	if !ok {
+		return client.JiraClient.CreateIssue(issue)
+	}
+
+	adf := issue.Fields.Description
+	fields := *issue.Fields
+	fields.Description = adfToWiki(doc)
+	withWiki := *issue
+	withWiki.Fields = &fields
+	created, err := client.JiraClient.CreateIssue(&withWiki)
+	if err != nil {
+		return nil, err
+	}
+
+	err = client.UpdateIssue(created.Key, map[string]interface{}{
+		"fields": map[string]interface{}{
+			"description": json.RawMessage(adf),
+		},
+	})
+	if err != nil {
+		return created, errors.Wrapf(errDescriptionNotSet, "issue %s: %v", created.Key, err)
+	}
+	return created, nil
+}
+
+// UpdateIssue sets a description written in ADF with the v3 API, and the
+// other fields with the v2 API, where rich-text fields are plain strings.
+func (client jiraCloudClient) UpdateIssue(issueKey string, data map[string]interface{}) error {
+	fields, _ := data["fields"].(map[string]interface{})
+	var description string
+	switch value := fields["description"].(type) {
+	case string:
+		description = value
+	case json.RawMessage:
+		description = string(value)
+	}
+	if _, ok := parseADFDocument(description); !ok {
+		return client.JiraClient.UpdateIssue(issueKey, data)
+	}
+
+	rest := map[string]interface{}{}
+	for key, value := range data {
+		rest[key] = value
+	}
+	restFields := map[string]interface{}{}
+	for key, value := range fields {
+		if key != "description" {
+			restFields[key] = value
+		}
+	}
+	delete(rest, "fields")
+	if len(restFields) > 0 {
+		rest["fields"] = restFields
+	}
+	if len(rest) > 0 {
+		if err := client.JiraClient.UpdateIssue(issueKey, rest); err != nil {
+			return err
+		}
+	}
+
+	adf := map[string]interface{}{
+		"fields": map[string]interface{}{
+			"description": json.RawMessage(description),
+		},
+	}
+	return client.RESTSend(http.MethodPut, fmt.Sprintf("3/issue/%s", issueKey), adf, nil)
 }
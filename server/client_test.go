@@ -4,10 +4,16 @@
 package main
 
 import (
+	"encoding/json"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/trivago/tgo/tcontainer"
 )
 
 func TestEndpointNameFromRequest(t *testing.T) {
@@ -41,3 +47,132 @@ func TestEndpointNameFromRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestCloudClientCreateIssueWithADF(t *testing.T) {
+	var created, updated map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/rest/api/2/issue":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&created))
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id":"10001","key":"BUG-12"}`))
+		case r.Method == http.MethodPut && r.URL.Path == "/rest/api/3/issue/BUG-12":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&updated))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	jiraClient, err := jira.NewClient(server.Client(), server.URL)
+	require.NoError(t, err)
+	client := newCloudClient(jiraClient)
+
+	adf := markdownToADFString("Some **bold** text", nil)
+	issue, err := client.CreateIssue(&jira.Issue{
+		Fields: &jira.IssueFields{
+			Summary:     "Summary",
+			Description: adf,
+			Unknowns:    tcontainer.MarshalMap{"customfield_10050": "Plain text"},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "BUG-12", issue.Key)
+
+	fields := created["fields"].(map[string]interface{})
+	assert.Equal(t, "Some *bold* text", fields["description"])
+	assert.Equal(t, "Plain text", fields["customfield_10050"])
+
+	description := updated["fields"].(map[string]interface{})["description"].(map[string]interface{})
+	assert.Equal(t, "doc", description["type"])
+}
+
+func TestCloudClientCreateIssueWithRejectedADF(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/rest/api/2/issue" {
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id":"10001","key":"BUG-12"}`))
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"errorMessages":["Invalid document"]}`))
+	}))
+	defer server.Close()
+
+	jiraClient, err := jira.NewClient(server.Client(), server.URL)
+	require.NoError(t, err)
+	client := newCloudClient(jiraClient)
+
+	issue, err := client.CreateIssue(&jira.Issue{
+		Fields: &jira.IssueFields{
+			Summary:     "Summary",
+			Description: markdownToADFString("Some **bold** text", nil),
+		},
+	})
+	require.NotNil(t, issue)
+	assert.Equal(t, "BUG-12", issue.Key)
+	assert.True(t, errors.Is(err, errDescriptionNotSet))
+	assert.Contains(t, err.Error(), "BUG-12")
+}
+
+func TestCloudClientDoTransitionWithADF(t *testing.T) {
+	var transitioned map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/rest/api/3/issue/BUG-12/transitions" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&transitioned))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	jiraClient, err := jira.NewClient(server.Client(), server.URL)
+	require.NoError(t, err)
+	client := newCloudClient(jiraClient)
+
+	err = client.DoTransitionWithFields("BUG-12", "31", map[string]interface{}{
+		"fields": map[string]interface{}{
+			"resolution": map[string]string{"id": "2"},
+		},
+		"update": map[string]interface{}{
+			"comment": []map[string]interface{}{
+				{"add": map[string]string{"body": markdownToADFString("Not **reproducible**", nil)}},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{"id": "31"}, transitioned["transition"])
+	assert.Equal(t, map[string]interface{}{"id": "2"}, transitioned["fields"].(map[string]interface{})["resolution"])
+	comments := transitioned["update"].(map[string]interface{})["comment"].([]interface{})
+	body := comments[0].(map[string]interface{})["add"].(map[string]interface{})["body"].(map[string]interface{})
+	assert.Equal(t, "doc", body["type"])
+}
+
+func TestClientSearchIssuesPage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		startAt, issues := "0", `[{"key":"TEST-1"},{"key":"TEST-2"}]`
+		if r.URL.Query().Get("startAt") == "2" {
+			startAt, issues = "2", `[{"key":"TEST-3"}]`
+		}
+		_, _ = w.Write([]byte(`{"startAt":` + startAt + `,"maxResults":2,"total":3,"issues":` + issues + `}`))
+	}))
+	defer server.Close()
+
+	jiraClient, err := jira.NewClient(server.Client(), server.URL)
+	require.NoError(t, err)
+	client := newServerClient(jiraClient)
+
+	page, err := client.SearchIssuesPage("", &jira.SearchOptions{MaxResults: 2}, "")
+	require.NoError(t, err)
+	assert.Len(t, page.Issues, 2)
+	assert.Equal(t, "2", page.NextPageToken)
+
+	page, err = client.SearchIssuesPage("", &jira.SearchOptions{MaxResults: 2}, page.NextPageToken)
+	require.NoError(t, err)
+	require.Len(t, page.Issues, 1)
+	assert.Equal(t, "TEST-3", page.Issues[0].Key)
+	assert.Empty(t, page.NextPageToken)
+}
@@ -27,40 +27,65 @@ const commandTrigger = "jira"
 
 var jiraCommandHandler = CommandHandler{
 	handlers: map[string]CommandHandlerFunc{
-		"assign":                       executeAssign,
-		"connect":                      executeConnect,
-		"disconnect":                   executeDisconnect,
-		"help":                         executeHelp,
-		"me":                           executeMe,
-		"about":                        executeAbout,
-		"install/cloud":                executeInstanceInstallCloud,
-		"install/cloud-oauth":          executeInstanceInstallCloudOAuth,
-		"install/server":               executeInstanceInstallServer,
-		"instance/alias":               executeInstanceAlias,
-		"instance/unalias":             executeInstanceUnalias,
-		"instance/connect":             executeConnect,
-		"instance/disconnect":          executeDisconnect,
-		"instance/install/cloud":       executeInstanceInstallCloud,
-		"instance/install/cloud-oauth": executeInstanceInstallCloudOAuth,
-		"instance/install/server":      executeInstanceInstallServer,
-		"instance/list":                executeInstanceList,
-		"instance/settings":            executeSettings,
-		"instance/uninstall":           executeInstanceUninstall,
-		"instance/v2":                  executeInstanceV2Legacy,
-		"instance/default":             executeDefaultInstance,
-		"issue/assign":                 executeAssign,
-		"issue/transition":             executeTransition,
-		"issue/unassign":               executeUnassign,
-		"issue/view":                   executeView,
-		"settings":                     executeSettings,
-		"subscribe/list":               executeSubscribeList,
-		"transition":                   executeTransition,
-		"unassign":                     executeUnassign,
-		"uninstall":                    executeInstanceUninstall,
-		"view":                         executeView,
-		"v2revert":                     executeV2Revert,
-		"webhook":                      executeWebhookURL,
-		"setup":                        executeSetup,
+		"assign":                           executeAssign,
+		"audit":                            executeAudit,
+		"bulk":                             executeBulk,
+		"channel/config":                   executeChannelConfig,
+		"connect":                          executeConnect,
+		"disconnect":                       executeDisconnect,
+		"help":                             executeHelp,
+		"me":                               executeMe,
+		"mention/add":                      executeMentionAdd,
+		"mention/list":                     executeMentionList,
+		"mention/remove":                   executeMentionRemove,
+		"incident/add":                     executeIncidentAdd,
+		"incident/list":                    executeIncidentList,
+		"incident/remove":                  executeIncidentRemove,
+		"reminder/add":                     executeReminderAdd,
+		"reminder/list":                    executeReminderList,
+		"reminder/remove":                  executeReminderRemove,
+		"release/notes":                    executeReleaseNotes,
+		"about":                            executeAbout,
+		"admin/fsck":                       executeAdminFsck,
+		"install/cloud":                    executeInstanceInstallCloud,
+		"install/cloud-oauth":              executeInstanceInstallCloudOAuth,
+		"install/server":                   executeInstanceInstallServer,
+		"instance/alias":                   executeInstanceAlias,
+		"instance/unalias":                 executeInstanceUnalias,
+		"instance/connect":                 executeConnect,
+		"instance/disconnect":              executeDisconnect,
+		"instance/install/cloud":           executeInstanceInstallCloud,
+		"instance/install/cloud-oauth":     executeInstanceInstallCloudOAuth,
+		"instance/install/server":          executeInstanceInstallServer,
+		"instance/list":                    executeInstanceList,
+		"instance/settings":                executeSettings,
+		"instance/uninstall":               executeInstanceUninstall,
+		"instance/restore":                 executeInstanceRestore,
+		"instance/migrate":                 executeInstanceMigrate,
+		"instance/bot":                     executeInstanceBot,
+		"instance/v2":                      executeInstanceV2Legacy,
+		"instance/default":                 executeDefaultInstance,
+		"issue/assign":                     executeAssign,
+		"issue/edit":                       executeIssueEdit,
+		"issue/transition":                 executeTransition,
+		"issue/unassign":                   executeUnassign,
+		"issue/view":                       executeView,
+		"settings":                         executeSettings,
+		"subscribe/list":                   executeSubscribeList,
+		"subscribe/instance/add":           executeSubscribeInstanceAdd,
+		"subscribe/instance/list":          executeSubscribeInstanceList,
+		"subscribe/instance/remove":        executeSubscribeInstanceRemove,
+		"subscribe/permissions/delegate":   executeSubscribePermissionsDelegate,
+		"subscribe/permissions/list":       executeSubscribePermissionsList,
+		"subscribe/permissions/team":       executeSubscribePermissionsTeam,
+		"subscribe/permissions/undelegate": executeSubscribePermissionsUndelegate,
+		"transition":                       executeTransition,
+		"unassign":                         executeUnassign,
+		"uninstall":                        executeInstanceUninstall,
+		"view":                             executeView,
+		"v2revert":                         executeV2Revert,
+		"webhook":                          executeWebhookURL,
+		"setup":                            executeSetup,
 	},
 	defaultHandler: executeJiraDefault,
 }
@@ -71,14 +96,22 @@ const commonHelpText = "\n" +
 	"* `/jira connect [jiraURL]` - Connect your Mattermost account to your Jira account\n" +
 	"* `/jira disconnect [jiraURL]` - Disconnect your Mattermost account from your Jira account\n" +
 	"* `/jira [issue] assign [issue-key] [assignee]` - Change the assignee of a Jira issue\n" +
+	"* `/jira bulk assign|transition|priority|comment [value] --jql \"[query]\"` - Change all the Jira issues matching a JQL query, after a confirmation\n" +
+	"* `/jira bulk label add|remove [label] --jql \"[query]\"` - Add or remove a label on all the Jira issues matching a JQL query\n" +
 	"* `/jira [issue] create [text]` - Create a new Issue with 'text' inserted into the description field\n" +
+	"* `/jira issue edit [issue-key]` - Edit the fields of a Jira issue, like its priority, labels or due date, in a dialog\n" +
 	"* `/jira [issue] transition [issue-key] [state]` - Change the state of a Jira issue\n" +
 	"* `/jira [issue] unassign [issue-key]` - Unassign the Jira issue\n" +
-	"* `/jira [issue] view [issue-key]` - View the details of a specific Jira issue\n" +
+	"* `/jira [issue] view [issue-key]` - View the details of a specific Jira issue. In a thread an issue was created from, the issue key can be omitted\n" +
 	"* `/jira help` - Launch the Jira plugin command line help syntax\n" +
 	"* `/jira me` - Display information about the current user\n" +
 	"* `/jira about` - Display build info\n" +
 	"* `/jira instance list` - List installed Jira instances\n" +
+	"* `/jira release notes [project-key] [version] [--export]` - Post the release notes of a version, grouped by issue type; `--export` attaches them as a markdown file\n" +
+	"* `/jira subscribe permissions delegate|undelegate [@username]` - Let a user manage the subscriptions of this channel, if you can manage them\n" +
+	"* `/jira reminder add [days] [status] [project=[key]]` - Remind the assignees by direct message when issues in the status have not been updated for days. The project must be the default project of the channel, which it defaults to, or one the channel is subscribed to, e.g. `/jira reminder add 3 In Review`\n" +
+	"* `/jira reminder list|remove [status]` - List or remove the reminder rules of this channel\n" +
+	"* `/jira channel config [project=<key>] [issuetype=<name>] [labels=<a,b>] [components=<a,b>]` - Show or set the default Jira instance and project of this channel; `clear` removes them\n" +
 	"* `/jira instance settings [setting] [role] [value]` - Update your user settings\n" +
 	"  * [setting] can be `notifications`\n" +
 	"  * [role] can be `assignee` , `mention` , `reporter` or `watching`\n" +
@@ -91,13 +124,32 @@ const sysAdminHelpText = "\n###### For System Administrators:\n" +
 	"* `/jira webhook [jiraURL]` - Display the webhook URLs to setup on Jira\n" +
 	"Install Jira instances:\n" +
 	"* `/jira instance install server [jiraURL]` - Connect Mattermost to a Jira Server or Data Center instance located at <jiraURL>\n" +
+	"* `/jira instance install server [jiraURL] --pat` - Connect Mattermost to a Jira Data Center instance located at <jiraURL>, with users connecting through personal access tokens\n" +
 	"* `/jira instance install cloud-oauth [jiraURL]` - Connect Mattermost to a Jira Cloud instance using OAuth 2.0 located at <jiraURL>\n" +
 	"Uninstall Jira instances:\n" +
 	"* `/jira instance uninstall server [jiraURL]` - Disconnect Mattermost from a Jira Server or Data Center instance located at <jiraURL>\n" +
 	"* `/jira instance uninstall cloud-oauth [jiraURL]` - Disconnect Mattermost from a Jira Cloud instance using OAuth 2.0 located at <jiraURL>\n" +
+	"* `/jira instance uninstall [server|cloud-oauth] [jiraURL] --purge` - Uninstall the instance and delete all its data, instead of keeping and archiving its subscriptions, templates and settings\n" +
+	"* `/jira instance restore [jiraURL]` - Restore the archived subscriptions, templates and settings of a reinstalled instance\n" +
+	"* `/jira instance bot [jiraURL] [enable|disable]` - Post the notifications and responses of an instance as a dedicated bot, named after its alias, instead of the Jira bot\n" +
+	"* `/jira instance bot [jiraURL] name|icon [value]` - Change the display name of the dedicated bot, or its icon to the image at a URL\n" +
+	"* `/jira instance migrate [oldURL] [newURL] [--dry-run]` - Move an instance, its connections, subscriptions and settings to a new URL after a Jira domain change\n" +
 	"Manage channel subscriptions:\n" +
 	"* `/jira subscribe ` - Configure the Jira notifications sent to this channel\n" +
 	"* `/jira subscribe list` - Display all the the subscription rules setup across all the channels and teams on your Mattermost instance\n" +
+	"* `/jira subscribe instance add [name] [events]` - Post project, issue type, workflow and user events of the Jira instance to this channel; events can be `project`, `issuetype`, `workflow`, `user`, `all` or single events like `project_created`\n" +
+	"* `/jira subscribe instance list|remove [name]` - List or remove the instance subscriptions of this channel\n" +
+	"* `/jira subscribe permissions team [roles=<role>] [projects=<KEY1,KEY2>]` - Override the roles allowed to manage the subscriptions of this team, and restrict the projects it can subscribe to\n" +
+	"* `/jira subscribe permissions list` - List the subscription permissions of the teams and channels\n" +
+	"* `/jira mention add [field] [value] [@group-or-user]` - Mention a Mattermost group or user in the subscription posts of issues where the field has the value, e.g. `/jira mention add team Payments @payments-oncall`\n" +
+	"* `/jira mention list|remove [field] [value]` - List or remove the mentions mapped to field values\n" +
+	"* `/jira incident add [name] project=[key] [priority=[name]] [type=[issue type]] [label=[label]] [event=created|updated|any] [channel=private|public]` - Create a channel in this team for each matching issue, e.g. `/jira incident add sev1 project=OPS priority=Highest`\n" +
+	"* `/jira incident list|remove [name]` - List or remove the incident channel rules\n" +
+	"Audit log:\n" +
+	"* `/jira audit [days=<n>] [action=<action>] [actor=<@username>] [instance=<jiraURL>] [target=<text>]` - List the recent administrative and user actions, like installs, subscription changes or issue transitions\n" +
+	"* `/jira audit format=csv|json [filters]` - Export the matching actions as a file sent to you by the Jira bot\n" +
+	"Troubleshooting:\n" +
+	"* `/jira admin fsck [--fix]` - Check the stored instances, users, connections and subscriptions for inconsistencies; `--fix` repairs them. A JSON report is sent to you by the Jira bot\n" +
 	"Other:\n" +
 	"* `/jira instance alias [URL] [alias-name]` - assign an alias to an instance\n" +
 	"* `/jira instance unalias [alias-name]` - remve an alias from an instance\n" +
@@ -155,6 +207,7 @@ func addSubCommands(jira *model.AutocompleteData, optInstance bool) {
 	jira.AddCommand(createTransitionCommand(optInstance))
 	jira.AddCommand(createAssignCommand(optInstance))
 	jira.AddCommand(createUnassignCommand(optInstance))
+	jira.AddCommand(createBulkCommand(optInstance))
 	jira.AddCommand(createConnectCommand())
 	jira.AddCommand(createDisconnectCommand())
 	jira.AddCommand(createSettingsCommand(optInstance))
@@ -162,9 +215,16 @@ func addSubCommands(jira *model.AutocompleteData, optInstance bool) {
 	// Generic commands
 	jira.AddCommand(createIssueCommand(optInstance))
 	jira.AddCommand(createInstanceCommand(optInstance))
+	jira.AddCommand(createReleaseCommand(optInstance))
 
 	// Admin commands
+	jira.AddCommand(createChannelCommand(optInstance))
 	jira.AddCommand(createSubscribeCommand(optInstance))
+	jira.AddCommand(createMentionCommand(optInstance))
+	jira.AddCommand(createIncidentCommand(optInstance))
+	jira.AddCommand(createReminderCommand(optInstance))
+	jira.AddCommand(createAuditCommand())
+	jira.AddCommand(createAdminCommand())
 	jira.AddCommand(createWebhookCommand(optInstance))
 	jira.AddCommand(createSetupCommand())
 
@@ -199,8 +259,36 @@ func createInstanceCommand(optInstance bool) *model.AutocompleteData {
 		"uninstall", "[server|cloud-oauth] [URL]", "Disconnect Mattermost from a Jira instance")
 	uninstall.AddStaticListArgument("Jira type: server, cloud or cloud-oauth", true, jiraTypes)
 	uninstall.AddDynamicListArgument("Jira instance", makeAutocompleteRoute(routeAutocompleteInstalledInstanceWithAlias), true)
+	uninstall.AddStaticListArgument("Delete all the data of the instance instead of archiving it", false, []model.AutocompleteListItem{
+		{HelpText: "Delete all the data of the instance", Item: "--purge"},
+	})
 	uninstall.RoleID = model.SystemAdminRoleId
 
+	restore := model.NewAutocompleteData(
+		"restore", "[URL]", "Restore the archived subscriptions, templates and settings of a reinstalled Jira instance")
+	restore.AddDynamicListArgument("Jira instance", makeAutocompleteRoute(routeAutocompleteInstalledInstanceWithAlias), true)
+	restore.RoleID = model.SystemAdminRoleId
+
+	migrate := model.NewAutocompleteData(
+		"migrate", "[old URL] [new URL]", "Move a Jira instance and its data to a new URL after a Jira domain change")
+	migrate.AddDynamicListArgument("Jira instance", makeAutocompleteRoute(routeAutocompleteInstalledInstanceWithAlias), true)
+	migrate.AddTextArgument("New Jira URL", "Enter the new Jira URL, e.g. https://mattermost.atlassian.net", "")
+	migrate.AddStaticListArgument("Only report what would be migrated", false, []model.AutocompleteListItem{
+		{HelpText: "Report what would be migrated without changing anything", Item: "--dry-run"},
+	})
+	migrate.RoleID = model.SystemAdminRoleId
+
+	bot := model.NewAutocompleteData(
+		"bot", "[URL] [enable|disable|name|icon]", "Post as a dedicated bot for the Jira instance")
+	bot.AddDynamicListArgument("Jira instance", makeAutocompleteRoute(routeAutocompleteInstalledInstanceWithAlias), true)
+	bot.AddStaticListArgument("Action", false, []model.AutocompleteListItem{
+		{HelpText: "Create or reactivate the bot of the instance", Item: "enable"},
+		{HelpText: "Post as the Jira bot again", Item: "disable"},
+		{HelpText: "Change the display name of the bot", Item: "name"},
+		{HelpText: "Change the icon of the bot to the image at a URL", Item: "icon"},
+	})
+	bot.RoleID = model.SystemAdminRoleId
+
 	list := model.NewAutocompleteData(
 		"list", "", "List installed Jira instances")
 	list.RoleID = model.SystemAdminRoleId
@@ -211,20 +299,54 @@ func createInstanceCommand(optInstance bool) *model.AutocompleteData {
 	instance.AddCommand(createSettingsCommand(optInstance))
 	instance.AddCommand(install)
 	instance.AddCommand(uninstall)
+	instance.AddCommand(restore)
+	instance.AddCommand(migrate)
+	instance.AddCommand(bot)
 	return instance
 }
 
+func createChannelCommand(optInstance bool) *model.AutocompleteData {
+	channel := model.NewAutocompleteData(
+		"channel", "[config]", "Manage the Jira defaults of this channel")
+	config := model.NewAutocompleteData(
+		"config", "[project=<key>] [issuetype=<name>] [labels=<a,b>] [components=<a,b>] | clear", "Show or set the default Jira instance and project of this channel")
+	config.AddTextArgument("Settings, or `clear`", "[project=<key>] [issuetype=<name>] [labels=<a,b>] [components=<a,b>]", "")
+	withFlagInstance(config, optInstance, makeAutocompleteRoute(routeAutocompleteInstalledInstanceWithAlias))
+	channel.AddCommand(config)
+	return channel
+}
+
+func createReleaseCommand(optInstance bool) *model.AutocompleteData {
+	release := model.NewAutocompleteData(
+		"release", "[notes]", "Work with Jira releases")
+	notes := model.NewAutocompleteData(
+		"notes", "[project-key] [version] [--export]", "Post the release notes of a version, grouped by issue type")
+	notes.AddTextArgument("Project key and version, add `--export` to attach a markdown file", "[project-key] [version] [--export]", "")
+	withFlagInstance(notes, optInstance, makeAutocompleteRoute(routeAutocompleteInstalledInstanceWithAlias))
+	release.AddCommand(notes)
+	return release
+}
+
 func createIssueCommand(optInstance bool) *model.AutocompleteData {
 	issue := model.NewAutocompleteData(
-		"issue", "[view|assign|transition|create]", "View and manage Jira issues")
+		"issue", "[view|assign|transition|edit|create]", "View and manage Jira issues")
 	issue.AddCommand(createViewCommand(optInstance))
 	issue.AddCommand(createTransitionCommand(optInstance))
 	issue.AddCommand(createAssignCommand(optInstance))
 	issue.AddCommand(createUnassignCommand(optInstance))
+	issue.AddCommand(createEditIssueCommand(optInstance))
 	issue.AddCommand(createCreateIssueCommand(optInstance))
 	return issue
 }
 
+func createEditIssueCommand(optInstance bool) *model.AutocompleteData {
+	edit := model.NewAutocompleteData(
+		"edit", "[Jira issue]", "Edit the fields of a Jira issue")
+	withParamIssueKey(edit)
+	withFlagInstance(edit, optInstance, makeAutocompleteRoute(routeAutocompleteInstalledInstanceWithAlias))
+	return edit
+}
+
 func createCreateIssueCommand(optInstance bool) *model.AutocompleteData {
 	create := model.NewAutocompleteData(
 		"create", "[description]", "Create a new Jira issue and optionally prefill its description")
@@ -357,6 +479,21 @@ func createUnassignCommand(optInstance bool) *model.AutocompleteData {
 	return unassign
 }
 
+func createBulkCommand(optInstance bool) *model.AutocompleteData {
+	bulk := model.NewAutocompleteData(
+		"bulk", "[action] [value] --jql \"[query]\"", "Change all the Jira issues matching a JQL query")
+	bulk.AddStaticListArgument("Action", true, []model.AutocompleteListItem{
+		{HelpText: "Assign the issues", Item: "assign"},
+		{HelpText: "Transition the issues", Item: "transition"},
+		{HelpText: "Add or remove a label", Item: "label"},
+		{HelpText: "Set the priority of the issues", Item: "priority"},
+		{HelpText: "Comment on the issues", Item: "comment"},
+	})
+	bulk.AddTextArgument("Value and JQL query", "[value] --jql \"[query]\"", "")
+	withFlagInstance(bulk, optInstance, makeAutocompleteRoute(routeAutocompleteInstalledInstanceWithAlias))
+	return bulk
+}
+
 func createSubscribeCommand(optInstance bool) *model.AutocompleteData {
 	subscribe := model.NewAutocompleteData(
 		"subscribe", "[edit|list]", "List or configure the Jira notifications sent to this channel")
@@ -367,9 +504,164 @@ func createSubscribeCommand(optInstance bool) *model.AutocompleteData {
 		"list", "", "List the Jira notifications sent to this channel")
 	withFlagInstance(list, optInstance, makeAutocompleteRoute(routeAutocompleteInstalledInstanceWithAlias))
 	subscribe.AddCommand(list)
+	subscribe.AddCommand(createSubscribeInstanceCommand(optInstance))
+	subscribe.AddCommand(createSubscribePermissionsCommand(optInstance))
 	return subscribe
 }
 
+func createSubscribePermissionsCommand(optInstance bool) *model.AutocompleteData {
+	permissions := model.NewAutocompleteData(
+		"permissions", "[list|team|delegate|undelegate]", "Manage who can subscribe this team and channel to Jira, and to which projects")
+
+	list := model.NewAutocompleteData(
+		"list", "", "List the subscription permissions of the teams and channels")
+	list.RoleID = model.SystemAdminRoleId
+	withFlagInstance(list, optInstance, makeAutocompleteRoute(routeAutocompleteInstalledInstanceWithAlias))
+	permissions.AddCommand(list)
+
+	team := model.NewAutocompleteData(
+		"team", "[roles=<role>] [projects=<keys>]", "Set the roles allowed to manage the subscriptions of this team, and the projects they can subscribe to")
+	team.RoleID = model.SystemAdminRoleId
+	team.AddTextArgument("roles=users|channel_admin|team_admin|system_admin|default and projects=KEY1,KEY2|all", "[roles=<role>] [projects=<keys>]", "")
+	withFlagInstance(team, optInstance, makeAutocompleteRoute(routeAutocompleteInstalledInstanceWithAlias))
+	permissions.AddCommand(team)
+
+	delegate := model.NewAutocompleteData(
+		"delegate", "[@username]", "Let a user manage the subscriptions of this channel")
+	delegate.AddTextArgument("Mattermost user", "[@username]", "")
+	withFlagInstance(delegate, optInstance, makeAutocompleteRoute(routeAutocompleteInstalledInstanceWithAlias))
+	permissions.AddCommand(delegate)
+
+	undelegate := model.NewAutocompleteData(
+		"undelegate", "[@username]", "Stop delegating the subscriptions of this channel to a user")
+	undelegate.AddTextArgument("Mattermost user", "[@username]", "")
+	withFlagInstance(undelegate, optInstance, makeAutocompleteRoute(routeAutocompleteInstalledInstanceWithAlias))
+	permissions.AddCommand(undelegate)
+
+	return permissions
+}
+
+func createSubscribeInstanceCommand(optInstance bool) *model.AutocompleteData {
+	instance := model.NewAutocompleteData(
+		"instance", "[add|list|remove]", "Manage the project, issue type, workflow and user events of the Jira instance sent to this channel")
+
+	add := model.NewAutocompleteData(
+		"add", "[name] [events]", "Subscribe this channel to events of the Jira instance")
+	add.AddTextArgument("Name of the subscription, followed by events: project, issuetype, workflow, user, all or single events like project_created", "[name] [events]", "")
+	withFlagInstance(add, optInstance, makeAutocompleteRoute(routeAutocompleteInstalledInstanceWithAlias))
+	instance.AddCommand(add)
+
+	list := model.NewAutocompleteData(
+		"list", "", "List the instance subscriptions of this channel")
+	withFlagInstance(list, optInstance, makeAutocompleteRoute(routeAutocompleteInstalledInstanceWithAlias))
+	instance.AddCommand(list)
+
+	remove := model.NewAutocompleteData(
+		"remove", "[name]", "Remove an instance subscription of this channel")
+	remove.AddTextArgument("Name of the subscription", "[name]", "")
+	withFlagInstance(remove, optInstance, makeAutocompleteRoute(routeAutocompleteInstalledInstanceWithAlias))
+	instance.AddCommand(remove)
+
+	return instance
+}
+
+func createMentionCommand(optInstance bool) *model.AutocompleteData {
+	mention := model.NewAutocompleteData(
+		"mention", "[add|list|remove]", "Mention Mattermost groups or users in the subscription posts of issues with a field value")
+	mention.RoleID = model.SystemAdminRoleId
+
+	add := model.NewAutocompleteData(
+		"add", "[field] [value] [@group-or-user]", "Mention a Mattermost group or user when a field of an issue has a value")
+	add.AddTextArgument("Field key like team, components or customfield_10050, followed by the value and the group or user to mention", "[field] [value] [@group-or-user]", "")
+	withFlagInstance(add, optInstance, makeAutocompleteRoute(routeAutocompleteInstalledInstanceWithAlias))
+	mention.AddCommand(add)
+
+	list := model.NewAutocompleteData(
+		"list", "", "List the mentions mapped to field values")
+	withFlagInstance(list, optInstance, makeAutocompleteRoute(routeAutocompleteInstalledInstanceWithAlias))
+	mention.AddCommand(list)
+
+	remove := model.NewAutocompleteData(
+		"remove", "[field] [value]", "Remove the mention mapped to a field value")
+	remove.AddTextArgument("Field key, followed by the value", "[field] [value]", "")
+	withFlagInstance(remove, optInstance, makeAutocompleteRoute(routeAutocompleteInstalledInstanceWithAlias))
+	mention.AddCommand(remove)
+
+	return mention
+}
+
+func createIncidentCommand(optInstance bool) *model.AutocompleteData {
+	incident := model.NewAutocompleteData(
+		"incident", "[add|list|remove]", "Create a channel for each issue that matches a rule")
+	incident.RoleID = model.SystemAdminRoleId
+
+	add := model.NewAutocompleteData(
+		"add", "[name] project=[key]", "Create a channel in this team for each issue that matches the filters")
+	add.AddTextArgument("Rule name, followed by project=, priority=, type=, label= and event=created|updated|any filters, and channel=private|public", "[name] project=[key] [priority=[name]]", "")
+	withFlagInstance(add, optInstance, makeAutocompleteRoute(routeAutocompleteInstalledInstanceWithAlias))
+	incident.AddCommand(add)
+
+	list := model.NewAutocompleteData(
+		"list", "", "List the incident channel rules")
+	withFlagInstance(list, optInstance, makeAutocompleteRoute(routeAutocompleteInstalledInstanceWithAlias))
+	incident.AddCommand(list)
+
+	remove := model.NewAutocompleteData(
+		"remove", "[name]", "Remove an incident channel rule")
+	remove.AddTextArgument("Rule name", "[name]", "")
+	withFlagInstance(remove, optInstance, makeAutocompleteRoute(routeAutocompleteInstalledInstanceWithAlias))
+	incident.AddCommand(remove)
+
+	return incident
+}
+
+func createReminderCommand(optInstance bool) *model.AutocompleteData {
+	reminder := model.NewAutocompleteData(
+		"reminder", "[add|list|remove]", "Remind the assignees of issues that have been in a status for days")
+
+	add := model.NewAutocompleteData(
+		"add", "[days] [status]", "Remind the assignees by direct message when issues in the status have not been updated for days")
+	add.AddTextArgument("Number of days, followed by the status and optionally project=[key]", "[days] [status] [project=[key]]", "")
+	withFlagInstance(add, optInstance, makeAutocompleteRoute(routeAutocompleteInstalledInstanceWithAlias))
+	reminder.AddCommand(add)
+
+	list := model.NewAutocompleteData(
+		"list", "", "List the reminder rules of this channel")
+	withFlagInstance(list, optInstance, makeAutocompleteRoute(routeAutocompleteInstalledInstanceWithAlias))
+	reminder.AddCommand(list)
+
+	remove := model.NewAutocompleteData(
+		"remove", "[status]", "Remove the reminder rule of this channel for a status")
+	remove.AddTextArgument("Status", "[status]", "")
+	withFlagInstance(remove, optInstance, makeAutocompleteRoute(routeAutocompleteInstalledInstanceWithAlias))
+	reminder.AddCommand(remove)
+
+	return reminder
+}
+
+func createAuditCommand() *model.AutocompleteData {
+	audit := model.NewAutocompleteData(
+		"audit", "[filters]", "List or export the audit log of administrative and user actions")
+	audit.RoleID = model.SystemAdminRoleId
+	audit.AddTextArgument("Filters like days=30, action=subscription, actor=@username, instance=<jiraURL>, target=<text> or format=csv|json", "[filters]", "")
+	return audit
+}
+
+func createAdminCommand() *model.AutocompleteData {
+	admin := model.NewAutocompleteData(
+		"admin", "[command]", "Available commands: fsck")
+	admin.RoleID = model.SystemAdminRoleId
+
+	fsck := model.NewAutocompleteData(
+		"fsck", "[--fix]", "Check the stored data for inconsistencies, and repair them with --fix")
+	fsck.RoleID = model.SystemAdminRoleId
+	fsck.AddStaticListArgument("Repair the inconsistencies", false, []model.AutocompleteListItem{
+		{HelpText: "Repair the inconsistencies that were found", Item: "--fix"},
+	})
+	admin.AddCommand(fsck)
+	return admin
+}
+
 func createWebhookCommand(optInstance bool) *model.AutocompleteData {
 	webhook := model.NewAutocompleteData(
 		"webhook", "[Jira URL]", "Display the webhook URLs to set up on Jira")
@@ -610,6 +902,7 @@ func executeInstanceAlias(p *Plugin, c *plugin.Context, header *model.CommandArg
 		return p.responsef(header, "Failed to save instance. Error: %v.", err)
 	}
 
+	p.audit(header.UserId, AuditInstanceAlias, instanceID, instanceID.String(), alias)
 	return p.responsef(header, "You have successfully aliased instance %v to `%v`.", instanceID, alias)
 }
 
@@ -659,6 +952,7 @@ func executeInstanceUnalias(p *Plugin, c *plugin.Context, header *model.CommandA
 		return p.responsef(header, "Failed to save instance. Error: %v.", err)
 	}
 
+	p.audit(header.UserId, AuditInstanceUnalias, idFound, idFound.String(), alias)
 	return p.responsef(header, "You have successfully unaliased instance %v from `%v`.", idFound, alias)
 }
 
@@ -697,6 +991,14 @@ func executeJiraDefault(p *Plugin, c *plugin.Context, header *model.CommandArgs,
 
 // executeView returns a Jira issue formatted as a slack attachment, or an error message.
 func executeView(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	// In a thread an issue was created from, the issue is viewed by default.
+	if len(args) == 0 && header.RootId != "" {
+		threadIssue, err := p.loadThreadIssue(header.RootId)
+		if err == nil && threadIssue != nil {
+			args = []string{"--instance", threadIssue.InstanceID.String(), threadIssue.IssueKey}
+		}
+	}
+
 	user, instance, args, err := p.loadFlagUserInstance(header.UserId, args)
 	if err != nil {
 		return p.responsef(header, "Failed to load your connection to Jira. Error: %v.", err)
@@ -719,7 +1021,7 @@ func executeView(p *Plugin, c *plugin.Context, header *model.CommandArgs, args .
 	}
 
 	post := &model.Post{
-		UserId:    p.getUserID(),
+		UserId:    p.getBotUserID(instance.GetID()),
 		ChannelId: header.ChannelId,
 		RootId:    header.RootId,
 	}
@@ -849,7 +1151,12 @@ func executeSubscribeList(p *Plugin, c *plugin.Context, header *model.CommandArg
 		return p.responsef(header, "%v", err)
 	}
 
-	return p.response(header, msg)
+	instanceMsg, err := p.listInstanceSubscriptions(instance.GetID(), header.TeamId)
+	if err != nil {
+		return p.responsef(header, "%v", err)
+	}
+
+	return p.response(header, msg+instanceMsg)
 }
 
 func authorizedSysAdmin(p *Plugin, userID string) (bool, error) {
@@ -879,6 +1186,7 @@ func executeInstanceInstallCloud(p *Plugin, c *plugin.Context, header *model.Com
 	if err != nil {
 		return p.response(header, err.Error())
 	}
+	p.audit(header.UserId, AuditInstanceInstall, types.ID(jiraURL), jiraURL, string(CloudInstanceType))
 
 	return p.respondCommandTemplate(header, "/command/install_cloud.md", map[string]string{
 		"JiraURL":                 jiraURL,
@@ -903,6 +1211,7 @@ func executeInstanceInstallCloudOAuth(p *Plugin, c *plugin.Context, header *mode
 	if err != nil {
 		return p.response(header, err.Error())
 	}
+	p.audit(header.UserId, AuditInstanceInstall, types.ID(jiraURL), jiraURL, string(CloudOAuthInstanceType))
 
 	state := flow.State{
 		keyEdition:          string(CloudOAuthInstanceType),
@@ -935,13 +1244,25 @@ func executeInstanceInstallServer(p *Plugin, c *plugin.Context, header *model.Co
 	if !authorized {
 		return p.responsef(header, "`/jira install` can only be run by a system administrator.")
 	}
+	authMode := ""
+	if len(args) == 2 && args[1] == "--pat" {
+		authMode = serverAuthModePAT
+		args = args[:1]
+	}
 	if len(args) != 1 {
 		return p.help(header)
 	}
-	jiraURL, instance, err := p.installServerInstance(args[0])
+	jiraURL, instance, err := p.installServerInstance(args[0], authMode)
 	if err != nil {
 		return p.response(header, err.Error())
 	}
+	p.audit(header.UserId, AuditInstanceInstall, types.ID(jiraURL), jiraURL, string(ServerInstanceType))
+	if instance.usesPAT() {
+		return p.respondCommandTemplate(header, "/command/install_server_pat.md", map[string]string{
+			"JiraURL":           jiraURL,
+			"ManageWebhooksURL": instance.GetManageWebhooksURL(),
+		})
+	}
 	pkey, err := p.publicKeyString()
 	if err != nil {
 		return p.responsef(header, "Failed to load public key: %v", err)
@@ -965,6 +1286,11 @@ func executeInstanceUninstall(p *Plugin, c *plugin.Context, header *model.Comman
 	if !authorized {
 		return p.responsef(header, "`/jira uninstall` can only be run by a System Administrator.")
 	}
+	purge := false
+	if len(args) == 3 && args[2] == "--purge" {
+		purge = true
+		args = args[:2]
+	}
 	if len(args) != 2 {
 		return p.help(header)
 	}
@@ -976,16 +1302,28 @@ func executeInstanceUninstall(p *Plugin, c *plugin.Context, header *model.Comman
 	if err != nil {
 		return p.response(header, err.Error())
 	}
-	uninstalled, err := p.UninstallInstance(types.ID(id), instanceType)
+	uninstalled, err := p.UninstallInstance(types.ID(id), instanceType, purge)
 	if err != nil {
 		return p.response(header, err.Error())
 	}
+	details := string(instanceType)
+	if purge {
+		details += ", purged"
+	}
+	p.audit(header.UserId, AuditInstanceUninstall, uninstalled.GetID(), uninstalled.GetURL(), details)
 
 	uninstallInstructions := `` +
 		`Jira instance successfully uninstalled. Navigate to [**your app management URL**](%s) in order to remove the application from your Jira instance.
 Don't forget to remove Jira-side webhook in [Jira System Settings/Webhooks](%s)'
 `
-	return p.responsef(header, uninstallInstructions, uninstalled.GetManageAppsURL(), uninstalled.GetManageWebhooksURL())
+	msg := fmt.Sprintf(uninstallInstructions, uninstalled.GetManageAppsURL(), uninstalled.GetManageWebhooksURL())
+	if purge {
+		msg += "All the data of the instance was deleted."
+	} else {
+		msg += fmt.Sprintf("The subscriptions, templates and settings of the instance were archived for %d days. To bring them back, run `/jira instance restore %s` after installing it again.",
+			int(instanceArchiveRetention.Hours()/24), uninstalled.GetURL())
+	}
+	return p.response(header, msg)
 }
 
 func executeUnassign(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
@@ -1054,6 +1392,7 @@ func executeTransition(p *Plugin, c *plugin.Context, header *model.CommandArgs,
 	msg, err := p.TransitionIssue(&InTransitionIssue{
 		InstanceID:       instanceID,
 		mattermostUserID: mattermostUserID,
+		triggerID:        header.TriggerId,
 		IssueKey:         issueKey,
 		ToState:          toState,
 	})
@@ -1249,8 +1588,14 @@ func executeSetup(p *Plugin, c *plugin.Context, header *model.CommandArgs, args
 }
 
 func (p *Plugin) postCommandResponse(args *model.CommandArgs, text string) {
+	botUserID := p.getUserID()
+	if fields := strings.Fields(args.Command); len(fields) > 0 {
+		if commandBotUserID := p.commandBotUserID(args.UserId, fields[1:]); commandBotUserID != "" {
+			botUserID = commandBotUserID
+		}
+	}
 	post := &model.Post{
-		UserId:    p.getUserID(),
+		UserId:    botUserID,
 		ChannelId: args.ChannelId,
 		RootId:    args.RootId,
 		Message:   text,
@@ -27,6 +27,32 @@ const (
 	eventUpdatedAffectsVersion = "event_updated_affects_version"
 	eventUpdatedReporter       = "event_updated_reporter"
 	eventUpdatedComponents     = "event_updated_components"
+	eventSLABreached           = "event_sla_breached"
+	eventSprintStarted         = "event_sprint_started"
+	eventSprintClosed          = "event_sprint_closed"
+	eventSprintUpdated         = "event_sprint_updated"
+	eventBoardCreated          = "event_board_created"
+	eventBoardUpdated          = "event_board_updated"
+	eventBoardDeleted          = "event_board_deleted"
+	eventVersionCreated        = "event_version_created"
+	eventVersionUpdated        = "event_version_updated"
+	eventVersionReleased       = "event_version_released"
+	eventVersionUnreleased     = "event_version_unreleased"
+	eventVersionDeleted        = "event_version_deleted"
+	eventProjectCreated        = "event_project_created"
+	eventProjectUpdated        = "event_project_updated"
+	eventProjectDeleted        = "event_project_deleted"
+	eventProjectArchived       = "event_project_archived"
+	eventProjectRestored       = "event_project_restored"
+	eventIssueTypeCreated      = "event_issue_type_created"
+	eventIssueTypeUpdated      = "event_issue_type_updated"
+	eventIssueTypeDeleted      = "event_issue_type_deleted"
+	eventWorkflowCreated       = "event_workflow_created"
+	eventWorkflowUpdated       = "event_workflow_updated"
+	eventWorkflowDeleted       = "event_workflow_deleted"
+	eventUserCreated           = "event_user_created"
+	eventUserUpdated           = "event_user_updated"
+	eventUserDeleted           = "event_user_deleted"
 )
 
 var legacyEvents = NewStringSet(
@@ -66,3 +92,22 @@ var allEvents = NewStringSet(
 	eventUpdatedIssuetype,
 	eventUpdatedFixVersion,
 )
+
+// instanceEvents are the events of instance subscriptions, which are not
+// scoped to a project.
+var instanceEvents = NewStringSet(
+	eventProjectCreated,
+	eventProjectUpdated,
+	eventProjectDeleted,
+	eventProjectArchived,
+	eventProjectRestored,
+	eventIssueTypeCreated,
+	eventIssueTypeUpdated,
+	eventIssueTypeDeleted,
+	eventWorkflowCreated,
+	eventWorkflowUpdated,
+	eventWorkflowDeleted,
+	eventUserCreated,
+	eventUserUpdated,
+	eventUserDeleted,
+)
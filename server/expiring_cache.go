@@ -0,0 +1,85 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+const expiringCacheMaxEntries = 10000
+
+// expiringCache keeps values read from the KV store or the server in memory
+// for a while, to save a read on hot paths. Another server of the cluster can change the
+// values, so they are only trusted until they expire. A nil cache caches
+// nothing.
+type expiringCache[V any] struct {
+	ttl     time.Duration
+	lock    sync.Mutex
+	entries map[string]expiringCacheEntry[V]
+}
+
+type expiringCacheEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+func newExpiringCache[V any](ttl time.Duration) *expiringCache[V] {
+	return &expiringCache[V]{
+		ttl:     ttl,
+		entries: map[string]expiringCacheEntry[V]{},
+	}
+}
+
+func (c *expiringCache[V]) get(key string) (V, bool) {
+	var zero V
+	if c == nil {
+		return zero, false
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		return zero, false
+	}
+	return entry.value, true
+}
+
+func (c *expiringCache[V]) set(key string, value V) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := time.Now()
+	if len(c.entries) >= expiringCacheMaxEntries {
+		for k, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= expiringCacheMaxEntries {
+			c.entries = map[string]expiringCacheEntry[V]{}
+		}
+	}
+	c.entries[key] = expiringCacheEntry[V]{value: value, expires: now.Add(c.ttl)}
+}
+
+func (c *expiringCache[V]) delete(key string) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.entries, key)
+}
+
+func (c *expiringCache[V]) clear() {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.entries = map[string]expiringCacheEntry[V]{}
+}
@@ -0,0 +1,395 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/mattermost/mattermost-plugin-jira/server/utils/types"
+)
+
+// Inconsistencies found by fsck
+const (
+	FsckInstanceMissing         = "instance_missing"
+	FsckInstanceUnindexed       = "instance_unindexed"
+	FsckUserInstanceMissing     = "user_instance_missing"
+	FsckConnectionMissing       = "connection_missing"
+	FsckConnectionReverseIndex  = "connection_reverse_index"
+	FsckUserDefaultInstance     = "user_default_instance"
+	FsckSubscriptionIndex       = "subscription_index"
+	FsckChannelDefaultsInstance = "channel_defaults_instance"
+)
+
+// FsckProblem is an inconsistency of the stored data. Fixed is set once it
+// has been repaired, and is never set for the problems that need an admin
+// to decide.
+type FsckProblem struct {
+	Check       string   `json:"check"`
+	InstanceID  types.ID `json:"instance_id,omitempty"`
+	Target      string   `json:"target"`
+	Description string   `json:"description"`
+	Fixed       bool     `json:"fixed"`
+}
+
+// FsckReport is the result of a scan of the KV store.
+type FsckReport struct {
+	ScannedAt     int64         `json:"scanned_at"`
+	Fix           bool          `json:"fix"`
+	Instances     int           `json:"instances"`
+	Users         int           `json:"users"`
+	Subscriptions int           `json:"subscriptions"`
+	Problems      []FsckProblem `json:"problems"`
+}
+
+// add adds a problem to the report. The returned problem is only valid until
+// the next one is added.
+func (r *FsckReport) add(check string, instanceID types.ID, target, format string, args ...interface{}) *FsckProblem {
+	r.Problems = append(r.Problems, FsckProblem{
+		Check:       check,
+		InstanceID:  instanceID,
+		Target:      target,
+		Description: fmt.Sprintf(format, args...),
+	})
+	return &r.Problems[len(r.Problems)-1]
+}
+
+// fsck scans the instances, users, connections, subscriptions and channel
+// defaults for inconsistencies, and repairs them if fix is set.
+func (p *Plugin) fsck(fix bool) (*FsckReport, error) {
+	report := &FsckReport{
+		ScannedAt: model.GetMillis(),
+		Fix:       fix,
+	}
+
+	instances, err := p.fsckInstances(report, fix)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.fsckUsers(report, instances, fix); err != nil {
+		return nil, err
+	}
+	for _, instanceID := range instances.IDs() {
+		if err = p.fsckSubscriptions(report, instanceID, fix); err != nil {
+			return nil, err
+		}
+	}
+	if err = p.fsckChannelDefaults(report, instances, fix); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// fsckInstances checks that the instances in the list of instances are
+// stored, and that all stored instances are listed. It returns the list of
+// instances, without the missing ones.
+func (p *Plugin) fsckInstances(report *FsckReport, fix bool) (*Instances, error) {
+	instances, err := p.instanceStore.LoadInstances()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load the instances")
+	}
+
+	var missing []types.ID
+	for _, instanceID := range instances.IDs() {
+		if _, err = p.instanceStore.LoadInstance(instanceID); err != nil {
+			missing = append(missing, instanceID)
+		}
+	}
+	for _, instanceID := range missing {
+		problem := report.add(FsckInstanceMissing, instanceID, instanceID.String(), "instance %s is listed but not stored", instanceID)
+		instances.Delete(instanceID)
+		problem.Fixed = fix
+	}
+	if fix && len(missing) > 0 {
+		if err = p.instanceStore.StoreInstances(instances); err != nil {
+			return nil, errors.Wrap(err, "failed to store the instances")
+		}
+	}
+	report.Instances = len(instances.IDs())
+
+	keys, err := p.listKeysWithPrefix(prefixInstance)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		instance, err := p.instanceStore.LoadInstanceFullKey(key)
+		if err != nil || instance == nil {
+			continue
+		}
+		if !instances.Contains(instance.GetID()) {
+			report.add(FsckInstanceUnindexed, instance.GetID(), key,
+				"instance %s is stored but not listed, install it again or uninstall it", instance.GetID())
+		}
+	}
+	return instances, nil
+}
+
+// fsckUsers checks the connected instances and the default instance of the
+// users, and the reverse Jira ID index of their connections.
+func (p *Plugin) fsckUsers(report *FsckReport, instances *Instances, fix bool) error {
+	return p.userStore.MapUsers(func(user *User) error {
+		report.Users++
+		changed := false
+		for _, instanceID := range user.ConnectedInstances.IDs() {
+			if !instances.Contains(instanceID) {
+				problem := report.add(FsckUserInstanceMissing, instanceID, user.MattermostUserID.String(),
+					"user %s is connected to %s, which is not installed", user.MattermostUserID, instanceID)
+				if fix {
+					user.ConnectedInstances.Delete(instanceID)
+					changed = true
+					problem.Fixed = true
+				}
+				continue
+			}
+
+			var data []byte
+			if err := p.client.KV.Get(keyWithInstanceID(instanceID, user.MattermostUserID), &data); err != nil {
+				return err
+			}
+			if len(data) == 0 {
+				problem := report.add(FsckConnectionMissing, instanceID, user.MattermostUserID.String(),
+					"user %s is connected to %s but has no stored connection", user.MattermostUserID, instanceID)
+				if fix {
+					user.ConnectedInstances.Delete(instanceID)
+					changed = true
+					problem.Fixed = true
+				}
+				continue
+			}
+
+			connection := &Connection{}
+			if err := json.Unmarshal(data, connection); err != nil {
+				return errors.Wrapf(err, "failed to read the connection of user %s to %s", user.MattermostUserID, instanceID)
+			}
+			jiraID := connection.JiraAccountID()
+			if jiraID == "" {
+				continue
+			}
+			mattermostUserID, err := p.userStore.LoadMattermostUserID(instanceID, jiraID.String())
+			if err == nil && mattermostUserID == user.MattermostUserID {
+				continue
+			}
+			problem := report.add(FsckConnectionReverseIndex, instanceID, user.MattermostUserID.String(),
+				"Jira user %s on %s is not indexed to user %s", jiraID, instanceID, user.MattermostUserID)
+			if fix {
+				if _, err = p.client.KV.Set(keyWithInstanceID(instanceID, jiraID), user.MattermostUserID); err != nil {
+					return errors.Wrap(err, "failed to store the Jira user index")
+				}
+				problem.Fixed = true
+			}
+		}
+
+		defaultID := user.DefaultInstanceID
+		if defaultID != "" && (!user.ConnectedInstances.Contains(defaultID) || !instances.Contains(defaultID)) {
+			problem := report.add(FsckUserDefaultInstance, defaultID, user.MattermostUserID.String(),
+				"the default instance of user %s is %s, which is not installed or not connected", user.MattermostUserID, defaultID)
+			if fix {
+				user.DefaultInstanceID = ""
+				changed = true
+				problem.Fixed = true
+			}
+		}
+
+		if changed {
+			if err := p.userStore.StoreUser(user); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// fsckSubscriptions checks that the channel and event indexes of the
+// subscriptions of an instance agree with the subscriptions, and rebuilds
+// them from the subscriptions otherwise.
+func (p *Plugin) fsckSubscriptions(report *FsckReport, instanceID types.ID, fix bool) error {
+	subs, err := p.getSubscriptions(instanceID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load the subscriptions of %s", instanceID)
+	}
+	report.Subscriptions += len(subs.Channel.ByID) + len(subs.Instance.ByID)
+
+	channelSubs, instanceSubs := rebuildSubscriptionIndexes(subs)
+	first := len(report.Problems)
+	if !sameStringSetIndex(subs.Channel.IDByChannelID, channelSubs.IDByChannelID) {
+		report.add(FsckSubscriptionIndex, instanceID, "channel subscriptions",
+			"the channel index of the channel subscriptions of %s does not match the subscriptions", instanceID)
+	}
+	if !sameStringSetIndex(subs.Channel.IDByEvent, channelSubs.IDByEvent) {
+		report.add(FsckSubscriptionIndex, instanceID, "channel subscriptions",
+			"the event index of the channel subscriptions of %s does not match the subscriptions", instanceID)
+	}
+	if !sameStringSetIndex(subs.Instance.IDByChannelID, instanceSubs.IDByChannelID) {
+		report.add(FsckSubscriptionIndex, instanceID, "instance subscriptions",
+			"the channel index of the instance subscriptions of %s does not match the subscriptions", instanceID)
+	}
+	if !fix || len(report.Problems) == first {
+		return nil
+	}
+
+	subKey := keyWithInstanceID(instanceID, JiraSubscriptionsKey)
+	err = p.client.KV.SetAtomicWithRetries(subKey, func(initialBytes []byte) (interface{}, error) {
+		subs, err := SubscriptionsFromJSON(initialBytes, instanceID)
+		if err != nil {
+			return nil, err
+		}
+		subs.Channel, subs.Instance = rebuildSubscriptionIndexes(subs)
+		return json.Marshal(&subs)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to store the subscriptions of %s", instanceID)
+	}
+	for i := first; i < len(report.Problems); i++ {
+		report.Problems[i].Fixed = true
+	}
+	return nil
+}
+
+// rebuildSubscriptionIndexes returns the subscriptions with their indexes
+// built from the subscriptions by ID.
+func rebuildSubscriptionIndexes(subs *Subscriptions) (*ChannelSubscriptions, *InstanceSubscriptions) {
+	channelSubs := NewChannelSubscriptions()
+	for _, sub := range subs.Channel.ByID {
+		sub := sub
+		channelSubs.add(&sub)
+	}
+	instanceSubs := NewInstanceSubscriptions()
+	for _, sub := range subs.Instance.ByID {
+		sub := sub
+		instanceSubs.add(&sub)
+	}
+	return channelSubs, instanceSubs
+}
+
+// sameStringSetIndex compares two indexes, ignoring the empty sets that are
+// left behind when subscriptions are removed.
+func sameStringSetIndex(a, b map[string]StringSet) bool {
+	for key, set := range a {
+		if set.Len() == 0 {
+			continue
+		}
+		if !sameStringSet(set, b[key]) {
+			return false
+		}
+	}
+	for key, set := range b {
+		if set.Len() > 0 && a[key].Len() == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func sameStringSet(a, b StringSet) bool {
+	if a.Len() != b.Len() {
+		return false
+	}
+	for elem := range a {
+		if !b[elem] {
+			return false
+		}
+	}
+	return true
+}
+
+// fsckChannelDefaults checks that the channel defaults point to installed
+// instances, and deletes them otherwise.
+func (p *Plugin) fsckChannelDefaults(report *FsckReport, instances *Instances, fix bool) error {
+	keys, err := p.listKeysWithPrefix(channelDefaultsKeyPrefix)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		var defaults *ChannelDefaults
+		if err = p.client.KV.Get(key, &defaults); err != nil {
+			return errors.Wrap(err, "failed to load channel defaults")
+		}
+		if defaults == nil || defaults.InstanceID == "" || instances.Contains(defaults.InstanceID) {
+			continue
+		}
+		problem := report.add(FsckChannelDefaultsInstance, defaults.InstanceID, key,
+			"channel defaults %s use %s, which is not installed", key, defaults.InstanceID)
+		if fix {
+			if err = p.client.KV.Delete(key); err != nil {
+				return errors.Wrap(err, "failed to delete channel defaults")
+			}
+			problem.Fixed = true
+		}
+	}
+	return nil
+}
+
+func executeAdminFsck(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	authorized, err := authorizedSysAdmin(p, header.UserId)
+	if err != nil {
+		return p.responsef(header, "%v", err)
+	}
+	if !authorized {
+		return p.responsef(header, "`/jira admin fsck` can only be run by a system administrator.")
+	}
+	fix := false
+	for _, arg := range args {
+		if arg != "--fix" {
+			return p.responsef(header, "Unknown argument `%s`. Usage: `/jira admin fsck [--fix]`.", arg)
+		}
+		fix = true
+	}
+
+	report, err := p.fsck(fix)
+	if err != nil {
+		return p.responsef(header, "Failed to check the stored data. Error: %v.", err)
+	}
+
+	counts := map[string]int{}
+	fixed := 0
+	for _, problem := range report.Problems {
+		counts[problem.Check]++
+		if problem.Fixed {
+			fixed++
+		}
+	}
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "Checked %d instances, %d users and %d subscriptions: ", report.Instances, report.Users, report.Subscriptions)
+	if len(report.Problems) == 0 {
+		sb.WriteString("no inconsistencies found.")
+		return p.response(header, sb.String())
+	}
+	fmt.Fprintf(sb, "found %d inconsistencies", len(report.Problems))
+	if fix {
+		fmt.Fprintf(sb, ", fixed %d", fixed)
+		p.audit(header.UserId, AuditAdminFsck, "", "fsck", fmt.Sprintf("problems: %d, fixed: %d", len(report.Problems), fixed))
+	}
+	sb.WriteString(".\n")
+	checks := make([]string, 0, len(counts))
+	for check := range counts {
+		checks = append(checks, check)
+	}
+	sort.Strings(checks)
+	for _, check := range checks {
+		fmt.Fprintf(sb, "* `%s`: %d\n", check, counts[check])
+	}
+	if !fix {
+		sb.WriteString("Run `/jira admin fsck --fix` to repair them.\n")
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return p.response(header, sb.String())
+	}
+	fileName := fmt.Sprintf("jira-fsck-%s.json", time.Now().UTC().Format(auditDayLayout))
+	if err = p.sendFileToUser(header.UserId, fileName, "Jira data check report.", data); err != nil {
+		fmt.Fprintf(sb, "Failed to send the report. Error: %v.", err)
+	} else {
+		sb.WriteString("The full report was sent to you as a JSON file in your direct messages with the Jira bot.")
+	}
+	return p.response(header, sb.String())
+}
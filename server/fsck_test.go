@@ -0,0 +1,110 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"testing"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-jira/server/utils/types"
+)
+
+func TestFsck(t *testing.T) {
+	api := &plugintest.API{}
+	p := setupTestPlugin(api)
+	kv := map[string][]byte{}
+	mockKVMap(api, kv)
+	api.On("KVList", 0, listPerPage).Return(func(page, perPage int) ([]string, *model.AppError) {
+		var keys []string
+		for key := range kv {
+			keys = append(keys, key)
+		}
+		return keys, nil
+	})
+	store := NewStore(p)
+	p.instanceStore = store
+	p.userStore = store
+
+	const installed, gone = types.ID("https://jira.example.com"), types.ID("https://gone.example.com")
+	instance := &serverInstance{InstanceCommon: newInstanceCommon(p, ServerInstanceType, installed)}
+	require.NoError(t, store.StoreInstance(instance))
+	require.NoError(t, store.StoreInstances(NewInstances(instance.Common(), newInstanceCommon(p, ServerInstanceType, gone))))
+
+	// jane is connected to an uninstalled instance, which is her default,
+	// and her Jira account is not indexed
+	jane := NewUser("jane")
+	jane.ConnectedInstances.Set(instance.Common())
+	jane.ConnectedInstances.Set(newInstanceCommon(p, ServerInstanceType, gone))
+	jane.DefaultInstanceID = gone
+	require.NoError(t, store.StoreUser(jane))
+	require.NoError(t, store.StoreConnection(installed, "jane", &Connection{User: jira.User{AccountID: "jira-jane"}}))
+	delete(kv, keyWithInstanceID(installed, "jira-jane"))
+
+	// bob has no connection
+	bob := NewUser("bob")
+	bob.ConnectedInstances.Set(instance.Common())
+	require.NoError(t, store.StoreUser(bob))
+
+	subs := NewSubscriptions()
+	subs.Channel.add(&ChannelSubscription{ID: "sub1", ChannelID: "channel1", Filters: SubscriptionFilters{Events: NewStringSet(eventCreated)}})
+	subs.Channel.IDByEvent[eventCreated] = NewStringSet("sub1", "deleted")
+	_, err := p.client.KV.Set(keyWithInstanceID(installed, JiraSubscriptionsKey), subs)
+	require.NoError(t, err)
+
+	require.NoError(t, p.storeChannelDefaults("channel1", &ChannelDefaults{InstanceID: gone, ProjectKey: "BUG"}))
+
+	report, err := p.fsck(false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Instances)
+	assert.Equal(t, 2, report.Users)
+	assert.Equal(t, 1, report.Subscriptions)
+	checks := map[string]int{}
+	for _, problem := range report.Problems {
+		checks[problem.Check]++
+		assert.False(t, problem.Fixed)
+	}
+	assert.Equal(t, map[string]int{
+		FsckInstanceMissing:         1,
+		FsckUserInstanceMissing:     1,
+		FsckConnectionReverseIndex:  1,
+		FsckUserDefaultInstance:     1,
+		FsckConnectionMissing:       1,
+		FsckSubscriptionIndex:       1,
+		FsckChannelDefaultsInstance: 1,
+	}, checks)
+
+	report, err = p.fsck(true)
+	require.NoError(t, err)
+	require.Len(t, report.Problems, 7)
+	for _, problem := range report.Problems {
+		assert.True(t, problem.Fixed, problem.Check)
+	}
+
+	report, err = p.fsck(false)
+	require.NoError(t, err)
+	assert.Empty(t, report.Problems)
+
+	user, err := store.LoadUser("jane")
+	require.NoError(t, err)
+	assert.Equal(t, []types.ID{installed}, user.ConnectedInstances.IDs())
+	assert.Empty(t, user.DefaultInstanceID)
+	mattermostUserID, err := store.LoadMattermostUserID(installed, "jira-jane")
+	require.NoError(t, err)
+	assert.Equal(t, types.ID("jane"), mattermostUserID)
+	defaults, err := p.loadChannelDefaults("channel1")
+	require.NoError(t, err)
+	assert.Nil(t, defaults)
+}
+
+func TestSameStringSetIndex(t *testing.T) {
+	a := map[string]StringSet{"x": NewStringSet("1", "2"), "y": NewStringSet()}
+	assert.True(t, sameStringSetIndex(a, map[string]StringSet{"x": NewStringSet("2", "1")}))
+	assert.False(t, sameStringSetIndex(a, map[string]StringSet{"x": NewStringSet("1")}))
+	assert.False(t, sameStringSetIndex(a, map[string]StringSet{"x": NewStringSet("1", "2"), "z": NewStringSet("3")}))
+}
@@ -44,6 +44,10 @@ const (
 	routeAPIGetAutoCompleteFields               = "/get-search-autocomplete-fields"
 	routeAPIGetSearchUsers                      = "/get-search-users"
 	routeAPIAttachCommentToIssue                = "/attach-comment-to-issue"
+	routeAPIGetThreadTranscript                 = "/get-thread-transcript"
+	routeAPIGetChannelDefaults                  = "/get-channel-defaults"
+	routeAPIGetRequestTypes                     = "/get-request-types"
+	routeAPICreateCustomerRequest               = "/create-customer-request"
 	routeAPIUserInfo                            = "/userinfo"
 	routeAPISubscribeWebhook                    = "/webhook"
 	routeAPISubscriptionsChannel                = "/subscriptions/channel"
@@ -51,7 +55,14 @@ const (
 	routeAPISubscriptionsChannelWithID          = routeAPISubscriptionsChannel + "/{id:[A-Za-z0-9]+}"
 	routeAPISubscriptionTemplatesWithID         = routeAPISubscriptionTemplates + "/{id:[A-Za-z0-9]+}"
 	routeAPISettingsInfo                        = "/settingsinfo"
+	routeAPIMentionMappings                     = "/mention-mappings"
+	routeAPISubscriptionPermissions             = "/subscription-permissions"
 	routeIssueTransition                        = "/transition"
+	routeBulkAction                             = "/bulk-action"
+	routeReminderSnooze                         = "/reminder-snooze"
+	routeIssueEdit                              = "/edit-issue"
+	routeIssueEditUsers                         = "/edit-issue-users"
+	routeIssueTransitionDialog                  = "/transition-dialog"
 	routeAPIUserDisconnect                      = "/api/v3/disconnect"
 	routeACInstalled                            = "/ac/installed"
 	routeACJSON                                 = "/ac/atlassian-connect.json"
@@ -62,6 +73,7 @@ const (
 	routeACUserDisconnected                     = "/ac/user_disconnected.html"
 	routeIncomingWebhook                        = "/webhook"
 	routeOAuth1Complete                         = "/oauth1/complete.html"
+	routePATConnect                             = "/pat/connect.html"
 	routeUserStart                              = "/user/start"
 	routeUserConnect                            = "/user/connect"
 	routeUserDisconnect                         = "/user/disconnect"
@@ -117,7 +129,16 @@ func (p *Plugin) initializeRouter() {
 	apiRouter.HandleFunc(routeAPIGetSearchIssues, p.checkAuth(p.handleResponse(p.httpGetSearchIssues))).Methods(http.MethodGet)
 	apiRouter.HandleFunc(routeAPIGetSearchUsers, p.checkAuth(p.handleResponse(p.httpGetSearchUsers))).Methods(http.MethodGet)
 	apiRouter.HandleFunc(routeAPIAttachCommentToIssue, p.checkAuth(p.handleResponse(p.httpAttachCommentToIssue))).Methods(http.MethodPost)
+	apiRouter.HandleFunc(routeAPIGetThreadTranscript, p.checkAuth(p.handleResponse(p.httpGetThreadTranscript))).Methods(http.MethodGet)
+	apiRouter.HandleFunc(routeAPIGetChannelDefaults, p.checkAuth(p.handleResponse(p.httpGetChannelDefaults))).Methods(http.MethodGet)
+	apiRouter.HandleFunc(routeAPIGetRequestTypes, p.checkAuth(p.handleResponse(p.httpGetRequestTypes))).Methods(http.MethodGet)
+	apiRouter.HandleFunc(routeAPICreateCustomerRequest, p.checkAuth(p.handleResponse(p.httpCreateCustomerRequest))).Methods(http.MethodPost)
 	apiRouter.HandleFunc(routeIssueTransition, p.handleResponse(p.httpTransitionIssuePostAction)).Methods(http.MethodPost)
+	apiRouter.HandleFunc(routeIssueTransitionDialog, p.checkAuth(p.handleResponse(p.httpTransitionIssueDialog))).Methods(http.MethodPost)
+	apiRouter.HandleFunc(routeIssueEdit, p.checkAuth(p.handleResponse(p.httpEditIssueDialog))).Methods(http.MethodPost)
+	apiRouter.HandleFunc(routeIssueEditUsers, p.checkAuth(p.handleResponse(p.httpEditIssueUsers))).Methods(http.MethodPost)
+	apiRouter.HandleFunc(routeBulkAction, p.handleResponse(p.httpBulkAction)).Methods(http.MethodPost)
+	apiRouter.HandleFunc(routeReminderSnooze, p.handleResponse(p.httpSnoozeReminder)).Methods(http.MethodPost)
 	apiRouter.HandleFunc(routeSharePublicly, p.handleResponse(p.httpShareIssuePublicly)).Methods(http.MethodPost)
 	apiRouter.HandleFunc(routeGetIssueByKey, p.handleResponse(p.httpGetIssueByKey)).Methods(http.MethodGet)
 
@@ -143,6 +164,10 @@ func (p *Plugin) initializeRouter() {
 	instanceRouter.HandleFunc(routeOAuth1Complete, p.checkAuth(p.handleResponseWithCallbackInstance(p.httpOAuth1aComplete))).Methods(http.MethodGet)
 	instanceRouter.HandleFunc(routeUserDisconnect, p.checkAuth(p.handleResponseWithCallbackInstance(p.httpOAuth1aDisconnect))).Methods(http.MethodPost)
 
+	// Personal access tokens (Jira Data Center)
+	instanceRouter.HandleFunc(routePATConnect, p.checkAuth(p.handleResponseWithCallbackInstance(p.httpPATConnectForm))).Methods(http.MethodGet)
+	instanceRouter.HandleFunc(routePATConnect, p.checkAuth(p.handleResponseWithCallbackInstance(p.httpPATConnect))).Methods(http.MethodPost)
+
 	// OAuth2 (Jira Cloud)
 	instanceRouter.HandleFunc(routeOAuth2Complete, p.handleResponseWithCallbackInstance(p.httpOAuth2Complete)).Methods(http.MethodGet)
 
@@ -169,6 +194,12 @@ func (p *Plugin) initializeRouter() {
 	apiRouter.HandleFunc(routeAPISubscriptionTemplates, p.checkAuth(p.handleResponse(p.httpEditSubscriptionTemplates))).Methods(http.MethodPut)
 	apiRouter.HandleFunc(routeAPISubscriptionTemplatesWithID, p.checkAuth(p.handleResponse(p.httpDeleteSubscriptionTemplate))).Methods(http.MethodDelete)
 	apiRouter.HandleFunc(routeAPISubscriptionTemplates, p.checkAuth(p.handleResponse(p.httpGetSubscriptionTemplates))).Methods(http.MethodGet)
+
+	// Mention mappings
+	apiRouter.HandleFunc(routeAPIMentionMappings, p.checkAuth(p.handleResponse(p.httpGetMentionMappings))).Methods(http.MethodGet)
+	apiRouter.HandleFunc(routeAPIMentionMappings, p.checkAuth(p.handleResponse(p.httpSetMentionMappings))).Methods(http.MethodPut)
+	apiRouter.HandleFunc(routeAPISubscriptionPermissions, p.checkAuth(p.handleResponse(p.httpGetSubscriptionPermissions))).Methods(http.MethodGet)
+	apiRouter.HandleFunc(routeAPISubscriptionPermissions, p.checkAuth(p.handleResponse(p.httpSetSubscriptionPermissions))).Methods(http.MethodPut)
 }
 
 func (p *Plugin) ServeHTTP(c *plugin.Context, w http.ResponseWriter, r *http.Request) {
@@ -283,6 +283,7 @@ func TestSubscribe(t *testing.T) {
 			subscription:       `{"channel_id": "aaaaaaaaaaaaaaaaaaaaaaaaab", "filters": {"events": ["jira:issue_created"], "project": ["myproject"]}}`,
 			expectedStatusCode: http.StatusForbidden,
 			apiCalls: func(api *plugintest.API) {
+				api.On("KVGet", keyWithInstanceID("", subscriptionPermissionsKey)).Return(nil, nil)
 				api.On("HasPermissionTo", mock.AnythingOfType("string"), mock.Anything).Return(false)
 			},
 		},
@@ -448,6 +449,7 @@ func TestSubscribe(t *testing.T) {
 	} {
 		t.Run(name, func(t *testing.T) {
 			api := &plugintest.API{}
+			api.On("KVGet", keyWithInstanceID(testInstance1.InstanceID, subscriptionPermissionsKey)).Return(nil, nil).Maybe()
 			p := Plugin{}
 
 			api.On("LogDebug", mockAnythingOfTypeBatch("string", 11)...).Return()
@@ -569,6 +571,7 @@ func TestDeleteSubscription(t *testing.T) {
 	} {
 		t.Run(name, func(t *testing.T) {
 			api := &plugintest.API{}
+			api.On("KVGet", keyWithInstanceID(testInstance1.InstanceID, subscriptionPermissionsKey)).Return(nil, nil).Maybe()
 			p := Plugin{}
 
 			api.On("LogDebug", mockAnythingOfTypeBatch("string", 11)...).Return()
@@ -859,6 +862,7 @@ func TestEditSubscription(t *testing.T) {
 	} {
 		t.Run(name, func(t *testing.T) {
 			api := &plugintest.API{}
+			api.On("KVGet", keyWithInstanceID(testInstance1.InstanceID, subscriptionPermissionsKey)).Return(nil, nil).Maybe()
 			p := Plugin{}
 
 			api.On("LogDebug", mockAnythingOfTypeBatch("string", 11)...).Return()
@@ -1030,6 +1034,7 @@ func TestGetSubscriptionsForChannel(t *testing.T) {
 	} {
 		t.Run(name, func(t *testing.T) {
 			api := &plugintest.API{}
+			api.On("KVGet", keyWithInstanceID(testInstance1.InstanceID, subscriptionPermissionsKey)).Return(nil, nil).Maybe()
 			p := Plugin{}
 
 			api.On("LogDebug", mockAnythingOfTypeBatch("string", 11)...).Return()
@@ -0,0 +1,458 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+
+	"github.com/mattermost/mattermost-plugin-jira/server/utils/types"
+)
+
+const (
+	incidentRulesKey = "incidentrules"
+
+	// + issue ID, within instanceKey, the incident channel of an issue
+	incidentChannelKeyPrefix = "incident_channel_"
+	incidentChannelCreating  = "creating"
+
+	// The creating marker is left behind if the plugin stops while the
+	// channel is created, and then expires so that it is created again
+	incidentChannelCreatingExpiry = 5 * time.Minute
+
+	incidentGroupMembersPerPage = 200
+
+	// The channel of an issue is named after its key, with a suffix up to
+	// this number if the name is taken by a channel the plugin did not create
+	incidentChannelMaxSuffix = 5
+)
+
+const incidentUsage = "Usage: `/jira incident add [name] project=[key] [priority=[name]] [type=[issue type]] [label=[label]] [event=created|updated|any] [channel=private|public]`, `/jira incident list` or `/jira incident remove [name]`."
+
+// IncidentRule creates a channel in a team for each issue that matches its
+// filters, like the issues of project OPS created with priority Highest.
+// The filters are those of the channel subscriptions. The channels are
+// private unless Public is set.
+type IncidentRule struct {
+	Name    string              `json:"name"`
+	TeamID  string              `json:"team_id"`
+	Filters SubscriptionFilters `json:"filters"`
+	Public  bool                `json:"public,omitempty"`
+}
+
+func (p *Plugin) getIncidentRules(instanceID types.ID) ([]IncidentRule, error) {
+	var rules []IncidentRule
+	if err := p.client.KV.Get(keyWithInstanceID(instanceID, incidentRulesKey), &rules); err != nil {
+		return nil, errors.Wrap(err, "failed to load the incident rules")
+	}
+	return rules, nil
+}
+
+// updateIncidentRules updates the incident rules of the instance atomically,
+// as admins can edit them concurrently.
+func (p *Plugin) updateIncidentRules(instanceID types.ID, update func(rules []IncidentRule) ([]IncidentRule, error)) error {
+	var updateErr error
+	err := p.client.KV.SetAtomicWithRetries(keyWithInstanceID(instanceID, incidentRulesKey), func(initialBytes []byte) (interface{}, error) {
+		var rules []IncidentRule
+		if len(initialBytes) != 0 {
+			if err := json.Unmarshal(initialBytes, &rules); err != nil {
+				return nil, errors.Wrap(err, "failed to load the incident rules")
+			}
+		}
+
+		rules, updateErr = update(rules)
+		if updateErr != nil {
+			return nil, updateErr
+		}
+		sort.Slice(rules, func(i, j int) bool {
+			return strings.ToLower(rules[i].Name) < strings.ToLower(rules[j].Name)
+		})
+		return rules, nil
+	})
+	if updateErr != nil {
+		return updateErr
+	}
+	return errors.WithMessage(err, "failed to store the incident rules")
+}
+
+// parseIncidentRule parses the filters of an incident rule, given as
+// key=value arguments. Priorities and issue types are given by name, and
+// resolved with the Jira client.
+func parseIncidentRule(client Client, name string, args []string) (*IncidentRule, error) {
+	rule := &IncidentRule{
+		Name: name,
+		Filters: SubscriptionFilters{
+			Events:     NewStringSet(eventCreated),
+			Projects:   NewStringSet(),
+			IssueTypes: NewStringSet(),
+		},
+	}
+
+	var issueTypes []string
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || value == "" {
+			return nil, errors.Errorf("`%s` is not in the form key=value", arg)
+		}
+		switch strings.ToLower(key) {
+		case "project":
+			rule.Filters.Projects = rule.Filters.Projects.Add(strings.ToUpper(value))
+		case "type":
+			issueTypes = append(issueTypes, value)
+		case priorityField:
+			priorityID, err := findPriorityID(client, value)
+			if err != nil {
+				return nil, err
+			}
+			rule.Filters.Fields = append(rule.Filters.Fields, FieldFilter{Key: priorityField, Inclusion: FilterIncludeAny, Values: NewStringSet(priorityID)})
+		case "label":
+			rule.Filters.Fields = append(rule.Filters.Fields, FieldFilter{Key: labelsField, Inclusion: FilterIncludeAny, Values: NewStringSet(value)})
+		case "event":
+			switch strings.ToLower(value) {
+			case "created":
+				rule.Filters.Events = NewStringSet(eventCreated)
+			case "updated":
+				rule.Filters.Events = NewStringSet(eventUpdatedAny)
+			case "any":
+				rule.Filters.Events = NewStringSet(eventCreated, eventUpdatedAny)
+			default:
+				return nil, errors.Errorf("the event must be `created`, `updated` or `any`, not `%s`", value)
+			}
+		case "channel":
+			switch strings.ToLower(value) {
+			case "private":
+				rule.Public = false
+			case "public":
+				rule.Public = true
+			default:
+				return nil, errors.Errorf("the channel must be `private` or `public`, not `%s`", value)
+			}
+		default:
+			return nil, errors.Errorf("unknown filter `%s`", key)
+		}
+	}
+	if rule.Filters.Projects.Len() == 0 {
+		return nil, errors.New("please specify the project of the issues, like `project=OPS`")
+	}
+
+	for _, issueType := range issueTypes {
+		found := false
+		for _, projectKey := range rule.Filters.Projects.Elems() {
+			project, err := client.GetProject(projectKey)
+			if err != nil {
+				return nil, errors.WithMessagef(err, "failed to load project %s", projectKey)
+			}
+			for _, t := range project.IssueTypes {
+				if strings.EqualFold(t.Name, issueType) {
+					rule.Filters.IssueTypes = rule.Filters.IssueTypes.Add(t.ID)
+					found = true
+				}
+			}
+		}
+		if !found {
+			return nil, errors.Errorf("no project has the issue type `%s`", issueType)
+		}
+	}
+	return rule, nil
+}
+
+func findPriorityID(client Client, name string) (string, error) {
+	var priorities []jira.Priority
+	if err := client.RESTGet("2/priority", nil, &priorities); err != nil {
+		return "", errors.WithMessage(err, "failed to load the priorities")
+	}
+	var names []string
+	for _, priority := range priorities {
+		if strings.EqualFold(priority.Name, name) || priority.ID == name {
+			return priority.ID, nil
+		}
+		names = append(names, priority.Name)
+	}
+	return "", errors.Errorf("`%s` is not a priority, use one of: %s", name, strings.Join(names, ", "))
+}
+
+func describeIncidentRule(rule IncidentRule) string {
+	var filters []string
+	projects := rule.Filters.Projects.Elems()
+	sort.Strings(projects)
+	filters = append(filters, "project "+strings.Join(projects, ", "))
+	if rule.Filters.IssueTypes.Len() > 0 {
+		filters = append(filters, fmt.Sprintf("%d issue types", rule.Filters.IssueTypes.Len()))
+	}
+	for _, field := range rule.Filters.Fields {
+		filters = append(filters, field.Key+" "+strings.Join(field.Values.Elems(), ", "))
+	}
+	events := rule.Filters.Events.Elems()
+	sort.Strings(events)
+	channelType := "private"
+	if rule.Public {
+		channelType = "public"
+	}
+	return fmt.Sprintf("**%s**: %s, on %s, in a %s channel", rule.Name, strings.Join(filters, ", "), strings.Join(events, ", "), channelType)
+}
+
+// processIncidentRules creates the incident channel of the issue of the
+// webhook, if it matches a rule and has no channel yet.
+func (p *Plugin) processIncidentRules(wh *webhook, instanceID types.ID) {
+	if wh.Issue.ID == "" || wh.Issue.Fields == nil {
+		return
+	}
+	rules, err := p.getIncidentRules(instanceID)
+	if err != nil {
+		p.client.Log.Warn("Failed to load the incident rules", "error", err.Error())
+		return
+	}
+	for _, rule := range rules {
+		if !p.matchesSubscriptionFilters(wh, instanceID, rule.Filters) {
+			continue
+		}
+		if err = p.createIncidentChannel(instanceID, rule, &wh.Issue); err != nil {
+			p.client.Log.Warn("Failed to create the incident channel", "issue", wh.Issue.Key, "rule", rule.Name, "error", err.Error())
+		}
+		// An issue has a single incident channel
+		return
+	}
+}
+
+func incidentChannelKey(instanceID types.ID, issueID string) string {
+	return instanceKey(incidentChannelKeyPrefix, instanceID, issueID)
+}
+
+func (p *Plugin) createIncidentChannel(instanceID types.ID, rule IncidentRule, issue *jira.Issue) error {
+	key := incidentChannelKey(instanceID, issue.ID)
+	// The events of an issue can be processed concurrently
+	saved, err := p.client.KV.Set(key, incidentChannelCreating, pluginapi.SetAtomic(nil), pluginapi.SetExpiry(incidentChannelCreatingExpiry))
+	if err != nil {
+		return err
+	}
+	if !saved {
+		return nil
+	}
+
+	channel, err := p.ensureIncidentChannel(instanceID, rule, issue)
+	if err != nil {
+		_ = p.client.KV.Delete(key)
+		return err
+	}
+	if _, err = p.client.KV.Set(key, channel.Id); err != nil {
+		p.client.Log.Warn("Failed to store the incident channel", "issue", issue.Key, "error", err.Error())
+	}
+
+	for _, userID := range p.getIncidentMembers(instanceID, issue) {
+		if _, err = p.client.Channel.AddMember(channel.Id, userID); err != nil {
+			p.client.Log.Debug("Failed to add a user to the incident channel", "issue", issue.Key, "user", userID, "error", err.Error())
+		}
+	}
+
+	if _, err = p.upsertIssueCard(instanceID, channel.Id, p.getBotUserID(instanceID), issue); err != nil {
+		p.client.Log.Warn("Failed to post the issue card in the incident channel", "issue", issue.Key, "error", err.Error())
+	}
+	return nil
+}
+
+// ensureIncidentChannel creates the channel named after the issue key. A
+// channel with that name is reused only if the plugin created it for the
+// issue, when the plugin stopped before it was stored. Otherwise the name
+// gets a suffix.
+func (p *Plugin) ensureIncidentChannel(instanceID types.ID, rule IncidentRule, issue *jira.Issue) (*model.Channel, error) {
+	instance, err := p.instanceStore.LoadInstance(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	issueURL := fmt.Sprintf("%s/browse/%s", instance.GetJiraBaseURL(), issue.Key)
+	headerPrefix := fmt.Sprintf("Incident [%s](%s)", issue.Key, issueURL)
+	botUserID := p.getBotUserID(instanceID)
+
+	name := ""
+	for i := 1; i <= incidentChannelMaxSuffix; i++ {
+		candidate := strings.ToLower(issue.Key)
+		if i > 1 {
+			candidate = fmt.Sprintf("%s-%d", candidate, i)
+		}
+		existing, getErr := p.client.Channel.GetByName(rule.TeamID, candidate, true)
+		if getErr != nil {
+			name = candidate
+			break
+		}
+		if existing.DeleteAt == 0 && existing.CreatorId == botUserID && strings.HasPrefix(existing.Header, headerPrefix) {
+			return existing, nil
+		}
+	}
+	if name == "" {
+		return nil, errors.Errorf("the channel names for %s are taken by other channels", issue.Key)
+	}
+
+	channelType := model.ChannelTypePrivate
+	if rule.Public {
+		channelType = model.ChannelTypeOpen
+	}
+	channel := &model.Channel{
+		TeamId:      rule.TeamID,
+		Type:        channelType,
+		Name:        name,
+		DisplayName: truncate(issue.Key+": "+issue.Fields.Summary, model.ChannelDisplayNameMaxRunes),
+		Header:      truncate(headerPrefix+": "+issue.Fields.Summary, model.ChannelHeaderMaxRunes),
+		Purpose:     truncate(issue.Fields.Summary, model.ChannelPurposeMaxRunes),
+		CreatorId:   botUserID,
+	}
+	if err = p.client.Channel.Create(channel); err != nil {
+		return nil, errors.WithMessagef(err, "failed to create the channel %s", name)
+	}
+	return channel, nil
+}
+
+// getIncidentMembers returns the Mattermost users to add to the incident
+// channel: the connected assignee and reporter of the issue, and the users
+// and the members of the groups mapped to its fields.
+func (p *Plugin) getIncidentMembers(instanceID types.ID, issue *jira.Issue) []string {
+	userIDs := NewStringSet()
+	for _, jiraUser := range []*jira.User{issue.Fields.Assignee, issue.Fields.Reporter} {
+		if jiraUser == nil {
+			continue
+		}
+		jiraUserID := jiraUser.AccountID
+		if jiraUserID == "" {
+			jiraUserID = jiraUser.Name
+		}
+		if mattermostUserID, err := p.userStore.LoadMattermostUserID(instanceID, jiraUserID); err == nil {
+			userIDs = userIDs.Add(mattermostUserID.String())
+		}
+	}
+
+	for _, name := range p.getIssueMentionNames(instanceID, issue) {
+		if user, err := p.client.User.GetByUsername(name); err == nil {
+			userIDs = userIDs.Add(user.Id)
+			continue
+		}
+		group, appErr := p.API.GetGroupByName(name)
+		if appErr != nil {
+			continue
+		}
+		for page := 0; ; page++ {
+			members, appErr := p.API.GetGroupMemberUsers(group.Id, page, incidentGroupMembersPerPage)
+			if appErr != nil {
+				break
+			}
+			for _, member := range members {
+				userIDs = userIDs.Add(member.Id)
+			}
+			if len(members) < incidentGroupMembersPerPage {
+				break
+			}
+		}
+	}
+
+	result := userIDs.Elems()
+	sort.Strings(result)
+	return result
+}
+
+func (p *Plugin) loadIncidentInstance(header *model.CommandArgs, args []string) (Instance, []string, *model.CommandResponse) {
+	authorized, err := authorizedSysAdmin(p, header.UserId)
+	if err != nil {
+		return nil, nil, p.responsef(header, "%v", err)
+	}
+	if !authorized {
+		return nil, nil, p.responsef(header, "`/jira incident` can only be run by a system administrator.")
+	}
+
+	_, instance, args, err := p.loadFlagUserInstance(header.UserId, args)
+	if err != nil {
+		return nil, nil, p.responsef(header, "Failed to identify the Jira instance. Error: %v.", err)
+	}
+
+	return instance, args, nil
+}
+
+func executeIncidentAdd(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	instance, args, resp := p.loadIncidentInstance(header, args)
+	if resp != nil {
+		return resp
+	}
+	if len(args) < 2 {
+		return p.response(header, incidentUsage)
+	}
+
+	client, _, _, err := p.getClient(instance.GetID(), types.ID(header.UserId))
+	if err != nil {
+		return p.responsef(header, "Failed to load your connection to Jira. Error: %v.", err)
+	}
+	rule, err := parseIncidentRule(client, args[0], args[1:])
+	if err != nil {
+		return p.responsef(header, "%v. %s", err, incidentUsage)
+	}
+	rule.TeamID = header.TeamId
+
+	err = p.updateIncidentRules(instance.GetID(), func(rules []IncidentRule) ([]IncidentRule, error) {
+		for _, existing := range rules {
+			if strings.EqualFold(existing.Name, rule.Name) {
+				return nil, errors.Errorf("the incident rule **%s** already exists", rule.Name)
+			}
+		}
+		return append(rules, *rule), nil
+	})
+	if err != nil {
+		return p.responsef(header, "Failed to add the incident rule. Error: %v.", err)
+	}
+	p.audit(header.UserId, AuditIncidentRuleEdit, instance.GetID(), rule.Name, "added")
+
+	return p.responsef(header, "Added the incident rule %s. A channel will be created in this team for each matching issue.", describeIncidentRule(*rule))
+}
+
+func executeIncidentList(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	instance, _, resp := p.loadIncidentInstance(header, args)
+	if resp != nil {
+		return resp
+	}
+	rules, err := p.getIncidentRules(instance.GetID())
+	if err != nil {
+		return p.responsef(header, "%v.", err)
+	}
+	if len(rules) == 0 {
+		return p.responsef(header, "%s has no incident rules.", instance.GetURL())
+	}
+
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "Incident rules of %s:\n", instance.GetURL())
+	for _, rule := range rules {
+		fmt.Fprintf(sb, "* %s\n", describeIncidentRule(rule))
+	}
+	return p.response(header, sb.String())
+}
+
+func executeIncidentRemove(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	instance, args, resp := p.loadIncidentInstance(header, args)
+	if resp != nil {
+		return resp
+	}
+	if len(args) != 1 {
+		return p.response(header, incidentUsage)
+	}
+
+	var removed string
+	err := p.updateIncidentRules(instance.GetID(), func(rules []IncidentRule) ([]IncidentRule, error) {
+		for i, rule := range rules {
+			if strings.EqualFold(rule.Name, args[0]) {
+				removed = rule.Name
+				return append(rules[:i], rules[i+1:]...), nil
+			}
+		}
+		return nil, errors.Errorf("there is no incident rule **%s**", args[0])
+	})
+	if err != nil {
+		return p.responsef(header, "Failed to remove the incident rule. Error: %v.", err)
+	}
+	p.audit(header.UserId, AuditIncidentRuleEdit, instance.GetID(), removed, "removed")
+	return p.responsef(header, "Removed the incident rule **%s**.", removed)
+}
@@ -0,0 +1,211 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type incidentTestClient struct {
+	testClient
+}
+
+func (client incidentTestClient) RESTGet(endpoint string, params map[string]string, dest interface{}) error {
+	priorities := []jira.Priority{{ID: "1", Name: "Highest"}, {ID: "2", Name: "High"}}
+	bb, _ := json.Marshal(priorities)
+	return json.Unmarshal(bb, dest)
+}
+
+func (client incidentTestClient) GetProject(key string) (*jira.Project, error) {
+	return &jira.Project{
+		Key:        key,
+		IssueTypes: []jira.IssueType{{ID: "10001", Name: "Bug"}, {ID: "10002", Name: "Incident"}},
+	}, nil
+}
+
+func TestParseIncidentRule(t *testing.T) {
+	client := incidentTestClient{}
+
+	t.Run("filters", func(t *testing.T) {
+		rule, err := parseIncidentRule(client, "sev1", []string{"project=ops", "priority=highest", "type=Incident", "label=customer", "event=any"})
+		require.NoError(t, err)
+		assert.Equal(t, "sev1", rule.Name)
+		assert.Equal(t, NewStringSet("OPS"), rule.Filters.Projects)
+		assert.Equal(t, NewStringSet("10002"), rule.Filters.IssueTypes)
+		assert.Equal(t, NewStringSet(eventCreated, eventUpdatedAny), rule.Filters.Events)
+		assert.Equal(t, []FieldFilter{
+			{Key: priorityField, Inclusion: FilterIncludeAny, Values: NewStringSet("1")},
+			{Key: labelsField, Inclusion: FilterIncludeAny, Values: NewStringSet("customer")},
+		}, rule.Filters.Fields)
+		assert.False(t, rule.Public)
+	})
+
+	t.Run("public channel", func(t *testing.T) {
+		rule, err := parseIncidentRule(client, "sev1", []string{"project=OPS", "channel=Public"})
+		require.NoError(t, err)
+		assert.True(t, rule.Public)
+	})
+
+	for name, tc := range map[string]struct {
+		args []string
+		err  string
+	}{
+		"no project":       {args: []string{"priority=High"}, err: "please specify the project of the issues, like `project=OPS`"},
+		"unknown priority": {args: []string{"project=OPS", "priority=Blocker"}, err: "`Blocker` is not a priority, use one of: Highest, High"},
+		"unknown type":     {args: []string{"project=OPS", "type=Story"}, err: "no project has the issue type `Story`"},
+		"unknown filter":   {args: []string{"project=OPS", "team=Payments"}, err: "unknown filter `team`"},
+		"bad event":        {args: []string{"project=OPS", "event=deleted"}, err: "the event must be `created`, `updated` or `any`, not `deleted`"},
+		"bad channel":      {args: []string{"project=OPS", "channel=secret"}, err: "the channel must be `private` or `public`, not `secret`"},
+		"not key=value":    {args: []string{"OPS"}, err: "`OPS` is not in the form key=value"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseIncidentRule(client, "sev1", tc.args)
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestCreateIncidentChannel(t *testing.T) {
+	api := &plugintest.API{}
+	p := setupTestPlugin(api)
+	kv := map[string][]byte{}
+	mockKVMap(api, kv)
+	p.updateConfig(func(conf *config) {
+		conf.botUserID = "bot-id"
+	})
+
+	mappings, err := json.Marshal([]MentionMapping{
+		{Field: "components", Value: "Backend", Mention: "backend"},
+		{Field: "components", Value: "Backend", Mention: "alice"},
+	})
+	require.NoError(t, err)
+	kv[keyWithInstanceID(testInstance1.InstanceID, mentionMappingsKey)] = mappings
+
+	issue := testCardIssue("To Do")
+	issue.Fields.Components = []*jira.Component{{ID: "1", Name: "Backend"}}
+	issue.Fields.Reporter = &jira.User{AccountID: "reporter"}
+	rule := IncidentRule{Name: "sev1", TeamID: "team-id"}
+
+	api.On("GetUserByUsername", "alice").Return(&model.User{Id: "alice-id"}, nil)
+	api.On("GetUserByUsername", "backend").Return(nil, model.NewAppError("GetUserByUsername", "", nil, "", http.StatusNotFound))
+	api.On("GetGroupByName", "backend").Return(&model.Group{Id: "group-id"}, nil)
+	api.On("GetGroupMemberUsers", "group-id", 0, incidentGroupMembersPerPage).Return([]*model.User{{Id: "bob-id"}}, nil)
+
+	api.On("GetChannelByName", "team-id", "bug-12", true).Return(nil, model.NewAppError("GetChannelByName", "", nil, "", http.StatusNotFound))
+	api.On("CreateChannel", mock.MatchedBy(func(channel *model.Channel) bool {
+		return channel.Name == "bug-12" &&
+			channel.Type == model.ChannelTypePrivate &&
+			channel.DisplayName == "BUG-12: The login page is slow" &&
+			channel.Header == "Incident [BUG-12]("+mockInstance1URL+"/browse/BUG-12): The login page is slow"
+	})).Return(&model.Channel{Id: "channel-id", TeamId: "team-id", Name: "bug-12"}, nil).Once()
+	api.On("GetConfig").Return(&model.Config{})
+	api.On("AddChannelMember", "channel-id", mock.AnythingOfType("string")).Return(&model.ChannelMember{}, nil)
+	api.On("CreatePost", mock.AnythingOfType("*model.Post")).Return(func(post *model.Post) (*model.Post, *model.AppError) {
+		card := post.Clone()
+		card.Id = "card-id"
+		return card, nil
+	}).Once()
+
+	require.NoError(t, p.createIncidentChannel(testInstance1.InstanceID, rule, issue))
+	assert.Equal(t, `"channel-id"`, string(kv[incidentChannelKey(testInstance1.InstanceID, "10001")]))
+	// The assignee and reporter are both mapped to the user with notifications
+	for _, userID := range []string{mockUserIDWithNotifications, "alice-id", "bob-id"} {
+		api.AssertCalled(t, "AddChannelMember", "channel-id", userID)
+	}
+	api.AssertNumberOfCalls(t, "AddChannelMember", 3)
+	api.AssertCalled(t, "CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+		return post.ChannelId == "channel-id" && post.UserId == "bot-id"
+	}))
+}
+
+func TestEnsureIncidentChannel(t *testing.T) {
+	issue := testCardIssue("To Do")
+	rule := IncidentRule{Name: "sev1", TeamID: "team-id"}
+	header := "Incident [BUG-12](" + mockInstance1URL + "/browse/BUG-12): The login page is slow"
+	notFound := model.NewAppError("GetChannelByName", "", nil, "", http.StatusNotFound)
+
+	setup := func() (*plugintest.API, *Plugin) {
+		api := &plugintest.API{}
+		p := setupTestPlugin(api)
+		p.updateConfig(func(conf *config) {
+			conf.botUserID = "bot-id"
+		})
+		return api, p
+	}
+
+	t.Run("channel created by the plugin for the issue", func(t *testing.T) {
+		api, p := setup()
+		existing := &model.Channel{Id: "channel-id", Name: "bug-12", CreatorId: "bot-id", Header: header}
+		api.On("GetChannelByName", "team-id", "bug-12", true).Return(existing, nil)
+
+		channel, err := p.ensureIncidentChannel(testInstance1.InstanceID, rule, issue)
+		require.NoError(t, err)
+		assert.Equal(t, existing, channel)
+		api.AssertNotCalled(t, "CreateChannel", mock.Anything)
+	})
+
+	t.Run("name taken by another channel", func(t *testing.T) {
+		api, p := setup()
+		api.On("GetChannelByName", "team-id", "bug-12", true).Return(&model.Channel{Id: "other-id", Name: "bug-12", CreatorId: "user-id"}, nil)
+		api.On("GetChannelByName", "team-id", "bug-12-2", true).Return(&model.Channel{Id: "archived-id", Name: "bug-12-2", CreatorId: "bot-id", Header: header, DeleteAt: 1}, nil)
+		api.On("GetChannelByName", "team-id", "bug-12-3", true).Return(nil, notFound)
+		api.On("CreateChannel", mock.MatchedBy(func(channel *model.Channel) bool {
+			return channel.Name == "bug-12-3" && channel.Header == header
+		})).Return(&model.Channel{Id: "channel-id", Name: "bug-12-3"}, nil).Once()
+		api.On("GetConfig").Return(&model.Config{})
+
+		channel, err := p.ensureIncidentChannel(testInstance1.InstanceID, rule, issue)
+		require.NoError(t, err)
+		assert.Equal(t, "bug-12-3", channel.Name)
+	})
+
+	t.Run("all names taken", func(t *testing.T) {
+		api, p := setup()
+		api.On("GetChannelByName", "team-id", mock.AnythingOfType("string"), true).Return(&model.Channel{Id: "other-id", CreatorId: "user-id"}, nil)
+
+		_, err := p.ensureIncidentChannel(testInstance1.InstanceID, rule, issue)
+		assert.EqualError(t, err, "the channel names for BUG-12 are taken by other channels")
+		api.AssertNumberOfCalls(t, "GetChannelByName", incidentChannelMaxSuffix)
+		api.AssertNotCalled(t, "CreateChannel", mock.Anything)
+	})
+}
+
+func TestUpdateIncidentRules(t *testing.T) {
+	api := &plugintest.API{}
+	p := setupTestPlugin(api)
+	kv := map[string][]byte{}
+	mockKVMap(api, kv)
+
+	add := func(name string) func([]IncidentRule) ([]IncidentRule, error) {
+		return func(rules []IncidentRule) ([]IncidentRule, error) {
+			return append(rules, IncidentRule{Name: name}), nil
+		}
+	}
+	require.NoError(t, p.updateIncidentRules(testInstance1.InstanceID, add("sev2")))
+	require.NoError(t, p.updateIncidentRules(testInstance1.InstanceID, add("Sev1")))
+
+	rules, err := p.getIncidentRules(testInstance1.InstanceID)
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	assert.Equal(t, "Sev1", rules[0].Name)
+	assert.Equal(t, "sev2", rules[1].Name)
+
+	err = p.updateIncidentRules(testInstance1.InstanceID, func([]IncidentRule) ([]IncidentRule, error) {
+		return nil, errors.New("the incident rule **sev2** already exists")
+	})
+	assert.EqualError(t, err, "the incident rule **sev2** already exists")
+	rules, err = p.getIncidentRules(testInstance1.InstanceID)
+	require.NoError(t, err)
+	assert.Len(t, rules, 2)
+}
@@ -42,6 +42,9 @@ type InstanceCommon struct {
 	IsV2Legacy bool
 
 	SetupWizardUserID string
+
+	// BotUserID is the dedicated bot that posts for the instance, if any
+	BotUserID string `json:",omitempty"`
 }
 
 func newInstanceCommon(p *Plugin, instanceType InstanceType, instanceID types.ID) *InstanceCommon {
@@ -0,0 +1,351 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+
+	"github.com/mattermost/mattermost-plugin-jira/server/utils"
+	"github.com/mattermost/mattermost-plugin-jira/server/utils/types"
+)
+
+const (
+	prefixInstanceArchive = "jira_archive_" // + hashed instance ID
+
+	// An uninstalled instance can be restored for this long
+	instanceArchiveRetention = 30 * 24 * time.Hour
+)
+
+// instanceScopedKeys are the keys, within keyWithInstanceID, of the instance
+// data that is archived on uninstall.
+var instanceScopedKeys = []string{
+	JiraSubscriptionsKey,
+	templateKey,
+	mentionMappingsKey,
+	subscriptionPermissionsKey,
+	incidentRulesKey,
+	reminderRulesKey,
+}
+
+// InstanceArchive is the snapshot of the subscriptions, templates and
+// settings of an uninstalled instance. The data is kept as stored, by key.
+type InstanceArchive struct {
+	InstanceID types.ID                   `json:"instance_id"`
+	Type       InstanceType               `json:"type"`
+	Alias      string                     `json:"alias,omitempty"`
+	ArchivedAt int64                      `json:"archived_at"`
+	Data       map[string]json.RawMessage `json:"data"`
+}
+
+func instanceArchiveKey(instanceID types.ID) string {
+	return hashkey(prefixInstanceArchive, instanceID.String())
+}
+
+func (p *Plugin) loadInstanceArchive(instanceID types.ID) (*InstanceArchive, error) {
+	var archive *InstanceArchive
+	if err := p.client.KV.Get(instanceArchiveKey(instanceID), &archive); err != nil {
+		return nil, errors.Wrap(err, "failed to load the instance archive")
+	}
+	return archive, nil
+}
+
+// archiveInstance moves the instance data to an archive that expires after
+// the retention, so that no data is left behind if the instance is not
+// installed and restored again.
+func (p *Plugin) archiveInstance(instance Instance) error {
+	archive := &InstanceArchive{
+		InstanceID: instance.GetID(),
+		Type:       instance.Common().Type,
+		Alias:      instance.Common().Alias,
+		ArchivedAt: model.GetMillis(),
+		Data:       map[string]json.RawMessage{},
+	}
+	for _, key := range instanceScopedKeys {
+		var data []byte
+		if err := p.client.KV.Get(keyWithInstanceID(instance.GetID(), types.ID(key)), &data); err != nil {
+			return errors.Wrapf(err, "failed to load %s", key)
+		}
+		if len(data) > 0 {
+			archive.Data[key] = data
+		}
+	}
+
+	if _, err := p.client.KV.Set(instanceArchiveKey(instance.GetID()), archive, pluginapi.SetExpiry(instanceArchiveRetention)); err != nil {
+		return errors.Wrap(err, "failed to store the instance archive")
+	}
+	return p.deleteInstanceScopedKeys(instance.GetID())
+}
+
+func (p *Plugin) deleteInstanceScopedKeys(instanceID types.ID) error {
+	for _, key := range instanceScopedKeys {
+		if err := p.client.KV.Delete(keyWithInstanceID(instanceID, types.ID(key))); err != nil {
+			return errors.Wrapf(err, "failed to delete %s", key)
+		}
+	}
+	p.mentionMappingsCache.delete(instanceID.String())
+	return nil
+}
+
+// purgeInstanceData deletes all the data of the instance: the
+// instance-scoped keys and the archive, the per-issue markers of reminders,
+// incident channels and SLA breaches, the channel defaults and thread issues
+// that use the instance, and the thread roots and issue cards of its issues.
+func (p *Plugin) purgeInstanceData(instance Instance) error {
+	instanceID := instance.GetID()
+	subs, err := p.getSubscriptions(instanceID)
+	if err != nil {
+		return err
+	}
+	channelIDs := map[string]bool{}
+	for channelID := range subs.Channel.IDByChannelID {
+		channelIDs[channelID] = true
+	}
+
+	keys, err := p.listKeysWithPrefix("")
+	if err != nil {
+		return err
+	}
+
+	var toDelete, threadRootKeys []string
+	for _, key := range keys {
+		switch {
+		case strings.HasPrefix(key, instanceKeyPrefix(incidentChannelKeyPrefix, instanceID)):
+			var channelID string
+			if err = p.client.KV.Get(key, &channelID); err != nil {
+				return errors.Wrap(err, "failed to load an incident channel")
+			}
+			if channelID != "" && channelID != incidentChannelCreating {
+				channelIDs[channelID] = true
+			}
+			toDelete = append(toDelete, key)
+
+		case strings.HasPrefix(key, instanceKeyPrefix(reminderKeyPrefix, instanceID)),
+			strings.HasPrefix(key, instanceKeyPrefix(slaBreachKeyPrefix, instanceID)),
+			strings.HasPrefix(key, instanceKeyPrefix(boardProjectsKeyPrefix, instanceID)):
+			toDelete = append(toDelete, key)
+
+		case strings.HasPrefix(key, channelDefaultsKeyPrefix):
+			var defaults *ChannelDefaults
+			if err = p.client.KV.Get(key, &defaults); err != nil {
+				return errors.Wrap(err, "failed to load channel defaults")
+			}
+			if defaults != nil && defaults.InstanceID == instanceID {
+				toDelete = append(toDelete, key)
+			}
+
+		case strings.HasPrefix(key, threadIssueKeyPrefix):
+			var threadIssue *ThreadIssue
+			if err = p.client.KV.Get(key, &threadIssue); err != nil {
+				return errors.Wrap(err, "failed to load a thread issue")
+			}
+			if threadIssue != nil && threadIssue.InstanceID == instanceID {
+				toDelete = append(toDelete, key)
+			}
+
+		case strings.HasPrefix(key, "ticket_post_id_"):
+			threadRootKeys = append(threadRootKeys, key)
+		}
+	}
+
+	for _, key := range threadRootKeys {
+		i := strings.LastIndex(key, "_channel_id_")
+		if i < 0 || !channelIDs[key[i+len("_channel_id_"):]] {
+			continue
+		}
+		ok, err := p.isThreadRootOfInstance(key, instance)
+		if err != nil {
+			return err
+		}
+		if ok {
+			toDelete = append(toDelete, key)
+		}
+	}
+
+	toDelete = append(toDelete, instanceArchiveKey(instanceID))
+	for _, key := range toDelete {
+		if err = p.client.KV.Delete(key); err != nil {
+			return errors.Wrapf(err, "failed to delete %s", key)
+		}
+	}
+	if err = p.deleteInstanceScopedKeys(instanceID); err != nil {
+		return err
+	}
+
+	p.teamFieldCacheLock.Lock()
+	delete(p.teamFieldCache, instanceID)
+	p.teamFieldCacheLock.Unlock()
+	p.threadIssueCache.clear()
+	return nil
+}
+
+// isThreadRootOfInstance returns true if the thread root or issue card of a
+// ticketRootPostIDKey is a post of the instance, as the key only has the
+// issue and channel IDs. Posts made before postInstanceIDProp are matched by
+// the links to the instance, and the keys of deleted posts are of no use.
+func (p *Plugin) isThreadRootOfInstance(key string, instance Instance) (bool, error) {
+	var postID string
+	if err := p.client.KV.Get(key, &postID); err != nil {
+		return false, errors.Wrap(err, "failed to load a thread root")
+	}
+	if postID == "" {
+		return true, nil
+	}
+	post, err := p.client.Post.GetPost(postID)
+	if err != nil || post.DeleteAt != 0 {
+		return true, nil
+	}
+
+	if id, ok := post.GetProp(postInstanceIDProp).(string); ok {
+		return id == instance.GetID().String(), nil
+	}
+	baseURL := instance.GetJiraBaseURL()
+	if strings.Contains(post.Message, baseURL) {
+		return true, nil
+	}
+	for _, attachment := range post.Attachments() {
+		if strings.Contains(attachment.TitleLink, baseURL) || strings.Contains(attachment.Text, baseURL) || strings.Contains(attachment.Pretext, baseURL) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// restoreInstance restores the archived data of a reinstalled instance. The
+// data that was set up since the reinstall is kept, and the names of the
+// restored and kept keys are returned.
+func (p *Plugin) restoreInstance(instanceID types.ID) (restored, kept []string, err error) {
+	archive, err := p.loadInstanceArchive(instanceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if archive == nil {
+		return nil, nil, errors.Errorf("no archive of %s was found, it may have expired after %d days", instanceID, int(instanceArchiveRetention.Hours()/24))
+	}
+
+	instance, err := p.instanceStore.LoadInstance(instanceID)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "%s must be installed again before it can be restored", instanceID)
+	}
+
+	for _, key := range instanceScopedKeys {
+		data, ok := archive.Data[key]
+		if !ok {
+			continue
+		}
+		kvKey := keyWithInstanceID(instanceID, types.ID(key))
+		var current []byte
+		if err = p.client.KV.Get(kvKey, &current); err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to load %s", key)
+		}
+		if hasInstanceData(key, current) {
+			kept = append(kept, key)
+			continue
+		}
+		if _, err = p.client.KV.Set(kvKey, []byte(data)); err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to restore %s", key)
+		}
+		restored = append(restored, key)
+	}
+
+	p.mentionMappingsCache.delete(instanceID.String())
+
+	if archive.Alias != "" && instance.Common().Alias == "" {
+		err = UpdateInstances(p.instanceStore, func(instances *Instances) error {
+			if unique, _ := instances.isAliasUnique(instanceID, archive.Alias); !unique {
+				return nil
+			}
+			instance.Common().Alias = archive.Alias
+			instances.Set(instance.Common())
+			return p.instanceStore.StoreInstance(instance)
+		})
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed to restore the alias")
+		}
+		if instance.Common().Alias == archive.Alias {
+			restored = append(restored, "alias")
+		}
+	}
+
+	if err = p.client.KV.Delete(instanceArchiveKey(instanceID)); err != nil {
+		p.client.Log.Warn("Failed to delete the restored instance archive", "instance", instanceID, "error", err.Error())
+	}
+	return restored, kept, nil
+}
+
+// hasInstanceData returns true if the stored data of a key is not empty, as
+// subscriptions are stored even without any.
+func hasInstanceData(key string, data []byte) bool {
+	if len(data) == 0 || string(data) == "null" || string(data) == "[]" {
+		return false
+	}
+	if key != JiraSubscriptionsKey {
+		return true
+	}
+	subs, err := SubscriptionsFromJSON(data, "")
+	if err != nil {
+		return true
+	}
+	return len(subs.Channel.ByID) > 0 || (subs.Instance != nil && len(subs.Instance.ByID) > 0)
+}
+
+func executeInstanceRestore(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	authorized, err := authorizedSysAdmin(p, header.UserId)
+	if err != nil {
+		return p.response(header, err.Error())
+	}
+	if !authorized {
+		return p.responsef(header, "`/jira instance restore` can only be run by a system administrator.")
+	}
+	if len(args) != 1 {
+		return p.responsef(header, "Please specify the Jira URL of the uninstalled instance, in the form `/jira instance restore <jiraURL>`.")
+	}
+
+	id, err := utils.NormalizeJiraURL(args[0])
+	if err != nil {
+		return p.response(header, err.Error())
+	}
+
+	restored, kept, err := p.restoreInstance(types.ID(id))
+	if err != nil {
+		return p.responsef(header, "Failed to restore %s. Error: %v.", id, err)
+	}
+	p.audit(header.UserId, AuditInstanceRestore, types.ID(id), id, strings.Join(restored, ", "))
+
+	msg := fmt.Sprintf("Restored %s: %s.", id, describeInstanceKeys(restored))
+	if len(kept) > 0 {
+		msg += fmt.Sprintf(" Kept the %s set up since the reinstall.", describeInstanceKeys(kept))
+	}
+	return p.response(header, msg)
+}
+
+func describeInstanceKeys(keys []string) string {
+	if len(keys) == 0 {
+		return "nothing"
+	}
+	names := map[string]string{
+		JiraSubscriptionsKey:       "subscriptions",
+		templateKey:                "subscription templates",
+		mentionMappingsKey:         "mention mappings",
+		subscriptionPermissionsKey: "subscription permissions",
+		incidentRulesKey:           "incident rules",
+		reminderRulesKey:           "reminder rules",
+	}
+	var result []string
+	for _, key := range keys {
+		if name, ok := names[key]; ok {
+			key = name
+		}
+		result = append(result, key)
+	}
+	return strings.Join(result, ", ")
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// mockKVMap makes the KV store of the API a map.
+func mockKVMap(api *plugintest.API, kv map[string][]byte) {
+	api.On("KVGet", mock.AnythingOfType("string")).Return(func(key string) ([]byte, *model.AppError) {
+		return kv[key], nil
+	})
+	api.On("KVSetWithOptions", mock.AnythingOfType("string"), mock.Anything, mock.Anything).Return(
+		func(key string, value []byte, _ model.PluginKVSetOptions) (bool, *model.AppError) {
+			if value == nil {
+				delete(kv, key)
+			} else {
+				kv[key] = value
+			}
+			return true, nil
+		})
+	api.On("KVDelete", mock.AnythingOfType("string")).Return(func(key string) *model.AppError {
+		delete(kv, key)
+		return nil
+	}).Maybe()
+}
+
+func TestArchiveAndRestoreInstance(t *testing.T) {
+	api := &plugintest.API{}
+	p := setupTestPlugin(api)
+	kv := map[string][]byte{}
+	mockKVMap(api, kv)
+
+	subs := NewSubscriptions()
+	subs.Channel.add(&ChannelSubscription{ID: "sub1", ChannelID: "channel1", Name: "Bugs"})
+	subsData, err := json.Marshal(subs)
+	require.NoError(t, err)
+	subsKey := keyWithInstanceID(testInstance1.InstanceID, JiraSubscriptionsKey)
+	mentionsKey := keyWithInstanceID(testInstance1.InstanceID, mentionMappingsKey)
+	kv[subsKey] = subsData
+	kv[mentionsKey] = []byte(`[{"field":"team","value":"Payments","mention":"payments"}]`)
+
+	require.NoError(t, p.archiveInstance(testInstance1))
+	require.Contains(t, kv, instanceArchiveKey(testInstance1.InstanceID))
+	// The live keys are only in the archive
+	assert.NotContains(t, kv, subsKey)
+	assert.NotContains(t, kv, mentionsKey)
+
+	// Mention mappings were set up after the reinstall, which are kept
+	kv[mentionsKey] = []byte(`[{"field":"team","value":"Billing","mention":"billing"}]`)
+
+	restored, kept, err := p.restoreInstance(testInstance1.InstanceID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{JiraSubscriptionsKey}, restored)
+	assert.Equal(t, []string{mentionMappingsKey}, kept)
+	assert.Equal(t, subsData, kv[subsKey])
+	assert.Contains(t, string(kv[mentionsKey]), "Billing")
+	assert.NotContains(t, kv, instanceArchiveKey(testInstance1.InstanceID))
+
+	_, _, err = p.restoreInstance(testInstance1.InstanceID)
+	assert.Error(t, err)
+}
+
+func TestPurgeInstanceData(t *testing.T) {
+	api := &plugintest.API{}
+	p := setupTestPlugin(api)
+	p.threadIssueCache = newExpiringCache[*ThreadIssue](threadIssueCacheTTL)
+	kv := map[string][]byte{}
+	mockKVMap(api, kv)
+	api.On("KVList", 0, listPerPage).Return(func(page, perPage int) ([]string, *model.AppError) {
+		var keys []string
+		for key := range kv {
+			keys = append(keys, key)
+		}
+		return keys, nil
+	})
+	posts := map[string]*model.Post{
+		"post1": {Id: "post1", Props: model.StringInterface{postInstanceIDProp: testInstance1.InstanceID.String()}},
+		"post3": {Id: "post3", Props: model.StringInterface{postInstanceIDProp: testInstance2.InstanceID.String()}},
+		"post4": {Id: "post4", Message: "[TEST-1](" + testInstance1.GetURL() + "/browse/TEST-1) was updated"},
+		"post5": {Id: "post5", Message: "[TEST-2](" + testInstance2.GetURL() + "/browse/TEST-2) was updated"},
+	}
+	api.On("GetPost", mock.AnythingOfType("string")).Return(func(postID string) (*model.Post, *model.AppError) {
+		if post, ok := posts[postID]; ok {
+			return post.Clone(), nil
+		}
+		return nil, &model.AppError{Message: "not found"}
+	})
+
+	subs := NewSubscriptions()
+	subs.Channel.add(&ChannelSubscription{ID: "sub1", ChannelID: "channel1", Name: "Bugs"})
+	subsData, err := json.Marshal(subs)
+	require.NoError(t, err)
+	subsKey := keyWithInstanceID(testInstance1.InstanceID, JiraSubscriptionsKey)
+	kv[subsKey] = subsData
+	kv[instanceArchiveKey(testInstance1.InstanceID)] = []byte(`{}`)
+	kv[incidentChannelKey(testInstance1.InstanceID, "10002")] = []byte(`"incident1"`)
+	kv[reminderKey(testInstance1.InstanceID, "channel1_in review", "TEST-1")] = []byte(`1`)
+	kv[instanceKey(slaBreachKeyPrefix, testInstance1.InstanceID, "10001_ttr_1")] = []byte(`1`)
+	kv[hashkey(channelDefaultsKeyPrefix, "channel1")] = []byte(`{"instance_id":"` + testInstance1.InstanceID.String() + `","project_key":"TEST"}`)
+	kv[hashkey(threadIssueKeyPrefix, "root1")] = []byte(`{"instance_id":"` + testInstance1.InstanceID.String() + `","issue_key":"TEST-1"}`)
+	kv["ticket_post_id_10001_channel_id_channel1"] = []byte(`"post1"`)
+	kv["ticket_post_id_10002_channel_id_incident1"] = []byte(`"deleted"`)
+	kv["ticket_post_id_10003_channel_id_channel1"] = []byte(`"post4"`)
+
+	// The data of the other instance is kept, even in the same channels
+	kept := map[string][]byte{
+		reminderKey(testInstance2.InstanceID, "channel1_in review", "TEST-1"): []byte(`1`),
+		hashkey(channelDefaultsKeyPrefix, "channel2"):                         []byte(`{"instance_id":"` + testInstance2.InstanceID.String() + `","project_key":"TEST"}`),
+		hashkey(threadIssueKeyPrefix, "root2"):                                []byte(`{"instance_id":"` + testInstance2.InstanceID.String() + `","issue_key":"TEST-1"}`),
+		"ticket_post_id_20001_channel_id_channel1":                            []byte(`"post3"`),
+		"ticket_post_id_20002_channel_id_channel1":                            []byte(`"post5"`),
+		"ticket_post_id_10001_channel_id_channel2":                            []byte(`"post2"`),
+	}
+	for key, value := range kept {
+		kv[key] = value
+	}
+
+	require.NoError(t, p.purgeInstanceData(testInstance1))
+	assert.Equal(t, kept, kv)
+}
+
+func TestHasInstanceData(t *testing.T) {
+	empty, err := json.Marshal(NewSubscriptions())
+	require.NoError(t, err)
+	subs := NewSubscriptions()
+	subs.Channel.add(&ChannelSubscription{ID: "sub1", ChannelID: "channel1"})
+	notEmpty, err := json.Marshal(subs)
+	require.NoError(t, err)
+
+	assert.False(t, hasInstanceData(JiraSubscriptionsKey, nil))
+	assert.False(t, hasInstanceData(JiraSubscriptionsKey, empty))
+	assert.True(t, hasInstanceData(JiraSubscriptionsKey, notEmpty))
+	assert.False(t, hasInstanceData(mentionMappingsKey, []byte("null")))
+	assert.True(t, hasInstanceData(mentionMappingsKey, []byte(`[{"field":"team"}]`)))
+}
@@ -0,0 +1,357 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/mattermost/mattermost-plugin-jira/server/utils"
+	"github.com/mattermost/mattermost-plugin-jira/server/utils/types"
+)
+
+const (
+	instanceBotUsernamePrefix = botUserName + "-"
+	instanceBotIconMaxSize    = 2 * 1024 * 1024
+	instanceBotIconTimeout    = 10 * time.Second
+
+	// Everything posted for an instance looks up its bot
+	botUserIDCacheTTL = time.Minute
+)
+
+var invalidUsernameChars = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// getBotUserID returns the user ID of the bot that posts for the instance,
+// the dedicated bot of the instance if it has one.
+func (p *Plugin) getBotUserID(instanceID types.ID) string {
+	if instanceID == "" || p.instanceStore == nil {
+		return p.getUserID()
+	}
+	botUserID, ok := p.botUserIDCache.get(instanceID.String())
+	if !ok {
+		instances, err := p.instanceStore.LoadInstances()
+		if err != nil {
+			return p.getUserID()
+		}
+		if instances.Contains(instanceID) {
+			botUserID = instances.Get(instanceID).BotUserID
+		}
+		p.botUserIDCache.set(instanceID.String(), botUserID)
+	}
+	if botUserID != "" {
+		return botUserID
+	}
+	return p.getUserID()
+}
+
+// isBotUserID returns true if the user is the plugin bot, or the bot of the
+// instance.
+func (p *Plugin) isBotUserID(userID string, instanceID types.ID) bool {
+	return userID == p.getUserID() || userID == p.getBotUserID(instanceID)
+}
+
+// commandBotUserID returns the bot that responds to a command, the dedicated
+// bot of the instance the command is for, or of the default instance of the
+// user. Nothing is looked up when no instance has a dedicated bot.
+func (p *Plugin) commandBotUserID(mattermostUserID string, args []string) string {
+	if p.instanceStore == nil {
+		return ""
+	}
+	instances, err := p.instanceStore.LoadInstances()
+	if err != nil || !hasInstanceBots(instances) {
+		return ""
+	}
+	instanceURL, _, err := p.parseCommandFlagInstanceURL(args)
+	if err != nil {
+		return ""
+	}
+	instanceID := types.ID(instanceURL)
+	if instanceID == "" {
+		_, instanceID, err = p.ResolveUserInstanceURL(types.ID(mattermostUserID), "")
+		if err != nil {
+			return ""
+		}
+	}
+	if !instances.Contains(instanceID) {
+		return ""
+	}
+	return instances.Get(instanceID).BotUserID
+}
+
+func hasInstanceBots(instances *Instances) bool {
+	if instances.IsEmpty() {
+		return false
+	}
+	for _, id := range instances.IDs() {
+		if instances.Get(id).BotUserID != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// instanceBotNames returns the default username and display name of the bot
+// of an instance, from its alias or else its host name.
+func instanceBotNames(ic *InstanceCommon) (username, displayName string) {
+	name := ic.Alias
+	if name == "" {
+		name = ic.InstanceID.String()
+		if u, err := url.Parse(name); err == nil && u.Host != "" {
+			name = u.Host
+		}
+	}
+
+	slug := invalidUsernameChars.ReplaceAllString(strings.ToLower(name), "-")
+	slug = strings.Trim(slug, "-._")
+	username = instanceBotUsernamePrefix + slug
+	if len(username) > model.UserNameMaxLength {
+		username = strings.TrimRight(username[:model.UserNameMaxLength], "-._")
+	}
+	return username, botDisplayName + " (" + name + ")"
+}
+
+// ensureInstanceBot creates the bot of the instance, or reactivates it if it
+// was disabled. The bot is not created with EnsureBot, which only supports a
+// single bot per plugin.
+func (p *Plugin) ensureInstanceBot(ic *InstanceCommon, displayName string) (string, error) {
+	username, defaultDisplayName := instanceBotNames(ic)
+	if displayName == "" {
+		displayName = defaultDisplayName
+	}
+
+	user, err := p.client.User.GetByUsername(username)
+	if err == nil && user != nil {
+		if !user.IsBot {
+			return "", errors.Errorf("the username @%s is taken by a user who is not a bot", username)
+		}
+		bot, err := p.client.Bot.Get(user.Id, true)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to load the bot")
+		}
+		if bot.OwnerId != manifest.Id {
+			return "", errors.Errorf("the username @%s is taken by a bot of another integration", username)
+		}
+		if _, err = p.client.Bot.UpdateActive(user.Id, true); err != nil {
+			return "", errors.Wrap(err, "failed to activate the bot")
+		}
+		if _, err = p.client.Bot.Patch(user.Id, &model.BotPatch{DisplayName: &displayName}); err != nil {
+			return "", errors.Wrap(err, "failed to update the bot")
+		}
+		return user.Id, nil
+	}
+
+	bot := &model.Bot{
+		OwnerId:     manifest.Id,
+		Username:    username,
+		DisplayName: displayName,
+		Description: fmt.Sprintf("Created by the Jira Plugin for %s.", ic.InstanceID),
+	}
+	if err = p.client.Bot.Create(bot); err != nil {
+		return "", errors.Wrap(err, "failed to create the bot")
+	}
+
+	bundlePath, err := p.client.System.GetBundlePath()
+	if err != nil {
+		return bot.UserId, nil
+	}
+	image, err := os.ReadFile(filepath.Join(bundlePath, "assets", "profile.png"))
+	if err != nil {
+		return bot.UserId, nil
+	}
+	if err = p.client.User.SetProfileImage(bot.UserId, bytes.NewReader(image)); err != nil {
+		p.client.Log.Warn("Failed to set the profile image of the instance bot", "instance", ic.InstanceID, "error", err.Error())
+	}
+	return bot.UserId, nil
+}
+
+// checkIconAddress returns an error if the image of a bot would be
+// downloaded from an address of the Mattermost server or its network.
+func checkIconAddress(ip net.IP) error {
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return errors.Errorf("the image can not be downloaded from %s", ip)
+	}
+	return nil
+}
+
+// newInstanceBotIconClient returns the HTTP client that downloads the image
+// of a bot. It only connects to public addresses, checked once the host is
+// resolved, and only follows redirects to https.
+func newInstanceBotIconClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: instanceBotIconTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			return checkIconAddress(net.ParseIP(host))
+		},
+	}
+	return &http.Client{
+		Timeout:   instanceBotIconTimeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: instanceBotIconTimeout},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if req.URL.Scheme != "https" {
+				return errors.New("the image URL redirects to a URL that is not https")
+			}
+			if len(via) >= 10 {
+				return errors.New("the image URL redirects too many times")
+			}
+			return nil
+		},
+	}
+}
+
+// setInstanceBotIcon downloads an image and sets it as the profile image of
+// the bot. The image must be served over https from a public address.
+func (p *Plugin) setInstanceBotIcon(botUserID, iconURL string) error {
+	u, err := url.Parse(iconURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return errors.Errorf("%q is not a valid image URL, it must start with https://", iconURL)
+	}
+
+	resp, err := newInstanceBotIconClient().Get(u.String())
+	if err != nil {
+		return errors.Wrap(err, "failed to download the image")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("failed to download the image: %s", resp.Status)
+	}
+	if contentType := resp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "image/") {
+		return errors.Errorf("%q is not an image", contentType)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, instanceBotIconMaxSize+1))
+	if err != nil {
+		return errors.Wrap(err, "failed to download the image")
+	}
+	if len(data) > instanceBotIconMaxSize {
+		return errors.Errorf("the image is larger than %d MB", instanceBotIconMaxSize/1024/1024)
+	}
+	return p.client.User.SetProfileImage(botUserID, bytes.NewReader(data))
+}
+
+// storeInstanceBot stores the bot of the instance, in the instance and in the
+// list of instances.
+func (p *Plugin) storeInstanceBot(instance Instance, botUserID string) error {
+	defer p.botUserIDCache.delete(instance.GetID().String())
+	return UpdateInstances(p.instanceStore, func(instances *Instances) error {
+		instance.Common().BotUserID = botUserID
+		instances.Set(instance.Common())
+		return p.instanceStore.StoreInstance(instance)
+	})
+}
+
+func executeInstanceBot(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	authorized, err := authorizedSysAdmin(p, header.UserId)
+	if err != nil {
+		return p.responsef(header, "%v", err)
+	}
+	if !authorized {
+		return p.responsef(header, "`/jira instance bot` can only be run by a system administrator.")
+	}
+	if len(args) < 1 {
+		return p.responsef(header, "Please specify the instance, in the form `/jira instance bot <jiraURL> [enable|disable|name <display name>|icon <image URL>]`.")
+	}
+
+	instances, err := p.instanceStore.LoadInstances()
+	if err != nil {
+		return p.responsef(header, "Failed to load instances. Error: %v.", err)
+	}
+	var instanceID types.ID
+	if ic := instances.getByAlias(args[0]); ic != nil {
+		instanceID = ic.InstanceID
+	} else {
+		normalized, nerr := utils.NormalizeJiraURL(args[0])
+		if nerr != nil {
+			return p.response(header, nerr.Error())
+		}
+		instanceID = types.ID(normalized)
+	}
+	instance, err := p.instanceStore.LoadInstance(instanceID)
+	if err != nil {
+		return p.responsef(header, "Failed to load instance. Error: %v.", err)
+	}
+	ic := instance.Common()
+
+	action := ""
+	if len(args) > 1 {
+		action = args[1]
+	}
+	switch action {
+	case "":
+		if ic.BotUserID == "" {
+			return p.responsef(header, "%s posts as the @%s bot. Run `/jira instance bot %s enable` to give it a dedicated bot.", instanceID, botUserName, args[0])
+		}
+		bot, err := p.client.Bot.Get(ic.BotUserID, false)
+		if err != nil {
+			return p.responsef(header, "Failed to load the bot of %s. Error: %v.", instanceID, err)
+		}
+		return p.responsef(header, "%s posts as the @%s bot, named %q.", instanceID, bot.Username, bot.DisplayName)
+
+	case "enable":
+		botUserID, err := p.ensureInstanceBot(ic, strings.Join(args[2:], " "))
+		if err != nil {
+			return p.responsef(header, "Failed to set up the bot of %s. Error: %v.", instanceID, err)
+		}
+		if err = p.storeInstanceBot(instance, botUserID); err != nil {
+			return p.responsef(header, "Failed to save instance. Error: %v.", err)
+		}
+		p.audit(header.UserId, AuditInstanceBot, instanceID, instanceID.String(), "enable")
+		return p.responsef(header, "The notifications and responses of %s are now posted by its own bot. Use `name` and `icon` to change how it looks.", instanceID)
+
+	case "disable":
+		if ic.BotUserID == "" {
+			return p.responsef(header, "%s does not have a dedicated bot.", instanceID)
+		}
+		botUserID := ic.BotUserID
+		if err = p.storeInstanceBot(instance, ""); err != nil {
+			return p.responsef(header, "Failed to save instance. Error: %v.", err)
+		}
+		// The bot is deactivated rather than deleted, so its posts keep their author
+		if _, err = p.client.Bot.UpdateActive(botUserID, false); err != nil {
+			p.client.Log.Warn("Failed to deactivate the instance bot", "instance", instanceID, "error", err.Error())
+		}
+		p.audit(header.UserId, AuditInstanceBot, instanceID, instanceID.String(), "disable")
+		return p.responsef(header, "%s now posts as the @%s bot again.", instanceID, botUserName)
+
+	case "name", "icon":
+		if ic.BotUserID == "" {
+			return p.responsef(header, "%s does not have a dedicated bot. Run `/jira instance bot %s enable` first.", instanceID, args[0])
+		}
+		value := strings.Join(args[2:], " ")
+		if value == "" {
+			return p.responsef(header, "Please specify the %s of the bot.", action)
+		}
+		if action == "name" {
+			_, err = p.client.Bot.Patch(ic.BotUserID, &model.BotPatch{DisplayName: &value})
+		} else {
+			err = p.setInstanceBotIcon(ic.BotUserID, value)
+		}
+		if err != nil {
+			return p.responsef(header, "Failed to update the bot of %s. Error: %v.", instanceID, err)
+		}
+		p.audit(header.UserId, AuditInstanceBot, instanceID, instanceID.String(), action+": "+value)
+		return p.responsef(header, "Updated the %s of the bot of %s.", action, instanceID)
+	}
+
+	return p.responsef(header, "Unknown action `%s`, use `enable`, `disable`, `name` or `icon`.", action)
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstanceBotNames(t *testing.T) {
+	for name, tc := range map[string]struct {
+		ic                  InstanceCommon
+		expectedUsername    string
+		expectedDisplayName string
+	}{
+		"alias": {
+			ic:                  InstanceCommon{InstanceID: "https://partner.atlassian.net", Alias: "Partner Site"},
+			expectedUsername:    "jira-partner-site",
+			expectedDisplayName: "Jira (Partner Site)",
+		},
+		"host name": {
+			ic:                  InstanceCommon{InstanceID: "https://jira.example.com:8080"},
+			expectedUsername:    "jira-jira.example.com-8080",
+			expectedDisplayName: "Jira (jira.example.com:8080)",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			username, displayName := instanceBotNames(&tc.ic)
+			assert.Equal(t, tc.expectedUsername, username)
+			assert.Equal(t, tc.expectedDisplayName, displayName)
+			assert.True(t, model.IsValidUsername(username))
+		})
+	}
+}
+
+func TestGetBotUserID(t *testing.T) {
+	api := &plugintest.API{}
+	p := setupTestPlugin(api)
+	p.updateConfig(func(conf *config) {
+		conf.botUserID = "jira-bot"
+	})
+
+	assert.Equal(t, "jira-bot", p.getBotUserID(testInstance1.InstanceID))
+	assert.Equal(t, "", p.commandBotUserID("connected_user", []string{"view", "--instance", testInstance1.InstanceID.String()}))
+
+	p.instanceStore.(*mockInstanceStoreKV).Instances.Get(testInstance1.InstanceID).BotUserID = "instance-bot"
+	assert.Equal(t, "instance-bot", p.getBotUserID(testInstance1.InstanceID))
+	assert.Equal(t, "jira-bot", p.getBotUserID("https://unknown.example.com"))
+	assert.Equal(t, "jira-bot", p.getBotUserID(""))
+	assert.Equal(t, "instance-bot", p.commandBotUserID("connected_user", []string{"view", "--instance", testInstance1.InstanceID.String()}))
+
+	// The bot is cached, storeInstanceBot deletes the entry when it changes
+	p.botUserIDCache = newExpiringCache[string](botUserIDCacheTTL)
+	assert.Equal(t, "instance-bot", p.getBotUserID(testInstance1.InstanceID))
+	p.instanceStore.(*mockInstanceStoreKV).Instances.Get(testInstance1.InstanceID).BotUserID = ""
+	assert.Equal(t, "instance-bot", p.getBotUserID(testInstance1.InstanceID))
+	p.botUserIDCache.delete(testInstance1.InstanceID.String())
+	assert.Equal(t, "jira-bot", p.getBotUserID(testInstance1.InstanceID))
+}
+
+func TestEnsureInstanceBot(t *testing.T) {
+	ic := &InstanceCommon{InstanceID: "https://partner.atlassian.net", Alias: "partner"}
+
+	t.Run("new bot", func(t *testing.T) {
+		api := &plugintest.API{}
+		p := setupTestPlugin(api)
+		api.On("GetUserByUsername", "jira-partner").Return(nil, &model.AppError{Message: "not found"})
+		api.On("CreateBot", mock.MatchedBy(func(bot *model.Bot) bool {
+			return bot.Username == "jira-partner" && bot.DisplayName == "Jira (partner)" && bot.OwnerId == manifest.Id
+		})).Return(&model.Bot{UserId: "bot-id"}, nil)
+		api.On("GetBundlePath").Return("", &model.AppError{Message: "no bundle"})
+
+		botUserID, err := p.ensureInstanceBot(ic, "")
+		require.NoError(t, err)
+		assert.Equal(t, "bot-id", botUserID)
+	})
+
+	t.Run("reactivated bot", func(t *testing.T) {
+		api := &plugintest.API{}
+		p := setupTestPlugin(api)
+		displayName := "Partner Jira"
+		api.On("GetUserByUsername", "jira-partner").Return(&model.User{Id: "bot-id", IsBot: true}, nil)
+		api.On("GetBot", "bot-id", true).Return(&model.Bot{UserId: "bot-id", OwnerId: manifest.Id}, nil)
+		api.On("UpdateBotActive", "bot-id", true).Return(&model.Bot{UserId: "bot-id"}, nil)
+		api.On("PatchBot", "bot-id", &model.BotPatch{DisplayName: &displayName}).Return(&model.Bot{UserId: "bot-id"}, nil)
+
+		botUserID, err := p.ensureInstanceBot(ic, displayName)
+		require.NoError(t, err)
+		assert.Equal(t, "bot-id", botUserID)
+	})
+
+	t.Run("username taken", func(t *testing.T) {
+		api := &plugintest.API{}
+		p := setupTestPlugin(api)
+		api.On("GetUserByUsername", "jira-partner").Return(&model.User{Id: "user-id"}, nil)
+
+		_, err := p.ensureInstanceBot(ic, "")
+		assert.EqualError(t, err, "the username @jira-partner is taken by a user who is not a bot")
+	})
+}
+
+func TestExecuteInstanceBotNormalizesURL(t *testing.T) {
+	api := &plugintest.API{}
+	p := setupTestPlugin(api)
+	api.On("GetUser", mockUserIDSysAdmin).Return(&model.User{Id: mockUserIDSysAdmin, Roles: "system_admin system_user"}, nil)
+	api.On("SendEphemeralPost", mockUserIDSysAdmin, mock.MatchedBy(func(post *model.Post) bool {
+		return post.Message == mockInstance1URL+" posts as the @jira bot. Run `/jira instance bot jiraurl1.com/ enable` to give it a dedicated bot."
+	})).Return(&model.Post{}).Once()
+
+	executeInstanceBot(p, nil, &model.CommandArgs{UserId: mockUserIDSysAdmin}, "jiraurl1.com/")
+	api.AssertCalled(t, "SendEphemeralPost", mockUserIDSysAdmin, mock.Anything)
+}
+
+func TestCheckIconAddress(t *testing.T) {
+	for _, ip := range []string{"127.0.0.1", "::1", "10.0.0.5", "192.168.1.1", "172.16.0.1", "169.254.169.254", "fd00::1", "0.0.0.0"} {
+		assert.Error(t, checkIconAddress(net.ParseIP(ip)), ip)
+	}
+	assert.NoError(t, checkIconAddress(net.ParseIP("104.192.136.1")))
+}
+
+func TestSetInstanceBotIcon(t *testing.T) {
+	p := setupTestPlugin(&plugintest.API{})
+
+	err := p.setInstanceBotIcon("bot-id", "http://example.com/icon.png")
+	assert.EqualError(t, err, `"http://example.com/icon.png" is not a valid image URL, it must start with https://`)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the image was downloaded from a loopback address")
+	}))
+	defer server.Close()
+	err = p.setInstanceBotIcon("bot-id", server.URL+"/icon.png")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "the image can not be downloaded from 127.0.0.1")
+}
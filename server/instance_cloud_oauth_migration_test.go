@@ -450,7 +450,7 @@ func TestCloudOAuthMigration(t *testing.T) {
 
 				defer fakeJiraServer.Close()
 
-				_, _, err := p.installServerInstance(fakeJiraServer.URL)
+				_, _, err := p.installServerInstance(fakeJiraServer.URL, "")
 				require.NoError(t, err)
 
 				return fakeJiraServer.URL
@@ -488,7 +488,7 @@ func TestCloudOAuthMigration(t *testing.T) {
 				api.On("LogDebug", mock.MatchedBy(func(logMessage string) bool {
 					return strings.Contains(logMessage, "Stored: connection") && strings.Contains(logMessage, "someuserid")
 				})).Return(nil)
-				_, _, err := p.installServerInstance(fakeJiraServer.URL)
+				_, _, err := p.installServerInstance(fakeJiraServer.URL, "")
 				require.NoError(t, err)
 
 				api.On("LogDebug", mock.MatchedBy(func(logMessage string) bool {
@@ -0,0 +1,466 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+
+	"github.com/mattermost/mattermost-plugin-jira/server/utils"
+	"github.com/mattermost/mattermost-plugin-jira/server/utils/types"
+)
+
+// A progress message is posted every this many migrated users
+const migrateProgressUsers = 100
+
+// migratedIssueKeyPrefixes are the prefixes of the instanceKey keys that are
+// migrated, with the expiry they are stored again with, as the remaining
+// time to live of a key can not be read. Reminders get the longest snooze, so
+// that snoozed reminders are not sent early.
+var migratedIssueKeyPrefixes = map[string]time.Duration{
+	incidentChannelKeyPrefix: 0,
+	reminderKeyPrefix:        time.Duration(reminderSnoozeDays[len(reminderSnoozeDays)-1]) * 24 * time.Hour,
+	slaBreachKeyPrefix:       slaBreachDedupExpiry,
+	boardProjectsKeyPrefix:   0,
+}
+
+// instanceMigration re-keys the data of an instance whose URL, and so its
+// instance ID, has changed. With dryRun nothing is written, and the counts
+// are what would be migrated.
+type instanceMigration struct {
+	from     types.ID
+	to       types.ID
+	dryRun   bool
+	progress func(message string)
+
+	keys            []string
+	subscriptions   int
+	templates       int
+	channelDefaults int
+	issueKeys       []string
+	threadIssues    int
+	users           int
+	failedUsers     []string
+}
+
+func (m *instanceMigration) progressf(format string, args ...interface{}) {
+	if m.progress != nil {
+		m.progress(fmt.Sprintf(format, args...))
+	}
+}
+
+// migrateInstance moves the instance and all its data from the old to the
+// new instance ID. The instance record is swapped last, so that an
+// interrupted migration can be run again.
+func (p *Plugin) migrateInstance(m *instanceMigration) (Instance, error) {
+	if m.from == m.to {
+		return nil, errors.New("the old and new URLs are the same")
+	}
+	instances, err := p.instanceStore.LoadInstances()
+	if err != nil {
+		return nil, err
+	}
+	if !instances.Contains(m.from) {
+		return nil, errors.Errorf("instance %s is not installed", m.from)
+	}
+	if instances.Contains(m.to) {
+		return nil, errors.Errorf("instance %s is already installed", m.to)
+	}
+	instance, err := p.instanceStore.LoadInstance(m.from)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = p.migrateInstanceScopedKeys(m); err != nil {
+		return nil, err
+	}
+	if err = p.migrateChannelDefaults(m); err != nil {
+		return nil, err
+	}
+	if err = p.migrateIssueKeys(m); err != nil {
+		return nil, err
+	}
+	if err = p.migrateConnections(m); err != nil {
+		return nil, err
+	}
+	if m.dryRun {
+		return instance, nil
+	}
+
+	if err = p.migrateInstanceRecord(instance, m.to); err != nil {
+		return nil, err
+	}
+	for _, key := range instanceScopedKeys {
+		if err = p.client.KV.Delete(keyWithInstanceID(m.from, types.ID(key))); err != nil {
+			return nil, errors.Wrapf(err, "failed to delete %s of %s", key, m.from)
+		}
+	}
+	for _, key := range m.issueKeys {
+		if err = p.client.KV.Delete(key); err != nil {
+			return nil, errors.Wrapf(err, "failed to delete %s", key)
+		}
+	}
+	p.teamFieldCacheLock.Lock()
+	delete(p.teamFieldCache, m.from)
+	p.teamFieldCacheLock.Unlock()
+	p.mentionMappingsCache.delete(m.from.String())
+	p.botUserIDCache.delete(m.from.String())
+	p.botUserIDCache.delete(m.to.String())
+	p.threadIssueCache.clear()
+	return instance, nil
+}
+
+// migrateInstanceScopedKeys copies the subscriptions, templates and settings
+// to the new instance ID. The subscriptions and templates hold the instance
+// ID, which is rewritten.
+func (p *Plugin) migrateInstanceScopedKeys(m *instanceMigration) error {
+	for _, key := range instanceScopedKeys {
+		var data []byte
+		if err := p.client.KV.Get(keyWithInstanceID(m.from, types.ID(key)), &data); err != nil {
+			return errors.Wrapf(err, "failed to load %s", key)
+		}
+		if !hasInstanceData(key, data) {
+			continue
+		}
+		m.keys = append(m.keys, key)
+
+		var value interface{} = data
+		switch key {
+		case JiraSubscriptionsKey:
+			subs, err := SubscriptionsFromJSON(data, m.to)
+			if err != nil {
+				return errors.Wrap(err, "failed to read the subscriptions")
+			}
+			for id, sub := range subs.Instance.ByID {
+				sub.InstanceID = m.to
+				subs.Instance.ByID[id] = sub
+			}
+			m.subscriptions = len(subs.Channel.ByID) + len(subs.Instance.ByID)
+			value = subs
+		case templateKey:
+			templates, err := SubscriptionTemplatesFromJSON(data)
+			if err != nil {
+				return errors.Wrap(err, "failed to read the subscription templates")
+			}
+			for id, template := range templates.Templates.ByID {
+				template.InstanceID = m.to
+				templates.Templates.ByID[id] = template
+			}
+			for _, collection := range templates.Templates.ByProjectID {
+				for _, template := range collection {
+					template.InstanceID = m.to
+				}
+			}
+			m.templates = len(templates.Templates.ByID)
+			value = templates
+		}
+
+		if m.dryRun {
+			continue
+		}
+		if _, err := p.client.KV.Set(keyWithInstanceID(m.to, types.ID(key)), value); err != nil {
+			return errors.Wrapf(err, "failed to store %s", key)
+		}
+	}
+	return nil
+}
+
+// migrateChannelDefaults points the channel defaults of the instance to the
+// new instance ID.
+func (p *Plugin) migrateChannelDefaults(m *instanceMigration) error {
+	keys, err := p.listKeysWithPrefix(channelDefaultsKeyPrefix)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		var defaults *ChannelDefaults
+		if err := p.client.KV.Get(key, &defaults); err != nil {
+			return errors.Wrap(err, "failed to load channel defaults")
+		}
+		if defaults == nil || defaults.InstanceID != m.from {
+			continue
+		}
+		m.channelDefaults++
+		if m.dryRun {
+			continue
+		}
+		defaults.InstanceID = m.to
+		if _, err := p.client.KV.Set(key, defaults); err != nil {
+			return errors.Wrap(err, "failed to store channel defaults")
+		}
+	}
+	return nil
+}
+
+// migrateIssueKeys copies the markers of reminders, incident channels and SLA
+// breaches to the new instance ID, and points the threads of issues of the
+// instance to it. The old markers are deleted once the instance is migrated.
+// The markers of incident channels that are still being created are not
+// copied.
+func (p *Plugin) migrateIssueKeys(m *instanceMigration) error {
+	keys, err := p.listKeysWithPrefix("")
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if strings.HasPrefix(key, threadIssueKeyPrefix) {
+			if err = p.migrateThreadIssue(m, key); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for prefix, expiry := range migratedIssueKeyPrefixes {
+			from := instanceKeyPrefix(prefix, m.from)
+			if !strings.HasPrefix(key, from) {
+				continue
+			}
+			var data []byte
+			if err = p.client.KV.Get(key, &data); err != nil {
+				return errors.Wrapf(err, "failed to load %s", key)
+			}
+			m.issueKeys = append(m.issueKeys, key)
+			if m.dryRun || len(data) == 0 || (prefix == incidentChannelKeyPrefix && string(data) == `"`+incidentChannelCreating+`"`) {
+				break
+			}
+
+			var options []pluginapi.KVSetOption
+			if expiry > 0 {
+				options = append(options, pluginapi.SetExpiry(expiry))
+			}
+			to := instanceKeyPrefix(prefix, m.to) + strings.TrimPrefix(key, from)
+			if _, err = p.client.KV.Set(to, data, options...); err != nil {
+				return errors.Wrapf(err, "failed to store %s", to)
+			}
+			break
+		}
+	}
+	return nil
+}
+
+func (p *Plugin) migrateThreadIssue(m *instanceMigration, key string) error {
+	var threadIssue *ThreadIssue
+	if err := p.client.KV.Get(key, &threadIssue); err != nil {
+		return errors.Wrap(err, "failed to load a thread issue")
+	}
+	if threadIssue == nil || threadIssue.InstanceID != m.from {
+		return nil
+	}
+	m.threadIssues++
+	if m.dryRun {
+		return nil
+	}
+	threadIssue.InstanceID = m.to
+	if _, err := p.client.KV.Set(key, threadIssue); err != nil {
+		return errors.Wrap(err, "failed to store a thread issue")
+	}
+	return nil
+}
+
+// migrateConnections moves the connections of the users to the new instance
+// ID, and updates their connected instances and default instance. A user
+// whose connection fails to migrate is reported, and will need to connect
+// again.
+func (p *Plugin) migrateConnections(m *instanceMigration) error {
+	return p.userStore.MapUsers(func(user *User) error {
+		if !user.ConnectedInstances.Contains(m.from) {
+			return nil
+		}
+		m.users++
+		if m.users%migrateProgressUsers == 0 {
+			m.progressf("Processed %d users...", m.users)
+		}
+		if m.dryRun {
+			return nil
+		}
+
+		if err := p.migrateConnection(user, m.from, m.to); err != nil {
+			p.client.Log.Warn("Failed to migrate the connection of a user", "user", user.MattermostUserID, "instance", m.from, "error", err.Error())
+			m.failedUsers = append(m.failedUsers, user.MattermostUserID.String())
+		}
+		return nil
+	})
+}
+
+func (p *Plugin) migrateConnection(user *User, from, to types.ID) error {
+	connection, err := p.userStore.LoadConnection(from, user.MattermostUserID)
+	if err != nil {
+		return err
+	}
+	if err = p.userStore.StoreConnection(to, user.MattermostUserID, connection); err != nil {
+		return err
+	}
+
+	ic := *user.ConnectedInstances.Get(from)
+	ic.InstanceID = to
+	user.ConnectedInstances.Delete(from)
+	user.ConnectedInstances.Set(&ic)
+	if user.DefaultInstanceID == from {
+		user.DefaultInstanceID = to
+	}
+	if err = p.userStore.StoreUser(user); err != nil {
+		return err
+	}
+
+	return p.userStore.DeleteConnection(from, user.MattermostUserID)
+}
+
+// migrateInstanceRecord stores the instance under the new instance ID, with
+// its URLs updated, and replaces it in the list of instances.
+func (p *Plugin) migrateInstanceRecord(instance Instance, to types.ID) error {
+	from := instance.GetID()
+	instance.Common().InstanceID = to
+
+	switch instance := instance.(type) {
+	case *serverInstance:
+		instance.DeprecatedJIRAServerURL = ""
+	case *cloudOAuthInstance:
+		instance.JiraBaseURL = to.String()
+		if instance.JWTInstance != nil {
+			instance.JWTInstance.InstanceID = to
+			if err := setCloudInstanceBaseURL(instance.JWTInstance, to); err != nil {
+				return err
+			}
+		}
+	case *cloudInstance:
+		if err := setCloudInstanceBaseURL(instance, to); err != nil {
+			return err
+		}
+	}
+
+	return UpdateInstances(p.instanceStore, func(instances *Instances) error {
+		if err := p.instanceStore.StoreInstance(instance); err != nil {
+			return err
+		}
+		instances.Delete(from)
+		instances.Set(instance.Common())
+		if err := p.instanceStore.DeleteInstance(from); err != nil {
+			p.client.Log.Warn("Failed to delete the migrated instance", "instance", from, "error", err.Error())
+		}
+		return nil
+	})
+}
+
+// setCloudInstanceBaseURL updates the base URL in the stored Atlassian
+// security context, keeping the fields that are not known here.
+func setCloudInstanceBaseURL(ci *cloudInstance, baseURL types.ID) error {
+	if ci.AtlassianSecurityContext != nil {
+		ci.AtlassianSecurityContext.BaseURL = baseURL.String()
+	}
+	if ci.RawAtlassianSecurityContext == "" {
+		return nil
+	}
+	asc := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(ci.RawAtlassianSecurityContext), &asc); err != nil {
+		return errors.Wrap(err, "failed to read the Atlassian security context")
+	}
+	asc["baseUrl"] = baseURL.String()
+	data, err := json.Marshal(asc)
+	if err != nil {
+		return err
+	}
+	ci.RawAtlassianSecurityContext = string(data)
+	return nil
+}
+
+func executeInstanceMigrate(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	authorized, err := authorizedSysAdmin(p, header.UserId)
+	if err != nil {
+		return p.response(header, err.Error())
+	}
+	if !authorized {
+		return p.responsef(header, "`/jira instance migrate` can only be run by a system administrator.")
+	}
+
+	dryRun := false
+	var urls []string
+	for _, arg := range args {
+		if arg == "--dry-run" {
+			dryRun = true
+			continue
+		}
+		urls = append(urls, arg)
+	}
+	if len(urls) != 2 {
+		return p.responsef(header, "Please specify the old and new Jira URLs, in the form `/jira instance migrate <old-url> <new-url> [--dry-run]`.")
+	}
+
+	instances, err := p.instanceStore.LoadInstances()
+	if err != nil {
+		return p.responsef(header, "Failed to load instances. Error: %v.", err)
+	}
+	var from types.ID
+	if ic := instances.getByAlias(urls[0]); ic != nil {
+		from = ic.InstanceID
+	} else {
+		normalized, nerr := utils.NormalizeJiraURL(urls[0])
+		if nerr != nil {
+			return p.response(header, nerr.Error())
+		}
+		from = types.ID(normalized)
+	}
+	to, err := utils.NormalizeJiraURL(urls[1])
+	if err != nil {
+		return p.response(header, err.Error())
+	}
+
+	m := &instanceMigration{
+		from:   from,
+		to:     types.ID(to),
+		dryRun: dryRun,
+		progress: func(message string) {
+			p.client.Post.SendEphemeralPost(header.UserId, &model.Post{
+				UserId:    p.getBotUserID(from),
+				ChannelId: header.ChannelId,
+				Message:   message,
+			})
+		},
+	}
+	if !dryRun {
+		m.progressf("Migrating %s to %s...", from, to)
+	}
+
+	instance, err := p.migrateInstance(m)
+	if err != nil {
+		return p.responsef(header, "Failed to migrate %s to %s. Error: %v.", from, to, err)
+	}
+
+	summary := fmt.Sprintf("%s, %d subscriptions, %d subscription templates, %d channel defaults, %d issue threads, %d reminder, incident and SLA markers, and the connections of %d users",
+		describeInstanceKeys(m.keys), m.subscriptions, m.templates, m.channelDefaults, m.threadIssues, len(m.issueKeys), m.users)
+	if dryRun {
+		return p.responsef(header, "Dry run: migrating %s to %s would move %s. Nothing was changed.", from, to, summary)
+	}
+	p.audit(header.UserId, AuditInstanceMigrate, m.to, from.String(), "to: "+to)
+
+	msg := fmt.Sprintf("Migrated %s to %s: %s.\n", from, to, summary)
+	if len(m.failedUsers) > 0 {
+		msg += fmt.Sprintf("The connections of %d users failed to migrate, they will need to run `/jira connect` again.\n", len(m.failedUsers))
+	}
+	switch instance.(type) {
+	case *cloudInstance, *cloudOAuthInstance:
+		go p.SetupAutolink(NewInstances(instance.Common()))
+		msg += fmt.Sprintf("Autolinks for %s are being added. The Autolink plugin can not remove links, please remove the ones of %s with `/autolink delete`.\n", to, from)
+	}
+
+	subWebhookURL, legacyWebhookURL, err := p.GetWebhookURL(to, header.TeamId, header.ChannelId)
+	if err != nil {
+		return p.response(header, msg+err.Error())
+	}
+	msg += "Please update the webhooks in [Jira System Settings/Webhooks](" + instance.GetManageWebhooksURL() + ") to the new URLs.\n" +
+		"##### Subscriptions webhook\n" +
+		"   - `" + subWebhookURL + "`\n" +
+		"##### Legacy webhook for this channel\n" +
+		"   - `" + legacyWebhookURL + "`"
+	return p.response(header, msg)
+}
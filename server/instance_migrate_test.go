@@ -0,0 +1,161 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-jira/server/utils/types"
+)
+
+func setupInstanceMigration(t *testing.T) (*Plugin, map[string][]byte) {
+	api := &plugintest.API{}
+	p := setupTestPlugin(api)
+	kv := map[string][]byte{}
+	mockKVMap(api, kv)
+	api.On("KVList", 0, listPerPage).Return(func(page, perPage int) ([]string, *model.AppError) {
+		var keys []string
+		for key := range kv {
+			keys = append(keys, key)
+		}
+		return keys, nil
+	})
+	store := NewStore(p)
+	p.instanceStore = store
+	p.userStore = store
+
+	instance := &serverInstance{InstanceCommon: newInstanceCommon(p, ServerInstanceType, "https://old.example.com")}
+	require.NoError(t, store.StoreInstance(instance))
+	require.NoError(t, store.StoreInstances(NewInstances(instance.Common())))
+
+	subs := NewSubscriptions()
+	subs.Channel.add(&ChannelSubscription{ID: "sub1", ChannelID: "channel1", Name: "Bugs", InstanceID: "https://old.example.com"})
+	subs.Instance.add(&InstanceSubscription{ID: "isub1", ChannelID: "channel1", Name: "Releases", InstanceID: "https://old.example.com"})
+	_, err := p.client.KV.Set(keyWithInstanceID("https://old.example.com", JiraSubscriptionsKey), subs)
+	require.NoError(t, err)
+	require.NoError(t, p.storeChannelDefaults("channel1", &ChannelDefaults{InstanceID: "https://old.example.com", ProjectKey: "BUG"}))
+	require.NoError(t, p.storeChannelDefaults("channel2", &ChannelDefaults{InstanceID: "https://other.example.com", ProjectKey: "OPS"}))
+
+	kv[reminderKey("https://old.example.com", "channel1_in review", "BUG-1")] = []byte(`true`)
+	kv[incidentChannelKey("https://old.example.com", "10001")] = []byte(`"incident1"`)
+	kv[incidentChannelKey("https://old.example.com", "10002")] = []byte(`"creating"`)
+	kv[hashkey(threadIssueKeyPrefix, "root1")] = []byte(`{"instance_id":"https://old.example.com","issue_key":"BUG-1"}`)
+	kv[hashkey(threadIssueKeyPrefix, "root2")] = []byte(`{"instance_id":"https://other.example.com","issue_key":"OPS-1"}`)
+
+	user := NewUser("jane")
+	user.ConnectedInstances.Set(instance.Common())
+	user.DefaultInstanceID = "https://old.example.com"
+	require.NoError(t, store.StoreUser(user))
+	require.NoError(t, store.StoreConnection("https://old.example.com", "jane", &Connection{User: jira.User{AccountID: "jira-jane", DisplayName: "Jane"}}))
+	return p, kv
+}
+
+func TestMigrateInstance(t *testing.T) {
+	p, kv := setupInstanceMigration(t)
+	from, to := types.ID("https://old.example.com"), types.ID("https://new.example.com")
+
+	m := &instanceMigration{from: from, to: to}
+	instance, err := p.migrateInstance(m)
+	require.NoError(t, err)
+	assert.Equal(t, to, instance.GetID())
+	assert.Equal(t, []string{JiraSubscriptionsKey}, m.keys)
+	assert.Equal(t, 2, m.subscriptions)
+	assert.Equal(t, 1, m.channelDefaults)
+	assert.Equal(t, 1, m.threadIssues)
+	assert.Len(t, m.issueKeys, 3)
+	assert.Equal(t, 1, m.users)
+	assert.Empty(t, m.failedUsers)
+
+	instances, err := p.instanceStore.LoadInstances()
+	require.NoError(t, err)
+	assert.Equal(t, []types.ID{to}, instances.IDs())
+	_, err = p.instanceStore.LoadInstance(from)
+	assert.Error(t, err)
+	_, err = p.instanceStore.LoadInstance(to)
+	assert.NoError(t, err)
+
+	assert.NotContains(t, kv, keyWithInstanceID(from, JiraSubscriptionsKey))
+	subs, err := p.getSubscriptions(to)
+	require.NoError(t, err)
+	assert.Equal(t, to, subs.Channel.ByID["sub1"].InstanceID)
+	assert.Equal(t, to, subs.Instance.ByID["isub1"].InstanceID)
+
+	assert.NotContains(t, kv, reminderKey(from, "channel1_in review", "BUG-1"))
+	assert.Equal(t, []byte(`true`), kv[reminderKey(to, "channel1_in review", "BUG-1")])
+	assert.NotContains(t, kv, incidentChannelKey(from, "10001"))
+	assert.Equal(t, []byte(`"incident1"`), kv[incidentChannelKey(to, "10001")])
+	assert.NotContains(t, kv, incidentChannelKey(from, "10002"))
+	assert.NotContains(t, kv, incidentChannelKey(to, "10002"))
+	threadIssue, err := p.loadThreadIssue("root1")
+	require.NoError(t, err)
+	assert.Equal(t, to, threadIssue.InstanceID)
+	threadIssue, err = p.loadThreadIssue("root2")
+	require.NoError(t, err)
+	assert.Equal(t, types.ID("https://other.example.com"), threadIssue.InstanceID)
+
+	defaults, err := p.loadChannelDefaults("channel1")
+	require.NoError(t, err)
+	assert.Equal(t, to, defaults.InstanceID)
+	defaults, err = p.loadChannelDefaults("channel2")
+	require.NoError(t, err)
+	assert.Equal(t, types.ID("https://other.example.com"), defaults.InstanceID)
+
+	user, err := p.userStore.LoadUser("jane")
+	require.NoError(t, err)
+	assert.Equal(t, []types.ID{to}, user.ConnectedInstances.IDs())
+	assert.Equal(t, to, user.DefaultInstanceID)
+	connection, err := p.userStore.LoadConnection(to, "jane")
+	require.NoError(t, err)
+	assert.Equal(t, "Jane", connection.DisplayName)
+	mattermostUserID, err := p.userStore.LoadMattermostUserID(to, "jira-jane")
+	require.NoError(t, err)
+	assert.Equal(t, types.ID("jane"), mattermostUserID)
+	assert.NotContains(t, kv, keyWithInstanceID(from, "jane"))
+	assert.NotContains(t, kv, keyWithInstanceID(from, "jira-jane"))
+
+	_, err = p.migrateInstance(&instanceMigration{from: from, to: to})
+	assert.EqualError(t, err, "instance https://old.example.com is not installed")
+}
+
+func TestMigrateInstanceDryRun(t *testing.T) {
+	p, kv := setupInstanceMigration(t)
+	before := map[string][]byte{}
+	for key, value := range kv {
+		before[key] = value
+	}
+
+	m := &instanceMigration{from: "https://old.example.com", to: "https://new.example.com", dryRun: true}
+	_, err := p.migrateInstance(m)
+	require.NoError(t, err)
+	assert.Equal(t, 2, m.subscriptions)
+	assert.Equal(t, 1, m.channelDefaults)
+	assert.Equal(t, 1, m.threadIssues)
+	assert.Len(t, m.issueKeys, 3)
+	assert.Equal(t, 1, m.users)
+	assert.Equal(t, before, kv)
+}
+
+func TestSetCloudInstanceBaseURL(t *testing.T) {
+	ci := &cloudInstance{
+		RawAtlassianSecurityContext: `{"baseUrl":"https://old.atlassian.net","clientKey":"key","displayUrl":"https://old.atlassian.net"}`,
+		AtlassianSecurityContext:    &AtlassianSecurityContext{BaseURL: "https://old.atlassian.net", ClientKey: "key"},
+	}
+	require.NoError(t, setCloudInstanceBaseURL(ci, "https://new.atlassian.net"))
+	assert.Equal(t, "https://new.atlassian.net", ci.BaseURL)
+
+	var asc map[string]string
+	require.NoError(t, json.Unmarshal([]byte(ci.RawAtlassianSecurityContext), &asc))
+	assert.Equal(t, map[string]string{
+		"baseUrl":    "https://new.atlassian.net",
+		"clientKey":  "key",
+		"displayUrl": "https://old.atlassian.net",
+	}, asc)
+}
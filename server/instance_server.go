@@ -22,11 +22,17 @@ type serverInstance struct {
 	MattermostKey string
 
 	DeprecatedJIRAServerURL string `json:"JIRAServerURL"`
+
+	// AuthMode is how users authenticate with Jira. It is empty for OAuth1 through
+	// an application link, or serverAuthModePAT for personal access tokens.
+	AuthMode string `json:",omitempty"`
 }
 
+const serverAuthModePAT = "pat"
+
 var _ Instance = (*serverInstance)(nil)
 
-func (p *Plugin) installServerInstance(rawURL string) (string, *serverInstance, error) {
+func (p *Plugin) installServerInstance(rawURL, authMode string) (string, *serverInstance, error) {
 	jiraURL, err := utils.CheckJiraURL(p.GetSiteURL(), rawURL, false)
 	if err != nil {
 		return "", nil, err
@@ -38,6 +44,7 @@ func (p *Plugin) installServerInstance(rawURL string) (string, *serverInstance,
 	instance := &serverInstance{
 		InstanceCommon: newInstanceCommon(p, ServerInstanceType, types.ID(jiraURL)),
 		MattermostKey:  p.GetPluginKey(),
+		AuthMode:       authMode,
 	}
 
 	err = p.InstallInstance(instance)
@@ -69,11 +76,20 @@ func (si *serverInstance) GetMattermostKey() string {
 }
 
 func (si *serverInstance) GetDisplayDetails() map[string]string {
+	if si.usesPAT() {
+		return map[string]string{
+			"Jira Server Authentication": "Personal access tokens",
+		}
+	}
 	return map[string]string{
 		"Jira Server Mattermost Key": si.MattermostKey,
 	}
 }
 
+func (si *serverInstance) usesPAT() bool {
+	return si.AuthMode == serverAuthModePAT
+}
+
 func (si *serverInstance) GetUserConnectURL(mattermostUserID string) (returnURL string, cookie *http.Cookie, returnErr error) {
 	defer func() {
 		if returnErr == nil {
@@ -82,6 +98,10 @@ func (si *serverInstance) GetUserConnectURL(mattermostUserID string) (returnURL
 		returnErr = errors.WithMessage(returnErr, "failed to get a connect link")
 	}()
 
+	if si.usesPAT() {
+		return si.Plugin.GetPluginURL() + "/" + instancePath(routePATConnect, si.InstanceID), nil, nil
+	}
+
 	oauth1Config := si.getOAuth1Config()
 	token, secret, err := oauth1Config.RequestToken()
 	if err != nil {
@@ -110,14 +130,25 @@ func (si *serverInstance) GetClient(connection *Connection) (client Client, retu
 		returnErr = errors.WithMessage(returnErr, fmt.Sprintf("failed to get a Jira client for %s", connection.Name))
 	}()
 
-	if connection.Oauth1AccessToken == "" || connection.Oauth1AccessSecret == "" {
-		return nil, errors.New("no access token, please use /jira connect")
+	var httpClient *http.Client
+	if si.usesPAT() {
+		if connection.EncryptedPAT == "" {
+			return nil, errors.New("no personal access token, please use /jira connect")
+		}
+		pat, err := si.Plugin.decryptPAT(connection.EncryptedPAT)
+		if err != nil {
+			return nil, err
+		}
+		httpClient = (&jira.BearerAuthTransport{Token: pat}).Client()
+	} else {
+		if connection.Oauth1AccessToken == "" || connection.Oauth1AccessSecret == "" {
+			return nil, errors.New("no access token, please use /jira connect")
+		}
+		token := oauth1.NewToken(connection.Oauth1AccessToken, connection.Oauth1AccessSecret)
+		httpClient = si.getOAuth1Config().Client(oauth1.NoContext, token)
 	}
 
-	token := oauth1.NewToken(connection.Oauth1AccessToken, connection.Oauth1AccessSecret)
 	conf := si.getConfig()
-
-	httpClient := si.getOAuth1Config().Client(oauth1.NoContext, token)
 	httpClient = utils.WrapHTTPClient(httpClient,
 		utils.WithRequestSizeLimit(conf.maxAttachmentSize),
 		utils.WithResponseSizeLimit(conf.maxAttachmentSize))
@@ -0,0 +1,65 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-jira/server/utils/types"
+)
+
+func TestServerInstancePAT(t *testing.T) {
+	api := &plugintest.API{}
+	p := setupTestPlugin(api)
+	p.secretsStore = NewStore(p)
+	p.updateConfig(func(conf *config) {
+		conf.maxAttachmentSize = 1024 * 1024
+	})
+	api.On("KVGet", mock.AnythingOfType("string")).Return([]byte("0123456789abcdef0123456789abcdef"), (*model.AppError)(nil))
+
+	jiraServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret-pat" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"name":"jdoe","displayName":"John Doe"}`))
+	}))
+	defer jiraServer.Close()
+
+	si := &serverInstance{
+		InstanceCommon: newInstanceCommon(p, ServerInstanceType, types.ID(jiraServer.URL)),
+		AuthMode:       serverAuthModePAT,
+	}
+
+	encrypted, err := p.encryptPAT("secret-pat")
+	require.NoError(t, err)
+	assert.NotContains(t, encrypted, "secret-pat")
+
+	decrypted, err := p.decryptPAT(encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "secret-pat", decrypted)
+
+	t.Run("token is sent as a bearer token", func(t *testing.T) {
+		client, err := si.GetClient(&Connection{EncryptedPAT: encrypted})
+		require.NoError(t, err)
+
+		user, err := client.GetSelf()
+		require.NoError(t, err)
+		assert.Equal(t, "John Doe", user.DisplayName)
+	})
+
+	t.Run("connection without a token", func(t *testing.T) {
+		_, err := si.GetClient(&Connection{Oauth1AccessToken: "token", Oauth1AccessSecret: "secret"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no personal access token")
+	})
+}
@@ -166,6 +166,7 @@ func (p *Plugin) InstallInstance(newInstance Instance) error {
 	if err != nil {
 		return err
 	}
+	p.botUserIDCache.delete(newInstance.GetID().String())
 
 	// Re-register the /jira command with the new number of instances.
 	err = p.registerJiraCommand(p.getConfig().EnableAutocomplete, updated.Len() > 1)
@@ -177,7 +178,11 @@ func (p *Plugin) InstallInstance(newInstance Instance) error {
 	return nil
 }
 
-func (p *Plugin) UninstallInstance(instanceID types.ID, instanceType InstanceType) (Instance, error) {
+// UninstallInstance disconnects the users and deletes the instance. Its
+// subscriptions, templates and settings are moved to an archive, which
+// `/jira instance restore` restores after a reinstall. With purge, all the
+// data of the instance is deleted instead.
+func (p *Plugin) UninstallInstance(instanceID types.ID, instanceType InstanceType, purge bool) (Instance, error) {
 	var instance Instance
 	var updated *Instances
 	err := UpdateInstances(p.instanceStore,
@@ -202,6 +207,12 @@ func (p *Plugin) UninstallInstance(instanceID types.ID, instanceType InstanceTyp
 				return errors.Errorf("%s did not match instance %s type %s", instanceType, instanceID, instance.Common().Type)
 			}
 
+			if !purge {
+				if err = p.archiveInstance(instance); err != nil {
+					return err
+				}
+			}
+
 			err = p.userStore.MapUsers(func(user *User) error {
 				if !user.ConnectedInstances.Contains(instance.GetID()) {
 					return nil
@@ -217,6 +228,12 @@ func (p *Plugin) UninstallInstance(instanceID types.ID, instanceType InstanceTyp
 				return err
 			}
 
+			if purge {
+				if err = p.purgeInstanceData(instance); err != nil {
+					return err
+				}
+			}
+
 			instances.Delete(instanceID)
 			updated = instances
 			return p.instanceStore.DeleteInstance(instanceID)
@@ -224,6 +241,14 @@ func (p *Plugin) UninstallInstance(instanceID types.ID, instanceType InstanceTyp
 	if err != nil {
 		return nil, err
 	}
+	p.botUserIDCache.delete(instanceID.String())
+
+	// The dedicated bot of the instance is reactivated if it is installed again
+	if instance != nil && instance.Common().BotUserID != "" {
+		if _, err = p.client.Bot.UpdateActive(instance.Common().BotUserID, false); err != nil {
+			p.client.Log.Warn("Failed to deactivate the instance bot", "instance", instanceID, "error", err.Error())
+		}
+	}
 
 	// Re-register the /jira command with the new number of instances.
 	err = p.registerJiraCommand(p.getConfig().EnableAutocomplete, updated.Len() > 1)
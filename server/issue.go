@@ -17,11 +17,13 @@ import (
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	jira "github.com/andygrunwald/go-jira"
 	"github.com/pkg/errors"
 
 	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
 
 	"github.com/mattermost/mattermost-plugin-jira/server/utils"
 	"github.com/mattermost/mattermost-plugin-jira/server/utils/types"
@@ -104,6 +106,7 @@ const (
 type CreateMetaInfo struct {
 	*jira.CreateMetaInfo
 	IssueTypesWithStatuses []*IssueTypeWithStatuses `json:"issue_types_with_statuses"`
+	ServiceDesks           []*ServiceDeskMetadata   `json:"service_desks,omitempty"`
 }
 
 func makePost(userID, channelID, message string) *model.Post {
@@ -173,7 +176,8 @@ func (p *Plugin) buildPostActionContext(authenticatedUserID string, requestData
 			return nil, http.StatusNotFound, "post not found"
 		}
 	} else {
-		if originalPost.UserId != jiraBotID {
+		// The actions are on the posts of the plugin bot, or of the bot of the instance
+		if originalPost.UserId != jiraBotID && originalPost.UserId != p.getBotUserID(types.ID(instanceID)) {
 			return nil, http.StatusUnauthorized, "user not authorized"
 		}
 
@@ -271,6 +275,7 @@ func (p *Plugin) httpTransitionIssuePostAction(w http.ResponseWriter, r *http.Re
 
 	_, err = p.TransitionIssue(&InTransitionIssue{
 		mattermostUserID: types.ID(ctx.authenticatedUserID),
+		triggerID:        requestData.TriggerId,
 		InstanceID:       types.ID(ctx.instanceID),
 		IssueKey:         ctx.issueKey,
 		ToState:          toState,
@@ -298,6 +303,7 @@ type InCreateIssue struct {
 	CurrentTeam              string           `json:"current_team"`
 	ChannelID                string           `json:"channel_id"`
 	Fields                   jira.IssueFields `json:"fields"`
+	FromThread               bool             `json:"from_thread"`
 }
 
 func (p *Plugin) httpCreateIssue(w http.ResponseWriter, r *http.Request) (int, error) {
@@ -335,14 +341,22 @@ func (p *Plugin) CreateIssue(in *InCreateIssue) (*jira.Issue, int, error) {
 			return nil, http.StatusNotFound, errors.New("failed to load post " + in.PostID + ": not found")
 		}
 		permalink := getPermaLink(instance, in.PostID, in.CurrentTeam)
+		source := "message"
+		if in.FromThread {
+			source = "thread"
+		}
 
 		if len(in.Fields.Description) > 0 {
-			in.Fields.Description += fmt.Sprintf("\n\n_Issue created from a [message in Mattermost|%v]_.", permalink)
+			in.Fields.Description += fmt.Sprintf("\n\n_Issue created from a [%s in Mattermost](%v)_.", source, permalink)
 		} else {
-			in.Fields.Description = fmt.Sprintf("_Issue created from a [message in Mattermost|%v]_.", permalink)
+			in.Fields.Description = fmt.Sprintf("_Issue created from a [%s in Mattermost](%v)_.", source, permalink)
 		}
 	}
 
+	if in.Fields.Description != "" {
+		in.Fields.Description = p.markdownToJiraText(instance, in.Fields.Description)
+	}
+
 	rootID := in.PostID
 	if post != nil && post.RootId != "" {
 		// the original post was a reply
@@ -368,6 +382,10 @@ func (p *Plugin) CreateIssue(in *InCreateIssue) (*jira.Issue, int, error) {
 		return nil, http.StatusForbidden, errors.New("User does not have access to this channel")
 	}
 
+	if defaults := p.channelDefaultsForInstance(channelID, instance.GetID()); defaults != nil {
+		defaults.applyToIssueFields(issue.Fields)
+	}
+
 	for i, notCovered := range in.RequiredFieldsNotCovered {
 		// First position in the slice is the key value (shouldn't change, regardless of localization)
 		if strings.ToLower(notCovered[0]) == reporterField {
@@ -405,13 +423,19 @@ func (p *Plugin) CreateIssue(in *InCreateIssue) (*jira.Issue, int, error) {
 			Message:   fmt.Sprintf("[Please create your Jira issue manually](%v). %v\n%v", createURL, message, fieldsString),
 			ChannelId: channelID,
 			RootId:    rootID,
-			UserId:    instance.Common().getConfig().botUserID,
+			UserId:    p.getBotUserID(instance.GetID()),
 		}
 		p.client.Post.SendEphemeralPost(in.mattermostUserID.String(), reply)
 		return nil, http.StatusForbidden, errors.Errorf("issue can not be created via API: %s", message)
 	}
 
 	created, err := client.CreateIssue(issue)
+	descriptionNote := ""
+	if created != nil && errors.Is(err, errDescriptionNotSet) {
+		p.client.Log.Warn("Failed to set the description of the created issue", "issue", created.Key, "error", err.Error())
+		descriptionNote = ". Its description could not be formatted and was saved in wiki markup."
+		err = nil
+	}
 	if err != nil {
 		// if have an error and Jira tells us there are required fields send user
 		// link to jira with fields already filled in.  Note the user will also see
@@ -427,7 +451,7 @@ func (p *Plugin) CreateIssue(in *InCreateIssue) (*jira.Issue, int, error) {
 				Message:   message,
 				ChannelId: channelID,
 				RootId:    rootID,
-				UserId:    instance.Common().getConfig().botUserID,
+				UserId:    p.getBotUserID(instance.GetID()),
 			})
 			return nil, http.StatusForbidden, errors.Errorf("issue can not be created via API: %s", message)
 		}
@@ -435,14 +459,16 @@ func (p *Plugin) CreateIssue(in *InCreateIssue) (*jira.Issue, int, error) {
 		return nil, http.StatusInternalServerError, errors.WithMessage(err, "failed to create issue")
 	}
 
+	p.audit(in.mattermostUserID.String(), AuditIssueCreate, instance.GetID(), created.Key, "")
+
 	// Reply with an ephemeral post with the Jira issue formatted as slack attachment.
-	msg := fmt.Sprintf("Created Jira issue [%s](%s/browse/%s)", created.Key, instance.GetJiraBaseURL(), created.Key)
+	msg := fmt.Sprintf("Created Jira issue [%s](%s/browse/%s)", created.Key, instance.GetJiraBaseURL(), created.Key) + descriptionNote
 
 	reply := &model.Post{
 		Message:   msg,
 		ChannelId: channelID,
 		RootId:    rootID,
-		UserId:    instance.Common().getConfig().botUserID,
+		UserId:    p.getBotUserID(instance.GetID()),
 	}
 
 	attachment, err := instance.Common().getIssueAsSlackAttachment(instance, connection, created.Key, true)
@@ -476,10 +502,33 @@ func (p *Plugin) CreateIssue(in *InCreateIssue) (*jira.Issue, int, error) {
 		return nil, http.StatusInternalServerError, errors.WithMessage(err, "failed to create notification post "+in.PostID)
 	}
 
-	if post != nil && len(post.FileIds) > 0 {
+	fileIDs := []string{}
+	if post != nil {
+		fileIDs = post.FileIds
+	}
+	if post != nil && in.FromThread {
+		err = p.storeThreadIssue(rootID, &ThreadIssue{
+			InstanceID: instance.GetID(),
+			IssueKey:   created.Key,
+		})
+		if err != nil {
+			p.client.Log.Warn("Failed to remember the issue created from a thread", "rootID", rootID, "error", err.Error())
+		}
+		// The creator already knows about the issue, so the banner is skipped for them.
+		p.markThreadBannerShown(rootID, in.mattermostUserID.String())
+
+		if _, posts, threadErr := p.loadThreadPosts(post); threadErr == nil {
+			fileIDs = nil
+			for _, threadPost := range posts {
+				fileIDs = append(fileIDs, threadPost.FileIds...)
+			}
+		}
+	}
+
+	if len(fileIDs) > 0 {
 		go func() {
 			conf := instance.Common().getConfig()
-			for _, fileID := range post.FileIds {
+			for _, fileID := range fileIDs {
 				mattermostName, _, _, err := client.AddAttachment(*p.client, created.ID, fileID, conf.maxAttachmentSize)
 				if err != nil {
 					notifyOnFailedAttachment(instance, in.mattermostUserID.String(), created.Key, err, "file: %s", mattermostName)
@@ -562,8 +611,9 @@ func (p *Plugin) GetCreateIssueMetadataForProjects(instanceID, mattermostUserID
 	}
 
 	return &CreateMetaInfo{
-		metaInfo,
-		projectStatuses,
+		CreateMetaInfo:         metaInfo,
+		IssueTypesWithStatuses: projectStatuses,
+		ServiceDesks:           p.getServiceDeskMetadata(instanceID, client, strings.Split(projectKeys, ",")...),
 	}, nil
 }
 
@@ -711,6 +761,7 @@ type SprintSearchResult struct {
 type Board struct {
 	ID   int    `json:"id"`
 	Name string `json:"name"`
+	Type string `json:"type,omitempty"`
 }
 
 type BoardSearchResult struct {
@@ -912,6 +963,13 @@ func (p *Plugin) httpGetSearchIssues(w http.ResponseWriter, r *http.Request) (in
 	fieldsStr := r.FormValue("fields")
 	limitStr := r.FormValue("limit")
 
+	// Without a query, the recent issues of the channel's default project are suggested.
+	if strings.TrimSpace(q) == "" && jqlString == "" {
+		if defaults := p.channelDefaultsForUser(r.FormValue("channel_id"), mattermostUserID, types.ID(instanceID)); defaults != nil {
+			jqlString = fmt.Sprintf(`project = "%s" AND updated >= -4w ORDER BY updated DESC`, escapeJQLString(defaults.ProjectKey))
+		}
+	}
+
 	result, err := p.GetSearchIssues(types.ID(instanceID), types.ID(mattermostUserID), q, jqlString, fieldsStr, limitStr)
 	if err != nil {
 		return respondErr(w, http.StatusInternalServerError, err)
@@ -1048,10 +1106,15 @@ func (p *Plugin) httpGetJiraProjectMetadata(w http.ResponseWriter, r *http.Reque
 		issues[prj.Key] = issueTypes
 	}
 
+	savedFieldValues := connection.SavedFieldValues
+	if defaults := p.channelDefaultsForUser(r.FormValue("channel_id"), mattermostUserID, types.ID(instanceID)); defaults != nil {
+		savedFieldValues = defaults.SavedFieldValues()
+	}
+
 	return respondJSON(w, OutProjectMetadata{
 		Projects:          projects,
 		IssuesPerProjects: issues,
-		SavedFieldValues:  connection.SavedFieldValues,
+		SavedFieldValues:  savedFieldValues,
 	})
 }
 
@@ -1106,6 +1169,17 @@ type InAttachCommentToIssue struct {
 	PostID           string   `json:"post_id"`
 	CurrentTeam      string   `json:"current_team"`
 	IssueKey         string   `json:"issueKey"`
+	IncludeThread    bool     `json:"include_thread"`
+}
+
+// maxJiraCommentLength is the default limit Jira puts on the length of a comment.
+const maxJiraCommentLength = 32767
+
+// jiraCommentDraft is a comment to be added to an issue, written in markdown, along
+// with the Mattermost files to upload with it.
+type jiraCommentDraft struct {
+	message string
+	fileIDs []string
 }
 
 func (p *Plugin) AttachCommentToIssue(in *InAttachCommentToIssue) (*jira.Comment, int, error) {
@@ -1128,38 +1202,174 @@ func (p *Plugin) AttachCommentToIssue(in *InAttachCommentToIssue) (*jira.Comment
 		return nil, http.StatusForbidden, errors.New("User does not have access to this post")
 	}
 
+	var drafts []jiraCommentDraft
+	if in.IncludeThread {
+		var status int
+		drafts, status, err = p.draftThreadComments(instance, connection, post, in.CurrentTeam)
+		if err != nil {
+			return nil, status, err
+		}
+	} else {
+		drafts, err = p.draftPostComment(instance, connection, post, in.CurrentTeam)
+		if err != nil {
+			return nil, http.StatusNotFound, err
+		}
+	}
+
+	var first *jira.Comment
+	for i, draft := range drafts {
+		added, err := p.addCommentDraft(instance, client, in, draft)
+		if err != nil {
+			if i > 0 {
+				// The parts already attached stay on the issue
+				return nil, http.StatusInternalServerError, errors.WithMessagef(err,
+					"attached parts 1 to %d of the %d parts of the thread to %s, failed to attach the others", i, len(drafts), in.IssueKey)
+			}
+			if strings.Contains(err.Error(), "you do not have the permission to comment on this issue") {
+				return nil, http.StatusForbidden, errors.New("you do not have permission to create a comment in the selected Jira issue. Please choose another issue or contact your Jira admin")
+			}
+
+			// The error was not a permissions error; it was unanticipated. Return it to the client.
+			return nil, http.StatusInternalServerError, errors.WithMessage(err, "failed to attach the comment, postId: "+in.PostID)
+		}
+		if first == nil {
+			first = added
+		}
+	}
+
+	rootID := in.PostID
+	if post.RootId != "" {
+		// the original post was a reply
+		rootID = post.RootId
+	}
+
+	p.UpdateUserDefaults(in.mattermostUserID, in.InstanceID, nil)
+
+	msg := fmt.Sprintf("Message attached to [%s](%s/browse/%s)", in.IssueKey, instance.GetJiraBaseURL(), in.IssueKey)
+	if in.IncludeThread {
+		msg = fmt.Sprintf("Thread attached to [%s](%s/browse/%s)", in.IssueKey, instance.GetJiraBaseURL(), in.IssueKey)
+	}
+
+	// Reply to the post with the issue link that was created
+	reply := &model.Post{
+		Message:   msg,
+		ChannelId: post.ChannelId,
+		RootId:    rootID,
+		UserId:    in.mattermostUserID.String(),
+	}
+	err = p.client.Post.CreatePost(reply)
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.WithMessage(err, "failed to create notification post "+in.PostID)
+	}
+
+	return first, http.StatusOK, nil
+}
+
+func (p *Plugin) draftPostComment(instance Instance, connection *Connection, post *model.Post, currentTeam string) ([]jiraCommentDraft, error) {
 	commentUser, err := p.client.User.Get(post.UserId)
 	if err != nil {
-		return nil, http.StatusNotFound, errors.New("failed to load post.UserID " + post.UserId + ": not found")
+		return nil, errors.New("failed to load post.UserID " + post.UserId + ": not found")
+	}
+
+	permalink := getPermaLink(instance, post.Id, currentTeam)
+
+	// The header is markdown too, with its @s escaped so they aren't resolved as mentions.
+	permalinkMessage := fmt.Sprintf("**\\@%s attached a** [message](%s) **from \\@%s**\n", connection.DisplayName, permalink, commentUser.Username)
+
+	return []jiraCommentDraft{{
+		message: permalinkMessage + post.Message,
+		fileIDs: post.FileIds,
+	}}, nil
+}
+
+// draftThreadComments drafts the comments for every post of the thread post belongs to.
+// The thread goes into a single comment when it fits, otherwise each post gets its own.
+func (p *Plugin) draftThreadComments(instance Instance, connection *Connection, post *model.Post, currentTeam string) ([]jiraCommentDraft, int, error) {
+	rootID, posts, err := p.loadThreadPosts(post)
+	if err != nil {
+		// Only a thread that is gone is not found, other errors are the server's
+		if errors.Is(err, pluginapi.ErrNotFound) {
+			return nil, http.StatusNotFound, err
+		}
+		return nil, http.StatusInternalServerError, err
+	}
+	usernames, _, err := p.loadThreadUsernames(posts)
+	if err != nil {
+		return nil, http.StatusNotFound, err
+	}
+
+	entries := make([]string, 0, len(posts))
+	var fileIDs []string
+	for _, threadPost := range posts {
+		entries = append(entries, formatThreadEntry(instance, threadPost, usernames[threadPost.UserId], currentTeam))
+		fileIDs = append(fileIDs, threadPost.FileIds...)
 	}
 
-	permalink := getPermaLink(instance, in.PostID, in.CurrentTeam)
+	header := fmt.Sprintf("**\\@%s attached a** [thread](%s) **of %d messages**",
+		connection.DisplayName, getPermaLink(instance, rootID, currentTeam), len(entries))
+	combined := header + "\n\n" + strings.Join(entries, "\n\n")
+	if p.jiraTextLength(instance, combined) <= maxJiraCommentLength {
+		return []jiraCommentDraft{{
+			message: combined,
+			fileIDs: fileIDs,
+		}}, http.StatusOK, nil
+	}
 
-	permalinkMessage := fmt.Sprintf("*@%s attached a* [message|%s] *from @%s*\n", connection.DisplayName, permalink, commentUser.Username)
+	drafts := make([]jiraCommentDraft, 0, len(entries))
+	for i, entry := range entries {
+		message := fmt.Sprintf("%s (%d/%d)\n\n%s", header, i+1, len(entries), entry)
+		drafts = append(drafts, jiraCommentDraft{
+			message: p.fitJiraComment(instance, message),
+			fileIDs: posts[i].FileIds,
+		})
+	}
+	return drafts, http.StatusOK, nil
+}
 
+// jiraTextLength returns the length of markdown once converted for Jira, as the
+// limits of Jira apply to the wiki markup or ADF.
+func (p *Plugin) jiraTextLength(instance Instance, markdown string) int {
+	return utf8.RuneCountInString(p.markdownToJiraText(instance, markdown))
+}
+
+// fitJiraComment truncates the markdown of a comment until it is short enough
+// once converted for Jira.
+func (p *Plugin) fitJiraComment(instance Instance, markdown string) string {
+	maxLen := utf8.RuneCountInString(markdown)
+	for {
+		converted := p.jiraTextLength(instance, markdown)
+		if converted <= maxJiraCommentLength || maxLen <= 0 {
+			return markdown
+		}
+		// The markup added by the conversion grows with the text
+		maxLen = min(maxLen-1, maxLen*maxJiraCommentLength/converted)
+		markdown = truncate(markdown, max(maxLen, 0))
+	}
+}
+
+// addCommentDraft adds a comment to the issue, then uploads its files in the background and
+// updates the comment to reference them.
+func (p *Plugin) addCommentDraft(instance Instance, client Client, in *InAttachCommentToIssue, draft jiraCommentDraft) (*jira.Comment, error) {
 	jiraComment := jira.Comment{
-		Body: permalinkMessage + post.Message,
+		Body: p.markdownToJiraText(instance, draft.message),
 	}
 
 	added, err := client.AddComment(in.IssueKey, &jiraComment)
 	if err != nil {
-		if strings.Contains(err.Error(), "you do not have the permission to comment on this issue") {
-			return nil, http.StatusForbidden, errors.New("you do not have permission to create a comment in the selected Jira issue. Please choose another issue or contact your Jira admin")
-		}
-
-		// The error was not a permissions error; it was unanticipated. Return it to the client.
-		return nil, http.StatusInternalServerError, errors.WithMessage(err, "failed to attach the comment, postId: "+in.PostID)
+		return nil, err
 	}
 
 	go func() {
 		conf := instance.Common().getConfig()
 		extraText := ""
-		for _, fileID := range post.FileIds {
+		var attachmentNames []string
+		for _, fileID := range draft.fileIDs {
 			mattermostName, jiraName, mime, e := client.AddAttachment(*p.client, in.IssueKey, fileID, conf.maxAttachmentSize)
 			if e != nil {
 				notifyOnFailedAttachment(instance, in.mattermostUserID.String(), in.IssueKey, e, "file: %s", mattermostName)
 				continue
 			}
+			attachmentNames = append(attachmentNames, "Attachment: "+jiraName)
 			if isImageMIME(mime) || isEmbbedableMIME(mime) {
 				extraText += "\n\nAttachment: !" + jiraName + "!"
 			} else {
@@ -1171,36 +1381,20 @@ func (p *Plugin) AttachCommentToIssue(in *InAttachCommentToIssue) (*jira.Comment
 		}
 
 		jiraComment.ID = added.ID
-		jiraComment.Body += extraText
+		if _, isADF := parseADFDocument(jiraComment.Body); isADF {
+			// ADF references attachments by media ID, which isn't exposed by the
+			// attachments API, so files are listed by name instead.
+			jiraComment.Body = appendADFText(jiraComment.Body, attachmentNames...)
+		} else {
+			jiraComment.Body += extraText
+		}
 		_, err = client.UpdateComment(in.IssueKey, &jiraComment)
 		if err != nil {
 			notifyOnFailedAttachment(instance, in.mattermostUserID.String(), in.IssueKey, err, "failed to completely update comment with attachments")
 		}
 	}()
 
-	rootID := in.PostID
-	if post.RootId != "" {
-		// the original post was a reply
-		rootID = post.RootId
-	}
-
-	p.UpdateUserDefaults(in.mattermostUserID, in.InstanceID, nil)
-
-	msg := fmt.Sprintf("Message attached to [%s](%s/browse/%s)", in.IssueKey, instance.GetJiraBaseURL(), in.IssueKey)
-
-	// Reply to the post with the issue link that was created
-	reply := &model.Post{
-		Message:   msg,
-		ChannelId: post.ChannelId,
-		RootId:    rootID,
-		UserId:    in.mattermostUserID.String(),
-	}
-	err = p.client.Post.CreatePost(reply)
-	if err != nil {
-		return nil, http.StatusInternalServerError, errors.WithMessage(err, "failed to create notification post "+in.PostID)
-	}
-
-	return added, http.StatusOK, nil
+	return added, nil
 }
 
 func notifyOnFailedAttachment(instance Instance, mattermostUserID, issueKey string, err error, format string, args ...interface{}) {
@@ -1400,6 +1594,7 @@ func (p *Plugin) UnassignIssue(instance Instance, mattermostUserID types.ID, iss
 		}
 		return "", err
 	}
+	p.audit(mattermostUserID.String(), AuditIssueUnassign, instance.GetID(), issueKey, "")
 
 	permalink := fmt.Sprintf("%v/browse/%v", instance.GetJiraBaseURL(), issueKey)
 
@@ -1484,6 +1679,7 @@ func (p *Plugin) AssignIssue(instance Instance, mattermostUserID types.ID, issue
 	if err := client.UpdateAssignee(issueKey, &user); err != nil {
 		return "", err
 	}
+	p.audit(mattermostUserID.String(), AuditIssueAssign, instance.GetID(), issueKey, "assignee: "+user.DisplayName)
 
 	permalink := fmt.Sprintf("%v/browse/%v", instance.GetJiraBaseURL(), issueKey)
 
@@ -1493,6 +1689,7 @@ func (p *Plugin) AssignIssue(instance Instance, mattermostUserID types.ID, issue
 
 type InTransitionIssue struct {
 	mattermostUserID types.ID
+	triggerID        string
 	InstanceID       types.ID `json:"instance_id"`
 	PostToChannelID  string   `json:"channel_id"`
 	IssueKey         string   `json:"issue_key"`
@@ -1513,42 +1710,37 @@ func (p *Plugin) TransitionIssue(in *InTransitionIssue) (string, error) {
 		return "", errors.New("you do not have the appropriate permissions to perform this action. Please contact your Jira administrator")
 	}
 
-	var transition jira.Transition
-	matchingStates := []string{}
-	availableStates := []string{}
-
-	potentialState := strings.ToLower(strings.Join(strings.Fields(in.ToState), ""))
-	for _, t := range transitions {
-		validState := strings.ToLower(strings.Join(strings.Fields(t.To.Name), ""))
-		if strings.Contains(validState, potentialState) {
-			matchingStates = append(matchingStates, t.To.Name)
-			transition = t
-		}
-		availableStates = append(availableStates, t.To.Name)
+	transition, err := findTransition(transitions, in.ToState)
+	if err != nil {
+		return "", err
 	}
 
-	switch len(matchingStates) {
-	case 0:
-		return "", errors.Errorf("%q is not a valid state. Please use one of: %q",
-			in.ToState, strings.Join(availableStates, ", "))
-
-	case 1:
-		// proceed
-
-	default:
-		return "", errors.Errorf("please be more specific, %q matched several states: %q",
-			in.ToState, strings.Join(matchingStates, ", "))
+	// A transition with a screen that has required fields fails without
+	// them, so they are asked for in a dialog first
+	fields, err := needsFieldValues(client, in.IssueKey, transition)
+	if err != nil {
+		return "", err
+	}
+	if fields != nil {
+		return p.openTransitionDialog(instance, in, transition, fields)
 	}
 
 	err = client.DoTransition(in.IssueKey, transition.ID)
 	if err != nil {
 		return "", err
 	}
+	return p.postIssueTransitioned(client, instance, in.mattermostUserID, in.PostToChannelID, in.IssueKey, transition.To.Name)
+}
+
+// postIssueTransitioned records a transition, and sends the user the issue
+// in its new state.
+func (p *Plugin) postIssueTransitioned(client Client, instance Instance, mattermostUserID types.ID, channelID, issueKey, toState string) (string, error) {
+	p.audit(mattermostUserID.String(), AuditIssueTransition, instance.GetID(), issueKey, "to: "+toState)
 
 	msg := fmt.Sprintf("[%s](%v/browse/%v) transitioned to `%s`",
-		in.IssueKey, instance.GetJiraBaseURL(), in.IssueKey, transition.To.Name)
+		issueKey, instance.GetJiraBaseURL(), issueKey, toState)
 
-	issue, err := client.GetIssue(in.IssueKey, nil)
+	issue, err := client.GetIssue(issueKey, nil)
 	if err != nil {
 		switch StatusCode(err) {
 		case http.StatusNotFound:
@@ -1567,13 +1759,44 @@ func (p *Plugin) TransitionIssue(in *InTransitionIssue) (string, error) {
 		return "", err
 	}
 
-	post := makePost(p.getUserID(), in.PostToChannelID, msg)
+	post := makePost(p.getBotUserID(instance.GetID()), channelID, msg)
 	post.AddProp("attachments", attachments)
-	p.client.Post.SendEphemeralPost(in.mattermostUserID.String(), post)
+	p.client.Post.SendEphemeralPost(mattermostUserID.String(), post)
 
 	return msg, nil
 }
 
+// findTransition returns the transition to the state, which can be given
+// partially, ignoring case and spaces.
+func findTransition(transitions []jira.Transition, toState string) (jira.Transition, error) {
+	var transition jira.Transition
+	matchingStates := []string{}
+	availableStates := []string{}
+
+	potentialState := strings.ToLower(strings.Join(strings.Fields(toState), ""))
+	for _, t := range transitions {
+		validState := strings.ToLower(strings.Join(strings.Fields(t.To.Name), ""))
+		if strings.Contains(validState, potentialState) {
+			matchingStates = append(matchingStates, t.To.Name)
+			transition = t
+		}
+		availableStates = append(availableStates, t.To.Name)
+	}
+
+	switch len(matchingStates) {
+	case 0:
+		return transition, errors.Errorf("%q is not a valid state. Please use one of: %q",
+			toState, strings.Join(availableStates, ", "))
+
+	case 1:
+		return transition, nil
+
+	default:
+		return transition, errors.Errorf("please be more specific, %q matched several states: %q",
+			toState, strings.Join(matchingStates, ", "))
+	}
+}
+
 func (p *Plugin) getClient(instanceID, mattermostUserID types.ID) (Client, Instance, *Connection, error) {
 	instance, err := p.instanceStore.LoadInstance(instanceID)
 	if err != nil {
@@ -0,0 +1,147 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+
+	"github.com/mattermost/mattermost-plugin-jira/server/utils/types"
+)
+
+const (
+	// issueCardProp marks the posts that are issue cards, as opposed to
+	// the comment threads that also use ticketRootPostIDKey
+	issueCardProp = "jira_issue_card"
+
+	issueCardTimeFormat = "Jan 2, 2006 15:04 MST"
+)
+
+// makeIssueCardAttachment returns the attachment of the card of an issue,
+// with its current status, assignee, priority and last update.
+func makeIssueCardAttachment(instanceID types.ID, jiraBaseURL string, issue *jira.Issue) *model.SlackAttachment {
+	attachment := &model.SlackAttachment{
+		Color:     "#95b7d0",
+		Fallback:  issue.Key,
+		Title:     issue.Key,
+		TitleLink: fmt.Sprintf("%s/browse/%s", jiraBaseURL, issue.Key),
+		Footer:    instanceID.String(),
+	}
+	fields := issue.Fields
+	if fields == nil {
+		return attachment
+	}
+	attachment.Title = issue.Key + ": " + truncate(fields.Summary, maxIssueSummaryLength)
+	attachment.Fallback = attachment.Title
+
+	status, assignee, priority, updated := "None", "Unassigned", "None", ""
+	if fields.Status != nil {
+		status = fields.Status.Name
+	}
+	if fields.Assignee != nil {
+		assignee = fields.Assignee.DisplayName
+	}
+	if fields.Priority != nil {
+		priority = fields.Priority.Name
+	}
+	if t := time.Time(fields.Updated); !t.IsZero() {
+		updated = t.UTC().Format(issueCardTimeFormat)
+	}
+
+	attachment.Fields = []*model.SlackAttachmentField{
+		{Title: "Status", Value: status, Short: true},
+		{Title: "Assignee", Value: assignee, Short: true},
+		{Title: "Priority", Value: priority, Short: true},
+	}
+	if updated != "" {
+		attachment.Fields = append(attachment.Fields, &model.SlackAttachmentField{Title: "Last updated", Value: updated, Short: true})
+	}
+	return attachment
+}
+
+// issueCardExpiry returns how long the card of an issue is updated after the
+// last event, the same time comments are threaded for.
+func (p *Plugin) issueCardExpiry() time.Duration {
+	days, err := strconv.Atoi(p.getConfig().ThreadedJiraCommentSubscriptionDuration)
+	if err != nil || days <= 0 {
+		days = 30
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// upsertIssueCard updates the card of the issue in the channel, or posts it
+// if the channel has none. It returns the ID of the card, which the event is
+// posted in the thread of.
+func (p *Plugin) upsertIssueCard(instanceID types.ID, channelID, fromUserID string, issue *jira.Issue) (string, error) {
+	instance, err := p.instanceStore.LoadInstance(instanceID)
+	if err != nil {
+		return "", err
+	}
+	attachment := makeIssueCardAttachment(instanceID, instance.GetJiraBaseURL(), issue)
+	key := fmt.Sprintf(ticketRootPostIDKey, issue.ID, channelID)
+	expiry := pluginapi.SetExpiry(p.issueCardExpiry())
+
+	var cardID string
+	if err = p.client.KV.Get(key, &cardID); err != nil {
+		return "", errors.WithMessage(err, "failed to load the issue card")
+	}
+	if cardID != "" {
+		card, err := p.client.Post.GetPost(cardID)
+		if err == nil && card.DeleteAt == 0 && card.GetProp(issueCardProp) != nil {
+			// The instance changes when it is migrated to a new URL
+			card.AddProp(postInstanceIDProp, instanceID.String())
+			model.ParseSlackAttachment(card, []*model.SlackAttachment{attachment})
+			if err = p.client.Post.UpdatePost(card); err != nil {
+				return "", errors.WithMessage(err, "failed to update the issue card")
+			}
+			// Active issues keep their card
+			if _, err = p.client.KV.Set(key, cardID, expiry); err != nil {
+				p.client.Log.Warn("Failed to extend the issue card", "issue", issue.Key, "error", err.Error())
+			}
+			return cardID, nil
+		}
+	}
+
+	card := &model.Post{
+		ChannelId: channelID,
+		UserId:    fromUserID,
+	}
+	card.AddProp(issueCardProp, true)
+	card.AddProp(postInstanceIDProp, instanceID.String())
+	model.ParseSlackAttachment(card, []*model.SlackAttachment{attachment})
+	if err = p.client.Post.CreatePost(card); err != nil {
+		return "", errors.WithMessage(err, "failed to post the issue card")
+	}
+
+	// Events of an issue can be processed concurrently, the first card wins
+	saved, err := p.client.KV.Set(key, card.Id, pluginapi.SetAtomic(cardIDOrNil(cardID)), expiry)
+	if err != nil {
+		return card.Id, errors.WithMessage(err, "failed to store the issue card")
+	}
+	if !saved {
+		if err = p.client.Post.DeletePost(card.Id); err != nil {
+			p.client.Log.Warn("Failed to delete a duplicate issue card", "issue", issue.Key, "error", err.Error())
+		}
+		cardID = ""
+		if err = p.client.KV.Get(key, &cardID); err != nil || cardID == "" {
+			return "", errors.New("failed to load the issue card")
+		}
+		return cardID, nil
+	}
+	return card.Id, nil
+}
+
+func cardIDOrNil(cardID string) interface{} {
+	if cardID == "" {
+		return nil
+	}
+	return cardID
+}
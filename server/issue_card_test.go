@@ -0,0 +1,79 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testCardIssue(status string) *jira.Issue {
+	return &jira.Issue{
+		ID:  "10001",
+		Key: "BUG-12",
+		Fields: &jira.IssueFields{
+			Summary:  "The login page is slow",
+			Status:   &jira.Status{Name: status},
+			Assignee: &jira.User{DisplayName: "Jane Doe"},
+			Updated:  jira.Time(time.Date(2024, 3, 10, 14, 30, 0, 0, time.UTC)),
+		},
+	}
+}
+
+func TestMakeIssueCardAttachment(t *testing.T) {
+	attachment := makeIssueCardAttachment(testInstance1.InstanceID, mockInstance1URL, testCardIssue("In Progress"))
+	assert.Equal(t, "BUG-12: The login page is slow", attachment.Title)
+	assert.Equal(t, mockInstance1URL+"/browse/BUG-12", attachment.TitleLink)
+
+	values := map[string]interface{}{}
+	for _, field := range attachment.Fields {
+		values[field.Title] = field.Value
+	}
+	assert.Equal(t, map[string]interface{}{
+		"Status":       "In Progress",
+		"Assignee":     "Jane Doe",
+		"Priority":     "None",
+		"Last updated": "Mar 10, 2024 14:30 UTC",
+	}, values)
+}
+
+func TestUpsertIssueCard(t *testing.T) {
+	api := &plugintest.API{}
+	p := setupTestPlugin(api)
+	kv := map[string][]byte{}
+	mockKVMap(api, kv)
+	key := fmt.Sprintf(ticketRootPostIDKey, "10001", "channel1")
+
+	var card *model.Post
+	api.On("CreatePost", mock.AnythingOfType("*model.Post")).Return(func(post *model.Post) (*model.Post, *model.AppError) {
+		card = post.Clone()
+		card.Id = "card-id"
+		return card.Clone(), nil
+	}).Once()
+	cardID, err := p.upsertIssueCard(testInstance1.InstanceID, "channel1", "bot-id", testCardIssue("To Do"))
+	require.NoError(t, err)
+	assert.Equal(t, "card-id", cardID)
+	assert.Equal(t, `"card-id"`, string(kv[key]))
+	require.NotNil(t, card)
+	assert.Equal(t, true, card.GetProp(issueCardProp))
+
+	api.On("GetPost", "card-id").Return(card.Clone(), nil)
+	api.On("UpdatePost", mock.MatchedBy(func(post *model.Post) bool {
+		attachments := post.Attachments()
+		return post.Id == "card-id" && len(attachments) == 1 && attachments[0].Fields[0].Value == "Done"
+	})).Return(card.Clone(), nil).Once()
+	cardID, err = p.upsertIssueCard(testInstance1.InstanceID, "channel1", "bot-id", testCardIssue("Done"))
+	require.NoError(t, err)
+	assert.Equal(t, "card-id", cardID)
+	api.AssertNumberOfCalls(t, "CreatePost", 1)
+	api.AssertNumberOfCalls(t, "UpdatePost", 1)
+}
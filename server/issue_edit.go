@@ -0,0 +1,562 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/mattermost/mattermost-plugin-jira/server/utils/types"
+)
+
+const (
+	customFieldTypeTextArea = "com.atlassian.jira.plugin.system.customfieldtypes:textarea"
+	customFieldTypeLabels   = "com.atlassian.jira.plugin.system.customfieldtypes:labels"
+	customFieldTypeURL      = "com.atlassian.jira.plugin.system.customfieldtypes:url"
+
+	editDateFormat = "2006-01-02"
+)
+
+// EditMetaField is a field of an issue the user can edit, as returned by
+// the Jira edit metadata API.
+type EditMetaField struct {
+	Required        bool                   `json:"required"`
+	HasDefaultValue bool                   `json:"hasDefaultValue"`
+	Schema          jira.FieldSchema       `json:"schema"`
+	Name            string                 `json:"name"`
+	Operations      []string               `json:"operations"`
+	AllowedValues   []EditMetaAllowedValue `json:"allowedValues"`
+}
+
+type EditMetaAllowedValue struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Disabled bool   `json:"disabled"`
+}
+
+// editIssueState is the state of the edit dialog. It holds the values the
+// fields had when the dialog was opened, so that only the fields the user
+// changed are updated.
+type editIssueState struct {
+	InstanceID types.ID          `json:"instance_id"`
+	IssueKey   string            `json:"issue_key"`
+	Values     map[string]string `json:"values"`
+}
+
+const (
+	editFieldText     = "text"
+	editFieldTextArea = "textarea"
+	editFieldNumber   = "number"
+	editFieldDate     = "date"
+	editFieldDateTime = "datetime"
+	editFieldLabels   = "labels"
+	editFieldSelect   = "select"
+	editFieldMulti    = "multiselect"
+	editFieldUser     = "user"
+
+	editUserSearchMaxResults = 10
+)
+
+// editFieldKind returns how a field is edited in the dialog, or "" if it is
+// not supported. These are the field types the create issue modal renders.
+// A single user, like the assignee or the reporter, is searched in Jira as
+// it is typed, see httpEditIssueUsers. The fields with several users, the
+// epics and the sprints can only be edited in Jira.
+func editFieldKind(field *EditMetaField) string {
+	canSet := false
+	for _, operation := range field.Operations {
+		if operation == "set" {
+			canSet = true
+		}
+	}
+	if !canSet {
+		return ""
+	}
+
+	schema := field.Schema
+	switch {
+	case schema.Type == "user":
+		return editFieldUser
+	case schema.System == "labels" || schema.Custom == customFieldTypeLabels:
+		return editFieldLabels
+	case schema.System == "description" || schema.Custom == customFieldTypeTextArea:
+		return editFieldTextArea
+	case len(field.AllowedValues) > 0 && schema.Type == "array" && schema.Items != "user":
+		return editFieldMulti
+	case len(field.AllowedValues) > 0 && schema.Type != "option-with-child":
+		return editFieldSelect
+	case schema.Type == "string":
+		return editFieldText
+	case schema.Type == "number":
+		return editFieldNumber
+	case schema.Type == "date":
+		return editFieldDate
+	case schema.Type == "datetime":
+		return editFieldDateTime
+	}
+	return ""
+}
+
+// editUserHelpText returns the help text of a user field, with the user the
+// field is set to.
+func editUserHelpText(value interface{}) string {
+	current := "nobody"
+	if user, ok := value.(map[string]interface{}); ok {
+		if name, _ := user["displayName"].(string); name != "" {
+			current = name
+		}
+	}
+	return truncate(fmt.Sprintf("Currently %s. Search to change it.", current), model.DialogElementHelpTextMaxLength)
+}
+
+// editFieldValue returns the value of a field in the form the dialog uses.
+func editFieldValue(kind string, value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case map[string]interface{}:
+		id, _ := v["id"].(string)
+		return id
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			if s := editFieldValue(kind, item); s != "" {
+				values = append(values, s)
+			}
+		}
+		if kind == editFieldLabels {
+			return strings.Join(values, " ")
+		}
+		return strings.Join(values, ",")
+	}
+	return ""
+}
+
+// editFieldElement returns the dialog element of a field, or nil if the
+// field can not be edited in the dialog.
+func editFieldElement(id string, field *EditMetaField, value string) *model.DialogElement {
+	kind := editFieldKind(field)
+	if kind == "" {
+		return nil
+	}
+
+	element := &model.DialogElement{
+		DisplayName: field.Name,
+		Name:        id,
+		Type:        "text",
+		Default:     value,
+		Optional:    !field.Required,
+	}
+	if name := []rune(field.Name); len(name) > model.DialogElementDisplayNameMaxLength {
+		element.DisplayName = strings.TrimSpace(string(name[:model.DialogElementDisplayNameMaxLength-3])) + "..."
+		element.HelpText = field.Name
+	}
+
+	maxLength := model.DialogElementTextMaxLength
+	switch kind {
+	case editFieldText:
+		if field.Schema.Custom == customFieldTypeURL {
+			element.SubType = "url"
+		}
+		if len(value) > maxLength {
+			element.Type = "textarea"
+			maxLength = model.DialogElementTextareaMaxLength
+		}
+	case editFieldTextArea:
+		element.Type = "textarea"
+		maxLength = model.DialogElementTextareaMaxLength
+	case editFieldNumber:
+		element.SubType = "number"
+	case editFieldDate:
+		element.Placeholder = "YYYY-MM-DD"
+	case editFieldDateTime:
+		element.Placeholder = "YYYY-MM-DDThh:mm:ss.sss+0000"
+	case editFieldLabels:
+		element.HelpText = "Separate the labels with spaces."
+	case editFieldUser:
+		element.Type = "select"
+		element.DataSource = "dynamic"
+		element.DataSourceURL = fmt.Sprintf("/plugins/%s%s%s", manifest.Id, routeAPI, routeIssueEditUsers)
+		// The dialog shows the user ID of a default value, so the field is
+		// left empty to keep the current user, see editUserHelpText
+		element.Default = ""
+		element.Optional = true
+		maxLength = model.DialogElementSelectMaxLength
+	case editFieldSelect, editFieldMulti:
+		element.Type = "select"
+		element.MultiSelect = kind == editFieldMulti
+		maxLength = model.DialogElementSelectMaxLength
+		for _, allowed := range field.AllowedValues {
+			if allowed.Disabled {
+				continue
+			}
+			text := allowed.Name
+			if text == "" {
+				text = allowed.Value
+			}
+			element.Options = append(element.Options, &model.PostActionOptions{Text: text, Value: allowed.ID})
+		}
+	}
+
+	// A value that doesn't fit would be truncated, and saved truncated
+	if len(value) > maxLength {
+		return nil
+	}
+	return element
+}
+
+// editFieldJiraValue returns the value to set in Jira for the submitted
+// value of a field.
+func editFieldJiraValue(field *EditMetaField, submitted string) (interface{}, error) {
+	kind := editFieldKind(field)
+	if submitted == "" && kind != editFieldLabels && kind != editFieldMulti {
+		return nil, nil
+	}
+
+	switch kind {
+	case editFieldNumber:
+		n, err := strconv.ParseFloat(submitted, 64)
+		if err != nil {
+			return nil, errors.New("must be a number")
+		}
+		return n, nil
+	case editFieldDate:
+		if _, err := time.Parse(editDateFormat, submitted); err != nil {
+			return nil, errors.New("must be a date, like 2024-12-31")
+		}
+		return submitted, nil
+	case editFieldLabels:
+		labels := strings.FieldsFunc(submitted, func(r rune) bool {
+			return r == ' ' || r == ','
+		})
+		if labels == nil {
+			labels = []string{}
+		}
+		return labels, nil
+	case editFieldSelect:
+		return map[string]string{"id": submitted}, nil
+	case editFieldUser:
+		// The account ID on Jira Cloud, the username on Jira Server, see
+		// editFieldsToJiraUsers
+		return submitted, nil
+	case editFieldMulti:
+		values := []map[string]string{}
+		for _, id := range strings.Split(submitted, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				values = append(values, map[string]string{"id": id})
+			}
+		}
+		return values, nil
+	}
+	return submitted, nil
+}
+
+// sortedEditFieldIDs returns the IDs of the fields, with the summary and
+// the description first and the others by name.
+func sortedEditFieldIDs(fields map[string]*EditMetaField) []string {
+	rank := func(id string) int {
+		switch id {
+		case "summary":
+			return 0
+		case "description":
+			return 1
+		}
+		return 2
+	}
+
+	ids := make([]string, 0, len(fields))
+	for id := range fields {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if rank(ids[i]) != rank(ids[j]) {
+			return rank(ids[i]) < rank(ids[j])
+		}
+		return strings.ToLower(fields[ids[i]].Name) < strings.ToLower(fields[ids[j]].Name)
+	})
+	return ids
+}
+
+// makeEditIssueDialog returns the dialog to edit an issue, from its edit
+// metadata and the current values of its fields.
+func makeEditIssueDialog(instanceID types.ID, issueKey string, meta map[string]*EditMetaField, values map[string]interface{}) (*model.Dialog, error) {
+	state := editIssueState{
+		InstanceID: instanceID,
+		IssueKey:   issueKey,
+		Values:     map[string]string{},
+	}
+	var elements []model.DialogElement
+	var skipped []string
+	for _, id := range sortedEditFieldIDs(meta) {
+		field := meta[id]
+		kind := editFieldKind(field)
+		value := editFieldValue(kind, values[id])
+		// Rich text is edited as markdown, see editFieldsToJiraText
+		if kind == editFieldTextArea {
+			value = preProcessText(value)
+		}
+		// A user is changed only if another one is selected
+		if kind == editFieldUser {
+			value = ""
+		}
+		element := editFieldElement(id, field, value)
+		if element == nil {
+			skipped = append(skipped, field.Name)
+			continue
+		}
+		if kind == editFieldUser {
+			element.HelpText = editUserHelpText(values[id])
+		}
+		elements = append(elements, *element)
+		state.Values[id] = value
+	}
+	if len(elements) == 0 {
+		return nil, errors.Errorf("none of the fields of %s can be edited in Mattermost", issueKey)
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+	dialog := &model.Dialog{
+		CallbackId:  issueKey,
+		Title:       "Edit " + issueKey,
+		Elements:    elements,
+		SubmitLabel: "Save",
+		State:       string(data),
+	}
+	if len(dialog.Title) > model.DialogTitleMaxLength {
+		dialog.Title = "Edit issue"
+	}
+	if len(skipped) > 0 {
+		dialog.IntroductionText = fmt.Sprintf("These fields can only be edited in Jira: %s.", strings.Join(skipped, ", "))
+	}
+	return dialog, nil
+}
+
+func executeIssueEdit(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	// In a thread an issue was created from, the issue is edited by default.
+	if len(args) == 0 && header.RootId != "" {
+		threadIssue, err := p.loadThreadIssue(header.RootId)
+		if err == nil && threadIssue != nil {
+			args = []string{"--instance", threadIssue.InstanceID.String(), threadIssue.IssueKey}
+		}
+	}
+
+	user, instance, args, err := p.loadFlagUserInstance(header.UserId, args)
+	if err != nil {
+		return p.responsef(header, "Failed to load your connection to Jira. Error: %v.", err)
+	}
+	if len(args) != 1 {
+		return p.responsef(header, "Please specify an issue key in the form `/jira issue edit <issue-key>`.")
+	}
+	issueKey := strings.ToUpper(args[0])
+
+	client, _, _, err := p.getClient(instance.GetID(), user.MattermostUserID)
+	if err != nil {
+		return p.responsef(header, "Failed to load your connection to Jira. Error: %v.", err)
+	}
+	meta, err := client.GetEditMeta(issueKey)
+	if err != nil {
+		return p.responsef(header, "Failed to load the fields of %s. Error: %v.", issueKey, err)
+	}
+	if len(meta) == 0 {
+		return p.responsef(header, "You do not have the permission to edit %s.", issueKey)
+	}
+
+	ids := make([]string, 0, len(meta))
+	for id := range meta {
+		ids = append(ids, id)
+	}
+	var issue struct {
+		Fields map[string]interface{} `json:"fields"`
+	}
+	err = client.RESTGet(fmt.Sprintf("2/issue/%s", issueKey), map[string]string{"fields": strings.Join(ids, ",")}, &issue)
+	if err != nil {
+		return p.responsef(header, "Failed to load %s. Error: %v.", issueKey, err)
+	}
+
+	dialog, err := makeEditIssueDialog(instance.GetID(), issueKey, meta, issue.Fields)
+	if err != nil {
+		return p.responsef(header, "%v.", err)
+	}
+	err = p.client.Frontend.OpenInteractiveDialog(model.OpenDialogRequest{
+		TriggerId: header.TriggerId,
+		URL:       fmt.Sprintf("/plugins/%s%s%s", manifest.Id, routeAPI, routeIssueEdit),
+		Dialog:    *dialog,
+	})
+	if err != nil {
+		return p.responsef(header, "Failed to open the edit dialog. Error: %v.", err)
+	}
+	return &model.CommandResponse{}
+}
+
+func (p *Plugin) httpEditIssueDialog(w http.ResponseWriter, r *http.Request) (int, error) {
+	mattermostUserID := r.Header.Get("Mattermost-User-Id")
+	var request model.SubmitDialogRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return respondErr(w, http.StatusBadRequest, errors.WithMessage(err, "failed to decode the dialog submission"))
+	}
+	if request.UserId != mattermostUserID {
+		return respondErr(w, http.StatusForbidden, errors.New("the dialog was submitted by another user"))
+	}
+	var state editIssueState
+	if err := json.Unmarshal([]byte(request.State), &state); err != nil {
+		return respondErr(w, http.StatusBadRequest, errors.WithMessage(err, "failed to decode the dialog state"))
+	}
+
+	client, instance, _, err := p.getClient(state.InstanceID, types.ID(mattermostUserID))
+	if err != nil {
+		return respondJSON(w, &model.SubmitDialogResponse{Error: err.Error()})
+	}
+	meta, err := client.GetEditMeta(state.IssueKey)
+	if err != nil {
+		return respondJSON(w, &model.SubmitDialogResponse{Error: err.Error()})
+	}
+
+	fields, names, fieldErrors := editIssueFields(meta, state.Values, request.Submission)
+	if len(fieldErrors) > 0 {
+		return respondJSON(w, &model.SubmitDialogResponse{Errors: fieldErrors})
+	}
+	p.editFieldsToJiraText(instance, meta, fields)
+	editFieldsToJiraUsers(instance, meta, fields)
+
+	message := fmt.Sprintf("Nothing changed in [%s](%s/browse/%s).", state.IssueKey, instance.GetJiraBaseURL(), state.IssueKey)
+	if len(fields) > 0 {
+		err = client.UpdateIssue(state.IssueKey, map[string]interface{}{"fields": fields})
+		if err != nil {
+			return respondJSON(w, &model.SubmitDialogResponse{Error: err.Error()})
+		}
+		p.audit(mattermostUserID, AuditIssueEdit, state.InstanceID, state.IssueKey, strings.Join(names, ", "))
+		message = fmt.Sprintf("Updated %s of [%s](%s/browse/%s).", strings.Join(names, ", "), state.IssueKey, instance.GetJiraBaseURL(), state.IssueKey)
+	}
+
+	p.client.Post.SendEphemeralPost(mattermostUserID, &model.Post{
+		UserId:    p.getBotUserID(state.InstanceID),
+		ChannelId: request.ChannelId,
+		Message:   message,
+	})
+	return respondJSON(w, &model.SubmitDialogResponse{})
+}
+
+// editFieldsToJiraText converts the rich text fields, which are edited as
+// markdown, into the format Jira expects. The description is set in ADF on
+// Jira Cloud, see jiraCloudClient.UpdateIssue, and the other fields are set
+// with the v2 API, in wiki markup.
+func (p *Plugin) editFieldsToJiraText(instance Instance, meta map[string]*EditMetaField, fields map[string]interface{}) {
+	for id, value := range fields {
+		markdown, ok := value.(string)
+		if !ok || editFieldKind(meta[id]) != editFieldTextArea {
+			continue
+		}
+		if id == "description" {
+			fields[id] = p.markdownToJiraText(instance, markdown)
+		} else {
+			fields[id] = markdownToWiki(markdown, p.jiraMentionResolver(instance))
+		}
+	}
+}
+
+// editFieldsToJiraUsers converts the selected users into the references
+// Jira expects: the account ID on Jira Cloud, the username on Jira Server.
+func editFieldsToJiraUsers(instance Instance, meta map[string]*EditMetaField, fields map[string]interface{}) {
+	for id, value := range fields {
+		userID, ok := value.(string)
+		if !ok || editFieldKind(meta[id]) != editFieldUser {
+			continue
+		}
+		if instance.Common().Type == ServerInstanceType {
+			fields[id] = map[string]string{"name": userID}
+		} else {
+			fields[id] = map[string]string{"accountId": userID}
+		}
+	}
+}
+
+// httpEditIssueUsers returns the Jira users matching the search of a user
+// field in the edit dialog. These are the users assignable to the issue, as
+// in the assignee search of the create issue modal.
+func (p *Plugin) httpEditIssueUsers(w http.ResponseWriter, r *http.Request) (int, error) {
+	mattermostUserID := r.Header.Get("Mattermost-User-Id")
+	var request model.SubmitDialogRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return respondErr(w, http.StatusBadRequest, errors.WithMessage(err, "failed to decode the lookup request"))
+	}
+	var state editIssueState
+	if err := json.Unmarshal([]byte(request.State), &state); err != nil {
+		return respondErr(w, http.StatusBadRequest, errors.WithMessage(err, "failed to decode the dialog state"))
+	}
+	query, _ := request.Submission["query"].(string)
+
+	client, _, _, err := p.getClient(state.InstanceID, types.ID(mattermostUserID))
+	if err != nil {
+		return respondErr(w, http.StatusInternalServerError, err)
+	}
+	users, err := client.SearchUsersAssignableToIssue(state.IssueKey, query, editUserSearchMaxResults)
+	if err != nil {
+		return respondErr(w, http.StatusInternalServerError, err)
+	}
+	return respondJSON(w, &model.LookupDialogResponse{Items: editUserOptions(users)})
+}
+
+// editUserOptions returns the options of a user field for the Jira users.
+func editUserOptions(users []jira.User) []model.DialogSelectOption {
+	options := []model.DialogSelectOption{}
+	for _, user := range users {
+		value := user.AccountID
+		if value == "" {
+			value = user.Name
+		}
+		if value == "" {
+			continue
+		}
+		text := user.DisplayName
+		if text == "" {
+			text = value
+		}
+		options = append(options, model.DialogSelectOption{Text: text, Value: value})
+	}
+	return options
+}
+
+// editIssueFields returns the fields to update in Jira, and their names, for
+// the values the user changed in the dialog.
+func editIssueFields(meta map[string]*EditMetaField, original map[string]string, submission map[string]interface{}) (map[string]interface{}, []string, map[string]string) {
+	fields := map[string]interface{}{}
+	var names []string
+	fieldErrors := map[string]string{}
+	for _, id := range sortedEditFieldIDs(meta) {
+		before, ok := original[id]
+		if !ok {
+			continue
+		}
+		field := meta[id]
+		submitted := editFieldValue(editFieldKind(field), submission[id])
+		if submitted == before {
+			continue
+		}
+		value, err := editFieldJiraValue(field, submitted)
+		if err != nil {
+			fieldErrors[id] = field.Name + " " + err.Error() + "."
+			continue
+		}
+		fields[id] = value
+		names = append(names, field.Name)
+	}
+	return fields, names, fieldErrors
+}
@@ -0,0 +1,209 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testEditMeta() map[string]*EditMetaField {
+	set := []string{"set"}
+	return map[string]*EditMetaField{
+		"summary":     {Name: "Summary", Required: true, Operations: set, Schema: jira.FieldSchema{Type: "string", System: "summary"}},
+		"description": {Name: "Description", Operations: set, Schema: jira.FieldSchema{Type: "string", System: "description"}},
+		"labels":      {Name: "Labels", Operations: []string{"add", "set", "remove"}, Schema: jira.FieldSchema{Type: "array", Items: "string", System: "labels"}},
+		"priority": {Name: "Priority", Operations: set, Schema: jira.FieldSchema{Type: "priority", System: "priority"},
+			AllowedValues: []EditMetaAllowedValue{{ID: "1", Name: "High"}, {ID: "2", Name: "Low"}}},
+		"components": {Name: "Components", Operations: []string{"add", "set", "remove"}, Schema: jira.FieldSchema{Type: "array", Items: "component", System: "components"},
+			AllowedValues: []EditMetaAllowedValue{{ID: "10", Name: "API"}, {ID: "11", Name: "UI"}}},
+		"duedate":           {Name: "Due date", Operations: set, Schema: jira.FieldSchema{Type: "date", System: "duedate"}},
+		"customfield_10016": {Name: "Story point estimate for the team", Operations: set, Schema: jira.FieldSchema{Type: "number", Custom: "com.atlassian.jira.plugin.system.customfieldtypes:float"}},
+		"assignee":          {Name: "Assignee", Operations: set, Schema: jira.FieldSchema{Type: "user", System: "assignee"}},
+		"customfield_10014": {Name: "Epic Link", Operations: set, Schema: jira.FieldSchema{Type: "any", Custom: "com.pyxis.greenhopper.jira:gh-epic-link"}},
+		"comment":           {Name: "Comment", Operations: []string{"add"}, Schema: jira.FieldSchema{Type: "comments-page", System: "comment"}},
+	}
+}
+
+func TestMakeEditIssueDialog(t *testing.T) {
+	values := map[string]interface{}{
+		"summary":           "The login page is slow",
+		"description":       "h2. Steps\n*Open* the login page",
+		"labels":            []interface{}{"perf", "web"},
+		"priority":          map[string]interface{}{"id": "1", "name": "High"},
+		"components":        []interface{}{map[string]interface{}{"id": "10"}, map[string]interface{}{"id": "11"}},
+		"customfield_10016": 3.5,
+		"assignee":          map[string]interface{}{"accountId": "jane-id", "displayName": "Jane Doe"},
+	}
+
+	dialog, err := makeEditIssueDialog(testInstance1.InstanceID, "BUG-12", testEditMeta(), values)
+	require.NoError(t, err)
+	require.NoError(t, dialog.IsValid())
+	assert.Equal(t, "Edit BUG-12", dialog.Title)
+	assert.Equal(t, "These fields can only be edited in Jira: Comment, Epic Link.", dialog.IntroductionText)
+
+	var names []string
+	elements := map[string]int{}
+	for i, element := range dialog.Elements {
+		names = append(names, element.Name)
+		elements[element.Name] = i
+	}
+	assert.Equal(t, []string{"summary", "description", "assignee", "components", "duedate", "labels", "priority", "customfield_10016"}, names)
+
+	summary := dialog.Elements[elements["summary"]]
+	assert.Equal(t, "text", summary.Type)
+	assert.False(t, summary.Optional)
+	description := dialog.Elements[elements["description"]]
+	assert.Equal(t, "textarea", description.Type)
+	assert.Equal(t, "## Steps\n**Open** the login page", description.Default)
+	assert.Equal(t, "perf web", dialog.Elements[elements["labels"]].Default)
+	assert.Equal(t, "1", dialog.Elements[elements["priority"]].Default)
+	components := dialog.Elements[elements["components"]]
+	assert.True(t, components.MultiSelect)
+	assert.Equal(t, "10,11", components.Default)
+	points := dialog.Elements[elements["customfield_10016"]]
+	assert.Equal(t, "number", points.SubType)
+	assert.Equal(t, "3.5", points.Default)
+	assert.Equal(t, "Story point estimate...", points.DisplayName)
+	assert.Equal(t, "Story point estimate for the team", points.HelpText)
+	assignee := dialog.Elements[elements["assignee"]]
+	assert.Equal(t, "select", assignee.Type)
+	assert.Equal(t, "dynamic", assignee.DataSource)
+	assert.Equal(t, "/plugins/jira/api/v2/edit-issue-users", assignee.DataSourceURL)
+	assert.Empty(t, assignee.Default)
+	assert.True(t, assignee.Optional)
+	assert.Equal(t, "Currently Jane Doe. Search to change it.", assignee.HelpText)
+
+	var state editIssueState
+	require.NoError(t, json.Unmarshal([]byte(dialog.State), &state))
+	assert.Equal(t, testInstance1.InstanceID, state.InstanceID)
+	assert.Equal(t, "BUG-12", state.IssueKey)
+	assert.Equal(t, "perf web", state.Values["labels"])
+	assert.Equal(t, "## Steps\n**Open** the login page", state.Values["description"])
+	assert.Equal(t, "", state.Values["assignee"])
+}
+
+func TestEditIssueFields(t *testing.T) {
+	meta := testEditMeta()
+	original := map[string]string{
+		"summary":           "The login page is slow",
+		"description":       "",
+		"labels":            "perf web",
+		"priority":          "1",
+		"components":        "10,11",
+		"duedate":           "",
+		"customfield_10016": "3.5",
+	}
+
+	t.Run("changed fields", func(t *testing.T) {
+		fields, names, fieldErrors := editIssueFields(meta, original, map[string]interface{}{
+			"summary":           "The login page is slow",
+			"labels":            "perf, mobile",
+			"priority":          "1",
+			"components":        []interface{}{"11"},
+			"duedate":           "2024-12-31",
+			"customfield_10016": float64(5),
+		})
+		assert.Empty(t, fieldErrors)
+		assert.Equal(t, []string{"Components", "Due date", "Labels", "Story point estimate for the team"}, names)
+		assert.Equal(t, map[string]interface{}{
+			"components":        []map[string]string{{"id": "11"}},
+			"duedate":           "2024-12-31",
+			"labels":            []string{"perf", "mobile"},
+			"customfield_10016": float64(5),
+		}, fields)
+	})
+
+	t.Run("cleared fields", func(t *testing.T) {
+		fields, _, fieldErrors := editIssueFields(meta, original, map[string]interface{}{
+			"summary":           "The login page is slow",
+			"labels":            "",
+			"priority":          "",
+			"components":        "10,11",
+			"customfield_10016": "3.5",
+		})
+		assert.Empty(t, fieldErrors)
+		assert.Equal(t, map[string]interface{}{
+			"labels":   []string{},
+			"priority": nil,
+		}, fields)
+	})
+
+	t.Run("user", func(t *testing.T) {
+		withUser := map[string]string{"assignee": ""}
+		fields, names, fieldErrors := editIssueFields(meta, withUser, map[string]interface{}{"assignee": "jane-id"})
+		assert.Empty(t, fieldErrors)
+		assert.Equal(t, []string{"Assignee"}, names)
+		assert.Equal(t, map[string]interface{}{"assignee": "jane-id"}, fields)
+
+		// The field is left empty to keep the current user
+		fields, _, _ = editIssueFields(meta, withUser, map[string]interface{}{"assignee": ""})
+		assert.Empty(t, fields)
+	})
+
+	t.Run("invalid values", func(t *testing.T) {
+		_, _, fieldErrors := editIssueFields(meta, original, map[string]interface{}{
+			"summary":           "The login page is slow",
+			"labels":            "perf web",
+			"priority":          "1",
+			"components":        "10,11",
+			"duedate":           "tomorrow",
+			"customfield_10016": "many",
+		})
+		assert.Equal(t, map[string]string{
+			"duedate":           "Due date must be a date, like 2024-12-31.",
+			"customfield_10016": "Story point estimate for the team must be a number.",
+		}, fieldErrors)
+	})
+}
+
+func TestEditFieldsToJiraText(t *testing.T) {
+	p := setupTestPlugin(&plugintest.API{})
+	meta := testEditMeta()
+	meta["customfield_10020"] = &EditMetaField{Name: "Notes", Operations: []string{"set"}, Schema: jira.FieldSchema{Type: "string", Custom: customFieldTypeTextArea}}
+	fields := map[string]interface{}{
+		"summary":           "The **login** page is slow",
+		"description":       "## Steps\n**Open** the login page",
+		"customfield_10020": "**Seen** on mobile",
+		"priority":          nil,
+	}
+
+	p.editFieldsToJiraText(testInstance1, meta, fields)
+	assert.Equal(t, map[string]interface{}{
+		"summary":           "The **login** page is slow",
+		"description":       "h2. Steps\n\n*Open* the login page",
+		"customfield_10020": "*Seen* on mobile",
+		"priority":          nil,
+	}, fields)
+}
+
+func TestEditFieldsToJiraUsers(t *testing.T) {
+	meta := testEditMeta()
+
+	fields := map[string]interface{}{"assignee": "jane-id", "summary": "The login page is slow"}
+	editFieldsToJiraUsers(&testInstance{InstanceCommon: InstanceCommon{Type: CloudInstanceType}}, meta, fields)
+	assert.Equal(t, map[string]interface{}{"assignee": map[string]string{"accountId": "jane-id"}, "summary": "The login page is slow"}, fields)
+
+	fields = map[string]interface{}{"assignee": "jane"}
+	editFieldsToJiraUsers(&testInstance{InstanceCommon: InstanceCommon{Type: ServerInstanceType}}, meta, fields)
+	assert.Equal(t, map[string]interface{}{"assignee": map[string]string{"name": "jane"}}, fields)
+}
+
+func TestEditUserOptions(t *testing.T) {
+	options := editUserOptions([]jira.User{
+		{AccountID: "jane-id", DisplayName: "Jane Doe"},
+		{Name: "john"},
+		{DisplayName: "Nobody"},
+	})
+	assert.Equal(t, []model.DialogSelectOption{
+		{Text: "Jane Doe", Value: "jane-id"},
+		{Text: "john", Value: "john"},
+	}, options)
+}
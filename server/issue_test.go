@@ -10,6 +10,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"unicode/utf8"
 
 	jira "github.com/andygrunwald/go-jira"
 	"github.com/mattermost/mattermost/server/public/model"
@@ -201,6 +202,29 @@ func TestBuildPostActionContext(t *testing.T) {
 
 		api.AssertExpectations(t)
 	})
+
+	t.Run("accepts the posts of the bot of the instance", func(t *testing.T) {
+		api := &plugintest.API{}
+		plg := setupTestPlugin(api)
+		plg.updateConfig(func(conf *config) {
+			conf.botUserID = "bot-user"
+			conf.Secret = someSecret
+		})
+		plg.instanceStore.(*mockInstanceStoreKV).Instances.Get(testInstance1.InstanceID).BotUserID = "instance-bot"
+		api.On("GetPost", postID).Return(&model.Post{Id: postID, ChannelId: channelID, UserId: "instance-bot"}, nil)
+
+		req := makeRequest(issueKey)
+		req.Context["instance_id"] = testInstance1.InstanceID.String()
+		ctx, status, msg := plg.buildPostActionContext(authenticatedUserID, req, true)
+		assert.Equal(t, 0, status)
+		assert.Equal(t, "", msg)
+		assert.Equal(t, testInstance1.InstanceID.String(), ctx.instanceID)
+
+		req.Context["instance_id"] = testInstance2.InstanceID.String()
+		_, status, msg = plg.buildPostActionContext(authenticatedUserID, req, true)
+		assert.Equal(t, http.StatusUnauthorized, status)
+		assert.Equal(t, "user not authorized", msg)
+	})
 }
 
 func (client testClient) GetCreateMetaInfo(api plugin.API, options *jira.GetQueryOptions) (*jira.CreateMetaInfo, error) {
@@ -912,10 +936,11 @@ func TestGetUserSetting(t *testing.T) {
 
 func TestRouteAttachCommentToIssue(t *testing.T) {
 	type requestStruct struct {
-		PostID      string `json:"post_id"`
-		InstanceID  string `json:"instance_id"`
-		CurrentTeam string `json:"current_team"`
-		IssueKey    string `json:"issueKey"`
+		PostID        string `json:"post_id"`
+		InstanceID    string `json:"instance_id"`
+		CurrentTeam   string `json:"current_team"`
+		IssueKey      string `json:"issueKey"`
+		IncludeThread bool   `json:"include_thread"`
 	}
 
 	type testCase struct {
@@ -1062,6 +1087,62 @@ func TestRouteAttachCommentToIssue(t *testing.T) {
 				successfulUserPostSetup(api)
 			},
 		},
+		"Failed to load the thread": {
+			method: "POST",
+			header: "1",
+			request: &requestStruct{
+				PostID:        "2",
+				IssueKey:      existingIssueKey,
+				IncludeThread: true,
+			},
+			expectedCode: http.StatusInternalServerError,
+			setupMocks: func(api *plugintest.API) {
+				baseMocks(api)
+				api.On("GetPost", "2").Return(&model.Post{Id: "2", RootId: "1", UserId: "1", ChannelId: "test_channel"}, (*model.AppError)(nil)).Once()
+				api.On("GetChannelMember", mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(&model.ChannelMember{}, nil).Once()
+				api.On("GetPostThread", "1").Return(nil, model.NewAppError("GetPostThread", "store_error", nil, "", http.StatusInternalServerError)).Once()
+			},
+		},
+		"Thread not found": {
+			method: "POST",
+			header: "1",
+			request: &requestStruct{
+				PostID:        "2",
+				IssueKey:      existingIssueKey,
+				IncludeThread: true,
+			},
+			expectedCode: http.StatusNotFound,
+			setupMocks: func(api *plugintest.API) {
+				baseMocks(api)
+				api.On("GetPost", "2").Return(&model.Post{Id: "2", RootId: "1", UserId: "1", ChannelId: "test_channel"}, (*model.AppError)(nil)).Once()
+				api.On("GetChannelMember", mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(&model.ChannelMember{}, nil).Once()
+				api.On("GetPostThread", "1").Return(nil, model.NewAppError("GetPostThread", "not_found", nil, "", http.StatusNotFound)).Once()
+			},
+		},
+		"Successfully attached the thread": {
+			method: "POST",
+			header: "1",
+			request: &requestStruct{
+				PostID:        "2",
+				IssueKey:      existingIssueKey,
+				IncludeThread: true,
+			},
+			expectedCode: http.StatusOK,
+			setupMocks: func(api *plugintest.API) {
+				baseMocks(api)
+				api.On("GetPost", "2").Return(&model.Post{Id: "2", RootId: "1", UserId: "1", ChannelId: "test_channel"}, (*model.AppError)(nil)).Once()
+				api.On("GetChannelMember", mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(&model.ChannelMember{}, nil).Once()
+				api.On("GetPostThread", "1").Return(&model.PostList{Posts: map[string]*model.Post{
+					"1": {Id: "1", UserId: "1", Message: "root", CreateAt: 1},
+					"2": {Id: "2", UserId: "1", Message: "reply", CreateAt: 2},
+					"3": {Id: "3", UserId: "1", Type: model.PostTypeJoinChannel, CreateAt: 3},
+				}}, (*model.AppError)(nil)).Once()
+				api.On("GetUser", "1").Return(&model.User{Username: "username"}, (*model.AppError)(nil)).Once()
+				api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+					return post.RootId == "1" && strings.HasPrefix(post.Message, "Thread attached to")
+				})).Return(&model.Post{}, (*model.AppError)(nil)).Once()
+			},
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -1152,6 +1233,7 @@ func setupCreateIssueAPIMock() *plugintest.API {
 		Message: "User does not have access to this channel",
 	})
 
+	api.On("KVGet", hashkey(channelDefaultsKeyPrefix, "channel_id_1")).Return(nil, (*model.AppError)(nil))
 	api.On("SendEphemeralPost", mock.AnythingOfType("string"), mock.AnythingOfType("*model.Post")).Return(&model.Post{})
 	api.On("CreatePost", mock.AnythingOfType("*model.Post")).Return(&model.Post{}, (*model.AppError)(nil))
 	api.On("PublishWebSocketEvent", "update_defaults", mock.AnythingOfType("map[string]interface {}"), mock.AnythingOfType("*model.WebsocketBroadcast"))
@@ -1904,3 +1986,20 @@ func TestSprintDeduplication(t *testing.T) {
 		assert.Equal(t, 1, result[2].ID)
 	})
 }
+
+func TestFitJiraComment(t *testing.T) {
+	p := &Plugin{}
+	cloud := &testInstance{InstanceCommon: InstanceCommon{InstanceID: mockInstance2URL, Type: CloudInstanceType}}
+
+	short := "Some **bold** text"
+	assert.Equal(t, short, p.fitJiraComment(cloud, short))
+
+	// Every word becomes an ADF text node, much longer than the markdown
+	long := strings.Repeat("**bold** ", maxJiraCommentLength/9)
+	require.Less(t, utf8.RuneCountInString(long), maxJiraCommentLength)
+	require.Greater(t, p.jiraTextLength(cloud, long), maxJiraCommentLength)
+
+	fitted := p.fitJiraComment(cloud, long)
+	assert.LessOrEqual(t, p.jiraTextLength(cloud, fitted), maxJiraCommentLength)
+	assert.True(t, strings.HasSuffix(fitted, "..."))
+}
@@ -0,0 +1,228 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+
+	"github.com/mattermost/mattermost-plugin-jira/server/utils/types"
+)
+
+const transitionCommentField = "comment"
+
+// transitionIssueState is the state of the dialog that asks for the fields
+// of a transition screen.
+type transitionIssueState struct {
+	InstanceID   types.ID `json:"instance_id"`
+	IssueKey     string   `json:"issue_key"`
+	TransitionID string   `json:"transition_id"`
+	ToState      string   `json:"to_state"`
+}
+
+// hasRequiredFields returns true if the screen of the transition has required
+// fields. They may have a default value, see needsFieldValues.
+func hasRequiredFields(transition jira.Transition) bool {
+	for _, field := range transition.Fields {
+		if field.Required {
+			return true
+		}
+	}
+	return false
+}
+
+// needsValue returns true if a field of a transition screen must be filled
+// in, Jira sets the required fields that have a default value.
+func needsValue(field *EditMetaField) bool {
+	return field.Required && !field.HasDefaultValue
+}
+
+// needsFieldValues returns the fields of the screen of the transition if some
+// must be filled in, or nil if it can be done without them.
+func needsFieldValues(client Client, issueKey string, transition jira.Transition) (map[string]*EditMetaField, error) {
+	if !hasRequiredFields(transition) {
+		return nil, nil
+	}
+	fields, err := client.GetTransitionFields(issueKey, transition.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range fields {
+		if needsValue(field) {
+			return fields, nil
+		}
+	}
+	return nil, nil
+}
+
+// transitionFieldElement returns the dialog element of a field of a
+// transition screen. Unlike when editing an issue, a comment can be added,
+// and the users can only be set in Jira.
+func transitionFieldElement(id string, field *EditMetaField) *model.DialogElement {
+	if editFieldKind(field) == editFieldUser {
+		return nil
+	}
+	if id != transitionCommentField {
+		return editFieldElement(id, field, "")
+	}
+	return &model.DialogElement{
+		DisplayName: field.Name,
+		Name:        id,
+		Type:        "textarea",
+		Optional:    !needsValue(field),
+	}
+}
+
+// makeTransitionDialog returns the dialog that asks for the required fields
+// of a transition that have no default value, and the comment if the screen
+// has one.
+func makeTransitionDialog(instanceID types.ID, issueKey string, transition jira.Transition, fields map[string]*EditMetaField) (*model.Dialog, error) {
+	var elements []model.DialogElement
+	var unsupported []string
+	for _, id := range sortedEditFieldIDs(fields) {
+		field := fields[id]
+		if !needsValue(field) && id != transitionCommentField {
+			continue
+		}
+		element := transitionFieldElement(id, field)
+		if element == nil {
+			unsupported = append(unsupported, field.Name)
+			continue
+		}
+		elements = append(elements, *element)
+	}
+	if len(unsupported) > 0 {
+		return nil, errors.Errorf("the transition to `%s` requires fields that can only be set in Jira: %s", transition.To.Name, strings.Join(unsupported, ", "))
+	}
+
+	data, err := json.Marshal(transitionIssueState{
+		InstanceID:   instanceID,
+		IssueKey:     issueKey,
+		TransitionID: transition.ID,
+		ToState:      transition.To.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	dialog := &model.Dialog{
+		CallbackId:       issueKey,
+		Title:            transition.Name,
+		IntroductionText: fmt.Sprintf("Transition %s to **%s**.", issueKey, transition.To.Name),
+		Elements:         elements,
+		SubmitLabel:      "Transition",
+		State:            string(data),
+	}
+	if dialog.Title == "" || len(dialog.Title) > model.DialogTitleMaxLength {
+		dialog.Title = "Transition " + issueKey
+	}
+	if len(dialog.Title) > model.DialogTitleMaxLength {
+		dialog.Title = "Transition issue"
+	}
+	return dialog, nil
+}
+
+// openTransitionDialog opens the dialog to fill in the fields of the screen
+// of a transition. The transition is done when the dialog is submitted.
+func (p *Plugin) openTransitionDialog(instance Instance, in *InTransitionIssue, transition jira.Transition, fields map[string]*EditMetaField) (string, error) {
+	issueURL := fmt.Sprintf("%s/browse/%s", instance.GetJiraBaseURL(), in.IssueKey)
+	if in.triggerID == "" {
+		return "", errors.Errorf("the transition to `%s` requires fields to be filled in, please transition the issue in [Jira](%s)", transition.To.Name, issueURL)
+	}
+
+	dialog, err := makeTransitionDialog(instance.GetID(), in.IssueKey, transition, fields)
+	if err != nil {
+		return "", errors.Errorf("%v, please transition the issue in [Jira](%s)", err, issueURL)
+	}
+	err = p.client.Frontend.OpenInteractiveDialog(model.OpenDialogRequest{
+		TriggerId: in.triggerID,
+		URL:       fmt.Sprintf("/plugins/%s%s%s", manifest.Id, routeAPI, routeIssueTransitionDialog),
+		Dialog:    *dialog,
+	})
+	if err != nil {
+		return "", errors.WithMessage(err, "failed to open the transition dialog")
+	}
+	return fmt.Sprintf("Please fill in the fields required to transition [%s](%s) to `%s`.", in.IssueKey, issueURL, transition.To.Name), nil
+}
+
+// transitionFieldsData returns the "fields" and "update" operations to submit
+// with a transition, from the values entered in the dialog. The comment and
+// the rich text fields are entered as markdown, and converted with
+// toJiraText.
+func transitionFieldsData(fields map[string]*EditMetaField, submission map[string]interface{}, toJiraText func(markdown string) string) (map[string]interface{}, map[string]string) {
+	values := map[string]interface{}{}
+	fieldErrors := map[string]string{}
+	data := map[string]interface{}{"fields": values}
+	for id, field := range fields {
+		submitted := editFieldValue(editFieldKind(field), submission[id])
+		if submitted == "" {
+			continue
+		}
+		if id == transitionCommentField {
+			data["update"] = map[string]interface{}{
+				transitionCommentField: []map[string]interface{}{
+					{"add": map[string]string{"body": toJiraText(submitted)}},
+				},
+			}
+			continue
+		}
+		value, err := editFieldJiraValue(field, submitted)
+		if err != nil {
+			fieldErrors[id] = field.Name + " " + err.Error() + "."
+			continue
+		}
+		if editFieldKind(field) == editFieldTextArea {
+			value = toJiraText(submitted)
+		}
+		values[id] = value
+	}
+	return data, fieldErrors
+}
+
+func (p *Plugin) httpTransitionIssueDialog(w http.ResponseWriter, r *http.Request) (int, error) {
+	mattermostUserID := r.Header.Get("Mattermost-User-Id")
+	var request model.SubmitDialogRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return respondErr(w, http.StatusBadRequest, errors.WithMessage(err, "failed to decode the dialog submission"))
+	}
+	if request.UserId != mattermostUserID {
+		return respondErr(w, http.StatusForbidden, errors.New("the dialog was submitted by another user"))
+	}
+	var state transitionIssueState
+	if err := json.Unmarshal([]byte(request.State), &state); err != nil {
+		return respondErr(w, http.StatusBadRequest, errors.WithMessage(err, "failed to decode the dialog state"))
+	}
+
+	client, instance, _, err := p.getClient(state.InstanceID, types.ID(mattermostUserID))
+	if err != nil {
+		return respondJSON(w, &model.SubmitDialogResponse{Error: err.Error()})
+	}
+	fields, err := client.GetTransitionFields(state.IssueKey, state.TransitionID)
+	if err != nil {
+		return respondJSON(w, &model.SubmitDialogResponse{Error: err.Error()})
+	}
+
+	data, fieldErrors := transitionFieldsData(fields, request.Submission, func(markdown string) string {
+		return p.markdownToJiraText(instance, markdown)
+	})
+	if len(fieldErrors) > 0 {
+		return respondJSON(w, &model.SubmitDialogResponse{Errors: fieldErrors})
+	}
+	err = client.DoTransitionWithFields(state.IssueKey, state.TransitionID, data)
+	if err != nil {
+		return respondJSON(w, &model.SubmitDialogResponse{Error: err.Error()})
+	}
+
+	_, err = p.postIssueTransitioned(client, instance, types.ID(mattermostUserID), request.ChannelId, state.IssueKey, state.ToState)
+	if err != nil {
+		p.client.Log.Warn("Failed to post the transitioned issue", "issue", state.IssueKey, "error", err.Error())
+	}
+	return respondJSON(w, &model.SubmitDialogResponse{})
+}
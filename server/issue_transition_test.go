@@ -0,0 +1,150 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type transitionTestClient struct {
+	testClient
+	fields map[string]*EditMetaField
+}
+
+func (client transitionTestClient) GetTransitionFields(issueKey, transitionID string) (map[string]*EditMetaField, error) {
+	return client.fields, nil
+}
+
+func testTransitionFields() map[string]*EditMetaField {
+	return map[string]*EditMetaField{
+		"resolution": {Name: "Resolution", Required: true, Operations: []string{"set"}, Schema: jira.FieldSchema{Type: "resolution", System: "resolution"},
+			AllowedValues: []EditMetaAllowedValue{{ID: "1", Name: "Fixed"}, {ID: "2", Name: "Won't Fix"}}},
+		"fixVersions": {Name: "Fix versions", Operations: []string{"set", "add", "remove"}, Schema: jira.FieldSchema{Type: "array", Items: "version", System: "fixVersions"},
+			AllowedValues: []EditMetaAllowedValue{{ID: "100", Name: "1.0"}}},
+		"comment": {Name: "Comment", Operations: []string{"add"}, Schema: jira.FieldSchema{Type: "comments-page", System: "comment"}},
+	}
+}
+
+func TestMakeTransitionDialog(t *testing.T) {
+	transition := jira.Transition{ID: "31", Name: "Resolve", To: jira.Status{Name: "Done"}}
+
+	t.Run("required fields and comment", func(t *testing.T) {
+		dialog, err := makeTransitionDialog(testInstance1.InstanceID, "BUG-12", transition, testTransitionFields())
+		require.NoError(t, err)
+		require.NoError(t, dialog.IsValid())
+		assert.Equal(t, "Resolve", dialog.Title)
+		require.Len(t, dialog.Elements, 2)
+		assert.Equal(t, "comment", dialog.Elements[0].Name)
+		assert.Equal(t, "textarea", dialog.Elements[0].Type)
+		assert.True(t, dialog.Elements[0].Optional)
+		assert.Equal(t, "resolution", dialog.Elements[1].Name)
+		assert.Equal(t, "select", dialog.Elements[1].Type)
+		assert.False(t, dialog.Elements[1].Optional)
+
+		var state transitionIssueState
+		require.NoError(t, json.Unmarshal([]byte(dialog.State), &state))
+		assert.Equal(t, transitionIssueState{InstanceID: testInstance1.InstanceID, IssueKey: "BUG-12", TransitionID: "31", ToState: "Done"}, state)
+	})
+
+	t.Run("required field with a default value", func(t *testing.T) {
+		fields := testTransitionFields()
+		fields["resolution"].HasDefaultValue = true
+		dialog, err := makeTransitionDialog(testInstance1.InstanceID, "BUG-12", transition, fields)
+		require.NoError(t, err)
+		require.Len(t, dialog.Elements, 1)
+		assert.Equal(t, "comment", dialog.Elements[0].Name)
+	})
+
+	t.Run("unsupported required field", func(t *testing.T) {
+		fields := testTransitionFields()
+		fields["customfield_10020"] = &EditMetaField{Name: "Reviewer", Required: true, Operations: []string{"set"}, Schema: jira.FieldSchema{Type: "user"}}
+		_, err := makeTransitionDialog(testInstance1.InstanceID, "BUG-12", transition, fields)
+		assert.EqualError(t, err, "the transition to `Done` requires fields that can only be set in Jira: Reviewer")
+	})
+}
+
+func TestTransitionFieldsData(t *testing.T) {
+	data, fieldErrors := transitionFieldsData(testTransitionFields(), map[string]interface{}{
+		"resolution": "2",
+		"comment":    "Not **reproducible**",
+	}, func(markdown string) string {
+		return markdownToWiki(markdown, nil)
+	})
+	assert.Empty(t, fieldErrors)
+	assert.Equal(t, map[string]interface{}{
+		"fields": map[string]interface{}{
+			"resolution": map[string]string{"id": "2"},
+		},
+		"update": map[string]interface{}{
+			"comment": []map[string]interface{}{
+				{"add": map[string]string{"body": "Not *reproducible*"}},
+			},
+		},
+	}, data)
+}
+
+func TestOpenTransitionDialog(t *testing.T) {
+	api := &plugintest.API{}
+	p := setupTestPlugin(api)
+	instance, err := p.instanceStore.LoadInstance(testInstance1.InstanceID)
+	require.NoError(t, err)
+	client := transitionTestClient{fields: testTransitionFields()}
+	transition := jira.Transition{
+		ID:     "31",
+		To:     jira.Status{Name: "Done"},
+		Fields: map[string]jira.TransitionField{"resolution": {Required: true}},
+	}
+	assert.True(t, hasRequiredFields(transition))
+
+	t.Run("from a command or a post action", func(t *testing.T) {
+		api.On("OpenInteractiveDialog", mock.MatchedBy(func(request model.OpenDialogRequest) bool {
+			return request.TriggerId == "trigger-id" && request.URL == "/plugins/jira/api/v2/transition-dialog"
+		})).Return(nil).Once()
+
+		fields, err := needsFieldValues(client, "BUG-12", transition)
+		require.NoError(t, err)
+		msg, err := p.openTransitionDialog(instance, &InTransitionIssue{IssueKey: "BUG-12", triggerID: "trigger-id"}, transition, fields)
+		require.NoError(t, err)
+		api.AssertCalled(t, "OpenInteractiveDialog", mock.Anything)
+		assert.Equal(t, "Please fill in the fields required to transition [BUG-12]("+mockInstance1URL+"/browse/BUG-12) to `Done`.", msg)
+	})
+
+	t.Run("without a trigger", func(t *testing.T) {
+		_, err := p.openTransitionDialog(instance, &InTransitionIssue{IssueKey: "BUG-12"}, transition, client.fields)
+		assert.EqualError(t, err, "the transition to `Done` requires fields to be filled in, please transition the issue in [Jira]("+mockInstance1URL+"/browse/BUG-12)")
+	})
+}
+
+func TestNeedsFieldValues(t *testing.T) {
+	transition := jira.Transition{ID: "31", Fields: map[string]jira.TransitionField{"resolution": {Required: true}}}
+
+	t.Run("required field without a default value", func(t *testing.T) {
+		client := transitionTestClient{fields: testTransitionFields()}
+		fields, err := needsFieldValues(client, "BUG-12", transition)
+		require.NoError(t, err)
+		assert.Equal(t, client.fields, fields)
+	})
+
+	t.Run("required field with a default value", func(t *testing.T) {
+		client := transitionTestClient{fields: testTransitionFields()}
+		client.fields["resolution"].HasDefaultValue = true
+		fields, err := needsFieldValues(client, "BUG-12", transition)
+		require.NoError(t, err)
+		assert.Nil(t, fields)
+	})
+
+	t.Run("no required fields", func(t *testing.T) {
+		fields, err := needsFieldValues(transitionTestClient{}, "BUG-12", jira.Transition{ID: "11"})
+		require.NoError(t, err)
+		assert.Nil(t, fields)
+	})
+}
@@ -41,6 +41,7 @@ type Store interface {
 	UserStore
 	SecretsStore
 	OTSStore
+	AuditStore
 }
 
 type SecretsStore interface {
@@ -103,6 +104,19 @@ func hashkey(prefix, key string) string {
 	return fmt.Sprintf("%s%x", prefix, h.Sum(nil))
 }
 
+// instanceKeyPrefix is the common prefix of the instanceKey keys of the
+// instance, so that they can be listed when the instance is purged or
+// migrated.
+func instanceKeyPrefix(prefix string, instanceID types.ID) string {
+	return hashkey(prefix, instanceID.String()) + "_"
+}
+
+// instanceKey is a key of per-issue data of an instance, like the markers of
+// reminders and incident channels, see instanceKeyPrefix.
+func instanceKey(prefix string, instanceID types.ID, key string) string {
+	return hashkey(instanceKeyPrefix(prefix, instanceID), key)
+}
+
 func (store store) get(key string, v interface{}) (returnErr error) {
 	defer func() {
 		if returnErr == nil {
@@ -789,3 +803,23 @@ func (p *Plugin) MigrateV2User(mattermostUserID types.ID) (*User, error) {
 
 	return user, nil
 }
+
+// listKeysWithPrefix returns all the keys that start with the prefix.
+func (p *Plugin) listKeysWithPrefix(prefix string) ([]string, error) {
+	var result []string
+	for i := 0; ; i++ {
+		keys, err := p.client.KV.ListKeys(i, listPerPage)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			if strings.HasPrefix(key, prefix) {
+				result = append(result, key)
+			}
+		}
+		if len(keys) < listPerPage {
+			break
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,650 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/mattermost/mattermost-plugin-jira/server/utils/types"
+)
+
+var (
+	mdFenceRegex     = regexp.MustCompile("^\\s*(```+|~~~+)\\s*([\\w+#.-]*)")
+	mdHeadingRegex   = regexp.MustCompile(`^\s{0,3}(#{1,6})\s+(.*?)\s*#*\s*$`)
+	mdRuleRegex      = regexp.MustCompile(`^\s{0,3}((-\s*){3,}|(\*\s*){3,}|(_\s*){3,})$`)
+	mdQuoteRegex     = regexp.MustCompile(`^\s{0,3}>\s?(.*)$`)
+	mdListRegex      = regexp.MustCompile(`^(\s*)([-*+]|\d{1,9}[.)])\s+(.*)$`)
+	mdTableSepRegex  = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	mdUsernameRegex  = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*`)
+	mdEmojiNameRegex = regexp.MustCompile(`^:([a-z0-9_+-]+):`)
+)
+
+// jiraMentionResolver maps a Mattermost username to the Jira user it's connected to.
+// The returned ID is an account ID on Jira Cloud and a username on Jira Server.
+type jiraMentionResolver func(mattermostUsername string) (jiraID, displayName string, ok bool)
+
+// mdEmoji maps common Mattermost emoji names to unicode and, where one exists,
+// the matching Jira wiki emoticon.
+var mdEmoji = map[string]struct {
+	unicode  string
+	emoticon string
+}{
+	"+1":                     {"👍", "(y)"},
+	"thumbsup":               {"👍", "(y)"},
+	"-1":                     {"👎", "(n)"},
+	"thumbsdown":             {"👎", "(n)"},
+	"white_check_mark":       {"✅", "(/)"},
+	"heavy_check_mark":       {"✔️", "(/)"},
+	"x":                      {"❌", "(x)"},
+	"warning":                {"⚠️", "(!)"},
+	"information_source":     {"ℹ️", "(i)"},
+	"question":               {"❓", "(?)"},
+	"bulb":                   {"💡", "(on)"},
+	"star":                   {"⭐", "(*)"},
+	"smile":                  {"😄", ":D"},
+	"smiley":                 {"😃", ":D"},
+	"slightly_smiling_face":  {"🙂", ":)"},
+	"slightly_frowning_face": {"🙁", ":("},
+	"wink":                   {"😉", ";)"},
+	"stuck_out_tongue":       {"😛", ":P"},
+	"heart":                  {"❤️", ""},
+	"tada":                   {"🎉", ""},
+	"fire":                   {"🔥", ""},
+	"rocket":                 {"🚀", ""},
+	"eyes":                   {"👀", ""},
+	"pray":                   {"🙏", ""},
+	"joy":                    {"😂", ""},
+	"thinking_face":          {"🤔", ""},
+}
+
+// markdownToADF parses Mattermost markdown into an ADF document. It is also the
+// intermediate form used to produce wiki markup, see markdownToWiki.
+func markdownToADF(markdown string, resolve jiraMentionResolver) *adfNode {
+	parser := mdParser{resolve: resolve}
+	lines := strings.Split(strings.ReplaceAll(markdown, "\r\n", "\n"), "\n")
+	return &adfNode{
+		Type:    "doc",
+		Version: 1,
+		Content: parser.blocks(lines),
+	}
+}
+
+// markdownToADFString returns the document produced by markdownToADF serialized as JSON,
+// which is how rich text travels in jira.Comment and jira.IssueFields.
+func markdownToADFString(markdown string, resolve jiraMentionResolver) string {
+	bb, err := json.Marshal(markdownToADF(markdown, resolve))
+	if err != nil {
+		return markdown
+	}
+	return string(bb)
+}
+
+// markdownToWiki converts Mattermost markdown into Jira wiki markup.
+func markdownToWiki(markdown string, resolve jiraMentionResolver) string {
+	return adfToWiki(markdownToADF(markdown, resolve))
+}
+
+// markdownToJiraText converts markdown written in Mattermost into the rich text format the
+// instance expects: ADF for Jira Cloud and wiki markup for Jira Server.
+func (p *Plugin) markdownToJiraText(instance Instance, markdown string) string {
+	resolve := p.jiraMentionResolver(instance)
+	if instance.Common().IsCloudInstance() {
+		return markdownToADFString(markdown, resolve)
+	}
+	return markdownToWiki(markdown, resolve)
+}
+
+// jiraMentionResolver resolves @mentions to the Jira accounts of users connected to instance.
+func (p *Plugin) jiraMentionResolver(instance Instance) jiraMentionResolver {
+	return func(username string) (string, string, bool) {
+		user, err := p.client.User.GetByUsername(username)
+		if err != nil || user == nil {
+			return "", "", false
+		}
+		connection, err := p.userStore.LoadConnection(instance.GetID(), types.ID(user.Id))
+		if err != nil {
+			return "", "", false
+		}
+		if instance.Common().IsCloudInstance() {
+			return connection.AccountID, connection.DisplayName, connection.AccountID != ""
+		}
+		return connection.Name, connection.DisplayName, connection.Name != ""
+	}
+}
+
+type mdParser struct {
+	resolve jiraMentionResolver
+}
+
+func (mp mdParser) blocks(lines []string) []*adfNode {
+	var nodes []*adfNode
+	for i := 0; i < len(lines); {
+		line := lines[i]
+
+		if strings.TrimSpace(line) == "" {
+			i++
+			continue
+		}
+
+		if m := mdFenceRegex.FindStringSubmatch(line); m != nil {
+			var code []string
+			i++
+			for ; i < len(lines); i++ {
+				if strings.HasPrefix(strings.TrimSpace(lines[i]), m[1]) {
+					i++
+					break
+				}
+				code = append(code, lines[i])
+			}
+			node := &adfNode{Type: "codeBlock"}
+			if m[2] != "" {
+				node.Attrs = map[string]interface{}{"language": m[2]}
+			}
+			if len(code) > 0 {
+				node.Content = []*adfNode{{Type: "text", Text: strings.Join(code, "\n")}}
+			}
+			nodes = append(nodes, node)
+			continue
+		}
+
+		if m := mdHeadingRegex.FindStringSubmatch(line); m != nil {
+			nodes = append(nodes, &adfNode{
+				Type:    "heading",
+				Attrs:   map[string]interface{}{"level": len(m[1])},
+				Content: mp.inline(m[2], nil),
+			})
+			i++
+			continue
+		}
+
+		if mdRuleRegex.MatchString(line) {
+			nodes = append(nodes, &adfNode{Type: "rule"})
+			i++
+			continue
+		}
+
+		if mdQuoteRegex.MatchString(line) {
+			var quoted []string
+			for ; i < len(lines); i++ {
+				m := mdQuoteRegex.FindStringSubmatch(lines[i])
+				if m == nil {
+					break
+				}
+				quoted = append(quoted, m[1])
+			}
+			nodes = append(nodes, &adfNode{Type: "blockquote", Content: mp.blocks(quoted)})
+			continue
+		}
+
+		if i+1 < len(lines) && strings.Contains(line, "|") && mdTableSepRegex.MatchString(lines[i+1]) && strings.Contains(lines[i+1], "-") {
+			var node *adfNode
+			node, i = mp.table(lines, i)
+			nodes = append(nodes, node)
+			continue
+		}
+
+		if mdListRegex.MatchString(line) {
+			var node *adfNode
+			node, i = mp.list(lines, i)
+			nodes = append(nodes, node)
+			continue
+		}
+
+		var paragraph []string
+		for ; i < len(lines) && !mp.startsBlock(lines, i); i++ {
+			paragraph = append(paragraph, strings.TrimSpace(lines[i]))
+		}
+		nodes = append(nodes, &adfNode{Type: "paragraph", Content: mp.inlineLines(paragraph)})
+	}
+	return nodes
+}
+
+func (mp mdParser) startsBlock(lines []string, i int) bool {
+	line := lines[i]
+	return strings.TrimSpace(line) == "" ||
+		mdFenceRegex.MatchString(line) ||
+		mdHeadingRegex.MatchString(line) ||
+		mdRuleRegex.MatchString(line) ||
+		mdQuoteRegex.MatchString(line) ||
+		mdListRegex.MatchString(line)
+}
+
+// inlineLines parses the lines of a paragraph, keeping Mattermost's behavior of
+// rendering single newlines as line breaks.
+func (mp mdParser) inlineLines(lines []string) []*adfNode {
+	var nodes []*adfNode
+	for i, line := range lines {
+		if i > 0 {
+			nodes = append(nodes, &adfNode{Type: "hardBreak"})
+		}
+		nodes = append(nodes, mp.inline(line, nil)...)
+	}
+	return nodes
+}
+
+func (mp mdParser) list(lines []string, start int) (*adfNode, int) {
+	first := mdListRegex.FindStringSubmatch(lines[start])
+	indent := len(first[1])
+	ordered := !strings.ContainsAny(first[2][:1], "-*+")
+
+	list := &adfNode{Type: "bulletList"}
+	if ordered {
+		list.Type = "orderedList"
+		order, _ := strconv.Atoi(strings.TrimRight(first[2], ".)"))
+		list.Attrs = map[string]interface{}{"order": order}
+	}
+
+	i := start
+	for i < len(lines) {
+		m := mdListRegex.FindStringSubmatch(lines[i])
+		if m == nil || len(m[1]) != indent || ordered == strings.ContainsAny(m[2][:1], "-*+") {
+			break
+		}
+
+		itemLines := []string{m[3]}
+		var nested []*adfNode
+		for i++; i < len(lines); {
+			line := lines[i]
+			if strings.TrimSpace(line) == "" {
+				break
+			}
+			if sub := mdListRegex.FindStringSubmatch(line); sub != nil {
+				if len(sub[1]) <= indent {
+					break
+				}
+				var node *adfNode
+				node, i = mp.list(lines, i)
+				nested = append(nested, node)
+				continue
+			}
+			if len(nested) > 0 || mp.startsBlock(lines, i) {
+				break
+			}
+			itemLines = append(itemLines, strings.TrimSpace(line))
+			i++
+		}
+
+		item := &adfNode{
+			Type:    "listItem",
+			Content: []*adfNode{{Type: "paragraph", Content: mp.inlineLines(itemLines)}},
+		}
+		item.Content = append(item.Content, nested...)
+		list.Content = append(list.Content, item)
+	}
+	return list, i
+}
+
+func (mp mdParser) table(lines []string, start int) (*adfNode, int) {
+	table := &adfNode{Type: "table"}
+	cellType := "tableHeader"
+	i := start
+	for ; i < len(lines) && strings.Contains(lines[i], "|"); i++ {
+		if i == start+1 {
+			// the alignment row
+			continue
+		}
+
+		row := &adfNode{Type: "tableRow"}
+		for _, cell := range splitMarkdownTableRow(lines[i]) {
+			row.Content = append(row.Content, &adfNode{
+				Type:    cellType,
+				Content: []*adfNode{{Type: "paragraph", Content: mp.inline(cell, nil)}},
+			})
+		}
+		table.Content = append(table.Content, row)
+		cellType = "tableCell"
+	}
+	return table, i
+}
+
+func splitMarkdownTableRow(row string) []string {
+	row = strings.TrimSpace(row)
+	row = strings.TrimPrefix(row, "|")
+	if strings.HasSuffix(row, "|") && !strings.HasSuffix(row, `\|`) {
+		row = row[:len(row)-1]
+	}
+
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(row); i++ {
+		switch {
+		case row[i] == '\\' && i+1 < len(row) && row[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case row[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(row[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+func withMark(marks []adfMark, mark adfMark) []adfMark {
+	out := make([]adfMark, 0, len(marks)+1)
+	out = append(out, marks...)
+	return append(out, mark)
+}
+
+// inline parses a single line of inline markdown into text, mention and emoji nodes.
+func (mp mdParser) inline(s string, marks []adfMark) []*adfNode {
+	var nodes []*adfNode
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			nodes = append(nodes, &adfNode{Type: "text", Text: text.String(), Marks: marks})
+			text.Reset()
+		}
+	}
+	emit := func(children ...*adfNode) {
+		flush()
+		nodes = append(nodes, children...)
+	}
+
+	for i := 0; i < len(s); {
+		c := s[i]
+		rest := s[i:]
+
+		switch {
+		case c == '\\' && i+1 < len(s) && unicode.IsPunct(rune(s[i+1])):
+			text.WriteByte(s[i+1])
+			i += 2
+			continue
+
+		case c == '`':
+			ticks := len(rest) - len(strings.TrimLeft(rest, "`"))
+			if end := strings.Index(rest[ticks:], rest[:ticks]); end >= 0 {
+				code := strings.TrimSpace(rest[ticks : ticks+end])
+				codeMarks := []adfMark{{Type: "code"}}
+				for _, mark := range marks {
+					if mark.Type == "link" {
+						codeMarks = append(codeMarks, mark)
+					}
+				}
+				emit(&adfNode{Type: "text", Text: code, Marks: codeMarks})
+				i += 2*ticks + end
+				continue
+			}
+
+		case strings.HasPrefix(rest, "**") || strings.HasPrefix(rest, "__"):
+			if end := strings.Index(rest[2:], rest[:2]); end > 0 {
+				emit(mp.inline(rest[2:2+end], withMark(marks, adfMark{Type: "strong"}))...)
+				i += end + 4
+				continue
+			}
+
+		case strings.HasPrefix(rest, "~~"):
+			if end := strings.Index(rest[2:], "~~"); end > 0 {
+				emit(mp.inline(rest[2:2+end], withMark(marks, adfMark{Type: "strike"}))...)
+				i += end + 4
+				continue
+			}
+
+		case c == '*' || c == '_':
+			if end := findMarkdownEmphasisClose(s, i); end > 0 {
+				emit(mp.inline(s[i+1:end], withMark(marks, adfMark{Type: "em"}))...)
+				i = end + 1
+				continue
+			}
+
+		case c == '[':
+			if label, href, n := parseMarkdownLink(rest); n > 0 {
+				link := adfMark{Type: "link", Attrs: map[string]interface{}{"href": href}}
+				emit(mp.inline(label, withMark(marks, link))...)
+				i += n
+				continue
+			}
+
+		case c == '<':
+			if end := strings.IndexByte(rest, '>'); end > 0 && hasWikiLinkScheme(rest[1:end]) {
+				href := rest[1:end]
+				link := adfMark{Type: "link", Attrs: map[string]interface{}{"href": href}}
+				emit(&adfNode{Type: "text", Text: href, Marks: withMark(marks, link)})
+				i += end + 1
+				continue
+			}
+
+		case c == '@' && mp.resolve != nil && !isWikiWordRune(runeBefore(s, i)):
+			username := strings.TrimRight(mdUsernameRegex.FindString(strings.ToLower(rest[1:])), ".-_")
+			if username != "" {
+				if id, displayName, ok := mp.resolve(username); ok {
+					if displayName == "" {
+						displayName = username
+					}
+					emit(&adfNode{Type: "mention", Attrs: map[string]interface{}{"id": id, "text": "@" + displayName}})
+					i += 1 + len(username)
+					continue
+				}
+			}
+
+		case c == ':' && !isWikiWordRune(runeBefore(s, i)):
+			if m := mdEmojiNameRegex.FindStringSubmatch(rest); m != nil {
+				attrs := map[string]interface{}{"shortName": m[0]}
+				if e, ok := mdEmoji[m[1]]; ok {
+					attrs["text"] = e.unicode
+				}
+				emit(&adfNode{Type: "emoji", Attrs: attrs})
+				i += len(m[0])
+				continue
+			}
+		}
+
+		text.WriteByte(c)
+		i++
+	}
+	flush()
+	return nodes
+}
+
+// findMarkdownEmphasisClose finds the end of *em* or _em_ opened at i. Underscores
+// inside words, as in snake_case, don't count.
+func findMarkdownEmphasisClose(s string, i int) int {
+	c := s[i]
+	if i+1 >= len(s) || unicode.IsSpace(runeAt(s, i+1)) || s[i+1] == c {
+		return -1
+	}
+	if c == '_' && isWikiWordRune(runeBefore(s, i)) {
+		return -1
+	}
+	for j := i + 2; j < len(s); j++ {
+		if s[j] != c || unicode.IsSpace(runeBefore(s, j)) {
+			continue
+		}
+		if j+1 < len(s) && (s[j+1] == c || (c == '_' && isWikiWordRune(runeAt(s, j+1)))) {
+			continue
+		}
+		return j
+	}
+	return -1
+}
+
+// parseMarkdownLink parses [label](href) at the start of s, returning the number of bytes consumed.
+func parseMarkdownLink(s string) (label, href string, n int) {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth > 0 {
+				continue
+			}
+			if i+1 >= len(s) || s[i+1] != '(' {
+				return "", "", 0
+			}
+			end := strings.IndexByte(s[i+2:], ')')
+			if end < 0 {
+				return "", "", 0
+			}
+			href = strings.TrimSpace(s[i+2 : i+2+end])
+			if title := strings.Index(href, ` "`); title > 0 {
+				href = href[:title]
+			}
+			return s[1:i], href, i + 3 + end
+		}
+	}
+	return "", "", 0
+}
+
+// adfToWiki renders an ADF document as Jira wiki markup.
+func adfToWiki(doc *adfNode) string {
+	return strings.TrimSpace(wikiFromADFBlocks(doc.Content, ""))
+}
+
+func wikiFromADFBlocks(nodes []*adfNode, listPrefix string) string {
+	blocks := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		if block := wikiFromADFBlock(node, listPrefix); block != "" {
+			blocks = append(blocks, block)
+		}
+	}
+	if listPrefix != "" {
+		return strings.Join(blocks, "\n")
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+func wikiFromADFBlock(node *adfNode, listPrefix string) string {
+	switch node.Type {
+	case "paragraph":
+		return wikiFromADFInline(node.Content)
+
+	case "heading":
+		return "h" + strconv.Itoa(adfAttrInt(node.Attrs, "level", 1)) + ". " + wikiFromADFInline(node.Content)
+
+	case "bulletList", "orderedList":
+		marker := "*"
+		if node.Type == "orderedList" {
+			marker = "#"
+		}
+		prefix := listPrefix + marker
+		items := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			var parts []string
+			for i, child := range item.Content {
+				if child.Type == "bulletList" || child.Type == "orderedList" {
+					parts = append(parts, wikiFromADFBlock(child, prefix))
+					continue
+				}
+				text := strings.ReplaceAll(wikiFromADFBlock(child, ""), "\n", " ")
+				if i == 0 {
+					text = prefix + " " + text
+				}
+				parts = append(parts, text)
+			}
+			items = append(items, strings.Join(parts, "\n"))
+		}
+		return strings.Join(items, "\n")
+
+	case "codeBlock":
+		var sb strings.Builder
+		for _, child := range node.Content {
+			sb.WriteString(child.Text)
+		}
+		if language := adfAttrString(node.Attrs, "language"); language != "" {
+			return "{code:" + language + "}\n" + sb.String() + "\n{code}"
+		}
+		return "{code}\n" + sb.String() + "\n{code}"
+
+	case "blockquote":
+		return "{quote}\n" + wikiFromADFBlocks(node.Content, "") + "\n{quote}"
+
+	case "rule":
+		return "----"
+
+	case "table":
+		rows := make([]string, 0, len(node.Content))
+		for _, row := range node.Content {
+			var sb strings.Builder
+			sep := "|"
+			for _, cell := range row.Content {
+				sep = "|"
+				if cell.Type == "tableHeader" {
+					sep = "||"
+				}
+				text := strings.ReplaceAll(wikiFromADFBlocks(cell.Content, ""), "\n", " ")
+				sb.WriteString(sep + strings.ReplaceAll(text, "|", `\|`))
+			}
+			sb.WriteString(sep)
+			rows = append(rows, sb.String())
+		}
+		return strings.Join(rows, "\n")
+	}
+
+	return wikiFromADFInline([]*adfNode{node})
+}
+
+func wikiFromADFInline(nodes []*adfNode) string {
+	var sb strings.Builder
+	for _, node := range nodes {
+		switch node.Type {
+		case "text":
+			sb.WriteString(wikiFromADFText(node))
+		case "hardBreak":
+			sb.WriteString("\n")
+		case "mention":
+			sb.WriteString("[~" + adfAttrString(node.Attrs, "id") + "]")
+		case "emoji":
+			name := strings.Trim(adfAttrString(node.Attrs, "shortName"), ":")
+			switch e, ok := mdEmoji[name]; {
+			case ok && e.emoticon != "":
+				sb.WriteString(e.emoticon)
+			case ok:
+				sb.WriteString(e.unicode)
+			default:
+				sb.WriteString(":" + name + ":")
+			}
+		}
+	}
+	return sb.String()
+}
+
+func wikiFromADFText(node *adfNode) string {
+	text := node.Text
+	link := ""
+	isCode := false
+	for _, mark := range node.Marks {
+		if mark.Type == "code" {
+			isCode = true
+		}
+	}
+
+	if isCode {
+		text = "{{" + text + "}}"
+	} else {
+		text = escapeWikiText(text)
+	}
+
+	for _, mark := range node.Marks {
+		switch mark.Type {
+		case "strong":
+			text = "*" + text + "*"
+		case "em":
+			text = "_" + text + "_"
+		case "strike":
+			text = "-" + text + "-"
+		case "link":
+			link = adfAttrString(mark.Attrs, "href")
+		}
+	}
+
+	if link != "" {
+		if text == link {
+			return "[" + link + "]"
+		}
+		return "[" + text + "|" + link + "]"
+	}
+	return text
+}
+
+// escapeWikiText escapes the characters that would start a macro or a link in Jira.
+func escapeWikiText(text string) string {
+	return strings.NewReplacer("{", `\{`, "[", `\[`).Replace(text)
+}
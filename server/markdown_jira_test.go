@@ -0,0 +1,120 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testMentionResolver(username string) (string, string, bool) {
+	if username == "bob" {
+		return "bob.jira", "Bob Builder", true
+	}
+	return "", "", false
+}
+
+func TestMarkdownToWiki(t *testing.T) {
+	tests := map[string]struct {
+		input          string
+		expectedOutput string
+	}{
+		"Inline formatting": {
+			input:          "**bold** _italic_ *also italic* ~~gone~~ `code`",
+			expectedOutput: "*bold* _italic_ _also italic_ -gone- {{code}}",
+		},
+		"Words with underscores are left alone": {
+			input:          "snake_case_name",
+			expectedOutput: "snake_case_name",
+		},
+		"Links": {
+			input:          "[docs](https://example.com/docs) and <https://example.com>",
+			expectedOutput: "[docs|https://example.com/docs] and [https://example.com]",
+		},
+		"Headings and line breaks": {
+			input:          "## Title\nline one\nline two",
+			expectedOutput: "h2. Title\n\nline one\nline two",
+		},
+		"Code fence": {
+			input:          "```go\nfmt.Println(\"{hi}\")\n```",
+			expectedOutput: "{code:go}\nfmt.Println(\"{hi}\")\n{code}",
+		},
+		"Code fence without language": {
+			input:          "```\nplain\n```",
+			expectedOutput: "{code}\nplain\n{code}",
+		},
+		"Nested lists": {
+			input:          "- one\n  1. first\n  2. second\n- two",
+			expectedOutput: "* one\n*# first\n*# second\n* two",
+		},
+		"Table": {
+			input:          "| Service | Status |\n| --- | :---: |\n| api | ok |",
+			expectedOutput: "||Service||Status||\n|api|ok|",
+		},
+		"Quote and rule": {
+			input:          "> quoted [WIP]\n\n---",
+			expectedOutput: "{quote}\nquoted \\[WIP]\n{quote}\n\n----",
+		},
+		"Mentions": {
+			input:          "ping @bob and @carol, or email bob@example.com",
+			expectedOutput: "ping [~bob.jira] and @carol, or email bob@example.com",
+		},
+		"Escaped mention": {
+			input:          `\@bob`,
+			expectedOutput: "@bob",
+		},
+		"Emoji": {
+			input:          ":thumbsup: :tada: :custom_emoji:",
+			expectedOutput: "(y) 🎉 :custom_emoji:",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedOutput, markdownToWiki(tc.input, testMentionResolver))
+		})
+	}
+}
+
+func TestMarkdownToADF(t *testing.T) {
+	body := markdownToADFString("hi @bob :tada:\n\n```sh\nmake\n```", testMentionResolver)
+
+	doc, ok := parseADFDocument(body)
+	require.True(t, ok)
+	require.Len(t, doc.Content, 2)
+
+	paragraph := doc.Content[0]
+	require.Len(t, paragraph.Content, 4)
+	assert.Equal(t, "mention", paragraph.Content[1].Type)
+	assert.Equal(t, "bob.jira", paragraph.Content[1].Attrs["id"])
+	assert.Equal(t, "@Bob Builder", paragraph.Content[1].Attrs["text"])
+	assert.Equal(t, "emoji", paragraph.Content[3].Type)
+	assert.Equal(t, ":tada:", paragraph.Content[3].Attrs["shortName"])
+
+	code := doc.Content[1]
+	assert.Equal(t, "codeBlock", code.Type)
+	assert.Equal(t, "sh", code.Attrs["language"])
+	assert.Equal(t, "make", code.Content[0].Text)
+
+	// Converting back gives the original markdown, with the mention in Jira's form.
+	assert.Equal(t, "hi [~accountid:bob.jira] :tada:\n\n```sh\nmake\n```", adfToMarkdown(doc))
+}
+
+func TestAppendADFText(t *testing.T) {
+	body := appendADFText(markdownToADFString("comment", nil), "Attachment: a.png")
+
+	doc, ok := parseADFDocument(body)
+	require.True(t, ok)
+	require.Len(t, doc.Content, 2)
+	assert.Equal(t, "Attachment: a.png", doc.Content[1].Content[0].Text)
+
+	var raw map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(body), &raw))
+	assert.Equal(t, float64(1), raw["version"])
+
+	assert.Equal(t, "not adf", appendADFText("not adf", "Attachment: a.png"))
+}
@@ -0,0 +1,406 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/mattermost/mattermost-plugin-jira/server/utils/types"
+)
+
+const (
+	mentionMappingsKey = "mentionmappings"
+
+	mentionMappingsCacheTTL = time.Minute
+
+	// MentionFieldTeam matches any of the team fields of the instance.
+	MentionFieldTeam = "team"
+)
+
+// MentionMapping makes the subscription posts of an issue mention a Mattermost
+// group or user when a field of the issue has a value, like @payments-oncall
+// when the team is "Payments". Field is a field key like "components" or
+// "customfield_10050", or "team". Value is matched against the names and the
+// IDs of the field values, case insensitively.
+type MentionMapping struct {
+	Field   string `json:"field"`
+	Value   string `json:"value"`
+	Mention string `json:"mention"`
+}
+
+func (m MentionMapping) sameKey(other MentionMapping) bool {
+	return strings.EqualFold(m.Field, other.Field) && strings.EqualFold(m.Value, other.Value)
+}
+
+// getMentionMappings returns the mention mappings of the instance. They are
+// cached, since they are read for every subscription post.
+func (p *Plugin) getMentionMappings(instanceID types.ID) ([]MentionMapping, error) {
+	if mappings, ok := p.mentionMappingsCache.get(instanceID.String()); ok {
+		return mappings, nil
+	}
+
+	var mappings []MentionMapping
+	if err := p.client.KV.Get(keyWithInstanceID(instanceID, mentionMappingsKey), &mappings); err != nil {
+		return nil, errors.Wrap(err, "failed to load the mention mappings")
+	}
+	p.mentionMappingsCache.set(instanceID.String(), mappings)
+	return mappings, nil
+}
+
+// updateMentionMappings atomically replaces the mention mappings of the
+// instance with the result of update.
+func (p *Plugin) updateMentionMappings(instanceID types.ID, update func(mappings []MentionMapping) ([]MentionMapping, error)) error {
+	defer p.mentionMappingsCache.delete(instanceID.String())
+
+	var updateErr error
+	err := p.client.KV.SetAtomicWithRetries(keyWithInstanceID(instanceID, mentionMappingsKey), func(initialBytes []byte) (interface{}, error) {
+		var mappings []MentionMapping
+		if len(initialBytes) != 0 {
+			if err := json.Unmarshal(initialBytes, &mappings); err != nil {
+				return nil, errors.Wrap(err, "failed to load the mention mappings")
+			}
+		}
+
+		mappings, updateErr = update(mappings)
+		if updateErr != nil {
+			return nil, updateErr
+		}
+		sort.Slice(mappings, func(i, j int) bool {
+			if mappings[i].Field != mappings[j].Field {
+				return mappings[i].Field < mappings[j].Field
+			}
+			return mappings[i].Value < mappings[j].Value
+		})
+		return mappings, nil
+	})
+	if updateErr != nil {
+		return updateErr
+	}
+	return errors.WithMessage(err, "failed to store the mention mappings")
+}
+
+// validateMentionMapping normalizes the mapping, and checks that it mentions
+// an existing Mattermost group or user.
+func (p *Plugin) validateMentionMapping(m *MentionMapping) error {
+	m.Field = strings.TrimSpace(m.Field)
+	m.Value = strings.TrimSpace(m.Value)
+	m.Mention = strings.TrimPrefix(strings.TrimSpace(m.Mention), "@")
+	if m.Field == "" || m.Value == "" || m.Mention == "" {
+		return errors.New("please provide a field, a value and a Mattermost group or user to mention")
+	}
+
+	if _, err := p.client.Group.GetByName(m.Mention); err == nil {
+		return nil
+	}
+	if _, err := p.client.User.GetByUsername(m.Mention); err == nil {
+		return nil
+	}
+	return errors.Errorf("@%s is not a Mattermost group or user", m.Mention)
+}
+
+func (p *Plugin) addMentionMapping(instanceID types.ID, m MentionMapping) error {
+	if err := p.validateMentionMapping(&m); err != nil {
+		return err
+	}
+
+	return p.updateMentionMappings(instanceID, func(mappings []MentionMapping) ([]MentionMapping, error) {
+		for i := range mappings {
+			if mappings[i].sameKey(m) {
+				mappings[i] = m
+				return mappings, nil
+			}
+		}
+		return append(mappings, m), nil
+	})
+}
+
+func (p *Plugin) removeMentionMapping(instanceID types.ID, m MentionMapping) error {
+	return p.updateMentionMappings(instanceID, func(mappings []MentionMapping) ([]MentionMapping, error) {
+		for i := range mappings {
+			if mappings[i].sameKey(m) {
+				return append(mappings[:i], mappings[i+1:]...), nil
+			}
+		}
+		return nil, errors.Errorf("no mention is mapped to %s %q", m.Field, m.Value)
+	})
+}
+
+// getIssueMentions returns the @mentions mapped to the field values of the
+// issue, see MentionMapping.
+func (p *Plugin) getIssueMentions(instanceID types.ID, issue *jira.Issue) string {
+	var mentions []string
+	for _, name := range p.getIssueMentionNames(instanceID, issue) {
+		mentions = append(mentions, "@"+name)
+	}
+	return strings.Join(mentions, " ")
+}
+
+// getIssueMentionNames returns the sorted names of the groups and users
+// mapped to the field values of the issue.
+func (p *Plugin) getIssueMentionNames(instanceID types.ID, issue *jira.Issue) []string {
+	mappings, err := p.getMentionMappings(instanceID)
+	if err != nil {
+		p.client.Log.Warn("Failed to load the mention mappings", "error", err.Error())
+		return nil
+	}
+	if len(mappings) == 0 {
+		return nil
+	}
+
+	valuesByField := map[string]StringSet{}
+	names := NewStringSet()
+	for _, m := range mappings {
+		field := strings.ToLower(m.Field)
+		values, ok := valuesByField[field]
+		if !ok {
+			values = p.getIssueMentionFieldValues(instanceID, issue, field)
+			valuesByField[field] = values
+		}
+		if values.ContainsAny(strings.ToLower(m.Value)) {
+			names = names.Add(m.Mention)
+		}
+	}
+
+	result := names.Elems()
+	sort.Strings(result)
+	return result
+}
+
+// getIssueMentionFieldValues returns the lowercase names and IDs of the values
+// of a field.
+func (p *Plugin) getIssueMentionFieldValues(instanceID types.ID, issue *jira.Issue, field string) StringSet {
+	result := NewStringSet()
+	if issue == nil || issue.Fields == nil {
+		return result
+	}
+
+	add := func(values ...string) {
+		for _, v := range values {
+			if v != "" {
+				result = result.Add(strings.ToLower(v))
+			}
+		}
+	}
+
+	keys := []string{field}
+	if field == MentionFieldTeam {
+		keys = nil
+		for key := range p.getTeamFieldKeys(instanceID) {
+			keys = append(keys, key)
+		}
+	}
+
+	for _, key := range keys {
+		add(getIssueFieldValue(issue, key).Elems()...)
+		switch key {
+		case statusField:
+			if issue.Fields.Status != nil {
+				add(issue.Fields.Status.Name)
+			}
+		case priorityField:
+			if issue.Fields.Priority != nil {
+				add(issue.Fields.Priority.Name)
+			}
+		case "components":
+			for _, c := range issue.Fields.Components {
+				if c != nil {
+					add(c.Name)
+				}
+			}
+		default:
+			if m, exists := issue.Fields.Unknowns.Value(key); exists {
+				add(getUnknownFieldNames(m)...)
+			}
+		}
+	}
+
+	return result
+}
+
+// getUnknownFieldNames returns the names of a custom field value, like the
+// names of the groups of a group picker.
+func getUnknownFieldNames(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case float64:
+		return []string{fmt.Sprintf("%.0f", v)}
+	case []interface{}:
+		var names []string
+		for _, item := range v {
+			names = append(names, getUnknownFieldNames(item)...)
+		}
+		return names
+	case map[string]interface{}:
+		var names []string
+		for _, key := range []string{"id", "name", "value", "title", "displayName", "groupId"} {
+			switch s := v[key].(type) {
+			case string:
+				names = append(names, s)
+			case float64:
+				names = append(names, fmt.Sprintf("%.0f", s))
+			}
+		}
+		return names
+	}
+	return nil
+}
+
+const mentionUsage = "Please use `/jira mention add <field> <value> <@group-or-user>`, `/jira mention remove <field> <value>` or `/jira mention list`. " +
+	"The field is `team`, or a field key like `components` or `customfield_10050`."
+
+func executeMentionList(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	instance, args, resp := p.loadMentionInstance(header, args)
+	if resp != nil {
+		return resp
+	}
+	if len(args) != 0 {
+		return p.responsef(header, "No arguments were expected.")
+	}
+
+	mappings, err := p.getMentionMappings(instance.GetID())
+	if err != nil {
+		return p.responsef(header, "%v", err)
+	}
+	if len(mappings) == 0 {
+		return p.responsef(header, "No mentions are mapped for %s. %s", instance.GetURL(), mentionUsage)
+	}
+
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "Mentions mapped for %s:\n", instance.GetURL())
+	for _, m := range mappings {
+		fmt.Fprintf(sb, "* `%s` is **%s**: `@%s`\n", m.Field, m.Value, m.Mention)
+	}
+	return p.response(header, sb.String())
+}
+
+func executeMentionAdd(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	instance, args, resp := p.loadMentionInstance(header, args)
+	if resp != nil {
+		return resp
+	}
+	if len(args) < 3 {
+		return p.response(header, mentionUsage)
+	}
+
+	m := MentionMapping{
+		Field:   args[0],
+		Value:   strings.Join(args[1:len(args)-1], " "),
+		Mention: args[len(args)-1],
+	}
+	if err := p.addMentionMapping(instance.GetID(), m); err != nil {
+		return p.responsef(header, "Failed to map the mention. Error: %v.", err)
+	}
+	p.audit(header.UserId, AuditMentionMappingsEdit, instance.GetID(), m.Field+" "+m.Value, "added @"+strings.TrimPrefix(m.Mention, "@"))
+
+	return p.responsef(header, "Subscription posts of issues where `%s` is **%s** will mention `@%s`.", m.Field, m.Value, strings.TrimPrefix(m.Mention, "@"))
+}
+
+func executeMentionRemove(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	instance, args, resp := p.loadMentionInstance(header, args)
+	if resp != nil {
+		return resp
+	}
+	if len(args) < 2 {
+		return p.response(header, mentionUsage)
+	}
+
+	m := MentionMapping{
+		Field: args[0],
+		Value: strings.Join(args[1:], " "),
+	}
+	if err := p.removeMentionMapping(instance.GetID(), m); err != nil {
+		return p.responsef(header, "Failed to remove the mention. Error: %v.", err)
+	}
+	p.audit(header.UserId, AuditMentionMappingsEdit, instance.GetID(), m.Field+" "+m.Value, "removed")
+
+	return p.responsef(header, "Removed the mention of issues where `%s` is **%s**.", m.Field, m.Value)
+}
+
+func (p *Plugin) loadMentionInstance(header *model.CommandArgs, args []string) (Instance, []string, *model.CommandResponse) {
+	authorized, err := authorizedSysAdmin(p, header.UserId)
+	if err != nil {
+		return nil, nil, p.responsef(header, "%v", err)
+	}
+	if !authorized {
+		return nil, nil, p.responsef(header, "`/jira mention` can only be run by a system administrator.")
+	}
+
+	_, instance, args, err := p.loadFlagUserInstance(header.UserId, args)
+	if err != nil {
+		return nil, nil, p.responsef(header, "Failed to identify the Jira instance. Error: %v.", err)
+	}
+
+	return instance, args, nil
+}
+
+func (p *Plugin) httpGetMentionMappings(w http.ResponseWriter, r *http.Request) (int, error) {
+	instanceID, status, err := p.checkMentionMappingsRequest(r)
+	if err != nil {
+		return respondErr(w, status, err)
+	}
+
+	mappings, err := p.getMentionMappings(instanceID)
+	if err != nil {
+		return respondErr(w, http.StatusInternalServerError, err)
+	}
+	if mappings == nil {
+		mappings = []MentionMapping{}
+	}
+
+	return respondJSON(w, mappings)
+}
+
+// httpSetMentionMappings replaces all the mention mappings of the instance.
+func (p *Plugin) httpSetMentionMappings(w http.ResponseWriter, r *http.Request) (int, error) {
+	instanceID, status, err := p.checkMentionMappingsRequest(r)
+	if err != nil {
+		return respondErr(w, status, err)
+	}
+
+	mappings := []MentionMapping{}
+	if err = json.NewDecoder(r.Body).Decode(&mappings); err != nil {
+		return respondErr(w, http.StatusBadRequest, errors.WithMessage(err, "failed to decode incoming request"))
+	}
+	for i := range mappings {
+		if err = p.validateMentionMapping(&mappings[i]); err != nil {
+			return respondErr(w, http.StatusBadRequest, err)
+		}
+	}
+
+	err = p.updateMentionMappings(instanceID, func([]MentionMapping) ([]MentionMapping, error) {
+		return mappings, nil
+	})
+	if err != nil {
+		return respondErr(w, http.StatusInternalServerError, err)
+	}
+	p.audit(r.Header.Get("Mattermost-User-Id"), AuditMentionMappingsEdit, instanceID, "all", fmt.Sprintf("%d mappings", len(mappings)))
+
+	return respondJSON(w, mappings)
+}
+
+func (p *Plugin) checkMentionMappingsRequest(r *http.Request) (types.ID, int, error) {
+	mattermostUserID := r.Header.Get("Mattermost-User-Id")
+	if !p.client.User.HasPermissionTo(mattermostUserID, model.PermissionManageSystem) {
+		return "", http.StatusForbidden, errors.New("only system administrators can manage the mention mappings")
+	}
+
+	instance, err := p.instanceStore.LoadInstance(types.ID(r.FormValue(QueryParamInstanceID)))
+	if err != nil {
+		return "", http.StatusBadRequest, errors.WithMessage(err, "failed to load the Jira instance")
+	}
+
+	return instance.GetID(), http.StatusOK, nil
+}
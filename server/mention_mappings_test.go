@@ -0,0 +1,93 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trivago/tgo/tcontainer"
+)
+
+func TestGetIssueMentions(t *testing.T) {
+	api := &plugintest.API{}
+	p := setupTestPlugin(api)
+
+	mappings := []MentionMapping{
+		{Field: MentionFieldTeam, Value: "payments", Mention: "payments-oncall"},
+		{Field: "components", Value: "Backend", Mention: "backend"},
+		{Field: "customfield_10050", Value: "jira-admins", Mention: "admins"},
+		{Field: "components", Value: "Frontend", Mention: "frontend"},
+	}
+	bb, err := json.Marshal(mappings)
+	require.NoError(t, err)
+	api.On("KVGet", keyWithInstanceID(testInstance1.InstanceID, mentionMappingsKey)).Return(bb, nil)
+
+	issue := &jira.Issue{
+		Fields: &jira.IssueFields{
+			Components: []*jira.Component{{ID: "1", Name: "Backend"}},
+			Unknowns: tcontainer.MarshalMap{
+				defaultTeamFieldKey: map[string]interface{}{"id": "42", "name": "Payments"},
+				"customfield_10050": []interface{}{
+					map[string]interface{}{"name": "jira-admins", "groupId": "g1"},
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, "@admins @backend @payments-oncall", p.getIssueMentions(testInstance1.InstanceID, issue))
+	assert.Equal(t, "", p.getIssueMentions(testInstance1.InstanceID, &jira.Issue{Fields: &jira.IssueFields{}}))
+}
+
+func TestValidateMentionMapping(t *testing.T) {
+	api := &plugintest.API{}
+	p := setupTestPlugin(api)
+	api.On("GetGroupByName", "payments-oncall").Return(&model.Group{Name: model.NewPointer("payments-oncall")}, (*model.AppError)(nil))
+	api.On("GetGroupByName", "nobody").Return(nil, &model.AppError{Message: "not found"})
+	api.On("GetUserByUsername", "nobody").Return(nil, &model.AppError{Message: "not found"})
+
+	m := MentionMapping{Field: " team ", Value: "Payments", Mention: "@payments-oncall"}
+	require.NoError(t, p.validateMentionMapping(&m))
+	assert.Equal(t, MentionMapping{Field: "team", Value: "Payments", Mention: "payments-oncall"}, m)
+
+	assert.EqualError(t, p.validateMentionMapping(&MentionMapping{Field: "team", Value: "Payments", Mention: "@nobody"}), "@nobody is not a Mattermost group or user")
+	assert.Error(t, p.validateMentionMapping(&MentionMapping{Field: "team", Mention: "@payments-oncall"}))
+}
+
+func TestUpdateMentionMappings(t *testing.T) {
+	api := &plugintest.API{}
+	p := setupTestPlugin(api)
+	kv := map[string][]byte{}
+	mockKVMap(api, kv)
+	p.mentionMappingsCache = newExpiringCache[[]MentionMapping](time.Minute)
+	api.On("GetGroupByName", mock.AnythingOfType("string")).Return(&model.Group{}, (*model.AppError)(nil))
+
+	require.NoError(t, p.addMentionMapping(testInstance1.InstanceID, MentionMapping{Field: "team", Value: "Payments", Mention: "payments"}))
+	mappings, err := p.getMentionMappings(testInstance1.InstanceID)
+	require.NoError(t, err)
+	assert.Equal(t, []MentionMapping{{Field: "team", Value: "Payments", Mention: "payments"}}, mappings)
+
+	// The cached mappings are dropped when they change
+	require.NoError(t, p.addMentionMapping(testInstance1.InstanceID, MentionMapping{Field: "components", Value: "Backend", Mention: "backend"}))
+	mappings, err = p.getMentionMappings(testInstance1.InstanceID)
+	require.NoError(t, err)
+	assert.Equal(t, []MentionMapping{
+		{Field: "components", Value: "Backend", Mention: "backend"},
+		{Field: "team", Value: "Payments", Mention: "payments"},
+	}, mappings)
+
+	require.NoError(t, p.removeMentionMapping(testInstance1.InstanceID, MentionMapping{Field: "Team", Value: "payments"}))
+	assert.EqualError(t, p.removeMentionMapping(testInstance1.InstanceID, MentionMapping{Field: "team", Value: "payments"}),
+		`no mention is mapped to team "payments"`)
+	mappings, err = p.getMentionMappings(testInstance1.InstanceID)
+	require.NoError(t, err)
+	assert.Len(t, mappings, 1)
+}
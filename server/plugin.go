@@ -26,6 +26,7 @@ import (
 	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/mattermost/mattermost/server/public/plugin"
 	"github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/mattermost/mattermost/server/public/pluginapi/cluster"
 	"github.com/mattermost/mattermost/server/public/pluginapi/experimental/flow"
 
 	"github.com/mattermost-community/mattermost-plugin-autolink/server/autolink"
@@ -84,6 +85,10 @@ type externalConfig struct {
 	// Display subscription name in notifications
 	DisplaySubscriptionNameInNotifications bool
 
+	// Keep a card per issue in each channel, updated by the later events
+	// of the issue, which are posted in its thread
+	EnableIssueCards bool
+
 	// The encryption key used to encrypt stored api tokens
 	EncryptionKey string
 
@@ -135,6 +140,7 @@ type Plugin struct {
 	userStore     UserStore
 	otsStore      OTSStore
 	secretsStore  SecretsStore
+	auditStore    AuditStore
 
 	setupFlow  *flow.Flow
 	oauth2Flow *flow.Flow
@@ -151,6 +157,12 @@ type Plugin struct {
 	// channel to distribute work to the webhook processors
 	webhookQueue chan *webhookMessage
 
+	// reminds the assignees of stale issues, on one server of the cluster
+	reminderJob *cluster.Job
+
+	// deletes the expired audit entries, on one server of the cluster
+	auditRetentionJob *cluster.Job
+
 	// service that determines if this Mattermost instance has access to
 	// enterprise features
 	enterpriseChecker enterprise.Checker
@@ -166,6 +178,27 @@ type Plugin struct {
 
 	teamFieldCache     map[types.ID]map[string]struct{}
 	teamFieldCacheLock sync.RWMutex
+
+	// the issues of the threads, see loadThreadIssue
+	threadIssueCache *expiringCache[*ThreadIssue]
+
+	// the Mattermost group IDs of users, see getMattermostGroupsOfUsers
+	groupsOfUserCache *expiringCache[[]string]
+
+	// the mention mappings of the instances, see getMentionMappings
+	mentionMappingsCache *expiringCache[[]MentionMapping]
+
+	// the dedicated bots of the instances, see getBotUserID
+	botUserIDCache *expiringCache[string]
+
+	// the service desks of the instances and their request types, see
+	// getServiceDeskMetadata
+	serviceDesksCache *expiringCache[[]ServiceDesk]
+	requestTypesCache *expiringCache[[]RequestType]
+
+	// whether the server audit log can be written by plugins, checked once
+	auditLogOnce      sync.Once
+	auditLogSupported bool
 }
 
 func (p *Plugin) getConfig() config {
@@ -339,6 +372,17 @@ func (p *Plugin) OnConfigurationChange() error {
 }
 
 func (p *Plugin) OnDeactivate() error {
+	if p.reminderJob != nil {
+		if err := p.reminderJob.Close(); err != nil {
+			return errors.Wrap(err, "OnDeactivate: Failed to close the reminder job")
+		}
+	}
+	if p.auditRetentionJob != nil {
+		if err := p.auditRetentionJob.Close(); err != nil {
+			return errors.Wrap(err, "OnDeactivate: Failed to close the audit retention job")
+		}
+	}
+
 	// close the tracker on plugin deactivation
 	if p.telemetryClient != nil {
 		err := p.telemetryClient.Close()
@@ -355,8 +399,15 @@ func (p *Plugin) OnActivate() error {
 	p.userStore = store
 	p.secretsStore = store
 	p.otsStore = store
+	p.auditStore = store
 	p.client = pluginapi.NewClient(p.API, p.Driver)
 	p.teamFieldCache = make(map[types.ID]map[string]struct{})
+	p.threadIssueCache = newExpiringCache[*ThreadIssue](threadIssueCacheTTL)
+	p.groupsOfUserCache = newExpiringCache[[]string](groupsOfUserCacheTTL)
+	p.mentionMappingsCache = newExpiringCache[[]MentionMapping](mentionMappingsCacheTTL)
+	p.botUserIDCache = newExpiringCache[string](botUserIDCacheTTL)
+	p.serviceDesksCache = newExpiringCache[[]ServiceDesk](serviceDeskCacheTTL)
+	p.requestTypesCache = newExpiringCache[[]RequestType](serviceDeskCacheTTL)
 
 	p.initializeRouter()
 
@@ -438,6 +489,16 @@ func (p *Plugin) OnActivate() error {
 		go webhookWorker{i, p, p.webhookQueue}.work()
 	}
 
+	p.reminderJob, err = cluster.Schedule(p.API, reminderJobKey, cluster.MakeWaitForRoundedInterval(reminderInterval), p.runReminders)
+	if err != nil {
+		return errors.Wrap(err, "OnActivate: failed to schedule the reminders")
+	}
+
+	p.auditRetentionJob, err = cluster.Schedule(p.API, auditRetentionJobKey, cluster.MakeWaitForRoundedInterval(auditRetentionPeriod), p.runAuditRetention)
+	if err != nil {
+		return errors.Wrap(err, "OnActivate: failed to schedule the audit retention")
+	}
+
 	p.enterpriseChecker = enterprise.NewEnterpriseChecker(p.API)
 
 	go func() {
@@ -0,0 +1,157 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	jira "github.com/andygrunwald/go-jira"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+)
+
+const (
+	releaseNotesPageSize  = 100
+	releaseNotesMaxIssues = 5000
+	releaseNotesUsage     = "Please use `/jira release notes <project-key> <version> [--export]`."
+)
+
+func executeReleaseNotes(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	user, instance, args, err := p.loadFlagUserInstance(header.UserId, args)
+	if err != nil {
+		return p.responsef(header, "Failed to load your connection to Jira. Error: %v.", err)
+	}
+
+	export := false
+	var rest []string
+	for _, arg := range args {
+		if arg == "--export" {
+			export = true
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	if len(rest) < 2 {
+		return p.response(header, releaseNotesUsage)
+	}
+	projectKey := strings.ToUpper(rest[0])
+	version := strings.Trim(strings.Join(rest[1:], " "), `"`)
+
+	client, _, _, err := p.getClient(instance.GetID(), user.MattermostUserID)
+	if err != nil {
+		return p.responsef(header, "Your username is not connected to Jira. Please type `/jira connect`. Error: %v.", err)
+	}
+
+	issues, err := searchReleaseNotesIssues(client, releaseNotesJQL(projectKey, version))
+	if err != nil {
+		return p.responsef(header, "Failed to get the issues of version `%s`. Error: %v.", version, err)
+	}
+	if len(issues) == 0 {
+		return p.responsef(header, "No issues found with fix version `%s` in project `%s`.", version, projectKey)
+	}
+
+	notes := releaseNotes(instance.GetJiraBaseURL(), projectKey, version, issues)
+
+	post := &model.Post{
+		UserId:    p.getBotUserID(instance.GetID()),
+		ChannelId: header.ChannelId,
+		RootId:    header.RootId,
+		Message:   notes,
+	}
+	if export || utf8.RuneCountInString(notes) > model.PostMessageMaxRunesV2 {
+		fileInfo, uploadErr := p.client.File.Upload(bytes.NewBufferString(notes), releaseNotesFileName(projectKey, version), header.ChannelId)
+		if uploadErr != nil {
+			return p.responsef(header, "Failed to export the release notes. Error: %v.", uploadErr)
+		}
+		post.Message = fmt.Sprintf("Release notes for %s %s (%d issues)", projectKey, version, len(issues))
+		post.FileIds = []string{fileInfo.Id}
+	}
+
+	if err = p.client.Post.CreatePost(post); err != nil {
+		return p.responsef(header, "Failed to post the release notes. Error: %v.", err)
+	}
+
+	return &model.CommandResponse{}
+}
+
+// searchReleaseNotesIssues pages through the search results, up to
+// releaseNotesMaxIssues issues.
+func searchReleaseNotesIssues(client Client, jql string) ([]jira.Issue, error) {
+	var issues []jira.Issue
+	pageToken := ""
+	for len(issues) < releaseNotesMaxIssues {
+		page, err := client.SearchIssuesPage(jql, &jira.SearchOptions{
+			MaxResults: min(releaseNotesPageSize, releaseNotesMaxIssues-len(issues)),
+			Fields:     []string{"summary", "issuetype"},
+		}, pageToken)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, page.Issues...)
+		if page.NextPageToken == "" || len(page.Issues) == 0 {
+			break
+		}
+		pageToken = page.NextPageToken
+	}
+	if len(issues) > releaseNotesMaxIssues {
+		issues = issues[:releaseNotesMaxIssues]
+	}
+	return issues, nil
+}
+
+func releaseNotesJQL(projectKey, version string) string {
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return fmt.Sprintf(`project = "%s" AND fixVersion = "%s" ORDER BY key ASC`, escape.Replace(projectKey), escape.Replace(version))
+}
+
+// releaseNotes renders the issues as markdown, grouped by issue type.
+func releaseNotes(jiraURL, projectKey, version string, issues []jira.Issue) string {
+	byType := map[string][]jira.Issue{}
+	for _, issue := range issues {
+		issueType := "Other"
+		if issue.Fields != nil && issue.Fields.Type.Name != "" {
+			issueType = issue.Fields.Type.Name
+		}
+		byType[issueType] = append(byType[issueType], issue)
+	}
+
+	issueTypes := make([]string, 0, len(byType))
+	for issueType := range byType {
+		issueTypes = append(issueTypes, issueType)
+	}
+	sort.Strings(issueTypes)
+
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "#### Release notes: %s %s\n", projectKey, version)
+	for _, issueType := range issueTypes {
+		fmt.Fprintf(sb, "\n##### %s\n", issueType)
+		for _, issue := range byType[issueType] {
+			summary := ""
+			if issue.Fields != nil {
+				summary = issue.Fields.Summary
+			}
+			fmt.Fprintf(sb, "* [%s](%s/browse/%s) %s\n", issue.Key, jiraURL, issue.Key, summary)
+		}
+	}
+	if len(issues) == releaseNotesMaxIssues {
+		fmt.Fprintf(sb, "\n_Only the first %d issues are listed._\n", releaseNotesMaxIssues)
+	}
+
+	return sb.String()
+}
+
+func releaseNotesFileName(projectKey, version string) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ' ' {
+			return '-'
+		}
+		return r
+	}, version)
+	return fmt.Sprintf("%s-%s-release-notes.md", projectKey, name)
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReleaseNotes(t *testing.T) {
+	issues := []jira.Issue{
+		{Key: "TEST-1", Fields: &jira.IssueFields{Summary: "Login fails", Type: jira.IssueType{Name: "Bug"}}},
+		{Key: "TEST-2", Fields: &jira.IssueFields{Summary: "Dark mode", Type: jira.IssueType{Name: "Story"}}},
+		{Key: "TEST-3", Fields: &jira.IssueFields{Summary: "Crash on start", Type: jira.IssueType{Name: "Bug"}}},
+	}
+
+	expected := "#### Release notes: TEST 1.0\n" +
+		"\n##### Bug\n" +
+		"* [TEST-1](https://jira.example.com/browse/TEST-1) Login fails\n" +
+		"* [TEST-3](https://jira.example.com/browse/TEST-3) Crash on start\n" +
+		"\n##### Story\n" +
+		"* [TEST-2](https://jira.example.com/browse/TEST-2) Dark mode\n"
+	assert.Equal(t, expected, releaseNotes("https://jira.example.com", "TEST", "1.0", issues))
+
+	assert.Equal(t, `project = "TEST" AND fixVersion = "Release \"2\"" ORDER BY key ASC`, releaseNotesJQL("TEST", `Release "2"`))
+	assert.Equal(t, "TEST-Release-2-1.0-release-notes.md", releaseNotesFileName("TEST", "Release 2/1.0"))
+}
+
+type releaseNotesTestClient struct {
+	testClient
+	total int
+}
+
+func (client releaseNotesTestClient) SearchIssuesPage(jql string, options *jira.SearchOptions, pageToken string) (*SearchPage, error) {
+	startAt, _ := strconv.Atoi(pageToken)
+	page := &SearchPage{}
+	for i := startAt; i < client.total && i < startAt+options.MaxResults; i++ {
+		page.Issues = append(page.Issues, jira.Issue{Key: fmt.Sprintf("TEST-%d", i+1)})
+	}
+	if next := startAt + len(page.Issues); next < client.total {
+		page.NextPageToken = strconv.Itoa(next)
+	}
+	return page, nil
+}
+
+func TestSearchReleaseNotesIssues(t *testing.T) {
+	issues, err := searchReleaseNotesIssues(releaseNotesTestClient{total: 250}, "")
+	require.NoError(t, err)
+	require.Len(t, issues, 250)
+	assert.Equal(t, "TEST-250", issues[249].Key)
+
+	issues, err = searchReleaseNotesIssues(releaseNotesTestClient{total: releaseNotesMaxIssues + 1}, "")
+	require.NoError(t, err)
+	assert.Len(t, issues, releaseNotesMaxIssues)
+}
+
+func TestSearchReleaseNotesIssuesCloud(t *testing.T) {
+	const total = 250
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/api/2/search/jql" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		// Like Jira Cloud, startAt is ignored and pages are continued with the token
+		startAt, _ := strconv.Atoi(r.URL.Query().Get("nextPageToken"))
+		maxResults, _ := strconv.Atoi(r.URL.Query().Get("maxResults"))
+		result := map[string]interface{}{}
+		var issues []jira.Issue
+		for i := startAt; i < total && i < startAt+maxResults; i++ {
+			issues = append(issues, jira.Issue{Key: fmt.Sprintf("TEST-%d", i+1)})
+		}
+		result["issues"] = issues
+		if next := startAt + len(issues); next < total {
+			result["nextPageToken"] = strconv.Itoa(next)
+		} else {
+			result["isLast"] = true
+		}
+		_ = json.NewEncoder(w).Encode(result)
+	}))
+	defer server.Close()
+
+	jiraClient, err := jira.NewClient(server.Client(), server.URL)
+	require.NoError(t, err)
+	issues, err := searchReleaseNotesIssues(newCloudClient(jiraClient), "")
+	require.NoError(t, err)
+	require.Len(t, issues, total)
+	keys := map[string]bool{}
+	for _, issue := range issues {
+		keys[issue.Key] = true
+	}
+	assert.Len(t, keys, total)
+	assert.Equal(t, "TEST-250", issues[249].Key)
+}
@@ -0,0 +1,474 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+
+	"github.com/mattermost/mattermost-plugin-jira/server/utils/types"
+)
+
+const (
+	reminderRulesKey = "reminderrules"
+
+	// + rule ID and issue key, within instanceKey, set while the assignee
+	// of the issue is not to be reminded again by the rule
+	reminderKeyPrefix = "reminder_"
+
+	reminderJobKey    = "stale_issue_reminders"
+	reminderInterval  = time.Hour
+	reminderRepeat    = 24 * time.Hour
+	reminderMaxIssues = 50
+	reminderMaxDays   = 365
+)
+
+// reminderSnoozeDays are the snooze options of a reminder, longer than
+// reminderRepeat which the assignee is not reminded again for anyway
+var reminderSnoozeDays = []int{3, 7}
+
+const reminderUsage = "Usage: `/jira reminder add [days] [status] [project=[key]]`, `/jira reminder list` or `/jira reminder remove [status]`, e.g. `/jira reminder add 3 In Review`."
+
+// ReminderRule reminds the assignees of the issues that have been in a status
+// for a number of days without an update. The rules are managed per channel,
+// and the issues of a project the channel is subscribed to, or of its default
+// project, are searched for with the connection of the user who added the
+// rule.
+type ReminderRule struct {
+	ChannelID  string `json:"channel_id"`
+	CreatorID  string `json:"creator_id"`
+	Status     string `json:"status"`
+	Days       int    `json:"days"`
+	ProjectKey string `json:"project_key,omitempty"`
+}
+
+// ID identifies the rule among the rules of the instance, a channel has one
+// rule per status.
+func (rule ReminderRule) ID() string {
+	return rule.ChannelID + "_" + strings.ToLower(rule.Status)
+}
+
+func (rule ReminderRule) JQL() string {
+	jql := fmt.Sprintf(`status = "%s" AND updated <= -%dd AND assignee IS NOT EMPTY`, escapeJQLString(rule.Status), rule.Days)
+	if rule.ProjectKey != "" {
+		jql = fmt.Sprintf(`project = "%s" AND %s`, escapeJQLString(rule.ProjectKey), jql)
+	}
+	return jql + " ORDER BY updated ASC"
+}
+
+func (rule ReminderRule) String() string {
+	s := fmt.Sprintf("Issues in **%s** for %d days without an update", rule.Status, rule.Days)
+	if rule.ProjectKey != "" {
+		s += " in project " + rule.ProjectKey
+	}
+	return s
+}
+
+func escapeJQLString(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
+
+func (p *Plugin) getReminderRules(instanceID types.ID) ([]ReminderRule, error) {
+	var rules []ReminderRule
+	if err := p.client.KV.Get(keyWithInstanceID(instanceID, reminderRulesKey), &rules); err != nil {
+		return nil, errors.Wrap(err, "failed to load the reminder rules")
+	}
+	return rules, nil
+}
+
+// updateReminderRules updates the reminder rules of the instance atomically,
+// as the rules of all the channels are stored together.
+func (p *Plugin) updateReminderRules(instanceID types.ID, update func(rules []ReminderRule) ([]ReminderRule, error)) error {
+	var updateErr error
+	err := p.client.KV.SetAtomicWithRetries(keyWithInstanceID(instanceID, reminderRulesKey), func(initialBytes []byte) (interface{}, error) {
+		var rules []ReminderRule
+		if len(initialBytes) != 0 {
+			if err := json.Unmarshal(initialBytes, &rules); err != nil {
+				return nil, errors.Wrap(err, "failed to load the reminder rules")
+			}
+		}
+
+		rules, updateErr = update(rules)
+		if updateErr != nil {
+			return nil, updateErr
+		}
+		sort.Slice(rules, func(i, j int) bool {
+			if rules[i].ChannelID != rules[j].ChannelID {
+				return rules[i].ChannelID < rules[j].ChannelID
+			}
+			return strings.ToLower(rules[i].Status) < strings.ToLower(rules[j].Status)
+		})
+		return rules, nil
+	})
+	if updateErr != nil {
+		return updateErr
+	}
+	return errors.WithMessage(err, "failed to store the reminder rules")
+}
+
+// scopeReminderRule sets the project of the rule to the default project of
+// the channel if it has none, and checks that the channel is subscribed to
+// the project, or that it is its default project.
+func (p *Plugin) scopeReminderRule(instanceID types.ID, rule *ReminderRule) error {
+	defaultProject := ""
+	defaults, err := p.loadChannelDefaults(rule.ChannelID)
+	if err != nil {
+		return err
+	}
+	if defaults != nil && defaults.InstanceID == instanceID {
+		defaultProject = defaults.ProjectKey
+	}
+	subs, err := p.getSubscriptionsForChannel(instanceID, rule.ChannelID)
+	if err != nil {
+		return err
+	}
+	projects := NewStringSet()
+	for _, sub := range subs {
+		projects = projects.Add(sub.Filters.Projects.Elems()...)
+	}
+
+	if rule.ProjectKey == "" {
+		switch {
+		case defaultProject != "":
+			rule.ProjectKey = defaultProject
+		case projects.Len() == 1:
+			rule.ProjectKey = projects.Elems()[0]
+		default:
+			return errors.New("please specify the project of the issues, like `project=OPS`")
+		}
+	}
+	if rule.ProjectKey != defaultProject && !projects.ContainsAny(rule.ProjectKey) {
+		return errors.Errorf("this channel is not subscribed to project %s and it is not its default project", rule.ProjectKey)
+	}
+	return nil
+}
+
+// parseReminderRule parses `[days] [status] [project=[key]]`. The status is
+// the rest of the arguments, as status names often contain spaces.
+func parseReminderRule(args []string) (*ReminderRule, error) {
+	rule := &ReminderRule{}
+	var status []string
+	for _, arg := range args {
+		if key, value, ok := strings.Cut(arg, "="); ok && strings.EqualFold(key, "project") {
+			rule.ProjectKey = strings.ToUpper(value)
+			continue
+		}
+		status = append(status, arg)
+	}
+	if len(status) < 2 {
+		return nil, errors.New("please specify the number of days and the status")
+	}
+
+	days, err := strconv.Atoi(status[0])
+	if err != nil || days < 1 || days > reminderMaxDays {
+		return nil, errors.Errorf("the number of days must be between 1 and %d, not `%s`", reminderMaxDays, status[0])
+	}
+	rule.Days = days
+	rule.Status = strings.Join(status[1:], " ")
+	return rule, nil
+}
+
+// runReminders reminds the assignees of the stale issues of all the rules.
+// It is scheduled on one server of the cluster.
+func (p *Plugin) runReminders() {
+	instances, err := p.instanceStore.LoadInstances()
+	if err != nil {
+		p.client.Log.Warn("Failed to load the instances to send reminders", "error", err.Error())
+		return
+	}
+	for _, instanceID := range instances.IDs() {
+		rules, err := p.getReminderRules(instanceID)
+		if err != nil {
+			p.client.Log.Warn("Failed to load the reminder rules", "instance", instanceID.String(), "error", err.Error())
+			continue
+		}
+		for _, rule := range rules {
+			if err = p.remindStaleIssues(instanceID, rule); err != nil {
+				p.client.Log.Warn("Failed to send the reminders of a rule", "instance", instanceID.String(), "channel", rule.ChannelID, "status", rule.Status, "error", err.Error())
+			}
+		}
+	}
+}
+
+func (p *Plugin) remindStaleIssues(instanceID types.ID, rule ReminderRule) error {
+	// The channel may have been unsubscribed from the project since
+	if err := p.scopeReminderRule(instanceID, &rule); err != nil {
+		return err
+	}
+	client, instance, _, err := p.getClient(instanceID, types.ID(rule.CreatorID))
+	if err != nil {
+		return err
+	}
+	issues, err := client.SearchIssues(rule.JQL(), &jira.SearchOptions{
+		MaxResults: reminderMaxIssues,
+		Fields:     []string{"summary", "status", "assignee", "updated"},
+	})
+	if err != nil {
+		return err
+	}
+	for i := range issues {
+		if err = p.remindAssignee(client, instance, rule, &issues[i]); err != nil {
+			p.client.Log.Debug("Failed to remind the assignee of an issue", "issue", issues[i].Key, "error", err.Error())
+		}
+	}
+	return nil
+}
+
+func reminderKey(instanceID types.ID, ruleID, issueKey string) string {
+	return instanceKey(reminderKeyPrefix, instanceID, ruleID+"_"+issueKey)
+}
+
+// remindAssignee sends a DM to the assignee of the issue, if they are
+// connected and were not reminded by the rule, or snoozed its reminders,
+// recently.
+func (p *Plugin) remindAssignee(client Client, instance Instance, rule ReminderRule, issue *jira.Issue) error {
+	if issue.Fields == nil || issue.Fields.Assignee == nil {
+		return nil
+	}
+	key := reminderKey(instance.GetID(), rule.ID(), issue.Key)
+	var reminded bool
+	if err := p.client.KV.Get(key, &reminded); err != nil {
+		return err
+	}
+	if reminded {
+		return nil
+	}
+
+	jiraUserID := issue.Fields.Assignee.AccountID
+	if jiraUserID == "" {
+		jiraUserID = issue.Fields.Assignee.Name
+	}
+	mattermostUserID, err := p.userStore.LoadMattermostUserID(instance.GetID(), jiraUserID)
+	if err != nil {
+		// The assignee is not connected
+		return nil
+	}
+
+	post, err := p.makeReminderPost(client, instance, rule, issue)
+	if err != nil {
+		return err
+	}
+	if err = p.client.Post.DM(p.getBotUserID(instance.GetID()), mattermostUserID.String(), post); err != nil {
+		return errors.WithMessage(err, "failed to send the reminder")
+	}
+	if _, err = p.client.KV.Set(key, true, pluginapi.SetExpiry(reminderRepeat)); err != nil {
+		return errors.WithMessage(err, "failed to store the reminder")
+	}
+	return nil
+}
+
+func (p *Plugin) makeReminderPost(client Client, instance Instance, rule ReminderRule, issue *jira.Issue) (*model.Post, error) {
+	status := ""
+	if issue.Fields.Status != nil {
+		status = issue.Fields.Status.Name
+	}
+	stale := "a while"
+	if updated := time.Time(issue.Fields.Updated); !updated.IsZero() {
+		days := int(time.Since(updated).Hours() / 24)
+		stale = fmt.Sprintf("%d days", days)
+		if days == 1 {
+			stale = "1 day"
+		}
+	}
+
+	ctx := map[string]interface{}{
+		"issue_key":   issue.Key,
+		"instance_id": instance.GetID().String(),
+	}
+	if sig := p.generatePostActionSignature(issue.Key, instance.GetID().String()); sig != "" {
+		ctx["action_signature"] = sig
+	}
+
+	transitions, err := client.GetTransitions(issue.Key)
+	if err != nil {
+		return nil, err
+	}
+	var options []*model.PostActionOptions
+	for _, transition := range transitions {
+		if transition.To.Name != status {
+			options = append(options, &model.PostActionOptions{
+				Text:  transition.Name,
+				Value: transition.To.Name,
+			})
+		}
+	}
+
+	var actions []*model.PostAction
+	if len(options) > 0 {
+		actions = append(actions, &model.PostAction{
+			Name:    "Transition issue",
+			Type:    model.PostActionTypeSelect,
+			Options: options,
+			Integration: &model.PostActionIntegration{
+				URL:     fmt.Sprintf("/plugins/%s%s%s", manifest.Id, routeAPI, routeIssueTransition),
+				Context: ctx,
+			},
+		})
+	}
+	for _, days := range reminderSnoozeDays {
+		snoozeCtx := map[string]interface{}{"days": days, "rule_id": rule.ID()}
+		for k, v := range ctx {
+			snoozeCtx[k] = v
+		}
+		actions = append(actions, &model.PostAction{
+			Name: fmt.Sprintf("Snooze %d days", days),
+			Type: model.PostActionTypeButton,
+			Integration: &model.PostActionIntegration{
+				URL:     fmt.Sprintf("/plugins/%s%s%s", manifest.Id, routeAPI, routeReminderSnooze),
+				Context: snoozeCtx,
+			},
+		})
+	}
+
+	post := &model.Post{}
+	model.ParseSlackAttachment(post, []*model.SlackAttachment{{
+		Fallback: fmt.Sprintf("%s has been in %s for %s without an update.", issue.Key, status, stale),
+		Text: fmt.Sprintf("[%s: %s](%s/browse/%s) has been in **%s** for %s without an update.",
+			issue.Key, truncate(issue.Fields.Summary, maxIssueSummaryLength), instance.GetJiraBaseURL(), issue.Key, status, stale),
+		Actions: actions,
+	}})
+	return post, nil
+}
+
+func (p *Plugin) httpSnoozeReminder(w http.ResponseWriter, r *http.Request) (int, error) {
+	authenticatedUserID, requestData, status, err := decodePostActionRequest(r)
+	if err != nil {
+		return respondErr(w, status, err)
+	}
+
+	jiraBotID := p.getUserID()
+	ctx, status, errMsg := p.buildPostActionContext(authenticatedUserID, requestData, true)
+	if errMsg != "" {
+		return p.respondErrWithFeedback(authenticatedUserID, makePost(jiraBotID, requestData.ChannelId, errMsg), w, status)
+	}
+	jiraBotID = p.getBotUserID(types.ID(ctx.instanceID))
+	signature, _ := requestData.Context["action_signature"].(string)
+	issueKey, _ := requestData.Context["issue_key"].(string)
+	if !p.verifyPostActionSignature(issueKey, ctx.instanceID, signature) {
+		return p.respondErrWithFeedback(authenticatedUserID, makePost(jiraBotID, ctx.channelID, "user not authorized"), w, http.StatusUnauthorized)
+	}
+
+	days, _ := requestData.Context["days"].(float64)
+	if days < 1 || days > reminderMaxDays {
+		return p.respondErrWithFeedback(authenticatedUserID, makePost(jiraBotID, ctx.channelID, "invalid snooze"), w, http.StatusBadRequest)
+	}
+	ruleID, _ := requestData.Context["rule_id"].(string)
+	if ruleID == "" {
+		return p.respondErrWithFeedback(authenticatedUserID, makePost(jiraBotID, ctx.channelID, "invalid snooze"), w, http.StatusBadRequest)
+	}
+	snooze := time.Duration(days) * 24 * time.Hour
+	if _, err = p.client.KV.Set(reminderKey(types.ID(ctx.instanceID), ruleID, ctx.issueKey), true, pluginapi.SetExpiry(snooze)); err != nil {
+		return respondErr(w, http.StatusInternalServerError, errors.WithMessage(err, "failed to snooze the reminders"))
+	}
+
+	return respondJSON(w, &model.PostActionIntegrationResponse{
+		EphemeralText: fmt.Sprintf("You will not be reminded about %s until %s.", ctx.issueKey, time.Now().Add(snooze).UTC().Format(issueCardTimeFormat)),
+	})
+}
+
+func executeReminderAdd(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	_, instance, args, err := p.loadFlagUserInstance(header.UserId, args)
+	if err != nil {
+		return p.responsef(header, "Failed to identify the Jira instance. Error: %v.", err)
+	}
+	if err = p.hasPermissionToManageSubscription(instance.GetID(), header.UserId, header.ChannelId); err != nil {
+		return p.responsef(header, "You are not allowed to manage the Jira reminders of this channel: %v.", err)
+	}
+	rule, err := parseReminderRule(args)
+	if err != nil {
+		return p.responsef(header, "%v. %s", err, reminderUsage)
+	}
+	rule.ChannelID = header.ChannelId
+	rule.CreatorID = header.UserId
+	if err = p.scopeReminderRule(instance.GetID(), rule); err != nil {
+		return p.responsef(header, "%v. %s", err, reminderUsage)
+	}
+
+	client, _, _, err := p.getClient(instance.GetID(), types.ID(header.UserId))
+	if err != nil {
+		return p.responsef(header, "%v", err)
+	}
+	// Checks the query, like the status name, before the first run
+	if _, err = client.SearchIssues(rule.JQL(), &jira.SearchOptions{MaxResults: 1, Fields: []string{"status"}}); err != nil {
+		return p.responsef(header, "Failed to search the issues in `%s`. Error: %v.", rule.Status, err)
+	}
+
+	err = p.updateReminderRules(instance.GetID(), func(rules []ReminderRule) ([]ReminderRule, error) {
+		for i, existing := range rules {
+			if existing.ID() == rule.ID() {
+				rules[i] = *rule
+				return rules, nil
+			}
+		}
+		return append(rules, *rule), nil
+	})
+	if err != nil {
+		return p.responsef(header, "%v.", err)
+	}
+
+	return p.responsef(header, "Saved the reminder rule of this channel: %s. Their assignees will be reminded by direct message.", rule)
+}
+
+func executeReminderList(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	_, instance, _, err := p.loadFlagUserInstance(header.UserId, args)
+	if err != nil {
+		return p.responsef(header, "Failed to identify the Jira instance. Error: %v.", err)
+	}
+	rules, err := p.getReminderRules(instance.GetID())
+	if err != nil {
+		return p.responsef(header, "%v.", err)
+	}
+
+	sb := &strings.Builder{}
+	for _, rule := range rules {
+		if rule.ChannelID == header.ChannelId {
+			fmt.Fprintf(sb, "* %s\n", rule)
+		}
+	}
+	if sb.Len() == 0 {
+		return p.responsef(header, "This channel has no reminder rules. %s", reminderUsage)
+	}
+	return p.response(header, "Reminder rules of this channel:\n"+sb.String())
+}
+
+func executeReminderRemove(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	_, instance, args, err := p.loadFlagUserInstance(header.UserId, args)
+	if err != nil {
+		return p.responsef(header, "Failed to identify the Jira instance. Error: %v.", err)
+	}
+	if len(args) == 0 {
+		return p.response(header, reminderUsage)
+	}
+	if err = p.hasPermissionToManageSubscription(instance.GetID(), header.UserId, header.ChannelId); err != nil {
+		return p.responsef(header, "You are not allowed to manage the Jira reminders of this channel: %v.", err)
+	}
+
+	status := strings.Join(args, " ")
+	removed := ""
+	err = p.updateReminderRules(instance.GetID(), func(rules []ReminderRule) ([]ReminderRule, error) {
+		for i, rule := range rules {
+			if rule.ChannelID == header.ChannelId && strings.EqualFold(rule.Status, status) {
+				removed = rule.Status
+				return append(rules[:i], rules[i+1:]...), nil
+			}
+		}
+		return nil, errors.Errorf("this channel has no reminder rule for issues in **%s**", status)
+	})
+	if err != nil {
+		return p.responsef(header, "%v.", err)
+	}
+	return p.responsef(header, "Removed the reminder rule of this channel for issues in **%s**.", removed)
+}
@@ -0,0 +1,288 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReminderRule(t *testing.T) {
+	rule, err := parseReminderRule([]string{"3", "In", "Review", "project=ops"})
+	require.NoError(t, err)
+	assert.Equal(t, &ReminderRule{Status: "In Review", Days: 3, ProjectKey: "OPS"}, rule)
+	assert.Equal(t, `project = "OPS" AND status = "In Review" AND updated <= -3d AND assignee IS NOT EMPTY ORDER BY updated ASC`, rule.JQL())
+
+	_, err = parseReminderRule([]string{"3"})
+	assert.EqualError(t, err, "please specify the number of days and the status")
+	_, err = parseReminderRule([]string{"In", "Review"})
+	assert.EqualError(t, err, "the number of days must be between 1 and 365, not `In`")
+	_, err = parseReminderRule([]string{"0", "Done"})
+	assert.EqualError(t, err, "the number of days must be between 1 and 365, not `0`")
+
+	rule = &ReminderRule{Status: `Say "hi"`, Days: 1}
+	assert.Equal(t, `status = "Say \"hi\"" AND updated <= -1d AND assignee IS NOT EMPTY ORDER BY updated ASC`, rule.JQL())
+}
+
+func TestRemindAssignee(t *testing.T) {
+	api := &plugintest.API{}
+	p := setupTestPlugin(api)
+	kv := map[string][]byte{}
+	mockKVMap(api, kv)
+	p.updateConfig(func(conf *config) {
+		conf.botUserID = "bot-id"
+	})
+	instance, err := p.instanceStore.LoadInstance(testInstance1.InstanceID)
+	require.NoError(t, err)
+
+	issue := testCardIssue("In Testing")
+	issue.Fields.Assignee.AccountID = "assignee"
+	issue.Fields.Updated = jira.Time(time.Now().Add(-72 * time.Hour))
+
+	api.On("GetDirectChannel", "bot-id", mockUserIDWithNotifications).Return(&model.Channel{Id: "dm-id"}, nil)
+	var dm *model.Post
+	api.On("CreatePost", mock.AnythingOfType("*model.Post")).Return(func(post *model.Post) (*model.Post, *model.AppError) {
+		dm = post.Clone()
+		return post.Clone(), nil
+	}).Once()
+
+	rule := ReminderRule{ChannelID: "channel-id", Status: "In Testing", Days: 3, ProjectKey: "BUG"}
+	require.NoError(t, p.remindAssignee(testClient{}, instance, rule, issue))
+	require.NotNil(t, dm)
+	assert.Equal(t, "dm-id", dm.ChannelId)
+	assert.Equal(t, "bot-id", dm.UserId)
+	attachments := dm.Attachments()
+	require.Len(t, attachments, 1)
+	assert.Equal(t, "[BUG-12: The login page is slow]("+mockInstance1URL+"/browse/BUG-12) has been in **In Testing** for 3 days without an update.", attachments[0].Text)
+
+	actions := attachments[0].Actions
+	require.Len(t, actions, 3)
+	assert.Equal(t, "Transition issue", actions[0].Name)
+	assert.Len(t, actions[0].Options, 2)
+	assert.Equal(t, "Snooze 3 days", actions[1].Name)
+	assert.Equal(t, "Snooze 7 days", actions[2].Name)
+	assert.Equal(t, 7, actions[2].Integration.Context["days"])
+	assert.Equal(t, "channel-id_in testing", actions[2].Integration.Context["rule_id"])
+	signature, _ := actions[2].Integration.Context["action_signature"].(string)
+	assert.True(t, p.verifyPostActionSignature("BUG-12", testInstance1.InstanceID.String(), signature))
+
+	// Reminded at most once a day by each rule
+	require.NoError(t, p.remindAssignee(testClient{}, instance, rule, issue))
+	api.AssertNumberOfCalls(t, "CreatePost", 1)
+	assert.Contains(t, kv, reminderKey(testInstance1.InstanceID, "channel-id_in testing", "BUG-12"))
+
+	api.On("CreatePost", mock.AnythingOfType("*model.Post")).Return(&model.Post{}, nil).Once()
+	other := ReminderRule{ChannelID: "other-channel-id", Status: "In Testing", Days: 3, ProjectKey: "BUG"}
+	require.NoError(t, p.remindAssignee(testClient{}, instance, other, issue))
+	api.AssertNumberOfCalls(t, "CreatePost", 2)
+}
+
+func TestRouteSnoozeReminder(t *testing.T) {
+	const (
+		headerUserID = "user-id"
+		botUserID    = "jira-bot"
+		postID       = "reminder-post"
+	)
+
+	api := &plugintest.API{}
+	p := setupTestPlugin(api)
+	kv := map[string][]byte{}
+	mockKVMap(api, kv)
+	p.updateConfig(func(conf *config) {
+		conf.botUserID = botUserID
+	})
+	api.On("LogWarn", mockAnythingOfTypeBatch("string", 11)...).Return().Maybe()
+	api.On("SendEphemeralPost", mock.AnythingOfType("string"), mock.AnythingOfType("*model.Post")).Return(&model.Post{})
+	api.On("GetPost", postID).Return(&model.Post{Id: postID, UserId: botUserID, ChannelId: "dm-id"}, nil)
+
+	snooze := func(signed bool) int {
+		request := &model.PostActionIntegrationRequest{
+			UserId:    headerUserID,
+			ChannelId: "dm-id",
+			PostId:    postID,
+			Context: map[string]interface{}{
+				"issue_key":   "BUG-12",
+				"instance_id": testInstance1.InstanceID.String(),
+				"days":        7,
+				"rule_id":     "channel-id_in review",
+			},
+		}
+		if signed {
+			addActionSignatureToRequest(p, request)
+		}
+		bb, err := json.Marshal(request)
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, makeAPIRoute(routeReminderSnooze), strings.NewReader(string(bb)))
+		req.Header.Set(headerMattermostUserID, headerUserID)
+		w := httptest.NewRecorder()
+		p.ServeHTTP(&plugin.Context{}, w, req)
+		return w.Result().StatusCode
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, snooze(false))
+	assert.NotContains(t, kv, reminderKey(testInstance1.InstanceID, "channel-id_in review", "BUG-12"))
+
+	assert.Equal(t, http.StatusOK, snooze(true))
+	assert.Equal(t, "true", string(kv[reminderKey(testInstance1.InstanceID, "channel-id_in review", "BUG-12")]))
+}
+
+func TestScopeReminderRule(t *testing.T) {
+	api := &plugintest.API{}
+	p := setupTestPlugin(api)
+	kv := map[string][]byte{}
+	mockKVMap(api, kv)
+
+	subs := NewSubscriptions()
+	subs.Channel.add(&ChannelSubscription{ID: "sub1", ChannelID: "subscribed", Filters: SubscriptionFilters{Projects: NewStringSet("OPS")}})
+	subs.Channel.add(&ChannelSubscription{ID: "sub2", ChannelID: "subscribed-twice", Filters: SubscriptionFilters{Projects: NewStringSet("OPS")}})
+	subs.Channel.add(&ChannelSubscription{ID: "sub3", ChannelID: "subscribed-twice", Filters: SubscriptionFilters{Projects: NewStringSet("BUG")}})
+	_, err := p.client.KV.Set(keyWithInstanceID(testInstance1.InstanceID, JiraSubscriptionsKey), subs)
+	require.NoError(t, err)
+	require.NoError(t, p.storeChannelDefaults("defaults", &ChannelDefaults{InstanceID: testInstance1.InstanceID, ProjectKey: "HR"}))
+	require.NoError(t, p.storeChannelDefaults("other-instance", &ChannelDefaults{InstanceID: testInstance2.InstanceID, ProjectKey: "HR"}))
+
+	for name, tc := range map[string]struct {
+		channelID string
+		project   string
+		expected  string
+		err       string
+	}{
+		"default project":           {channelID: "defaults", expected: "HR"},
+		"the subscribed project":    {channelID: "subscribed", expected: "OPS"},
+		"a subscribed project":      {channelID: "subscribed-twice", project: "BUG", expected: "BUG"},
+		"several projects":          {channelID: "subscribed-twice", err: "please specify the project of the issues, like `project=OPS`"},
+		"not subscribed":            {channelID: "subscribed", project: "HR", err: "this channel is not subscribed to project HR and it is not its default project"},
+		"default of other instance": {channelID: "other-instance", err: "please specify the project of the issues, like `project=OPS`"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			rule := &ReminderRule{ChannelID: tc.channelID, Status: "In Review", Days: 3, ProjectKey: tc.project}
+			err := p.scopeReminderRule(testInstance1.InstanceID, rule)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, rule.ProjectKey)
+		})
+	}
+}
+
+func TestUpdateReminderRules(t *testing.T) {
+	api := &plugintest.API{}
+	p := setupTestPlugin(api)
+	kv := map[string][]byte{}
+	mockKVMap(api, kv)
+
+	add := func(rule ReminderRule) func([]ReminderRule) ([]ReminderRule, error) {
+		return func(rules []ReminderRule) ([]ReminderRule, error) {
+			return append(rules, rule), nil
+		}
+	}
+	require.NoError(t, p.updateReminderRules(testInstance1.InstanceID, add(ReminderRule{ChannelID: "b", Status: "Done"})))
+	require.NoError(t, p.updateReminderRules(testInstance1.InstanceID, add(ReminderRule{ChannelID: "a", Status: "To Do"})))
+	require.NoError(t, p.updateReminderRules(testInstance1.InstanceID, add(ReminderRule{ChannelID: "a", Status: "In Review"})))
+
+	rules, err := p.getReminderRules(testInstance1.InstanceID)
+	require.NoError(t, err)
+	assert.Equal(t, []ReminderRule{
+		{ChannelID: "a", Status: "In Review"},
+		{ChannelID: "a", Status: "To Do"},
+		{ChannelID: "b", Status: "Done"},
+	}, rules)
+
+	err = p.updateReminderRules(testInstance1.InstanceID, func([]ReminderRule) ([]ReminderRule, error) {
+		return nil, errors.New("this channel has no reminder rule for issues in **Blocked**")
+	})
+	assert.EqualError(t, err, "this channel has no reminder rule for issues in **Blocked**")
+	rules, err = p.getReminderRules(testInstance1.InstanceID)
+	require.NoError(t, err)
+	assert.Len(t, rules, 3)
+}
+
+func TestSnoozeReminderOutlastsRepeat(t *testing.T) {
+	api := &plugintest.API{}
+	p := setupTestPlugin(api)
+	p.updateConfig(func(conf *config) {
+		conf.botUserID = "bot-id"
+	})
+	instance, err := p.instanceStore.LoadInstance(testInstance1.InstanceID)
+	require.NoError(t, err)
+
+	kv := map[string][]byte{}
+	expiries := map[string]int64{}
+	api.On("KVGet", mock.AnythingOfType("string")).Return(func(key string) ([]byte, *model.AppError) {
+		return kv[key], nil
+	})
+	api.On("KVSetWithOptions", mock.AnythingOfType("string"), mock.Anything, mock.Anything).Return(
+		func(key string, value []byte, options model.PluginKVSetOptions) (bool, *model.AppError) {
+			kv[key] = value
+			expiries[key] = options.ExpireInSeconds
+			return true, nil
+		})
+	// expire drops the keys that expire within the duration
+	expire := func(d time.Duration) {
+		for key, expiry := range expiries {
+			if expiry > 0 && expiry <= int64(d.Seconds()) {
+				delete(kv, key)
+				delete(expiries, key)
+			}
+		}
+	}
+
+	api.On("GetDirectChannel", "bot-id", mockUserIDWithNotifications).Return(&model.Channel{Id: "dm-id"}, nil)
+	api.On("CreatePost", mock.AnythingOfType("*model.Post")).Return(&model.Post{Id: "reminder-post"}, nil)
+	api.On("GetPost", "reminder-post").Return(&model.Post{Id: "reminder-post", UserId: "bot-id", ChannelId: "dm-id"}, nil)
+	api.On("SendEphemeralPost", mock.AnythingOfType("string"), mock.AnythingOfType("*model.Post")).Return(&model.Post{})
+
+	issue := testCardIssue("In Testing")
+	issue.Fields.Assignee.AccountID = "assignee"
+	rule := ReminderRule{ChannelID: "channel-id", Status: "In Testing", Days: 3, ProjectKey: "BUG"}
+	require.NoError(t, p.remindAssignee(testClient{}, instance, rule, issue))
+	api.AssertNumberOfCalls(t, "CreatePost", 1)
+
+	for _, days := range reminderSnoozeDays {
+		assert.Greater(t, time.Duration(days)*24*time.Hour, reminderRepeat)
+	}
+	request := &model.PostActionIntegrationRequest{
+		UserId:    mockUserIDWithNotifications,
+		ChannelId: "dm-id",
+		PostId:    "reminder-post",
+		Context: map[string]interface{}{
+			"issue_key":   "BUG-12",
+			"instance_id": testInstance1.InstanceID.String(),
+			"days":        reminderSnoozeDays[0],
+			"rule_id":     rule.ID(),
+		},
+	}
+	addActionSignatureToRequest(p, request)
+	bb, err := json.Marshal(request)
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, makeAPIRoute(routeReminderSnooze), strings.NewReader(string(bb)))
+	req.Header.Set(headerMattermostUserID, mockUserIDWithNotifications)
+	w := httptest.NewRecorder()
+	p.ServeHTTP(&plugin.Context{}, w, req)
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	// A day later the issue would be reminded again, unless it was snoozed
+	expire(reminderRepeat)
+	require.NoError(t, p.remindAssignee(testClient{}, instance, rule, issue))
+	api.AssertNumberOfCalls(t, "CreatePost", 1)
+
+	expire(time.Duration(reminderSnoozeDays[0]) * 24 * time.Hour)
+	require.NoError(t, p.remindAssignee(testClient{}, instance, rule, issue))
+	api.AssertNumberOfCalls(t, "CreatePost", 2)
+}
@@ -0,0 +1,414 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+
+	"github.com/mattermost/mattermost-plugin-jira/server/utils/types"
+)
+
+const (
+	// Subscription filters for Jira Service Management projects. They are not issue
+	// fields, their values are derived from the webhook in matchesSubscriptionFilters.
+	RequestTypeFilter     = "requestType"
+	SLAFilter             = "sla"
+	CommentAudienceFilter = "commentAudience"
+
+	slaBreached             = "breached"
+	slaWithinGoal           = "within_goal"
+	commentAudiencePublic   = "public"
+	commentAudienceInternal = "internal"
+
+	// jsdPublicCommentProperty is the comment property Jira Data Center uses to mark
+	// internal comments.
+	jsdPublicCommentProperty = "sd.public.comment"
+
+	slaBreachKeyPrefix   = "sla_breach_"
+	slaBreachDedupExpiry = 30 * 24 * time.Hour
+
+	// The service desks and request types rarely change, and are needed each
+	// time the create issue modal is opened
+	serviceDeskCacheTTL = 10 * time.Minute
+)
+
+// ServiceDesk is a Jira Service Management service desk, there is one per service project.
+type ServiceDesk struct {
+	ID          string `json:"id"`
+	ProjectID   string `json:"projectId"`
+	ProjectKey  string `json:"projectKey"`
+	ProjectName string `json:"projectName"`
+}
+
+// RequestType is a type of customer request of a service desk.
+type RequestType struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	Description   string `json:"description,omitempty"`
+	ServiceDeskID string `json:"serviceDeskId"`
+}
+
+// ServiceDeskMetadata is what the webapp needs to know about the service desk of a project.
+type ServiceDeskMetadata struct {
+	ID           string        `json:"id"`
+	ProjectKey   string        `json:"project_key"`
+	RequestTypes []RequestType `json:"request_types"`
+}
+
+// getServiceDeskMetadata returns the service desks of the given projects. Instances without
+// Jira Service Management, or projects that are not service projects, have none. The service
+// desks and request types are cached per instance.
+func (p *Plugin) getServiceDeskMetadata(instanceID types.ID, client Client, projectKeys ...string) []*ServiceDeskMetadata {
+	serviceDesks, ok := p.serviceDesksCache.get(instanceID.String())
+	if !ok {
+		var err error
+		serviceDesks, err = client.GetServiceDesks()
+		if err != nil {
+			// Instances without Jira Service Management are not asked again until
+			// the cache expires
+			p.client.Log.Debug("Failed to get service desks", "error", err.Error())
+			serviceDesks = []ServiceDesk{}
+		}
+		p.serviceDesksCache.set(instanceID.String(), serviceDesks)
+	}
+
+	var result []*ServiceDeskMetadata
+	for _, serviceDesk := range serviceDesks {
+		found := false
+		for _, key := range projectKeys {
+			if strings.EqualFold(serviceDesk.ProjectKey, key) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			continue
+		}
+
+		requestTypesKey := instanceID.String() + "/" + serviceDesk.ID
+		requestTypes, ok := p.requestTypesCache.get(requestTypesKey)
+		if !ok {
+			var err error
+			requestTypes, err = client.GetRequestTypes(serviceDesk.ID)
+			if err != nil {
+				p.client.Log.Debug("Failed to get request types", "serviceDeskID", serviceDesk.ID, "error", err.Error())
+				continue
+			}
+			p.requestTypesCache.set(requestTypesKey, requestTypes)
+		}
+		result = append(result, &ServiceDeskMetadata{
+			ID:           serviceDesk.ID,
+			ProjectKey:   serviceDesk.ProjectKey,
+			RequestTypes: requestTypes,
+		})
+	}
+	return result
+}
+
+func (p *Plugin) httpGetRequestTypes(w http.ResponseWriter, r *http.Request) (int, error) {
+	mattermostUserID := r.Header.Get("Mattermost-User-Id")
+	instanceID := types.ID(r.FormValue("instance_id"))
+	projectKey := r.FormValue(QueryParamProjectKey)
+	if projectKey == "" {
+		return respondErr(w, http.StatusBadRequest, errors.New("project_key is required"))
+	}
+
+	client, _, _, err := p.getClient(instanceID, types.ID(mattermostUserID))
+	if err != nil {
+		return respondErr(w, http.StatusInternalServerError, err)
+	}
+
+	serviceDesks := p.getServiceDeskMetadata(instanceID, client, projectKey)
+	if len(serviceDesks) == 0 {
+		return respondErr(w, http.StatusNotFound,
+			errors.Errorf("project %s is not a Jira Service Management project", projectKey))
+	}
+	return respondJSON(w, serviceDesks[0])
+}
+
+type InCreateCustomerRequest struct {
+	mattermostUserID types.ID
+	InstanceID       types.ID `json:"instance_id"`
+	PostID           string   `json:"post_id"`
+	CurrentTeam      string   `json:"current_team"`
+	ServiceDeskID    string   `json:"service_desk_id"`
+	RequestTypeID    string   `json:"request_type_id"`
+	Summary          string   `json:"summary"`
+}
+
+func (p *Plugin) httpCreateCustomerRequest(w http.ResponseWriter, r *http.Request) (int, error) {
+	in := InCreateCustomerRequest{}
+	err := json.NewDecoder(r.Body).Decode(&in)
+	if err != nil {
+		return respondErr(w, http.StatusBadRequest,
+			errors.WithMessage(err, "failed to decode incoming request"))
+	}
+
+	in.mattermostUserID = types.ID(r.Header.Get("Mattermost-User-Id"))
+	created, statusCode, err := p.CreateCustomerRequest(&in)
+	if err != nil {
+		return respondErr(w, statusCode, errors.WithMessage(err, "failed to create customer request"))
+	}
+
+	return respondJSON(w, created)
+}
+
+// CreateCustomerRequest raises a customer request from a post. The post becomes the
+// description of the request, and the channel is told about the new request.
+func (p *Plugin) CreateCustomerRequest(in *InCreateCustomerRequest) (*jira.Request, int, error) {
+	if in.ServiceDeskID == "" || in.RequestTypeID == "" {
+		return nil, http.StatusBadRequest, errors.New("a service desk and a request type are required")
+	}
+	if strings.TrimSpace(in.Summary) == "" {
+		return nil, http.StatusBadRequest, errors.New("a summary is required")
+	}
+
+	client, instance, connection, err := p.getClient(in.InstanceID, in.mattermostUserID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	post, err := p.client.Post.GetPost(in.PostID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.WithMessage(err, "failed to load post "+in.PostID)
+	}
+	if post == nil {
+		return nil, http.StatusNotFound, errors.New("failed to load post " + in.PostID + ": not found")
+	}
+
+	_, err = p.client.Channel.GetMember(post.ChannelId, in.mattermostUserID.String())
+	if err != nil {
+		return nil, http.StatusForbidden, errors.New("User does not have access to this post")
+	}
+
+	drafts, err := p.draftPostComment(instance, connection, post, in.CurrentTeam)
+	if err != nil {
+		return nil, http.StatusNotFound, err
+	}
+
+	// The request API takes wiki markup on both Jira Cloud and Jira Data Center.
+	description := markdownToWiki(drafts[0].message, p.jiraMentionResolver(instance))
+	created, err := client.CreateCustomerRequest(&jira.Request{
+		ServiceDeskID: in.ServiceDeskID,
+		TypeID:        in.RequestTypeID,
+		FieldValues: []jira.RequestFieldValue{
+			{FieldID: "summary", Value: strings.TrimSpace(in.Summary)},
+			{FieldID: "description", Value: description},
+		},
+	})
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	rootID := post.Id
+	if post.RootId != "" {
+		rootID = post.RootId
+	}
+
+	reply := &model.Post{
+		Message: fmt.Sprintf("Raised customer request [%s](%s/browse/%s)",
+			created.IssueKey, instance.GetJiraBaseURL(), created.IssueKey),
+		ChannelId: post.ChannelId,
+		RootId:    rootID,
+		UserId:    in.mattermostUserID.String(),
+	}
+	err = p.client.Post.CreatePost(reply)
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.WithMessage(err, "failed to create notification post "+in.PostID)
+	}
+
+	return created, http.StatusOK, nil
+}
+
+// getRequestTypeValue returns the ID and the name of the customer request type of the issue.
+// The request type field is a custom field, so it is recognized by the shape of its value.
+func getRequestTypeValue(issue *jira.Issue) StringSet {
+	result := NewStringSet()
+	if issue == nil || issue.Fields == nil {
+		return result
+	}
+	for _, value := range issue.Fields.Unknowns {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		requestType, ok := m["requestType"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if id, ok := requestType["id"].(string); ok && id != "" {
+			result = result.Add(id)
+		}
+		if name, ok := requestType["name"].(string); ok && name != "" {
+			result = result.Add(name)
+		}
+	}
+	return result
+}
+
+// issueSLA is the current state of an SLA of a service desk issue.
+type issueSLA struct {
+	name string
+
+	// breachedCycles holds the start time of the cycles of the SLA that were breached.
+	breachedCycles []string
+}
+
+// getIssueSLAs returns the SLAs of the issue, found by the shape of their custom field value.
+func getIssueSLAs(issue *jira.Issue) []issueSLA {
+	if issue == nil || issue.Fields == nil {
+		return nil
+	}
+
+	var slas []issueSLA
+	for _, value := range issue.Fields.Unknowns {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := m["name"].(string)
+		ongoing, hasOngoing := m["ongoingCycle"].(map[string]interface{})
+		completed, hasCompleted := m["completedCycles"].([]interface{})
+		if name == "" || (!hasOngoing && !hasCompleted) {
+			continue
+		}
+
+		sla := issueSLA{name: name}
+		cycles := append([]interface{}{}, completed...)
+		if hasOngoing {
+			cycles = append(cycles, ongoing)
+		}
+		for _, c := range cycles {
+			cycle, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if breached, _ := cycle["breached"].(bool); breached {
+				sla.breachedCycles = append(sla.breachedCycles, slaCycleStart(cycle))
+			}
+		}
+		slas = append(slas, sla)
+	}
+	return slas
+}
+
+func slaCycleStart(cycle map[string]interface{}) string {
+	startTime, ok := cycle["startTime"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	if iso, ok := startTime["iso8601"].(string); ok {
+		return iso
+	}
+	if millis, ok := startTime["epochMillis"].(float64); ok {
+		return fmt.Sprintf("%.0f", millis)
+	}
+	return ""
+}
+
+// getSLAValue returns whether an SLA of the issue is breached, or empty if it has no SLA.
+func getSLAValue(issue *jira.Issue) StringSet {
+	slas := getIssueSLAs(issue)
+	if len(slas) == 0 {
+		return NewStringSet()
+	}
+	for _, sla := range slas {
+		if len(sla.breachedCycles) > 0 {
+			return NewStringSet(slaBreached)
+		}
+	}
+	return NewStringSet(slaWithinGoal)
+}
+
+// getCommentAudienceValue returns whether the comment of the webhook is visible to the
+// customers of a service desk, or empty if the webhook has no such comment.
+func getCommentAudienceValue(wh *webhook) StringSet {
+	if wh.CommentPublic == nil {
+		return NewStringSet()
+	}
+	if *wh.CommentPublic {
+		return NewStringSet(commentAudiencePublic)
+	}
+	return NewStringSet(commentAudienceInternal)
+}
+
+// parseCommentPublic tells whether the comment of a webhook is public or internal. Jira Cloud
+// sets jsdPublic on the comments of service desk issues, Jira Data Center uses a property.
+// It returns nil when the comment is not a service desk comment.
+func parseCommentPublic(bb []byte) *bool {
+	raw := struct {
+		Comment struct {
+			JSDPublic  *bool `json:"jsdPublic"`
+			Properties []struct {
+				Key   string `json:"key"`
+				Value struct {
+					Internal bool `json:"internal"`
+				} `json:"value"`
+			} `json:"properties"`
+		} `json:"comment"`
+	}{}
+	if err := json.Unmarshal(bb, &raw); err != nil {
+		return nil
+	}
+	if raw.Comment.JSDPublic != nil {
+		return raw.Comment.JSDPublic
+	}
+	for _, property := range raw.Comment.Properties {
+		if property.Key == jsdPublicCommentProperty {
+			public := !property.Value.Internal
+			return &public
+		}
+	}
+	return nil
+}
+
+// postSLABreachWarnings warns the channels subscribed to eventSLABreached about SLAs the
+// issue has breached. Jira sends no webhook when an SLA is breached, so the breach is
+// noticed with the next event of the issue. Every breached cycle is only reported once in
+// each channel, and reported again with the next event if the warning failed to post.
+func (p *Plugin) postSLABreachWarnings(wh *webhook, instanceID types.ID) {
+	for _, sla := range getIssueSLAs(&wh.Issue) {
+		for _, cycleStart := range sla.breachedCycles {
+			warning := &webhook{
+				JiraWebhook: wh.JiraWebhook,
+				eventTypes:  NewStringSet(eventSLABreached),
+				headline:    fmt.Sprintf(":warning: SLA **%s** breached on %s", sla.name, wh.mdKeySummaryLink()),
+			}
+			channels, err := p.getChannelsSubscribed(warning, instanceID)
+			if err != nil {
+				p.client.Log.Warn("Failed to get the channels subscribed to SLA breaches", "error", err.Error())
+				return
+			}
+			for _, channel := range channels {
+				// The breach is claimed so that concurrent events do not both warn, and the
+				// claim is only kept once the warning is posted
+				key := instanceKey(slaBreachKeyPrefix, instanceID, fmt.Sprintf("%s_%s_%s_%s", wh.Issue.ID, sla.name, cycleStart, channel.ChannelID))
+				isNew, err := p.client.KV.Set(key, []byte("1"), pluginapi.SetAtomic(nil), pluginapi.SetExpiry(slaBreachDedupExpiry))
+				if err != nil {
+					p.client.Log.Warn("Failed to store SLA breach", "issue", wh.Issue.Key, "error", err.Error())
+					continue
+				}
+				if !isNew {
+					continue
+				}
+				if _, _, err = warning.PostToChannel(p, instanceID, channel.ChannelID, p.getBotUserID(instanceID), channel.Name); err != nil {
+					p.errorf("Failed to post SLA breach warning to channel %s: %v", channel.ChannelID, err)
+					if err = p.client.KV.Delete(key); err != nil {
+						p.client.Log.Warn("Failed to release the SLA breach", "issue", wh.Issue.Key, "error", err.Error())
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serviceDeskIssue(breached bool) *jira.Issue {
+	return &jira.Issue{
+		Key: "SD-1",
+		Fields: &jira.IssueFields{
+			Unknowns: map[string]interface{}{
+				"customfield_10010": map[string]interface{}{
+					"requestType": map[string]interface{}{
+						"id":   "11",
+						"name": "Get IT help",
+					},
+				},
+				"customfield_10030": map[string]interface{}{
+					"name": "Time to first response",
+					"ongoingCycle": map[string]interface{}{
+						"breached": breached,
+						"startTime": map[string]interface{}{
+							"iso8601": "2024-01-02T10:00:00+0000",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestServiceDeskFieldValues(t *testing.T) {
+	assert.ElementsMatch(t, []string{"11", "Get IT help"}, getRequestTypeValue(serviceDeskIssue(false)).Elems())
+	assert.Equal(t, NewStringSet(slaBreached), getSLAValue(serviceDeskIssue(true)))
+	assert.Equal(t, NewStringSet(slaWithinGoal), getSLAValue(serviceDeskIssue(false)))
+
+	plain := &jira.Issue{Fields: &jira.IssueFields{}}
+	assert.Equal(t, 0, getRequestTypeValue(plain).Len())
+	assert.Equal(t, 0, getSLAValue(plain).Len())
+
+	slas := getIssueSLAs(serviceDeskIssue(true))
+	require.Len(t, slas, 1)
+	assert.Equal(t, "Time to first response", slas[0].name)
+	assert.Equal(t, []string{"2024-01-02T10:00:00+0000"}, slas[0].breachedCycles)
+}
+
+func TestParseCommentPublic(t *testing.T) {
+	for name, tc := range map[string]struct {
+		body     string
+		expected *bool
+	}{
+		"cloud public comment": {
+			body:     `{"comment": {"id": "1", "jsdPublic": true}}`,
+			expected: model.NewPointer(true),
+		},
+		"cloud internal comment": {
+			body:     `{"comment": {"id": "1", "jsdPublic": false}}`,
+			expected: model.NewPointer(false),
+		},
+		"data center internal comment": {
+			body:     `{"comment": {"id": "1", "properties": [{"key": "sd.public.comment", "value": {"internal": true}}]}}`,
+			expected: model.NewPointer(false),
+		},
+		"not a service desk comment": {
+			body: `{"comment": {"id": "1"}}`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, parseCommentPublic([]byte(tc.body)))
+		})
+	}
+}
+
+type serviceDeskTestClient struct {
+	testClient
+	serviceDeskCalls  *int
+	requestTypesCalls *int
+}
+
+func (client serviceDeskTestClient) GetServiceDesks() ([]ServiceDesk, error) {
+	*client.serviceDeskCalls++
+	return []ServiceDesk{{ID: "1", ProjectKey: "SD"}, {ID: "2", ProjectKey: "HR"}}, nil
+}
+
+func (client serviceDeskTestClient) GetRequestTypes(serviceDeskID string) ([]RequestType, error) {
+	*client.requestTypesCalls++
+	return []RequestType{{ID: "11", Name: "Get IT help", ServiceDeskID: serviceDeskID}}, nil
+}
+
+func TestGetServiceDeskMetadataCached(t *testing.T) {
+	p := setupTestPlugin(&plugintest.API{})
+	p.serviceDesksCache = newExpiringCache[[]ServiceDesk](serviceDeskCacheTTL)
+	p.requestTypesCache = newExpiringCache[[]RequestType](serviceDeskCacheTTL)
+	var serviceDeskCalls, requestTypesCalls int
+	client := serviceDeskTestClient{serviceDeskCalls: &serviceDeskCalls, requestTypesCalls: &requestTypesCalls}
+
+	for i := 0; i < 3; i++ {
+		serviceDesks := p.getServiceDeskMetadata(testInstance1.InstanceID, client, "SD")
+		require.Len(t, serviceDesks, 1)
+		assert.Equal(t, "SD", serviceDesks[0].ProjectKey)
+		assert.Equal(t, "Get IT help", serviceDesks[0].RequestTypes[0].Name)
+	}
+	assert.Equal(t, 1, serviceDeskCalls)
+	assert.Equal(t, 1, requestTypesCalls)
+
+	// Only the request types of the new project are fetched
+	assert.Len(t, p.getServiceDeskMetadata(testInstance1.InstanceID, client, "SD", "HR"), 2)
+	assert.Equal(t, 1, serviceDeskCalls)
+	assert.Equal(t, 2, requestTypesCalls)
+
+	p.getServiceDeskMetadata(testInstance2.InstanceID, client, "SD")
+	assert.Equal(t, 2, serviceDeskCalls)
+}
+
+func TestPostSLABreachWarnings(t *testing.T) {
+	api := &plugintest.API{}
+	p := setupTestPlugin(api)
+	kv := map[string][]byte{}
+	// The breaches are claimed atomically
+	api.On("KVSetWithOptions", mock.AnythingOfType("string"), mock.Anything, mock.MatchedBy(func(options model.PluginKVSetOptions) bool {
+		return options.Atomic
+	})).Return(func(key string, value []byte, options model.PluginKVSetOptions) (bool, *model.AppError) {
+		if !bytes.Equal(kv[key], options.OldValue) {
+			return false, nil
+		}
+		kv[key] = value
+		return true, nil
+	})
+	mockKVMap(api, kv)
+	p.updateConfig(func(conf *config) {
+		conf.botUserID = "bot-id"
+	})
+
+	subs := NewSubscriptions()
+	subs.Channel.add(&ChannelSubscription{ID: "sub1", ChannelID: "channel1", Filters: SubscriptionFilters{
+		Events:   NewStringSet(eventSLABreached),
+		Projects: NewStringSet("SD"),
+	}})
+	_, err := p.client.KV.Set(keyWithInstanceID(testInstance1.InstanceID, JiraSubscriptionsKey), subs)
+	require.NoError(t, err)
+
+	issue := serviceDeskIssue(true)
+	issue.ID = "10001"
+	issue.Fields.Project = jira.Project{Key: "SD"}
+	wh := &webhook{JiraWebhook: &JiraWebhook{Issue: *issue}}
+
+	api.On("CreatePost", mock.AnythingOfType("*model.Post")).Return(nil, &model.AppError{Message: "failed"}).Once()
+	api.On("LogError", mockAnythingOfTypeBatch("string", 3)...).Return().Maybe()
+	p.postSLABreachWarnings(wh, testInstance1.InstanceID)
+	// The failed warning is posted with the next event
+	api.On("CreatePost", mock.AnythingOfType("*model.Post")).Return(&model.Post{Id: "warning"}, nil).Once()
+	p.postSLABreachWarnings(wh, testInstance1.InstanceID)
+	p.postSLABreachWarnings(wh, testInstance1.InstanceID)
+	api.AssertNumberOfCalls(t, "CreatePost", 2)
+	api.AssertCalled(t, "CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+		return post.ChannelId == "channel1" && post.UserId == "bot-id"
+	}))
+}
@@ -254,6 +254,24 @@ func (p *Plugin) stepChooseEdition() flow.Step {
 			},
 			OnDialogSubmit: p.submitCreateServerInstance,
 		}).
+		WithButton(flow.Button{
+			Name:  "Jira Data Center (Personal Access Token)",
+			Color: flow.ColorPrimary,
+			Dialog: &model.Dialog{
+				Title:            "Enter Jira Data Center URL",
+				IntroductionText: "Users will connect by entering a Jira personal access token, no Application Link is needed.",
+				SubmitLabel:      "Continue",
+				Elements: []model.DialogElement{
+					{
+						DisplayName: "Jira Data Center URL",
+						Name:        "url",
+						Type:        "text",
+						SubType:     "url",
+					},
+				},
+			},
+			OnDialogSubmit: p.submitCreateServerPATInstance,
+		}).
 		WithButton(cancelButton)
 }
 
@@ -409,7 +427,10 @@ func (p *Plugin) stepWebhook() flow.Step {
 			"5. **Issue related events**: we recommend leaving the query at **All Issues**. Check **Comment** " +
 			"and **Issue** events. Leave **Entity property**, **Worklog**, and **Issue " +
 			"link** events unchecked, they are not yet supported.\n" +
-			"6. Leave all other checkboxes blank.\n" +
+			"6. To subscribe channels to sprint and board events, also check **Sprint** and **Board** under " +
+			"**Jira Software related events**. For release notifications, check the **Version** events under " +
+			"**Project related events**. For `/jira subscribe instance`, check the **Project**, **Issue type**, " +
+			"**Workflow** and **User** events. Leave all other checkboxes blank.\n" +
 			"7. Select **View Webhook URL** to see the secret **URL** to enter in Jira, and continue.\n").
 		WithImage("public/configure-webhook.png").
 		OnRender(p.trackSetupWizard("setup_wizard_webhook_start", nil)).
@@ -612,6 +633,7 @@ func (p *Plugin) initCreateCloudOAuthInstance(f *flow.Flow, submission map[strin
 	if err != nil {
 		return "", nil, nil, err
 	}
+	p.audit(f.UserID, AuditInstanceInstall, types.ID(jiraURL), jiraURL, string(CloudOAuthInstanceType))
 
 	return stepCloudOAuthConfigure, flow.State{
 		keyEdition:          string(CloudOAuthInstanceType),
@@ -683,10 +705,11 @@ func (p *Plugin) submitCreateServerInstance(f *flow.Flow, submission map[string]
 	}
 	jiraURL = strings.TrimSpace(jiraURL)
 
-	jiraURL, si, err := p.installServerInstance(jiraURL)
+	jiraURL, si, err := p.installServerInstance(jiraURL, "")
 	if err != nil {
 		return "", nil, nil, err
 	}
+	p.audit(f.UserID, AuditInstanceInstall, si.InstanceID, jiraURL, string(ServerInstanceType))
 	pkey, err := p.publicKeyString()
 	if err != nil {
 		return "", nil, nil, errors.Wrap(err, "failed to load public key")
@@ -704,6 +727,31 @@ func (p *Plugin) submitCreateServerInstance(f *flow.Flow, submission map[string]
 	}, nil, nil
 }
 
+// submitCreateServerPATInstance installs a Jira server instance that users connect to with
+// personal access tokens. There is no Application Link to configure, so the flow continues
+// with the webhook setup.
+func (p *Plugin) submitCreateServerPATInstance(f *flow.Flow, submission map[string]interface{}) (flow.Name, flow.State, map[string]string, error) {
+	jiraURL, _ := submission["url"].(string)
+	if jiraURL == "" {
+		return "", nil, nil, errors.New("no Jira server URL in the request")
+	}
+	jiraURL = strings.TrimSpace(jiraURL)
+
+	jiraURL, si, err := p.installServerInstance(jiraURL, serverAuthModePAT)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	p.audit(f.UserID, AuditInstanceInstall, si.InstanceID, jiraURL, string(ServerInstanceType))
+
+	return stepWebhook, flow.State{
+		keyEdition:           string(ServerInstanceType),
+		keyJiraURL:           jiraURL,
+		keyConnectURL:        p.GetPluginURL() + "/" + instancePath(routeUserConnect, si.InstanceID),
+		keyWebhookURL:        p.getSubscriptionsWebhookURL(si.InstanceID),
+		keyManageWebhooksURL: si.GetManageWebhooksURL(),
+	}, nil, nil
+}
+
 func (p *Plugin) trackSetupWizard(event string, args map[string]interface{}) func(f *flow.Flow) {
 	return func(f *flow.Flow) {
 		p.TrackUserEvent(event, f.UserID, args)
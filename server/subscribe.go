@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"sort"
 	"strings"
+	"time"
 
 	jira "github.com/andygrunwald/go-jira"
 	"github.com/gorilla/mux"
@@ -46,13 +47,15 @@ type FieldFilter struct {
 	Key       string    `json:"key"`
 	Inclusion string    `json:"inclusion"`
 	Values    StringSet `json:"values"`
+	ValueType string    `json:"value_type,omitempty"`
 }
 
 type SubscriptionFilters struct {
-	Events     StringSet     `json:"events"`
-	Projects   StringSet     `json:"projects"`
-	IssueTypes StringSet     `json:"issue_types"`
-	Fields     []FieldFilter `json:"fields"`
+	Events     StringSet      `json:"events"`
+	Projects   StringSet      `json:"projects"`
+	IssueTypes StringSet      `json:"issue_types"`
+	Fields     []FieldFilter  `json:"fields"`
+	Changes    []ChangeFilter `json:"changes,omitempty"`
 }
 
 type ChannelSubscription struct {
@@ -106,6 +109,7 @@ func (s *ChannelSubscriptions) add(newSubscription *ChannelSubscription) {
 type Subscriptions struct {
 	PluginVersion string
 	Channel       *ChannelSubscriptions
+	Instance      *InstanceSubscriptions
 }
 
 type SubscriptionTemplateCollection map[string]*SubscriptionTemplate
@@ -124,6 +128,7 @@ func NewSubscriptions() *Subscriptions {
 	return &Subscriptions{
 		PluginVersion: manifest.Version,
 		Channel:       NewChannelSubscriptions(),
+		Instance:      NewInstanceSubscriptions(),
 	}
 }
 
@@ -158,6 +163,9 @@ func SubscriptionsFromJSON(bytes []byte, instanceID types.ID) (*Subscriptions, e
 		sub.InstanceID = instanceID
 		subs.Channel.ByID[subID] = sub
 	}
+	if subs.Instance == nil {
+		subs.Instance = NewInstanceSubscriptions()
+	}
 
 	return subs, nil
 }
@@ -209,6 +217,10 @@ func (p *Plugin) matchesSubscriptionFilters(wh *webhook, instanceID types.ID, fi
 		return false
 	}
 
+	if !matchesChangeFilters(wh, filters.Changes) {
+		return false
+	}
+
 	containsSecurityLevelFilter := false
 	useEmptySecurityLevel := p.getConfig().SecurityLevelEmptyForJiraSubscriptions
 	for _, field := range filters.Fields {
@@ -219,6 +231,13 @@ func (p *Plugin) matchesSubscriptionFilters(wh *webhook, instanceID types.ID, fi
 			return false
 		}
 
+		if field.ValueType != "" {
+			if !p.matchesTypedFieldFilter(instanceID, issue, field, time.Now()) {
+				return false
+			}
+			continue
+		}
+
 		if field.Key == securityLevelField {
 			containsSecurityLevelFilter = true
 			if inclusion == FilterExcludeAny && useEmptySecurityLevel {
@@ -235,6 +254,15 @@ func (p *Plugin) matchesSubscriptionFilters(wh *webhook, instanceID types.ID, fi
 			value = updateTeamValue(value, issue, teamFieldKeys)
 		}
 
+		switch field.Key {
+		case RequestTypeFilter:
+			value = getRequestTypeValue(issue)
+		case SLAFilter:
+			value = getSLAValue(issue)
+		case CommentAudienceFilter:
+			value = getCommentAudienceValue(wh)
+		}
+
 		if shouldAddVisibleToAllUsersToFieldValues(wh, field) {
 			field.Values = field.Values.Add(visibleToAllUsers)
 		}
@@ -450,7 +478,7 @@ func (p *Plugin) removeSubscriptionsForChannel(instanceID types.ID, channelID st
 		return err
 	}
 
-	if subs.Channel.IDByChannelID[channelID].Len() == 0 {
+	if subs.Channel.IDByChannelID[channelID].Len() == 0 && subs.Instance.IDByChannelID[channelID].Len() == 0 {
 		return nil
 	}
 
@@ -467,6 +495,9 @@ func (p *Plugin) removeSubscriptionsForChannel(instanceID types.ID, channelID st
 				subs.Channel.remove(&sub)
 			}
 		}
+		for _, sub := range subs.Instance.forChannel(channelID) {
+			subs.Instance.remove(&sub)
+		}
 
 		modifiedBytes, marshalErr := json.Marshal(&subs)
 		if marshalErr != nil {
@@ -635,6 +666,10 @@ func (p *Plugin) validateSubscriptionTemplate(subscriptionTemplate *Subscription
 		return errors.New("please provide at least one event type")
 	}
 
+	if err := p.validateProjectEvents(instanceID, subscriptionTemplate.Filters.Events); err != nil {
+		return err
+	}
+
 	if len(subscriptionTemplate.Filters.IssueTypes) == 0 {
 		return errors.New("please provide at least one issue type")
 	}
@@ -674,6 +709,10 @@ func (p *Plugin) validateSubscription(instanceID types.ID, subscription *Channel
 		return errors.New("please provide at least one event type")
 	}
 
+	if err := p.validateProjectEvents(instanceID, subscription.Filters.Events); err != nil {
+		return err
+	}
+
 	if len(subscription.Filters.IssueTypes) == 0 {
 		return errors.New("please provide at least one issue type")
 	}
@@ -684,9 +723,23 @@ func (p *Plugin) validateSubscription(instanceID types.ID, subscription *Channel
 
 	projectKey := subscription.Filters.Projects.Elems()[0]
 
+	if err := p.validateSubscriptionProjects(instanceID, subscription); err != nil {
+		return err
+	}
+
+	for _, change := range subscription.Filters.Changes {
+		if err := validateChangeFilter(change); err != nil {
+			return err
+		}
+	}
+
 	var securityLevels StringSet
 	useEmptySecurityLevel := p.getConfig().SecurityLevelEmptyForJiraSubscriptions
 	for _, field := range subscription.Filters.Fields {
+		if err := validateTypedFieldFilter(field); err != nil {
+			return err
+		}
+
 		if field.Key != securityLevelField {
 			continue
 		}
@@ -1049,33 +1102,12 @@ func inAllowedGroup(inGroups []*jira.UserGroup, allowedGroups []string) bool {
 func (p *Plugin) hasPermissionToManageSubscription(instanceID types.ID, userID, channelID string) error {
 	cfg := p.getConfig()
 
-	switch cfg.RolesAllowedToEditJiraSubscriptions {
-	case "team_admin":
-		if !p.client.User.HasPermissionToChannel(userID, channelID, model.PermissionManageTeam) {
-			return errors.New("is not team admin")
-		}
-	case "channel_admin":
-		channel, err := p.client.Channel.Get(channelID)
-		if err != nil {
-			return errors.Wrap(err, "unable to get channel to check permission")
-		}
-		switch channel.Type {
-		case model.ChannelTypeOpen:
-			if !p.client.User.HasPermissionToChannel(userID, channelID, model.PermissionManagePublicChannelProperties) {
-				return errors.New("is not channel admin")
-			}
-		case model.ChannelTypePrivate:
-			if !p.client.User.HasPermissionToChannel(userID, channelID, model.PermissionManagePrivateChannelProperties) {
-				return errors.New("is not channel admin")
-			}
-		default:
-			return errors.New("can only subscribe in public and private channels")
-		}
-	case "users":
-	default:
-		if !p.client.User.HasPermissionTo(userID, model.PermissionManageSystem) {
-			return errors.New("is not system admin")
-		}
+	perms, err := p.getSubscriptionPermissions(instanceID)
+	if err != nil {
+		return err
+	}
+	if _, err = p.checkSubscriptionRoleInChannel(perms, userID, channelID); err != nil {
+		return err
 	}
 
 	instance, err := p.instanceStore.LoadInstance(instanceID)
@@ -1191,6 +1223,7 @@ func (p *Plugin) httpChannelCreateSubscription(w http.ResponseWriter, r *http.Re
 	if err != nil {
 		return respondErr(w, http.StatusInternalServerError, err)
 	}
+	p.audit(mattermostUserID, AuditSubscriptionCreate, subscription.InstanceID, subscription.Name, subscriptionAuditDetails(subscription.ID, subscription.ChannelID))
 
 	projectKey := ""
 	if subscription.Filters.Projects.Len() == 1 {
@@ -1283,6 +1316,7 @@ func (p *Plugin) httpChannelEditSubscription(w http.ResponseWriter, r *http.Requ
 	if err != nil {
 		return respondErr(w, http.StatusInternalServerError, err)
 	}
+	p.audit(mattermostUserID, AuditSubscriptionEdit, subscription.InstanceID, subscription.Name, subscriptionAuditDetails(subscription.ID, subscription.ChannelID))
 
 	projectKey := ""
 	if subscription.Filters.Projects.Len() == 1 {
@@ -1343,6 +1377,7 @@ func (p *Plugin) httpChannelDeleteSubscription(w http.ResponseWriter, r *http.Re
 		return respondErr(w, http.StatusInternalServerError,
 			errors.Wrap(err, "unable to remove channel subscription"))
 	}
+	p.audit(mattermostUserID, AuditSubscriptionDelete, instanceID, subscription.Name, subscriptionAuditDetails(subscriptionID, subscription.ChannelID))
 
 	code, err := respondJSON(w, map[string]interface{}{"status": "OK"})
 	if err != nil {
@@ -1451,6 +1486,7 @@ func (p *Plugin) httpEditSubscriptionTemplates(w http.ResponseWriter, r *http.Re
 	if err = p.editSubscriptionTemplate(subscriptionTemplate.InstanceID, &subscriptionTemplate, client); err != nil {
 		return respondErr(w, http.StatusInternalServerError, err)
 	}
+	p.audit(mattermostUserID, AuditTemplateEdit, subscriptionTemplate.InstanceID, subscriptionTemplate.Name, subscriptionAuditDetails(subscriptionTemplate.ID, subscriptionTemplate.ChannelID))
 
 	_ = p.API.SendEphemeralPost(mattermostUserID, &model.Post{
 		UserId:    p.getConfig().botUserID,
@@ -1481,6 +1517,7 @@ func (p *Plugin) httpCreateSubscriptionTemplate(w http.ResponseWriter, r *http.R
 	if err = p.addSubscriptionTemplate(subscriptionTemplate.InstanceID, &subscriptionTemplate, client); err != nil {
 		return respondErr(w, http.StatusInternalServerError, err)
 	}
+	p.audit(mattermostUserID, AuditTemplateCreate, subscriptionTemplate.InstanceID, subscriptionTemplate.Name, subscriptionAuditDetails(subscriptionTemplate.ID, subscriptionTemplate.ChannelID))
 
 	_ = p.API.SendEphemeralPost(mattermostUserID, &model.Post{
 		UserId:    p.getConfig().botUserID,
@@ -1528,6 +1565,7 @@ func (p *Plugin) httpDeleteSubscriptionTemplate(w http.ResponseWriter, r *http.R
 	if rErr := p.removeSubscriptionTemplate(instanceID, subscriptionTemplateID, projectKey); rErr != nil {
 		return respondErr(w, http.StatusInternalServerError, errors.Wrap(err, "unable to remove channel subscription template"))
 	}
+	p.audit(mattermostUserID, AuditTemplateDelete, instanceID, subscriptionTemplate.Name, subscriptionAuditDetails(subscriptionTemplateID, subscriptionTemplate.ChannelID))
 
 	_ = p.API.SendEphemeralPost(mattermostUserID, &model.Post{
 		UserId:    p.getConfig().botUserID,
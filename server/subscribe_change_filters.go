@@ -0,0 +1,77 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ChangeValueNone matches a field being set from, or cleared to, no value.
+const ChangeValueNone = "none"
+
+// ChangeFilter matches the updates of an issue field by the values it changed
+// from and to, like a status going from "In Review" to "Done", or anything
+// leaving "Blocked". Field is the name or ID of the field in the changelog,
+// and the values are matched against the displayed values and the IDs, case
+// insensitively. An empty From or To matches any value.
+type ChangeFilter struct {
+	Field string    `json:"field"`
+	From  StringSet `json:"from,omitempty"`
+	To    StringSet `json:"to,omitempty"`
+}
+
+func validateChangeFilter(filter ChangeFilter) error {
+	if filter.Field == "" {
+		return errors.New("please provide the field of the change filter")
+	}
+	if filter.From.Len() == 0 && filter.To.Len() == 0 {
+		return errors.Errorf("please provide the values the %s changes from or to", filter.Field)
+	}
+	return nil
+}
+
+// matchesChangeFilters checks that each filter matches a change of the
+// webhook. Webhooks without a changelog, like created issues, match no filter.
+func matchesChangeFilters(wh *webhook, filters []ChangeFilter) bool {
+	for _, filter := range filters {
+		found := false
+		for _, change := range wh.changes {
+			if filter.matches(change) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (filter ChangeFilter) matches(change webhookField) bool {
+	if !strings.EqualFold(filter.Field, change.name) && !strings.EqualFold(filter.Field, change.id) {
+		return false
+	}
+	return matchesChangeValue(filter.From, change.from, change.fromID) &&
+		matchesChangeValue(filter.To, change.to, change.toID)
+}
+
+func matchesChangeValue(values StringSet, value, id string) bool {
+	if values.Len() == 0 {
+		return true
+	}
+	for _, v := range values.Elems() {
+		switch {
+		case value == "" && id == "" && strings.EqualFold(v, ChangeValueNone):
+			return true
+		case value != "" && strings.EqualFold(v, value):
+			return true
+		case id != "" && v == id:
+			return true
+		}
+	}
+	return false
+}
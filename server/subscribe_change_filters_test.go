@@ -0,0 +1,67 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchesChangeFilters(t *testing.T) {
+	data, err := getJiraTestData("webhook-issue-updated-started-working.json")
+	require.NoError(t, err)
+	parsed, err := ParseWebhook(data)
+	require.NoError(t, err)
+	wh := parsed.(*webhook)
+
+	for name, tc := range map[string]struct {
+		filters  []ChangeFilter
+		expected bool
+	}{
+		"no filters": {
+			expected: true,
+		},
+		"from and to status names": {
+			filters:  []ChangeFilter{{Field: "status", From: NewStringSet("to do"), To: NewStringSet("In Progress")}},
+			expected: true,
+		},
+		"to status ID": {
+			filters:  []ChangeFilter{{Field: "Status", To: NewStringSet("3")}},
+			expected: true,
+		},
+		"leaving another status": {
+			filters: []ChangeFilter{{Field: "status", From: NewStringSet("Blocked")}},
+		},
+		"to another status": {
+			filters: []ChangeFilter{{Field: "status", From: NewStringSet("To Do"), To: NewStringSet("Done")}},
+		},
+		"another field": {
+			filters: []ChangeFilter{{Field: "priority", To: NewStringSet("High")}},
+		},
+		"all filters must match": {
+			filters: []ChangeFilter{
+				{Field: "status", To: NewStringSet("In Progress")},
+				{Field: "assignee", To: NewStringSet(ChangeValueNone)},
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, matchesChangeFilters(wh, tc.filters))
+		})
+	}
+
+	assert.False(t, matchesChangeFilters(&webhook{}, []ChangeFilter{{Field: "status", To: NewStringSet("Done")}}))
+
+	cleared := &webhook{changes: []webhookField{{name: "assignee", id: "assignee", from: "John", fromID: "1"}}}
+	assert.True(t, matchesChangeFilters(cleared, []ChangeFilter{{Field: "assignee", To: NewStringSet(ChangeValueNone)}}))
+	assert.True(t, matchesChangeFilters(cleared, []ChangeFilter{{Field: "assignee", From: NewStringSet("1")}}))
+}
+
+func TestValidateChangeFilter(t *testing.T) {
+	assert.NoError(t, validateChangeFilter(ChangeFilter{Field: "status", To: NewStringSet("Done")}))
+	assert.Error(t, validateChangeFilter(ChangeFilter{To: NewStringSet("Done")}))
+	assert.Error(t, validateChangeFilter(ChangeFilter{Field: "status"}))
+}
@@ -0,0 +1,301 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-plugin-jira/server/utils/types"
+)
+
+// Operators of typed field filters, in addition to the set operators.
+const (
+	FilterGreaterThan = "greater_than"
+	FilterLessThan    = "less_than"
+	FilterWithinDays  = "within_days"
+)
+
+// Value types of field filters. The values of untyped filters are the IDs of
+// the Jira field values.
+const (
+	// FilterValueNumber compares a number field, like story points, to a
+	// single number.
+	FilterValueNumber = "number"
+	// FilterValueDate compares a date field, like the due date, to a number of
+	// days relative to today. FilterValueDateTime does the same for date and
+	// time fields, like the creation date, in the server's time zone.
+	FilterValueDate     = "date"
+	FilterValueDateTime = "datetime"
+	// FilterValueMattermostUser and FilterValueMattermostGroup match the
+	// Mattermost users connected to the Jira users of a user field.
+	FilterValueMattermostUser  = "mattermost_user"
+	FilterValueMattermostGroup = "mattermost_group"
+)
+
+// jiraDateTimeFields are the system fields with the datetime schema type.
+// Filters on them saved before FilterValueDateTime existed have the date value
+// type.
+var jiraDateTimeFields = NewStringSet("created", "updated", "resolutiondate", "lastviewed")
+
+// groupsOfUserCacheTTL is how long the Mattermost groups of a user are kept
+// for group filters.
+const groupsOfUserCacheTTL = 5 * time.Minute
+
+var jiraDateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02T15:04:05.000-0700",
+	time.RFC3339,
+}
+
+func validateTypedFieldFilter(field FieldFilter) error {
+	switch field.ValueType {
+	case FilterValueNumber, FilterValueDate, FilterValueDateTime:
+		switch field.Inclusion {
+		case FilterEmpty:
+			return nil
+		case FilterGreaterThan, FilterLessThan:
+		case FilterWithinDays:
+			if field.ValueType == FilterValueNumber {
+				return errors.Errorf("%q only applies to date fields", field.Inclusion)
+			}
+		default:
+			return errors.Errorf("%q is not a valid comparison for %s fields", field.Inclusion, field.ValueType)
+		}
+		if field.Values.Len() != 1 {
+			return errors.Errorf("please provide a single value to compare field %q to", field.Key)
+		}
+		if _, err := strconv.ParseFloat(field.Values.Elems()[0], 64); err != nil {
+			return errors.Errorf("%q is not a number", field.Values.Elems()[0])
+		}
+	case FilterValueMattermostUser, FilterValueMattermostGroup:
+		switch field.Inclusion {
+		case FilterIncludeAny, FilterExcludeAny, FilterEmpty, FilterIncludeOrEmpty:
+		default:
+			return errors.Errorf("%q is not a valid inclusion for %s filters", field.Inclusion, field.ValueType)
+		}
+	case "":
+	default:
+		return errors.Errorf("unknown filter value type %q", field.ValueType)
+	}
+
+	return nil
+}
+
+// matchesTypedFieldFilter checks a filter with a value type, see
+// FieldFilter.ValueType.
+func (p *Plugin) matchesTypedFieldFilter(instanceID types.ID, issue *jira.Issue, field FieldFilter, now time.Time) bool {
+	switch field.ValueType {
+	case FilterValueNumber:
+		value, ok := getIssueNumberFieldValue(issue, field.Key)
+		if field.Inclusion == FilterEmpty || !ok {
+			return field.Inclusion == FilterEmpty && !ok
+		}
+		limit, err := strconv.ParseFloat(field.Values.Elems()[0], 64)
+		if err != nil {
+			return false
+		}
+		switch field.Inclusion {
+		case FilterGreaterThan:
+			return value > limit
+		case FilterLessThan:
+			return value < limit
+		}
+	case FilterValueDate, FilterValueDateTime:
+		value, ok := getIssueDateFieldValue(issue, field.Key)
+		if field.Inclusion == FilterEmpty || !ok {
+			return field.Inclusion == FilterEmpty && !ok
+		}
+		days, err := strconv.ParseFloat(field.Values.Elems()[0], 64)
+		if err != nil {
+			return false
+		}
+		dateOnly := field.ValueType == FilterValueDate && !jiraDateTimeFields.ContainsAny(strings.ToLower(field.Key))
+		return matchesDateFilter(value, dateOnly, field.Inclusion, int(days), now)
+	case FilterValueMattermostUser, FilterValueMattermostGroup:
+		value := p.getIssueFieldMattermostUsers(instanceID, issue, field.Key)
+		if field.ValueType == FilterValueMattermostGroup {
+			value = p.getMattermostGroupsOfUsers(value)
+		}
+		return isValidFieldInclusion(field, value, field.Inclusion)
+	}
+
+	return false
+}
+
+// matchesDateFilter compares whole days: "within 3 days" is from today to the
+// end of the third day from now, "less than 0 days" is overdue. The values of
+// date-only fields, like the due date, are days rather than instants.
+func matchesDateFilter(value time.Time, dateOnly bool, inclusion string, days int, now time.Time) bool {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	limit := today.AddDate(0, 0, days)
+	if dateOnly {
+		// Dates are parsed as UTC midnight, they are the same day everywhere
+		value = value.UTC()
+		value = time.Date(value.Year(), value.Month(), value.Day(), 0, 0, 0, 0, now.Location())
+	} else {
+		value = value.In(now.Location())
+	}
+
+	switch inclusion {
+	case FilterWithinDays:
+		if days < 0 {
+			return !value.Before(limit) && value.Before(today.AddDate(0, 0, 1))
+		}
+		return !value.Before(today) && value.Before(limit.AddDate(0, 0, 1))
+	case FilterLessThan:
+		return value.Before(limit)
+	case FilterGreaterThan:
+		return !value.Before(limit.AddDate(0, 0, 1))
+	}
+
+	return false
+}
+
+func getIssueNumberFieldValue(issue *jira.Issue, key string) (float64, bool) {
+	if issue == nil || issue.Fields == nil {
+		return 0, false
+	}
+
+	m, exists := issue.Fields.Unknowns.Value(key)
+	if !exists || m == nil {
+		return 0, false
+	}
+
+	switch value := m.(type) {
+	case float64:
+		return value, true
+	case int:
+		return float64(value), true
+	case string:
+		f, err := strconv.ParseFloat(value, 64)
+		return f, err == nil
+	}
+
+	return 0, false
+}
+
+func getIssueDateFieldValue(issue *jira.Issue, key string) (time.Time, bool) {
+	if issue == nil || issue.Fields == nil {
+		return time.Time{}, false
+	}
+
+	var value time.Time
+	switch strings.ToLower(key) {
+	case "duedate":
+		value = time.Time(issue.Fields.Duedate)
+	case "created":
+		value = time.Time(issue.Fields.Created)
+	case "updated":
+		value = time.Time(issue.Fields.Updated)
+	case "resolutiondate":
+		value = time.Time(issue.Fields.Resolutiondate)
+	default:
+		m, exists := issue.Fields.Unknowns.Value(key)
+		if !exists || m == nil {
+			return time.Time{}, false
+		}
+		s, ok := m.(string)
+		if !ok {
+			return time.Time{}, false
+		}
+		for _, layout := range jiraDateLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				value = t
+				break
+			}
+		}
+	}
+
+	return value, !value.IsZero()
+}
+
+// getIssueFieldMattermostUsers returns the IDs of the Mattermost users
+// connected to the Jira users of a user field.
+func (p *Plugin) getIssueFieldMattermostUsers(instanceID types.ID, issue *jira.Issue, key string) StringSet {
+	result := NewStringSet()
+	if issue == nil || issue.Fields == nil {
+		return result
+	}
+
+	var jiraUsers []map[string]string
+	addUser := func(user *jira.User) {
+		if user != nil {
+			jiraUsers = append(jiraUsers, map[string]string{jiraUserAccountID: user.AccountID, jiraUserName: user.Name})
+		}
+	}
+	addUnknown := func(v interface{}) {
+		if obj, ok := v.(map[string]interface{}); ok {
+			accountID, _ := obj["accountId"].(string)
+			name, _ := obj["name"].(string)
+			jiraUsers = append(jiraUsers, map[string]string{jiraUserAccountID: accountID, jiraUserName: name})
+		}
+	}
+
+	switch strings.ToLower(key) {
+	case assigneeField:
+		addUser(issue.Fields.Assignee)
+	case reporterField:
+		addUser(issue.Fields.Reporter)
+	case "creator":
+		addUser(issue.Fields.Creator)
+	default:
+		m, exists := issue.Fields.Unknowns.Value(key)
+		if !exists || m == nil {
+			return result
+		}
+		if list, ok := m.([]interface{}); ok {
+			for _, v := range list {
+				addUnknown(v)
+			}
+		} else {
+			addUnknown(m)
+		}
+	}
+
+	for _, account := range jiraUsers {
+		accountKey := account[jiraUserName]
+		if account[jiraUserAccountID] != "" {
+			accountKey = account[jiraUserAccountID]
+		}
+		if accountKey == "" {
+			continue
+		}
+		mattermostUserID, err := p.userStore.LoadMattermostUserID(instanceID, accountKey)
+		if err != nil {
+			continue
+		}
+		result = result.Add(mattermostUserID.String())
+	}
+
+	return result
+}
+
+// getMattermostGroupsOfUsers returns the IDs of the groups of the users. The
+// groups of each user are cached, since every event is matched against the
+// group filters of the subscriptions.
+func (p *Plugin) getMattermostGroupsOfUsers(userIDs StringSet) StringSet {
+	result := NewStringSet()
+	for _, userID := range userIDs.Elems() {
+		groupIDs, ok := p.groupsOfUserCache.get(userID)
+		if !ok {
+			groups, err := p.client.Group.ListForUser(userID)
+			if err != nil {
+				p.client.Log.Debug("Failed to get the groups of the user", "UserID", userID, "error", err.Error())
+				continue
+			}
+			groupIDs = make([]string, 0, len(groups))
+			for _, group := range groups {
+				groupIDs = append(groupIDs, group.Id)
+			}
+			p.groupsOfUserCache.set(userID, groupIDs)
+		}
+		result = result.Add(groupIDs...)
+	}
+	return result
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/trivago/tgo/tcontainer"
+)
+
+func TestMatchesDateFilter(t *testing.T) {
+	now := time.Date(2024, 3, 10, 15, 0, 0, 0, time.UTC)
+	day := func(d int) time.Time {
+		return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	for name, tc := range map[string]struct {
+		value     time.Time
+		inclusion string
+		days      int
+		expected  bool
+	}{
+		"due today is within 3 days":          {value: day(10), inclusion: FilterWithinDays, days: 3, expected: true},
+		"due in 3 days is within 3 days":      {value: day(13), inclusion: FilterWithinDays, days: 3, expected: true},
+		"due in 4 days is not within 3 days":  {value: day(14), inclusion: FilterWithinDays, days: 3},
+		"overdue is not within 3 days":        {value: day(9), inclusion: FilterWithinDays, days: 3},
+		"updated yesterday is within -2 days": {value: day(9), inclusion: FilterWithinDays, days: -2, expected: true},
+		"yesterday is less than 0 days":       {value: day(9), inclusion: FilterLessThan, days: 0, expected: true},
+		"today is not less than 0 days":       {value: day(10), inclusion: FilterLessThan, days: 0},
+		"in 8 days is greater than 7 days":    {value: day(18), inclusion: FilterGreaterThan, days: 7, expected: true},
+		"in 7 days is not greater than 7":     {value: day(17), inclusion: FilterGreaterThan, days: 7},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, matchesDateFilter(tc.value, true, tc.inclusion, tc.days, now))
+		})
+	}
+
+	// Midnight UTC is still yesterday five hours west of UTC, unless the
+	// field is a date
+	est := time.FixedZone("EST", -5*60*60)
+	assert.True(t, matchesDateFilter(day(10), false, FilterLessThan, 0, now.In(est)))
+	assert.False(t, matchesDateFilter(day(10), true, FilterLessThan, 0, now.In(est)))
+}
+
+func TestMatchesTypedFieldFilter(t *testing.T) {
+	api := &plugintest.API{}
+	p := setupTestPlugin(api)
+	p.userStore = mockUserStore{}
+	api.On("GetGroupsForUser", mockUserIDWithNotifications).Return([]*model.Group{{Id: "group1"}}, (*model.AppError)(nil))
+
+	now := time.Now()
+	issue := &jira.Issue{
+		Fields: &jira.IssueFields{
+			Duedate:  jira.Date(now.AddDate(0, 0, 2)),
+			Assignee: &jira.User{AccountID: "jira-user"},
+			Unknowns: tcontainer.MarshalMap{
+				"customfield_10016": float64(5),
+			},
+		},
+	}
+
+	for name, tc := range map[string]struct {
+		filter   FieldFilter
+		expected bool
+	}{
+		"story points greater than 3": {
+			filter:   FieldFilter{Key: "customfield_10016", ValueType: FilterValueNumber, Inclusion: FilterGreaterThan, Values: NewStringSet("3")},
+			expected: true,
+		},
+		"story points less than 3": {
+			filter: FieldFilter{Key: "customfield_10016", ValueType: FilterValueNumber, Inclusion: FilterLessThan, Values: NewStringSet("3")},
+		},
+		"story points empty": {
+			filter: FieldFilter{Key: "customfield_10016", ValueType: FilterValueNumber, Inclusion: FilterEmpty},
+		},
+		"unset number is empty": {
+			filter:   FieldFilter{Key: "customfield_10020", ValueType: FilterValueNumber, Inclusion: FilterEmpty},
+			expected: true,
+		},
+		"due within 3 days": {
+			filter:   FieldFilter{Key: "duedate", ValueType: FilterValueDate, Inclusion: FilterWithinDays, Values: NewStringSet("3")},
+			expected: true,
+		},
+		"due within 1 day": {
+			filter: FieldFilter{Key: "duedate", ValueType: FilterValueDate, Inclusion: FilterWithinDays, Values: NewStringSet("1")},
+		},
+		"assignee is a Mattermost user": {
+			filter:   FieldFilter{Key: "assignee", ValueType: FilterValueMattermostUser, Inclusion: FilterIncludeAny, Values: NewStringSet(mockUserIDWithNotifications)},
+			expected: true,
+		},
+		"assignee is in a Mattermost group": {
+			filter:   FieldFilter{Key: "assignee", ValueType: FilterValueMattermostGroup, Inclusion: FilterIncludeAny, Values: NewStringSet("group1")},
+			expected: true,
+		},
+		"assignee is not in a Mattermost group": {
+			filter: FieldFilter{Key: "assignee", ValueType: FilterValueMattermostGroup, Inclusion: FilterIncludeAny, Values: NewStringSet("group2")},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, p.matchesTypedFieldFilter(testInstance1.InstanceID, issue, tc.filter, now))
+		})
+	}
+
+	t.Run("groups of users are cached", func(t *testing.T) {
+		api := &plugintest.API{}
+		p.SetAPI(api)
+		p.client = pluginapi.NewClient(api, p.Driver)
+		p.groupsOfUserCache = newExpiringCache[[]string](time.Minute)
+		api.On("GetGroupsForUser", mockUserIDWithNotifications).Return([]*model.Group{{Id: "group1"}}, (*model.AppError)(nil))
+
+		filter := FieldFilter{Key: "assignee", ValueType: FilterValueMattermostGroup, Inclusion: FilterIncludeAny, Values: NewStringSet("group1")}
+		assert.True(t, p.matchesTypedFieldFilter(testInstance1.InstanceID, issue, filter, now))
+		assert.True(t, p.matchesTypedFieldFilter(testInstance1.InstanceID, issue, filter, now))
+		api.AssertNumberOfCalls(t, "GetGroupsForUser", 1)
+	})
+}
+
+func TestValidateTypedFieldFilter(t *testing.T) {
+	assert.NoError(t, validateTypedFieldFilter(FieldFilter{Key: "duedate", ValueType: FilterValueDate, Inclusion: FilterWithinDays, Values: NewStringSet("3")}))
+	assert.NoError(t, validateTypedFieldFilter(FieldFilter{Key: "created", ValueType: FilterValueDateTime, Inclusion: FilterWithinDays, Values: NewStringSet("-3")}))
+	assert.NoError(t, validateTypedFieldFilter(FieldFilter{Key: "priority", Inclusion: FilterIncludeAny, Values: NewStringSet("1")}))
+	assert.Error(t, validateTypedFieldFilter(FieldFilter{Key: "customfield_10016", ValueType: FilterValueNumber, Inclusion: FilterWithinDays, Values: NewStringSet("3")}))
+	assert.Error(t, validateTypedFieldFilter(FieldFilter{Key: "customfield_10016", ValueType: FilterValueNumber, Inclusion: FilterGreaterThan, Values: NewStringSet("three")}))
+	assert.Error(t, validateTypedFieldFilter(FieldFilter{Key: "assignee", ValueType: FilterValueMattermostUser, Inclusion: FilterGreaterThan, Values: NewStringSet("user")}))
+	assert.Error(t, validateTypedFieldFilter(FieldFilter{Key: "assignee", ValueType: "unknown"}))
+}
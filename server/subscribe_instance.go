@@ -0,0 +1,306 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/mattermost/mattermost-plugin-jira/server/utils/types"
+)
+
+// InstanceSubscription routes the events about the Jira instance itself, like
+// projects and users being created, to a channel. Unlike channel
+// subscriptions, these are not scoped to a project.
+type InstanceSubscription struct {
+	ID         string    `json:"id"`
+	ChannelID  string    `json:"channel_id"`
+	Name       string    `json:"name"`
+	Events     StringSet `json:"events"`
+	InstanceID types.ID  `json:"instance_id"`
+}
+
+type InstanceSubscriptions struct {
+	ByID          map[string]InstanceSubscription `json:"by_id"`
+	IDByChannelID map[string]StringSet            `json:"id_by_channel_id"`
+}
+
+func NewInstanceSubscriptions() *InstanceSubscriptions {
+	return &InstanceSubscriptions{
+		ByID:          map[string]InstanceSubscription{},
+		IDByChannelID: map[string]StringSet{},
+	}
+}
+
+func (s *InstanceSubscriptions) add(sub *InstanceSubscription) {
+	s.ByID[sub.ID] = *sub
+	s.IDByChannelID[sub.ChannelID] = s.IDByChannelID[sub.ChannelID].Add(sub.ID)
+}
+
+func (s *InstanceSubscriptions) remove(sub *InstanceSubscription) {
+	delete(s.ByID, sub.ID)
+	s.IDByChannelID[sub.ChannelID] = s.IDByChannelID[sub.ChannelID].Subtract(sub.ID)
+}
+
+func (s *InstanceSubscriptions) forChannel(channelID string) []InstanceSubscription {
+	subs := []InstanceSubscription{}
+	for _, id := range s.IDByChannelID[channelID].Elems() {
+		subs = append(subs, s.ByID[id])
+	}
+	sort.Slice(subs, func(i, j int) bool {
+		return subs[i].Name < subs[j].Name
+	})
+	return subs
+}
+
+// instanceEventGroups are the shorthands accepted by
+// `/jira subscribe instance add` for all the events of a kind.
+var instanceEventGroups = map[string]StringSet{
+	"project":   NewStringSet(eventProjectCreated, eventProjectUpdated, eventProjectDeleted, eventProjectArchived, eventProjectRestored),
+	"issuetype": NewStringSet(eventIssueTypeCreated, eventIssueTypeUpdated, eventIssueTypeDeleted),
+	"workflow":  NewStringSet(eventWorkflowCreated, eventWorkflowUpdated, eventWorkflowDeleted),
+	"user":      NewStringSet(eventUserCreated, eventUserUpdated, eventUserDeleted),
+	"all":       instanceEvents,
+}
+
+// parseInstanceEvents turns `project`, `project_created` or
+// `event_project_created` into subscription events. Issue type events can be
+// spelled `issuetype_created` as well as `issue_type_created`.
+func parseInstanceEvents(args []string) (StringSet, error) {
+	events := NewStringSet()
+	for _, arg := range args {
+		for _, name := range splitCommaList(arg) {
+			name = strings.ToLower(name)
+			if group, ok := instanceEventGroups[name]; ok {
+				events = events.Union(group)
+				continue
+			}
+			// The group is spelled `issuetype`, the events `issue_type_*`
+			event := strings.Replace(name, "issuetype_", "issue_type_", 1)
+			if !strings.HasPrefix(event, "event_") {
+				event = "event_" + event
+			}
+			if !instanceEvents.ContainsAny(event) {
+				return nil, errors.Errorf("`%s` is not a known event", name)
+			}
+			events = events.Add(event)
+		}
+	}
+	if events.Len() == 0 {
+		return nil, errors.New("please provide at least one event")
+	}
+	return events, nil
+}
+
+func (p *Plugin) addInstanceSubscription(instanceID types.ID, sub *InstanceSubscription) error {
+	if sub.Name == "" {
+		return errors.New("please provide a name for the subscription")
+	}
+	if len(sub.Name) > MaxSubscriptionNameLength {
+		return errors.Errorf("please provide a name less than %d characters", MaxSubscriptionNameLength)
+	}
+
+	subKey := keyWithInstanceID(instanceID, JiraSubscriptionsKey)
+	return p.client.KV.SetAtomicWithRetries(subKey, func(initialBytes []byte) (interface{}, error) {
+		subs, err := SubscriptionsFromJSON(initialBytes, instanceID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, existing := range subs.Instance.forChannel(sub.ChannelID) {
+			if existing.Name == sub.Name {
+				return nil, errors.Errorf("a subscription named %q already exists in this channel", sub.Name)
+			}
+		}
+
+		sub.ID = model.NewId()
+		sub.InstanceID = instanceID
+		subs.Instance.add(sub)
+
+		modifiedBytes, marshalErr := json.Marshal(&subs)
+		if marshalErr != nil {
+			return nil, marshalErr
+		}
+
+		return modifiedBytes, nil
+	})
+}
+
+// removeInstanceSubscription removes the subscription of the channel with the
+// given name or ID.
+func (p *Plugin) removeInstanceSubscription(instanceID types.ID, channelID, nameOrID string) error {
+	subKey := keyWithInstanceID(instanceID, JiraSubscriptionsKey)
+	return p.client.KV.SetAtomicWithRetries(subKey, func(initialBytes []byte) (interface{}, error) {
+		subs, err := SubscriptionsFromJSON(initialBytes, instanceID)
+		if err != nil {
+			return nil, err
+		}
+
+		found := false
+		for _, sub := range subs.Instance.forChannel(channelID) {
+			if sub.ID == nameOrID || sub.Name == nameOrID {
+				subs.Instance.remove(&sub)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.New("could not find subscription")
+		}
+
+		modifiedBytes, marshalErr := json.Marshal(&subs)
+		if marshalErr != nil {
+			return nil, marshalErr
+		}
+
+		return modifiedBytes, nil
+	})
+}
+
+const subscribeInstanceUsage = "Please use `/jira subscribe instance add <name> <events>`, `/jira subscribe instance remove <name>` or `/jira subscribe instance list`. " +
+	"Events can be `project`, `issuetype`, `workflow`, `user`, `all`, or single events like `project_created`."
+
+func executeSubscribeInstanceList(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	instance, args, resp := p.loadSubscribeInstance(header, args)
+	if resp != nil {
+		return resp
+	}
+	if len(args) != 0 {
+		return p.responsef(header, "No arguments were expected.")
+	}
+
+	subs, err := p.getSubscriptions(instance.GetID())
+	if err != nil {
+		return p.responsef(header, "%v", err)
+	}
+
+	channelSubs := subs.Instance.forChannel(header.ChannelId)
+	if len(channelSubs) == 0 {
+		return p.responsef(header, "This channel has no instance subscriptions for %s. %s", instance.GetURL(), subscribeInstanceUsage)
+	}
+
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "Instance subscriptions of this channel for %s:\n", instance.GetURL())
+	for _, sub := range channelSubs {
+		fmt.Fprintf(sb, "* **%s**: %s\n", sub.Name, formatInstanceEvents(sub.Events))
+	}
+	return p.response(header, sb.String())
+}
+
+// listInstanceSubscriptions lists the instance subscriptions of all channels,
+// for `/jira subscribe list`. Channels on the given team are linked.
+func (p *Plugin) listInstanceSubscriptions(instanceID types.ID, teamID string) (string, error) {
+	subs, err := p.getSubscriptions(instanceID)
+	if err != nil {
+		return "", err
+	}
+	if len(subs.Instance.ByID) == 0 {
+		return "", nil
+	}
+
+	var rows []string
+	for channelID := range subs.Instance.IDByChannelID {
+		channelSubs := subs.Instance.forChannel(channelID)
+		if len(channelSubs) == 0 {
+			continue
+		}
+		channel, appErr := p.client.Channel.Get(channelID)
+		if appErr != nil {
+			p.client.Log.Debug("listInstanceSubscriptions: failed to get channel.", "channelID", channelID, "error", appErr)
+			continue
+		}
+
+		channelRow := fmt.Sprintf("* **%s** (%d):", channel.Name, len(channelSubs))
+		if channel.TeamId == teamID {
+			channelRow = fmt.Sprintf("* **~%s** (%d):", channel.Name, len(channelSubs))
+		}
+		for _, sub := range channelSubs {
+			channelRow += fmt.Sprintf("\n\t* %s - %s", sub.Name, formatInstanceEvents(sub.Events))
+		}
+		rows = append(rows, channelRow)
+	}
+	if len(rows) == 0 {
+		return "", nil
+	}
+	sort.Strings(rows)
+
+	return fmt.Sprintf("\n#### Instance subscriptions of %s\n%s", instanceID, strings.Join(rows, "\n")), nil
+}
+
+func formatInstanceEvents(events StringSet) string {
+	names := events.Elems()
+	for i, event := range names {
+		names[i] = "`" + strings.TrimPrefix(event, "event_") + "`"
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func executeSubscribeInstanceAdd(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	instance, args, resp := p.loadSubscribeInstance(header, args)
+	if resp != nil {
+		return resp
+	}
+	if len(args) < 2 {
+		return p.response(header, subscribeInstanceUsage)
+	}
+
+	events, err := parseInstanceEvents(args[1:])
+	if err != nil {
+		return p.responsef(header, "%v. %s", err, subscribeInstanceUsage)
+	}
+
+	sub := &InstanceSubscription{
+		ChannelID: header.ChannelId,
+		Name:      args[0],
+		Events:    events,
+	}
+	if err = p.addInstanceSubscription(instance.GetID(), sub); err != nil {
+		return p.responsef(header, "Failed to add the subscription. Error: %v.", err)
+	}
+
+	return p.responsef(header, "Added the instance subscription **%s** for %d events of %s to this channel.", sub.Name, events.Len(), instance.GetURL())
+}
+
+func executeSubscribeInstanceRemove(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	instance, args, resp := p.loadSubscribeInstance(header, args)
+	if resp != nil {
+		return resp
+	}
+	if len(args) != 1 {
+		return p.response(header, subscribeInstanceUsage)
+	}
+
+	if err := p.removeInstanceSubscription(instance.GetID(), header.ChannelId, args[0]); err != nil {
+		return p.responsef(header, "Failed to remove the subscription. Error: %v.", err)
+	}
+
+	return p.responsef(header, "Removed the instance subscription **%s** from this channel.", args[0])
+}
+
+// loadSubscribeInstance checks that instance subscriptions are managed by a
+// system administrator, and resolves the Jira instance.
+func (p *Plugin) loadSubscribeInstance(header *model.CommandArgs, args []string) (Instance, []string, *model.CommandResponse) {
+	authorized, err := authorizedSysAdmin(p, header.UserId)
+	if err != nil {
+		return nil, nil, p.responsef(header, "%v", err)
+	}
+	if !authorized {
+		return nil, nil, p.responsef(header, "`/jira subscribe instance` can only be run by a system administrator.")
+	}
+
+	_, instance, args, err := p.loadFlagUserInstance(header.UserId, args)
+	if err != nil {
+		return nil, nil, p.responsef(header, "Failed to identify the Jira instance. Error: %v.", err)
+	}
+
+	return instance, args, nil
+}
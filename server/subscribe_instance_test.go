@@ -0,0 +1,118 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseInstanceEvents(t *testing.T) {
+	events, err := parseInstanceEvents([]string{"user", "project_created,event_workflow_updated"})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{eventUserCreated, eventUserUpdated, eventUserDeleted, eventProjectCreated, eventWorkflowUpdated}, events.Elems())
+
+	events, err = parseInstanceEvents([]string{"all"})
+	require.NoError(t, err)
+	assert.Equal(t, instanceEvents.Len(), events.Len())
+
+	events, err = parseInstanceEvents([]string{"issuetype_created,issue_type_deleted"})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{eventIssueTypeCreated, eventIssueTypeDeleted}, events.Elems())
+
+	_, err = parseInstanceEvents([]string{"issue_created"})
+	assert.EqualError(t, err, "`issue_created` is not a known event")
+
+	_, err = parseInstanceEvents(nil)
+	assert.Error(t, err)
+}
+
+func TestInstanceSubscriptionsStore(t *testing.T) {
+	// Subscriptions stored before instance subscriptions existed
+	subs, err := SubscriptionsFromJSON([]byte(`{"PluginVersion": "4.0.0", "Channel": {"by_id": {}, "id_by_channel_id": {}, "id_by_event": {}}}`), testInstance1.InstanceID)
+	require.NoError(t, err)
+	require.NotNil(t, subs.Instance)
+
+	subs.Instance.add(&InstanceSubscription{ID: "b", ChannelID: "channel", Name: "Users", Events: NewStringSet(eventUserCreated)})
+	subs.Instance.add(&InstanceSubscription{ID: "a", ChannelID: "channel", Name: "Projects", Events: NewStringSet(eventProjectCreated)})
+
+	bb, err := json.Marshal(subs)
+	require.NoError(t, err)
+	subs, err = SubscriptionsFromJSON(bb, testInstance1.InstanceID)
+	require.NoError(t, err)
+
+	channelSubs := subs.Instance.forChannel("channel")
+	require.Len(t, channelSubs, 2)
+	assert.Equal(t, "Projects", channelSubs[0].Name)
+
+	subs.Instance.remove(&channelSubs[0])
+	assert.Len(t, subs.Instance.forChannel("channel"), 1)
+}
+
+func TestListInstanceSubscriptions(t *testing.T) {
+	api := &plugintest.API{}
+	p := setupTestPlugin(api)
+	kv := map[string][]byte{}
+	mockKVMap(api, kv)
+
+	msg, err := p.listInstanceSubscriptions(testInstance1.InstanceID, "team-id")
+	require.NoError(t, err)
+	assert.Empty(t, msg)
+
+	subs := NewSubscriptions()
+	subs.Instance.add(&InstanceSubscription{ID: "a", ChannelID: "channel-id", Name: "Projects", Events: NewStringSet(eventProjectCreated, eventProjectDeleted)})
+	bb, err := json.Marshal(subs)
+	require.NoError(t, err)
+	kv[keyWithInstanceID(testInstance1.InstanceID, JiraSubscriptionsKey)] = bb
+	api.On("GetChannel", "channel-id").Return(&model.Channel{Id: "channel-id", Name: "ops", TeamId: "team-id"}, nil)
+
+	msg, err = p.listInstanceSubscriptions(testInstance1.InstanceID, "team-id")
+	require.NoError(t, err)
+	assert.Equal(t, "\n#### Instance subscriptions of "+testInstance1.InstanceID.String()+"\n* **~ops** (1):\n\t* Projects - `project_created`, `project_deleted`", msg)
+}
+
+func TestParseInstanceWebhook(t *testing.T) {
+	for name, tc := range map[string]struct {
+		body             string
+		expectedEvent    string
+		expectedHeadline string
+	}{
+		"project created": {
+			body:             `{"webhookEvent": "project_created", "project": {"id": 10000, "key": "TEST", "name": "Test project", "projectLead": {"displayName": "Jane"}}}`,
+			expectedEvent:    eventProjectCreated,
+			expectedHeadline: "Project **Test project** (`TEST`) was created",
+		},
+		"project archived": {
+			body:             `{"webhookEvent": "jira:project_soft_deleted", "project": {"id": 10000, "key": "TEST", "name": "Test project"}}`,
+			expectedEvent:    eventProjectArchived,
+			expectedHeadline: "Project **Test project** (`TEST`) was archived",
+		},
+		"issue type updated": {
+			body:             `{"webhookEvent": "issuetype_updated", "issueType": {"id": "3", "name": "Task"}}`,
+			expectedEvent:    eventIssueTypeUpdated,
+			expectedHeadline: "Issue type **Task** was updated",
+		},
+		"user created": {
+			body:             `{"webhookEvent": "user_created", "user": {"accountId": "1", "displayName": "John"}}`,
+			expectedEvent:    eventUserCreated,
+			expectedHeadline: "Jira user **John** was created",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			wh, err := parseInstanceWebhook([]byte(tc.body))
+			require.NoError(t, err)
+			assert.True(t, wh.eventTypes.ContainsAny(tc.expectedEvent))
+			assert.Equal(t, tc.expectedHeadline, wh.headline)
+		})
+	}
+
+	_, err := parseInstanceWebhook([]byte(`{"webhookEvent": "project_created"}`))
+	assert.Error(t, err)
+	assert.False(t, isInstanceWebhookEvent("jira:issue_created"))
+}
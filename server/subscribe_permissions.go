@@ -0,0 +1,488 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/mattermost/mattermost-plugin-jira/server/utils/types"
+)
+
+const subscriptionPermissionsKey = "subscriptionpermissions"
+
+// Values of RolesAllowedToEditJiraSubscriptions
+const (
+	SubscriptionRoleUsers        = "users"
+	SubscriptionRoleChannelAdmin = "channel_admin"
+	SubscriptionRoleTeamAdmin    = "team_admin"
+	SubscriptionRoleSystemAdmin  = "system_admin"
+)
+
+var subscriptionRoles = NewStringSet(SubscriptionRoleUsers, SubscriptionRoleChannelAdmin, SubscriptionRoleTeamAdmin, SubscriptionRoleSystemAdmin)
+
+// SubscriptionPermissions refine who manages the channel subscriptions of an
+// instance, and what they subscribe to, by team and by channel. They are
+// managed with `/jira subscribe permissions` rather than in the plugin
+// settings.
+type SubscriptionPermissions struct {
+	Teams    map[string]TeamSubscriptionPermissions    `json:"teams,omitempty"`
+	Channels map[string]ChannelSubscriptionPermissions `json:"channels,omitempty"`
+}
+
+// TeamSubscriptionPermissions apply to the channels of a team.
+type TeamSubscriptionPermissions struct {
+	// Roles overrides RolesAllowedToEditJiraSubscriptions when set.
+	Roles string `json:"roles,omitempty"`
+
+	// Projects are the Jira project keys the channels can subscribe to, any
+	// project when empty.
+	Projects StringSet `json:"projects,omitempty"`
+}
+
+// ChannelSubscriptionPermissions delegate the subscriptions of a channel.
+type ChannelSubscriptionPermissions struct {
+	// Users are the Mattermost user IDs allowed to manage the subscriptions of
+	// the channel, whatever their role.
+	Users StringSet `json:"users,omitempty"`
+}
+
+func (p *Plugin) getSubscriptionPermissions(instanceID types.ID) (*SubscriptionPermissions, error) {
+	perms := &SubscriptionPermissions{}
+	if err := p.client.KV.Get(keyWithInstanceID(instanceID, subscriptionPermissionsKey), perms); err != nil {
+		return nil, errors.Wrap(err, "failed to load the subscription permissions")
+	}
+	return perms, nil
+}
+
+// pruneSubscriptionPermissions drops the teams and channels without any
+// permissions.
+func pruneSubscriptionPermissions(perms *SubscriptionPermissions) {
+	for teamID, tp := range perms.Teams {
+		if tp.Roles == "" && tp.Projects.Len() == 0 {
+			delete(perms.Teams, teamID)
+		}
+	}
+	for channelID, cp := range perms.Channels {
+		if cp.Users.Len() == 0 {
+			delete(perms.Channels, channelID)
+		}
+	}
+}
+
+// updateSubscriptionPermissions atomically updates the subscription
+// permissions of the instance.
+func (p *Plugin) updateSubscriptionPermissions(instanceID types.ID, update func(perms *SubscriptionPermissions) error) error {
+	var updateErr error
+	err := p.client.KV.SetAtomicWithRetries(keyWithInstanceID(instanceID, subscriptionPermissionsKey), func(initialBytes []byte) (interface{}, error) {
+		perms := &SubscriptionPermissions{}
+		if len(initialBytes) != 0 {
+			if err := json.Unmarshal(initialBytes, perms); err != nil {
+				return nil, errors.Wrap(err, "failed to load the subscription permissions")
+			}
+		}
+		if perms.Teams == nil {
+			perms.Teams = map[string]TeamSubscriptionPermissions{}
+		}
+		if perms.Channels == nil {
+			perms.Channels = map[string]ChannelSubscriptionPermissions{}
+		}
+		if updateErr = update(perms); updateErr != nil {
+			return nil, updateErr
+		}
+		pruneSubscriptionPermissions(perms)
+		return perms, nil
+	})
+	if updateErr != nil {
+		return updateErr
+	}
+	return errors.WithMessage(err, "failed to store the subscription permissions")
+}
+
+func validateSubscriptionPermissions(perms *SubscriptionPermissions) error {
+	for teamID, tp := range perms.Teams {
+		if tp.Roles != "" && !subscriptionRoles.ContainsAny(tp.Roles) {
+			return errors.Errorf("%q is not a valid role for team %s, use users, channel_admin, team_admin or system_admin", tp.Roles, teamID)
+		}
+	}
+	return nil
+}
+
+// channelTeamID returns the team of a channel, only when team permissions are
+// set so that the channel is not loaded otherwise.
+func (p *Plugin) channelTeamID(perms *SubscriptionPermissions, channelID string) (string, error) {
+	if len(perms.Teams) == 0 {
+		return "", nil
+	}
+	channel, err := p.client.Channel.Get(channelID)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to get channel to check permission")
+	}
+	return channel.TeamId, nil
+}
+
+// checkSubscriptionRole checks that the user has the role, as in
+// RolesAllowedToEditJiraSubscriptions, in the channel.
+func (p *Plugin) checkSubscriptionRole(role, userID, channelID string) error {
+	switch role {
+	case SubscriptionRoleTeamAdmin:
+		if !p.client.User.HasPermissionToChannel(userID, channelID, model.PermissionManageTeam) {
+			return errors.New("is not team admin")
+		}
+	case SubscriptionRoleChannelAdmin:
+		channel, err := p.client.Channel.Get(channelID)
+		if err != nil {
+			return errors.Wrap(err, "unable to get channel to check permission")
+		}
+		switch channel.Type {
+		case model.ChannelTypeOpen:
+			if !p.client.User.HasPermissionToChannel(userID, channelID, model.PermissionManagePublicChannelProperties) {
+				return errors.New("is not channel admin")
+			}
+		case model.ChannelTypePrivate:
+			if !p.client.User.HasPermissionToChannel(userID, channelID, model.PermissionManagePrivateChannelProperties) {
+				return errors.New("is not channel admin")
+			}
+		default:
+			return errors.New("can only subscribe in public and private channels")
+		}
+	case SubscriptionRoleUsers:
+	default:
+		if !p.client.User.HasPermissionTo(userID, model.PermissionManageSystem) {
+			return errors.New("is not system admin")
+		}
+	}
+	return nil
+}
+
+// checkSubscriptionRoleInChannel checks the role of the user in the channel
+// with the team override of the role, if any. delegated is true when the
+// channel subscriptions were delegated to the user instead, and the user is
+// still a member of the channel.
+func (p *Plugin) checkSubscriptionRoleInChannel(perms *SubscriptionPermissions, userID, channelID string) (delegated bool, err error) {
+	if perms.Channels[channelID].Users.ContainsAny(userID) {
+		// The delegation lapses when the user leaves the channel
+		if _, memberErr := p.client.Channel.GetMember(channelID, userID); memberErr == nil {
+			return true, nil
+		}
+	}
+
+	role := p.getConfig().RolesAllowedToEditJiraSubscriptions
+	teamID, err := p.channelTeamID(perms, channelID)
+	if err != nil {
+		return false, err
+	}
+	if tp, ok := perms.Teams[teamID]; ok && tp.Roles != "" {
+		role = tp.Roles
+	}
+
+	return false, p.checkSubscriptionRole(role, userID, channelID)
+}
+
+// validateSubscriptionProjects checks that the projects of a subscription are
+// allowed in the team of its channel.
+func (p *Plugin) validateSubscriptionProjects(instanceID types.ID, subscription *ChannelSubscription) error {
+	perms, err := p.getSubscriptionPermissions(instanceID)
+	if err != nil {
+		return err
+	}
+	teamID, err := p.channelTeamID(perms, subscription.ChannelID)
+	if err != nil {
+		return err
+	}
+	allowed := perms.Teams[teamID].Projects
+	if allowed.Len() == 0 {
+		return nil
+	}
+
+	for _, projectKey := range subscription.Filters.Projects.Elems() {
+		if !allowed.ContainsAny(strings.ToUpper(projectKey)) {
+			projects := allowed.Elems()
+			sort.Strings(projects)
+			return errors.Errorf("project %s can not be subscribed to in this team, only %s", projectKey, strings.Join(projects, ", "))
+		}
+	}
+	return nil
+}
+
+const subscribePermissionsUsage = "Please use `/jira subscribe permissions list`, " +
+	"`/jira subscribe permissions team [roles=<users|channel_admin|team_admin|system_admin|default>] [projects=<KEY1,KEY2|all>]` to set the permissions of this team, " +
+	"or `/jira subscribe permissions delegate|undelegate <@username>` to let a user manage the subscriptions of this channel."
+
+func executeSubscribePermissionsList(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	instance, args, resp := p.loadSubscribePermissionsInstance(header, args, true)
+	if resp != nil {
+		return resp
+	}
+	if len(args) != 0 {
+		return p.responsef(header, "No arguments were expected.")
+	}
+
+	perms, err := p.getSubscriptionPermissions(instance.GetID())
+	if err != nil {
+		return p.responsef(header, "%v", err)
+	}
+	if len(perms.Teams) == 0 && len(perms.Channels) == 0 {
+		return p.responsef(header, "Subscriptions to %s are managed by `%s` in all the teams and channels. %s",
+			instance.GetURL(), p.getConfig().RolesAllowedToEditJiraSubscriptions, subscribePermissionsUsage)
+	}
+
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "Subscription permissions for %s:\n", instance.GetURL())
+	for _, teamID := range sortedKeys(perms.Teams) {
+		tp := perms.Teams[teamID]
+		name := teamID
+		if team, err := p.client.Team.Get(teamID); err == nil {
+			name = team.DisplayName
+		}
+		roles, projects := tp.Roles, "any"
+		if roles == "" {
+			roles = "default"
+		}
+		if tp.Projects.Len() > 0 {
+			keys := tp.Projects.Elems()
+			sort.Strings(keys)
+			projects = strings.Join(keys, ", ")
+		}
+		fmt.Fprintf(sb, "* Team **%s**: managed by `%s`, projects: %s\n", name, roles, projects)
+	}
+	for _, channelID := range sortedKeys(perms.Channels) {
+		name := channelID
+		if channel, err := p.client.Channel.Get(channelID); err == nil {
+			name = "~" + channel.Name
+		}
+		var users []string
+		for _, userID := range perms.Channels[channelID].Users.Elems() {
+			if user, err := p.client.User.Get(userID); err == nil {
+				users = append(users, "@"+user.Username)
+			}
+		}
+		sort.Strings(users)
+		fmt.Fprintf(sb, "* Channel %s: delegated to %s\n", name, strings.Join(users, ", "))
+	}
+	return p.response(header, sb.String())
+}
+
+func executeSubscribePermissionsTeam(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	instance, args, resp := p.loadSubscribePermissionsInstance(header, args, true)
+	if resp != nil {
+		return resp
+	}
+	if len(args) == 0 {
+		return p.response(header, subscribePermissionsUsage)
+	}
+
+	var tp TeamSubscriptionPermissions
+	err := p.updateSubscriptionPermissions(instance.GetID(), func(perms *SubscriptionPermissions) error {
+		tp = perms.Teams[header.TeamId]
+		for _, arg := range args {
+			key, value, ok := strings.Cut(arg, "=")
+			if !ok || value == "" {
+				return errors.Errorf("`%s` is not valid", arg)
+			}
+			switch strings.ToLower(key) {
+			case "roles", "role":
+				tp.Roles = strings.ToLower(value)
+				if tp.Roles == "default" {
+					tp.Roles = ""
+				}
+			case "projects", "project":
+				tp.Projects = nil
+				if strings.ToLower(value) != "all" {
+					for _, key := range strings.Split(value, ",") {
+						if key = strings.ToUpper(strings.TrimSpace(key)); key != "" {
+							tp.Projects = tp.Projects.Add(key)
+						}
+					}
+				}
+			default:
+				return errors.Errorf("`%s` is not a known setting", key)
+			}
+		}
+		perms.Teams[header.TeamId] = tp
+		return validateSubscriptionPermissions(perms)
+	})
+	if err != nil {
+		return p.responsef(header, "Failed to update the permissions. Error: %v. %s", err, subscribePermissionsUsage)
+	}
+	p.audit(header.UserId, AuditSubscriptionPermissionsEdit, instance.GetID(), "team "+header.TeamId, strings.Join(args, " "))
+
+	roles, projects := tp.Roles, "any project"
+	if roles == "" {
+		roles = p.getConfig().RolesAllowedToEditJiraSubscriptions
+	}
+	if tp.Projects.Len() > 0 {
+		keys := tp.Projects.Elems()
+		sort.Strings(keys)
+		projects = strings.Join(keys, ", ")
+	}
+	return p.responsef(header, "Subscriptions of this team to %s are managed by `%s`, for %s.", instance.GetURL(), roles, projects)
+}
+
+func executeSubscribePermissionsDelegate(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	return p.delegateChannelSubscriptions(header, args, true)
+}
+
+func executeSubscribePermissionsUndelegate(p *Plugin, c *plugin.Context, header *model.CommandArgs, args ...string) *model.CommandResponse {
+	return p.delegateChannelSubscriptions(header, args, false)
+}
+
+// delegateChannelSubscriptions lets users manage the subscriptions of the
+// channel. Users who can manage them in the channel can delegate them, but
+// delegated users can not delegate them further.
+func (p *Plugin) delegateChannelSubscriptions(header *model.CommandArgs, args []string, delegate bool) *model.CommandResponse {
+	instance, args, resp := p.loadSubscribePermissionsInstance(header, args, false)
+	if resp != nil {
+		return resp
+	}
+	if len(args) != 1 {
+		return p.response(header, subscribePermissionsUsage)
+	}
+
+	perms, err := p.getSubscriptionPermissions(instance.GetID())
+	if err != nil {
+		return p.responsef(header, "%v", err)
+	}
+	delegated, err := p.checkSubscriptionRoleInChannel(perms, header.UserId, header.ChannelId)
+	if err != nil || delegated {
+		return p.responsef(header, "You don't have permission to delegate the subscriptions of this channel.")
+	}
+
+	username := strings.TrimPrefix(args[0], "@")
+	user, err := p.client.User.GetByUsername(username)
+	if err != nil {
+		return p.responsef(header, "User @%s not found.", username)
+	}
+	if delegate {
+		if _, err = p.client.Channel.GetMember(header.ChannelId, user.Id); err != nil {
+			return p.responsef(header, "@%s is not a member of this channel.", username)
+		}
+	}
+
+	err = p.updateSubscriptionPermissions(instance.GetID(), func(perms *SubscriptionPermissions) error {
+		cp := perms.Channels[header.ChannelId]
+		if delegate {
+			cp.Users = cp.Users.Add(user.Id)
+		} else {
+			if !cp.Users.ContainsAny(user.Id) {
+				return errors.Errorf("the subscriptions of this channel are not delegated to @%s", username)
+			}
+			cp.Users = cp.Users.Subtract(user.Id)
+		}
+		perms.Channels[header.ChannelId] = cp
+		return nil
+	})
+	if err != nil {
+		return p.responsef(header, "Failed to update the permissions. Error: %v.", err)
+	}
+
+	if delegate {
+		p.audit(header.UserId, AuditSubscriptionPermissionsEdit, instance.GetID(), "channel "+header.ChannelId, "delegated to @"+username)
+		return p.responsef(header, "@%s can now manage the subscriptions of this channel to %s.", username, instance.GetURL())
+	}
+	p.audit(header.UserId, AuditSubscriptionPermissionsEdit, instance.GetID(), "channel "+header.ChannelId, "undelegated from @"+username)
+	return p.responsef(header, "The subscriptions of this channel are no longer delegated to @%s.", username)
+}
+
+// loadSubscribePermissionsInstance resolves the Jira instance, checking that
+// the user is a system administrator if sysAdmin is set.
+func (p *Plugin) loadSubscribePermissionsInstance(header *model.CommandArgs, args []string, sysAdmin bool) (Instance, []string, *model.CommandResponse) {
+	if sysAdmin {
+		authorized, err := authorizedSysAdmin(p, header.UserId)
+		if err != nil {
+			return nil, nil, p.responsef(header, "%v", err)
+		}
+		if !authorized {
+			return nil, nil, p.responsef(header, "`/jira subscribe permissions` can only be run by a system administrator.")
+		}
+	}
+
+	_, instance, args, err := p.loadFlagUserInstance(header.UserId, args)
+	if err != nil {
+		return nil, nil, p.responsef(header, "Failed to identify the Jira instance. Error: %v.", err)
+	}
+
+	return instance, args, nil
+}
+
+func (p *Plugin) httpGetSubscriptionPermissions(w http.ResponseWriter, r *http.Request) (int, error) {
+	instanceID, status, err := p.checkSubscriptionPermissionsRequest(r)
+	if err != nil {
+		return respondErr(w, status, err)
+	}
+
+	perms, err := p.getSubscriptionPermissions(instanceID)
+	if err != nil {
+		return respondErr(w, http.StatusInternalServerError, err)
+	}
+
+	return respondJSON(w, perms)
+}
+
+// httpSetSubscriptionPermissions replaces all the subscription permissions of
+// the instance.
+func (p *Plugin) httpSetSubscriptionPermissions(w http.ResponseWriter, r *http.Request) (int, error) {
+	instanceID, status, err := p.checkSubscriptionPermissionsRequest(r)
+	if err != nil {
+		return respondErr(w, status, err)
+	}
+
+	perms := &SubscriptionPermissions{}
+	if err = json.NewDecoder(r.Body).Decode(perms); err != nil {
+		return respondErr(w, http.StatusBadRequest, errors.WithMessage(err, "failed to decode incoming request"))
+	}
+	for teamID, tp := range perms.Teams {
+		projects := NewStringSet()
+		for _, key := range tp.Projects.Elems() {
+			projects = projects.Add(strings.ToUpper(key))
+		}
+		tp.Projects = projects
+		perms.Teams[teamID] = tp
+	}
+	if err = validateSubscriptionPermissions(perms); err != nil {
+		return respondErr(w, http.StatusBadRequest, err)
+	}
+
+	err = p.updateSubscriptionPermissions(instanceID, func(stored *SubscriptionPermissions) error {
+		*stored = *perms
+		return nil
+	})
+	if err != nil {
+		return respondErr(w, http.StatusInternalServerError, err)
+	}
+	p.audit(r.Header.Get("Mattermost-User-Id"), AuditSubscriptionPermissionsEdit, instanceID, "all", "")
+
+	return respondJSON(w, perms)
+}
+
+func (p *Plugin) checkSubscriptionPermissionsRequest(r *http.Request) (types.ID, int, error) {
+	mattermostUserID := r.Header.Get("Mattermost-User-Id")
+	if !p.client.User.HasPermissionTo(mattermostUserID, model.PermissionManageSystem) {
+		return "", http.StatusForbidden, errors.New("only system administrators can manage the subscription permissions")
+	}
+
+	instance, err := p.instanceStore.LoadInstance(types.ID(r.FormValue(QueryParamInstanceID)))
+	if err != nil {
+		return "", http.StatusBadRequest, errors.WithMessage(err, "failed to load the Jira instance")
+	}
+
+	return instance.GetID(), http.StatusOK, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,193 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckSubscriptionRoleInChannel(t *testing.T) {
+	perms := &SubscriptionPermissions{
+		Teams: map[string]TeamSubscriptionPermissions{
+			"team-x": {Roles: SubscriptionRoleTeamAdmin},
+		},
+		Channels: map[string]ChannelSubscriptionPermissions{
+			"channel-y": {Users: NewStringSet("delegate", "former-delegate")},
+		},
+	}
+
+	for name, tc := range map[string]struct {
+		userID            string
+		channelID         string
+		teamID            string
+		teamAdmin         bool
+		expectedDelegated bool
+		expectedError     string
+	}{
+		"team admin in a team with an override": {
+			userID:    "user",
+			channelID: "channel-x",
+			teamID:    "team-x",
+			teamAdmin: true,
+		},
+		"user in a team with an override": {
+			userID:        "user",
+			channelID:     "channel-x",
+			teamID:        "team-x",
+			expectedError: "is not team admin",
+		},
+		"team admin in a team without override": {
+			userID:        "user",
+			channelID:     "channel-y",
+			teamID:        "team-y",
+			teamAdmin:     true,
+			expectedError: "is not system admin",
+		},
+		"delegated user": {
+			userID:            "delegate",
+			channelID:         "channel-y",
+			teamID:            "team-y",
+			expectedDelegated: true,
+		},
+		"delegated user who left the channel": {
+			userID:        "former-delegate",
+			channelID:     "channel-y",
+			teamID:        "team-y",
+			expectedError: "is not system admin",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			api := &plugintest.API{}
+			p := setupTestPlugin(api)
+			api.On("GetChannel", tc.channelID).Return(&model.Channel{Id: tc.channelID, TeamId: tc.teamID}, nil)
+			api.On("HasPermissionToChannel", tc.userID, tc.channelID, model.PermissionManageTeam).Return(tc.teamAdmin)
+			api.On("HasPermissionTo", tc.userID, model.PermissionManageSystem).Return(false)
+			api.On("GetChannelMember", "channel-y", "delegate").Return(&model.ChannelMember{}, nil)
+			api.On("GetChannelMember", "channel-y", "former-delegate").Return(nil, &model.AppError{Message: "not found"})
+
+			delegated, err := p.checkSubscriptionRoleInChannel(perms, tc.userID, tc.channelID)
+			assert.Equal(t, tc.expectedDelegated, delegated)
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestValidateSubscriptionProjects(t *testing.T) {
+	api := &plugintest.API{}
+	p := setupTestPlugin(api)
+
+	perms := SubscriptionPermissions{
+		Teams: map[string]TeamSubscriptionPermissions{
+			"team-x": {Projects: NewStringSet("A", "B")},
+		},
+	}
+	bb, err := json.Marshal(perms)
+	require.NoError(t, err)
+	api.On("KVGet", keyWithInstanceID(testInstance1.InstanceID, subscriptionPermissionsKey)).Return(bb, nil)
+	api.On("GetChannel", "channel-x").Return(&model.Channel{Id: "channel-x", TeamId: "team-x"}, nil)
+	api.On("GetChannel", "channel-y").Return(&model.Channel{Id: "channel-y", TeamId: "team-y"}, nil)
+
+	sub := func(channelID string, projects ...string) *ChannelSubscription {
+		return &ChannelSubscription{
+			ChannelID: channelID,
+			Filters:   SubscriptionFilters{Projects: NewStringSet(projects...)},
+		}
+	}
+	assert.NoError(t, p.validateSubscriptionProjects(testInstance1.InstanceID, sub("channel-x", "a")))
+	assert.EqualError(t, p.validateSubscriptionProjects(testInstance1.InstanceID, sub("channel-x", "C")),
+		"project C can not be subscribed to in this team, only A, B")
+	assert.NoError(t, p.validateSubscriptionProjects(testInstance1.InstanceID, sub("channel-y", "C")))
+}
+
+func TestDelegateChannelSubscriptions(t *testing.T) {
+	api := &plugintest.API{}
+	p := setupTestPlugin(api)
+	kv := map[string][]byte{}
+	mockKVMap(api, kv)
+	api.On("GetUser", "connected_user").Return(&model.User{Id: "connected_user", Roles: "system_admin system_user"}, nil)
+	api.On("HasPermissionTo", "connected_user", model.PermissionManageSystem).Return(true)
+	api.On("GetChannel", "channel").Return(&model.Channel{Id: "channel", TeamId: "team"}, nil)
+	api.On("GetChannelMember", "channel", "connected_user").Return(nil, &model.AppError{Message: "not found"})
+	api.On("GetUserByUsername", "member").Return(&model.User{Id: "member-id"}, nil)
+	api.On("GetUserByUsername", "outsider").Return(&model.User{Id: "outsider-id"}, nil)
+	api.On("GetChannelMember", "channel", "member-id").Return(&model.ChannelMember{}, nil)
+	api.On("GetChannelMember", "channel", "outsider-id").Return(nil, &model.AppError{Message: "not found"})
+
+	message := ""
+	api.On("SendEphemeralPost", "connected_user", mock.AnythingOfType("*model.Post")).Run(func(args mock.Arguments) {
+		message = args.Get(1).(*model.Post).Message
+	}).Return(&model.Post{})
+	header := &model.CommandArgs{UserId: "connected_user", TeamId: "team", ChannelId: "channel"}
+
+	p.delegateChannelSubscriptions(header, []string{"@outsider"}, true)
+	assert.Equal(t, "@outsider is not a member of this channel.", message)
+	assert.NotContains(t, kv, keyWithInstanceID(testInstance1.InstanceID, subscriptionPermissionsKey))
+
+	p.delegateChannelSubscriptions(header, []string{"@member"}, true)
+	assert.Contains(t, message, "@member can now manage the subscriptions of this channel")
+	perms, err := p.getSubscriptionPermissions(testInstance1.InstanceID)
+	require.NoError(t, err)
+	assert.Equal(t, NewStringSet("member-id"), perms.Channels["channel"].Users)
+}
+
+func TestExecuteSubscribePermissionsTeam(t *testing.T) {
+	for name, tc := range map[string]struct {
+		args            []string
+		expectedMessage string
+		expectedStored  *TeamSubscriptionPermissions
+	}{
+		"set roles and projects": {
+			args:            []string{"roles=team_admin", "projects=a,b"},
+			expectedMessage: "are managed by `team_admin`, for A, B.",
+			expectedStored:  &TeamSubscriptionPermissions{Roles: SubscriptionRoleTeamAdmin, Projects: NewStringSet("A", "B")},
+		},
+		"invalid role": {
+			args:            []string{"roles=owner"},
+			expectedMessage: "\"owner\" is not a valid role for team team-x",
+		},
+		"unknown setting": {
+			args:            []string{"channels=all"},
+			expectedMessage: "`channels` is not a known setting",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			api := &plugintest.API{}
+			p := setupTestPlugin(api)
+			api.On("GetUser", "connected_user").Return(&model.User{Id: "connected_user", Roles: "system_admin system_user"}, nil)
+			api.On("KVGet", keyWithInstanceID(testInstance1.InstanceID, subscriptionPermissionsKey)).Return(nil, nil)
+
+			var stored []byte
+			api.On("KVSetWithOptions", keyWithInstanceID(testInstance1.InstanceID, subscriptionPermissionsKey), mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				stored = args.Get(1).([]byte)
+			}).Return(true, nil).Maybe()
+
+			message := ""
+			api.On("SendEphemeralPost", "connected_user", mock.AnythingOfType("*model.Post")).Run(func(args mock.Arguments) {
+				message = args.Get(1).(*model.Post).Message
+			}).Return(&model.Post{})
+
+			executeSubscribePermissionsTeam(p, nil, &model.CommandArgs{UserId: "connected_user", TeamId: "team-x", ChannelId: "channel"}, tc.args...)
+			assert.Contains(t, message, tc.expectedMessage)
+
+			if tc.expectedStored == nil {
+				assert.Nil(t, stored)
+				return
+			}
+			var perms SubscriptionPermissions
+			require.NoError(t, json.Unmarshal(stored, &perms))
+			assert.Equal(t, *tc.expectedStored, perms.Teams["team-x"])
+		})
+	}
+}
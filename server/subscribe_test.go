@@ -195,6 +195,7 @@ func TestValidateSubscription(t *testing.T) {
 			p.client = pluginapi.NewClient(p.API, p.Driver)
 
 			api.On("KVGet", testSubKey).Return(nil, nil)
+			api.On("KVGet", keyWithInstanceID(testInstance1.InstanceID, subscriptionPermissionsKey)).Return(nil, nil)
 
 			p.updateConfig(func(conf *config) {
 				conf.SecurityLevelEmptyForJiraSubscriptions = !tc.disableSecurityConfig
@@ -0,0 +1,246 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+
+	"github.com/mattermost/mattermost-plugin-jira/server/utils/types"
+)
+
+const (
+	threadIssueKeyPrefix  = "thread_issue_"
+	threadBannerKeyPrefix = "thread_banner:"
+	threadBannerCooldown  = 24 * time.Hour
+
+	// Every reply looks up the issue of its thread
+	threadIssueCacheTTL = time.Minute
+
+	threadTimestampFormat = "2006-01-02 15:04 MST"
+)
+
+// ThreadIssue is the Jira issue that was created from a Mattermost thread.
+type ThreadIssue struct {
+	InstanceID types.ID `json:"instance_id"`
+	IssueKey   string   `json:"issue_key"`
+}
+
+func (p *Plugin) storeThreadIssue(rootID string, threadIssue *ThreadIssue) error {
+	_, err := p.client.KV.Set(hashkey(threadIssueKeyPrefix, rootID), threadIssue)
+	if err != nil {
+		return errors.WithMessage(err, "failed to store the issue of thread "+rootID)
+	}
+	p.threadIssueCache.set(rootID, threadIssue)
+	return nil
+}
+
+// loadThreadIssue returns the issue created from the thread, or nil if there is none.
+func (p *Plugin) loadThreadIssue(rootID string) (*ThreadIssue, error) {
+	if threadIssue, ok := p.threadIssueCache.get(rootID); ok {
+		return threadIssue, nil
+	}
+	var threadIssue *ThreadIssue
+	err := p.client.KV.Get(hashkey(threadIssueKeyPrefix, rootID), &threadIssue)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to load the issue of thread "+rootID)
+	}
+	if threadIssue == nil || threadIssue.IssueKey == "" {
+		threadIssue = nil
+	}
+	p.threadIssueCache.set(rootID, threadIssue)
+	return threadIssue, nil
+}
+
+// loadThreadPosts returns the root ID of the thread post belongs to, and its user posts
+// in the order they were made.
+func (p *Plugin) loadThreadPosts(post *model.Post) (string, []*model.Post, error) {
+	rootID := post.Id
+	if post.RootId != "" {
+		rootID = post.RootId
+	}
+
+	thread, err := p.client.Post.GetPostThread(rootID)
+	if err != nil {
+		return "", nil, errors.WithMessage(err, "failed to load thread "+rootID)
+	}
+
+	posts := make([]*model.Post, 0, len(thread.Posts))
+	for _, threadPost := range thread.Posts {
+		if threadPost.DeleteAt != 0 || threadPost.IsSystemMessage() {
+			continue
+		}
+		posts = append(posts, threadPost)
+	}
+	sort.Slice(posts, func(i, j int) bool {
+		return posts[i].CreateAt < posts[j].CreateAt
+	})
+	return rootID, posts, nil
+}
+
+// loadThreadUsernames returns the usernames of the authors of posts, keyed by user ID,
+// along with the usernames in the order the authors first posted.
+func (p *Plugin) loadThreadUsernames(posts []*model.Post) (map[string]string, []string, error) {
+	usernames := map[string]string{}
+	var participants []string
+	for _, threadPost := range posts {
+		if _, ok := usernames[threadPost.UserId]; ok {
+			continue
+		}
+		user, err := p.client.User.Get(threadPost.UserId)
+		if err != nil {
+			return nil, nil, errors.New("failed to load post.UserID " + threadPost.UserId + ": not found")
+		}
+		usernames[threadPost.UserId] = user.Username
+		participants = append(participants, user.Username)
+	}
+	return usernames, participants, nil
+}
+
+// formatThreadEntry renders a post of a thread as markdown, attributed to its author. The
+// @ is escaped so that quoting someone doesn't mention them in Jira.
+func formatThreadEntry(instance Instance, post *model.Post, username, currentTeam string) string {
+	createdAt := time.UnixMilli(post.CreateAt).UTC().Format(threadTimestampFormat)
+	return fmt.Sprintf("**\\@%s** [%s](%s):\n%s",
+		username, createdAt, getPermaLink(instance, post.Id, currentTeam), post.Message)
+}
+
+// GetThreadTranscript renders the thread post belongs to as markdown, to be used as the
+// description of an issue created from the thread.
+func (p *Plugin) GetThreadTranscript(instanceID, mattermostUserID types.ID, postID, currentTeam string) (string, int, error) {
+	instance, err := p.instanceStore.LoadInstance(instanceID)
+	if err != nil {
+		return "", http.StatusInternalServerError, err
+	}
+
+	post, err := p.client.Post.GetPost(postID)
+	if err != nil {
+		return "", http.StatusInternalServerError, errors.WithMessage(err, "failed to load post "+postID)
+	}
+	if post == nil {
+		return "", http.StatusNotFound, errors.New("failed to load post " + postID + ": not found")
+	}
+
+	if _, err = p.client.Channel.GetMember(post.ChannelId, mattermostUserID.String()); err != nil {
+		return "", http.StatusForbidden, errors.New("User does not have access to this post")
+	}
+
+	rootID, posts, err := p.loadThreadPosts(post)
+	if err != nil {
+		return "", http.StatusInternalServerError, err
+	}
+	usernames, participants, err := p.loadThreadUsernames(posts)
+	if err != nil {
+		return "", http.StatusNotFound, err
+	}
+
+	for i, participant := range participants {
+		participants[i] = "\\@" + participant
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Transcript of a [thread in Mattermost](%s).\n\n", getPermaLink(instance, rootID, currentTeam))
+	fmt.Fprintf(&sb, "**Participants:** %s\n", strings.Join(participants, ", "))
+	for _, threadPost := range posts {
+		sb.WriteString("\n---\n\n")
+		sb.WriteString(formatThreadEntry(instance, threadPost, usernames[threadPost.UserId], currentTeam))
+		sb.WriteString("\n")
+
+		var fileNames []string
+		for _, fileID := range threadPost.FileIds {
+			info, err := p.client.File.GetInfo(fileID)
+			if err != nil {
+				p.client.Log.Debug("Failed to load file info for thread transcript", "fileID", fileID, "error", err.Error())
+				continue
+			}
+			fileNames = append(fileNames, info.Name)
+		}
+		if len(fileNames) > 0 {
+			fmt.Fprintf(&sb, "_Files: %s_\n", strings.Join(fileNames, ", "))
+		}
+	}
+
+	return sb.String(), http.StatusOK, nil
+}
+
+func (p *Plugin) httpGetThreadTranscript(w http.ResponseWriter, r *http.Request) (int, error) {
+	mattermostUserID := r.Header.Get("Mattermost-User-Id")
+	instanceID := r.FormValue("instance_id")
+	postID := r.FormValue("post_id")
+	currentTeam := r.FormValue("current_team")
+	if postID == "" {
+		return respondErr(w, http.StatusBadRequest, errors.New("post_id is required"))
+	}
+
+	if instanceID == "" {
+		_, instance, err := p.LoadUserInstance(types.ID(mattermostUserID), "")
+		if err != nil {
+			return respondErr(w, http.StatusInternalServerError, err)
+		}
+		instanceID = instance.GetID().String()
+	}
+
+	transcript, statusCode, err := p.GetThreadTranscript(types.ID(instanceID), types.ID(mattermostUserID), postID, currentTeam)
+	if err != nil {
+		return respondErr(w, statusCode, errors.WithMessage(err, "failed to get thread transcript"))
+	}
+
+	return respondJSON(w, map[string]string{
+		"description": transcript,
+	})
+}
+
+// MessageHasBeenPosted lets people replying to a thread an issue was created from know about
+// the issue, at most once a day per thread.
+func (p *Plugin) MessageHasBeenPosted(c *plugin.Context, post *model.Post) {
+	if post.RootId == "" || post.IsSystemMessage() || post.UserId == p.getUserID() ||
+		post.GetProp("from_bot") == "true" || post.GetProp("from_webhook") == "true" {
+		return
+	}
+
+	threadIssue, err := p.loadThreadIssue(post.RootId)
+	if err != nil {
+		p.client.Log.Debug("Failed to load thread issue", "rootID", post.RootId, "error", err.Error())
+		return
+	}
+	if threadIssue == nil || p.isBotUserID(post.UserId, threadIssue.InstanceID) {
+		return
+	}
+
+	if !p.markThreadBannerShown(post.RootId, post.UserId) {
+		return
+	}
+
+	instance, err := p.instanceStore.LoadInstance(threadIssue.InstanceID)
+	if err != nil {
+		return
+	}
+
+	p.client.Post.SendEphemeralPost(post.UserId, &model.Post{
+		UserId:    p.getBotUserID(threadIssue.InstanceID),
+		ChannelId: post.ChannelId,
+		RootId:    post.RootId,
+		Message: fmt.Sprintf("This thread is tracked in Jira issue [%s](%s/browse/%s). Type `/jira view` in this thread to see its details.",
+			threadIssue.IssueKey, instance.GetJiraBaseURL(), threadIssue.IssueKey),
+	})
+}
+
+// markThreadBannerShown records that the user was told about the issue of the thread, and
+// returns false if they already were recently.
+func (p *Plugin) markThreadBannerShown(rootID, mattermostUserID string) bool {
+	ok, err := p.client.KV.Set(hashkey(threadBannerKeyPrefix, rootID+":"+mattermostUserID), []byte("1"),
+		pluginapi.SetAtomic(nil),
+		pluginapi.SetExpiry(threadBannerCooldown),
+	)
+	return err == nil && ok
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetThreadTranscript(t *testing.T) {
+	api := &plugintest.API{}
+	p := setupTestPlugin(api)
+
+	api.On("GetPost", "2").Return(&model.Post{Id: "2", RootId: "1", ChannelId: "channel"}, (*model.AppError)(nil))
+	api.On("GetChannelMember", "channel", "user").Return(&model.ChannelMember{}, (*model.AppError)(nil))
+	api.On("GetPostThread", "1").Return(&model.PostList{Posts: map[string]*model.Post{
+		"2": {Id: "2", UserId: "u2", Message: "on it", CreateAt: 1700000060000, FileIds: []string{"f1"}},
+		"1": {Id: "1", UserId: "u1", Message: "api is down", CreateAt: 1700000000000},
+		"3": {Id: "3", UserId: "u1", Type: model.PostTypeAddToChannel, CreateAt: 1700000120000},
+		"4": {Id: "4", UserId: "u1", Message: "deleted", CreateAt: 1700000180000, DeleteAt: 1700000190000},
+	}}, (*model.AppError)(nil))
+	api.On("GetUser", "u1").Return(&model.User{Username: "alice"}, (*model.AppError)(nil)).Once()
+	api.On("GetUser", "u2").Return(&model.User{Username: "bob"}, (*model.AppError)(nil)).Once()
+	api.On("GetFileInfo", "f1").Return(&model.FileInfo{Name: "api.log"}, (*model.AppError)(nil))
+
+	transcript, status, err := p.GetThreadTranscript(testInstance1.InstanceID, "user", "2", "team")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+
+	assert.Contains(t, transcript, "**Participants:** \\@alice, \\@bob\n")
+	assert.Contains(t, transcript, "**\\@alice** [2023-11-14 22:13 UTC]")
+	assert.Contains(t, transcript, "):\non it\n_Files: api.log_\n")
+	assert.NotContains(t, transcript, "deleted")
+	assert.Less(t, strings.Index(transcript, "api is down"), strings.Index(transcript, "on it"))
+}
+
+func TestMessageHasBeenPostedThreadBanner(t *testing.T) {
+	threadIssue, err := json.Marshal(&ThreadIssue{InstanceID: testInstance1.InstanceID, IssueKey: "TEST-1"})
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		post          *model.Post
+		alreadyShown  bool
+		expectedPosts int
+	}{
+		"reply in thread": {
+			post:          &model.Post{RootId: "root", UserId: "user", ChannelId: "channel"},
+			expectedPosts: 1,
+		},
+		"banner already shown": {
+			post:         &model.Post{RootId: "root", UserId: "user", ChannelId: "channel"},
+			alreadyShown: true,
+		},
+		"not a reply": {
+			post: &model.Post{UserId: "user", ChannelId: "channel"},
+		},
+		"bot reply": {
+			post: &model.Post{RootId: "root", UserId: "user", ChannelId: "channel", Props: model.StringInterface{"from_bot": "true"}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			api := &plugintest.API{}
+			p := setupTestPlugin(api)
+
+			api.On("KVGet", hashkey(threadIssueKeyPrefix, "root")).Return(threadIssue, (*model.AppError)(nil)).Maybe()
+			api.On("KVSetWithOptions", hashkey(threadBannerKeyPrefix, "root:user"), mock.Anything, mock.Anything).Return(!tc.alreadyShown, (*model.AppError)(nil)).Maybe()
+			api.On("SendEphemeralPost", "user", mock.MatchedBy(func(post *model.Post) bool {
+				return post.RootId == "root" && assert.Contains(t, post.Message, "[TEST-1]")
+			})).Return(&model.Post{}).Maybe()
+
+			p.MessageHasBeenPosted(nil, tc.post)
+
+			api.AssertNumberOfCalls(t, "SendEphemeralPost", tc.expectedPosts)
+		})
+	}
+}
+
+func TestLoadThreadIssueCached(t *testing.T) {
+	api := &plugintest.API{}
+	p := setupTestPlugin(api)
+	p.threadIssueCache = newExpiringCache[*ThreadIssue](threadIssueCacheTTL)
+	api.On("KVGet", hashkey(threadIssueKeyPrefix, "root")).Return(nil, (*model.AppError)(nil)).Once()
+
+	for i := 0; i < 2; i++ {
+		threadIssue, err := p.loadThreadIssue("root")
+		require.NoError(t, err)
+		assert.Nil(t, threadIssue)
+	}
+	api.AssertNumberOfCalls(t, "KVGet", 1)
+
+	api.On("KVSetWithOptions", hashkey(threadIssueKeyPrefix, "root"), mock.Anything, mock.Anything).Return(true, (*model.AppError)(nil)).Once()
+	require.NoError(t, p.storeThreadIssue("root", &ThreadIssue{InstanceID: testInstance1.InstanceID, IssueKey: "TEST-1"}))
+	threadIssue, err := p.loadThreadIssue("root")
+	require.NoError(t, err)
+	assert.Equal(t, "TEST-1", threadIssue.IssueKey)
+	api.AssertNumberOfCalls(t, "KVGet", 1)
+}
@@ -32,6 +32,7 @@ type Connection struct {
 	PluginVersion      string
 	Oauth1AccessToken  string        `json:",omitempty"`
 	Oauth1AccessSecret string        `json:",omitempty"`
+	EncryptedPAT       string        `json:",omitempty"`
 	OAuth2Token        *oauth2.Token `json:",omitempty"`
 	Settings           *ConnectionSettings
 	SavedFieldValues   *SavedFieldValues `json:"saved_field_values,omitempty"`
@@ -258,6 +259,7 @@ func (p *Plugin) connectUser(instance Instance, mattermostUserID types.ID, conne
 	)
 
 	p.TrackUserEvent("userConnected", mattermostUserID.String(), nil)
+	p.audit(mattermostUserID.String(), AuditUserConnect, instance.GetID(), connection.DisplayName, "")
 
 	return nil
 }
@@ -323,6 +325,7 @@ func (p *Plugin) disconnectUser(instance Instance, user *User) (*Connection, err
 		&model.WebsocketBroadcast{UserId: user.MattermostUserID.String()})
 
 	p.TrackUserEvent("userDisconnected", user.MattermostUserID.String(), nil)
+	p.audit(user.MattermostUserID.String(), AuditUserDisconnect, instance.GetID(), conn.DisplayName, "")
 
 	return conn, nil
 }
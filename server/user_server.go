@@ -5,7 +5,10 @@ package main
 
 import (
 	"crypto/x509"
+	"encoding/json"
 	"encoding/pem"
+	"fmt"
+	"io"
 	"net/http"
 	"strings"
 
@@ -146,6 +149,151 @@ func (p *Plugin) httpOAuth1aDisconnect(w http.ResponseWriter, r *http.Request, i
 		})
 }
 
+func (p *Plugin) httpPATConnectForm(w http.ResponseWriter, r *http.Request, instanceID types.ID) (int, error) {
+	instance, err := p.instanceStore.LoadInstance(instanceID)
+	if err != nil {
+		return respondErr(w, http.StatusInternalServerError, err)
+	}
+	si, ok := instance.(*serverInstance)
+	if !ok || !si.usesPAT() {
+		return respondErr(w, http.StatusBadRequest,
+			errors.Errorf("Jira instance %s does not use personal access tokens", instanceID))
+	}
+
+	return p.respondTemplate(w, r, ContentTypeHTML, struct {
+		JiraURL   string
+		SubmitURL string
+	}{
+		JiraURL:   si.GetURL(),
+		SubmitURL: p.CreateFullURLPath(instancePath(routePATConnect, instanceID)),
+	})
+}
+
+func (p *Plugin) httpPATConnect(w http.ResponseWriter, r *http.Request, instanceID types.ID) (status int, err error) {
+	// Prettify error output
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		errtext := err.Error()
+		if len(errtext) > 0 {
+			errtext = strings.ToUpper(errtext[:1]) + errtext[1:]
+		}
+		status, err = p.respondSpecialTemplate(w, "/other/message.html", status, ContentTypeHTML, struct {
+			Header  string
+			Message string
+		}{
+			Header:  "Failed to connect to Jira.",
+			Message: errtext,
+		})
+	}()
+
+	instance, err := p.instanceStore.LoadInstance(instanceID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	si, ok := instance.(*serverInstance)
+	if !ok || !si.usesPAT() {
+		return http.StatusBadRequest,
+			errors.Errorf("Jira instance %s does not use personal access tokens", instanceID)
+	}
+
+	request := struct {
+		Token string `json:"token"`
+	}{}
+	err = json.NewDecoder(io.LimitReader(r.Body, 4096)).Decode(&request)
+	if err != nil {
+		return http.StatusBadRequest, errors.WithMessage(err, "failed to decode request")
+	}
+	pat := strings.TrimSpace(request.Token)
+	if pat == "" {
+		return http.StatusBadRequest, errors.New("a personal access token is required")
+	}
+
+	mattermostUserID := r.Header.Get("Mattermost-User-Id")
+	mmuser, err := p.client.User.Get(mattermostUserID)
+	if err != nil {
+		return http.StatusInternalServerError,
+			errors.WithMessage(err, "failed to load user "+mattermostUserID)
+	}
+
+	encryptedPAT, err := p.encryptPAT(pat)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	connection := &Connection{
+		PluginVersion: manifest.Version,
+		EncryptedPAT:  encryptedPAT,
+	}
+
+	client, err := instance.GetClient(connection)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	juser, err := client.GetSelf()
+	if err != nil {
+		return http.StatusUnauthorized,
+			errors.WithMessage(err, "the personal access token was not accepted by Jira")
+	}
+	connection.User = *juser
+
+	// Set default settings the first time a user connects
+	connection.Settings = &ConnectionSettings{
+		Notifications: true,
+		RolesForDMNotification: map[string]bool{
+			mentionRole:  true,
+			assigneeRole: true,
+			reporterRole: true,
+			watchingRole: true,
+		},
+	}
+
+	err = p.connectUser(instance, types.ID(mattermostUserID), connection)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return p.respondSpecialTemplate(w, "/other/message.html", http.StatusOK, ContentTypeHTML, struct {
+		Header  string
+		Message string
+	}{
+		Header: "Mattermost user is now connected to Jira",
+		Message: fmt.Sprintf("Mattermost account %s is connected to Jira account %s (%s). It is now safe to close this browser window.",
+			mmuser.GetDisplayName(model.ShowNicknameFullName), juser.DisplayName, juser.Name),
+	})
+}
+
+// encryptPAT encrypts a personal access token to be stored in a user's connection.
+func (p *Plugin) encryptPAT(pat string) (string, error) {
+	secret, err := p.secretsStore.EnsureAuthTokenEncryptSecret()
+	if err != nil {
+		return "", err
+	}
+	encrypted, err := encrypt([]byte(pat), secret)
+	if err != nil {
+		return "", errors.WithMessage(err, "failed to encrypt personal access token")
+	}
+	return encode(encrypted), nil
+}
+
+func (p *Plugin) decryptPAT(encoded string) (string, error) {
+	secret, err := p.secretsStore.EnsureAuthTokenEncryptSecret()
+	if err != nil {
+		return "", err
+	}
+	decoded, err := decode(encoded)
+	if err != nil {
+		return "", errors.WithMessage(err, "failed to decode personal access token")
+	}
+	pat, err := decrypt(decoded, secret)
+	if err != nil {
+		return "", errors.WithMessage(err, "failed to decrypt personal access token")
+	}
+	return string(pat), nil
+}
+
 func (p *Plugin) publicKeyString() (string, error) {
 	rsaKey := p.getConfig().rsaKey
 	b, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
@@ -47,14 +47,14 @@ func (p *Plugin) CreateBotDMPost(instanceID, mattermostUserID types.ID, message,
 		return nil, nil
 	}
 
-	conf := p.getConfig()
-	channel, err := p.client.Channel.GetDirect(mattermostUserID.String(), conf.botUserID)
+	botUserID := p.getBotUserID(instanceID)
+	channel, err := p.client.Channel.GetDirect(mattermostUserID.String(), botUserID)
 	if err != nil {
 		return nil, err
 	}
 
 	post = &model.Post{
-		UserId:    conf.botUserID,
+		UserId:    botUserID,
 		ChannelId: channel.Id,
 		Message:   message,
 		Type:      postType,
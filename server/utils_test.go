@@ -459,17 +459,39 @@ func (m *mockJiraClient) AddComment(_ string, _ *jira.Comment) (*jira.Comment, e
 	return nil, nil
 }
 func (m *mockJiraClient) DoTransition(_, _ string) error { return nil }
+func (m *mockJiraClient) DoTransitionWithFields(_, _ string, _ map[string]interface{}) error {
+	return nil
+}
+func (m *mockJiraClient) GetTransitionFields(_, _ string) (map[string]*EditMetaField, error) {
+	return nil, nil
+}
 func (m *mockJiraClient) GetCreateMetaInfo(_ plugin.API, _ *jira.GetQueryOptions) (*jira.CreateMetaInfo, error) {
 	return nil, nil
 }
-func (m *mockJiraClient) GetTransitions(_ string) ([]jira.Transition, error) { return nil, nil }
-func (m *mockJiraClient) UpdateAssignee(_ string, _ *jira.User) error        { return nil }
+func (m *mockJiraClient) GetEditMeta(_ string) (map[string]*EditMetaField, error) {
+	return nil, nil
+}
+func (m *mockJiraClient) GetTransitions(_ string) ([]jira.Transition, error)   { return nil, nil }
+func (m *mockJiraClient) UpdateAssignee(_ string, _ *jira.User) error          { return nil }
+func (m *mockJiraClient) UpdateIssue(_ string, _ map[string]interface{}) error { return nil }
 func (m *mockJiraClient) UpdateComment(_ string, _ *jira.Comment) (*jira.Comment, error) {
 	return nil, nil
 }
+func (m *mockJiraClient) GetServiceDesks() ([]ServiceDesk, error) {
+	return nil, nil
+}
+func (m *mockJiraClient) GetRequestTypes(_ string) ([]RequestType, error) {
+	return nil, nil
+}
+func (m *mockJiraClient) CreateCustomerRequest(_ *jira.Request) (*jira.Request, error) {
+	return nil, nil
+}
 func (m *mockJiraClient) SearchIssues(_ string, _ *jira.SearchOptions) ([]jira.Issue, error) {
 	return nil, nil
 }
+func (m *mockJiraClient) SearchIssuesPage(_ string, _ *jira.SearchOptions, _ string) (*SearchPage, error) {
+	return &SearchPage{}, nil
+}
 func (m *mockJiraClient) SearchUsersAssignableToIssue(_, _ string, _ int) ([]jira.User, error) {
 	return nil, nil
 }
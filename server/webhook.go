@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -35,6 +36,10 @@ const (
 	worklogUpdated = "jira:worklog_updated"
 
 	ticketRootPostIDKey = "ticket_post_id_%s_channel_id_%s"
+
+	// postInstanceIDProp is the instance of the issue of a subscription post,
+	// to tell the ticketRootPostIDKey posts of the instances apart
+	postInstanceIDProp = "jira_instance_id"
 )
 
 type Webhook interface {
@@ -48,6 +53,11 @@ type webhookField struct {
 	id   string
 	from string
 	to   string
+
+	// fromID and toID are the raw values of the changelog, like status IDs
+	// or user account IDs.
+	fromID string
+	toID   string
 }
 
 type webhook struct {
@@ -58,6 +68,10 @@ type webhook struct {
 	fields        []*model.SlackAttachmentField
 	notifications []webhookUserNotification
 	fieldInfo     webhookField
+
+	// changes are all the items of the changelog, including the fields that
+	// have no event of their own. See ChangeFilter.
+	changes []webhookField
 }
 
 type webhookUserNotification struct {
@@ -86,6 +100,7 @@ func (wh webhook) PostToChannel(p *Plugin, instanceID types.ID, channelID, fromU
 		ChannelId: channelID,
 		UserId:    fromUserID,
 	}
+	post.AddProp(postInstanceIDProp, instanceID.String())
 
 	key := fmt.Sprintf(ticketRootPostIDKey, wh.Issue.ID, channelID)
 	var rootID string
@@ -95,7 +110,16 @@ func (wh webhook) PostToChannel(p *Plugin, instanceID types.ID, channelID, fromU
 	_, hasDeletedComment := wh.eventTypes[eventDeletedComment]
 	_, hasUpdatedComment := wh.eventTypes[eventUpdatedComment]
 
-	if hasCreatedComment || hasDeletedComment || hasUpdatedComment {
+	if pluginConfig.EnableIssueCards && wh.Issue.ID != "" {
+		// The card of the issue is updated, and the event goes in its thread
+		cardID, err := p.upsertIssueCard(instanceID, channelID, fromUserID, &wh.Issue)
+		if err != nil {
+			p.client.Log.Warn("Failed to update the issue card, posting the event on its own", "TicketID", wh.Issue.ID, "error", err.Error())
+		} else {
+			rootPostExists = true
+			post.RootId = cardID
+		}
+	} else if hasCreatedComment || hasDeletedComment || hasUpdatedComment {
 		err := p.client.KV.Get(key, &rootID)
 		if err != nil || rootID == "" {
 			p.client.Log.Info("Post ID not found in KV store, creating a new post for Jira subscription comment event", "TicketID", wh.Issue.ID)
@@ -129,6 +153,11 @@ func (wh webhook) PostToChannel(p *Plugin, instanceID types.ID, channelID, fromU
 		post.Message = wh.headline
 	}
 
+	// Mentions only notify in the message, not in the attachment
+	if mentions := p.getIssueMentions(instanceID, &wh.Issue); mentions != "" {
+		post.Message = strings.TrimSpace(post.Message + "\n" + mentions)
+	}
+
 	if err := p.client.Post.CreatePost(post); err != nil {
 		return nil, http.StatusInternalServerError, err
 	}
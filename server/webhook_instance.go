@@ -0,0 +1,174 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+
+	"github.com/mattermost/mattermost-plugin-jira/server/utils/types"
+)
+
+// instanceWebhookEvents maps the Jira webhook events about the instance
+// itself to subscription events. Jira Cloud prefixes some of them with
+// "jira:", which is stripped before the lookup.
+var instanceWebhookEvents = map[string]string{
+	"project_created":           eventProjectCreated,
+	"project_updated":           eventProjectUpdated,
+	"project_deleted":           eventProjectDeleted,
+	"project_soft_deleted":      eventProjectArchived,
+	"project_archived":          eventProjectArchived,
+	"project_restored_deleted":  eventProjectRestored,
+	"project_restored_archived": eventProjectRestored,
+	"issuetype_created":         eventIssueTypeCreated,
+	"issuetype_updated":         eventIssueTypeUpdated,
+	"issuetype_deleted":         eventIssueTypeDeleted,
+	"workflow_created":          eventWorkflowCreated,
+	"workflow_updated":          eventWorkflowUpdated,
+	"workflow_deleted":          eventWorkflowDeleted,
+	"user_created":              eventUserCreated,
+	"user_updated":              eventUserUpdated,
+	"user_deleted":              eventUserDeleted,
+}
+
+// InstanceWebhook is the payload of the project, issue type, workflow and user
+// webhook events. Only the fields used in posts are decoded, as the IDs are
+// numbers in some payloads and strings in others.
+type InstanceWebhook struct {
+	WebhookEvent string `json:"webhookEvent"`
+	Timestamp    int64  `json:"timestamp"`
+	Project      *struct {
+		Key         string `json:"key"`
+		Name        string `json:"name"`
+		ProjectLead *struct {
+			DisplayName string `json:"displayName"`
+		} `json:"projectLead"`
+	} `json:"project,omitempty"`
+	IssueType *struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Subtask     bool   `json:"subtask"`
+	} `json:"issueType,omitempty"`
+	Workflow *struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	} `json:"workflow,omitempty"`
+	User *struct {
+		Name        string `json:"name"`
+		DisplayName string `json:"displayName"`
+	} `json:"user,omitempty"`
+}
+
+type instanceWebhook struct {
+	*InstanceWebhook
+	projectEvent
+}
+
+func instanceWebhookEvent(webhookEvent string) string {
+	return instanceWebhookEvents[strings.TrimPrefix(webhookEvent, "jira:")]
+}
+
+func isInstanceWebhookEvent(webhookEvent string) bool {
+	return instanceWebhookEvent(webhookEvent) != ""
+}
+
+func parseInstanceWebhook(bb []byte) (*instanceWebhook, error) {
+	iwh := &InstanceWebhook{}
+	if err := json.Unmarshal(bb, iwh); err != nil {
+		return nil, err
+	}
+
+	event := instanceWebhookEvent(iwh.WebhookEvent)
+	if event == "" {
+		return nil, ErrWebhookIgnored
+	}
+
+	wh := &instanceWebhook{InstanceWebhook: iwh}
+	wh.eventTypes = NewStringSet(event)
+	action := event[strings.LastIndex(event, "_")+1:]
+
+	switch {
+	case strings.HasPrefix(event, "event_project_"):
+		if iwh.Project == nil {
+			return nil, errors.New("project webhook without a project")
+		}
+		wh.headline = fmt.Sprintf("Project **%s** (`%s`) was %s", iwh.Project.Name, iwh.Project.Key, action)
+		if iwh.Project.ProjectLead != nil && iwh.Project.ProjectLead.DisplayName != "" {
+			wh.fields = append(wh.fields, &model.SlackAttachmentField{Title: "Project lead", Value: iwh.Project.ProjectLead.DisplayName, Short: true})
+		}
+	case strings.HasPrefix(event, "event_issue_type_"):
+		if iwh.IssueType == nil {
+			return nil, errors.New("issue type webhook without an issue type")
+		}
+		kind := "Issue type"
+		if iwh.IssueType.Subtask {
+			kind = "Sub-task issue type"
+		}
+		wh.headline = fmt.Sprintf("%s **%s** was %s", kind, iwh.IssueType.Name, action)
+		if iwh.IssueType.Description != "" {
+			wh.fields = append(wh.fields, &model.SlackAttachmentField{Title: "Description", Value: iwh.IssueType.Description})
+		}
+	case strings.HasPrefix(event, "event_workflow_"):
+		name := ""
+		if iwh.Workflow != nil {
+			name = fmt.Sprintf(" **%s**", iwh.Workflow.Name)
+			if iwh.Workflow.Description != "" {
+				wh.fields = append(wh.fields, &model.SlackAttachmentField{Title: "Description", Value: iwh.Workflow.Description})
+			}
+		}
+		wh.headline = fmt.Sprintf("Workflow%s was %s", name, action)
+	case strings.HasPrefix(event, "event_user_"):
+		if iwh.User == nil {
+			return nil, errors.New("user webhook without a user")
+		}
+		name := iwh.User.DisplayName
+		if name == "" {
+			name = iwh.User.Name
+		}
+		wh.headline = fmt.Sprintf("Jira user **%s** was %s", name, action)
+	}
+
+	return wh, nil
+}
+
+func (p *Plugin) processInstanceWebhook(bb []byte, instanceID types.ID) error {
+	wh, err := parseInstanceWebhook(bb)
+	if err != nil {
+		return err
+	}
+
+	subs, err := p.getSubscriptions(instanceID)
+	if err != nil {
+		return err
+	}
+
+	botUserID := p.getBotUserID(instanceID)
+	posted := map[string]bool{}
+	for _, sub := range subs.Instance.ByID {
+		if posted[sub.ChannelID] || sub.Events.Intersection(wh.eventTypes).Len() == 0 {
+			continue
+		}
+		posted[sub.ChannelID] = true
+
+		channel, err := p.client.Channel.Get(sub.ChannelID)
+		if err != nil {
+			p.client.Log.Warn("Error occurred while getting the channel details while posting the webhook event", "ChannelID", sub.ChannelID, "Error", err.Error())
+			continue
+		}
+		if channel.DeleteAt > 0 {
+			continue
+		}
+
+		if _, err := wh.postToChannel(p, sub.ChannelID, botUserID, sub.Name); err != nil {
+			p.errorf("error posting %s to channel %s, err: %v", wh.WebhookEvent, sub.ChannelID, err)
+		}
+	}
+
+	return nil
+}
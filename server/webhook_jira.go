@@ -35,6 +35,10 @@ type JiraWebhook struct {
 	// JiraBaseURL is set during expandIssue to use the user-facing Jira URL
 	// instead of the API URL for OAuth instances
 	JiraBaseURL string `json:"-"`
+
+	// CommentPublic tells whether the comment is visible to the customers of a service
+	// desk. It is nil for comments of other issues, see parseCommentPublic.
+	CommentPublic *bool `json:"-"`
 }
 
 func (jwh *JiraWebhook) expandIssue(p *Plugin, instanceID types.ID) error {
@@ -133,7 +137,7 @@ func (jwh *JiraWebhook) mdJiraLink(title, suffix string) string {
 }
 
 func (jwh *JiraWebhook) mdIssueDescription() string {
-	return truncate(jwh.Issue.Fields.Description, 3000)
+	return truncate(preProcessText(jwh.Issue.Fields.Description), 3000)
 }
 
 func (jwh *JiraWebhook) mdIssueSummary() string {
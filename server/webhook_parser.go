@@ -7,8 +7,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"regexp"
-	"strconv"
 	"strings"
 	"time"
 
@@ -45,7 +43,14 @@ func ParseWebhook(bb []byte) (wh Webhook, err error) {
 	jwh := &JiraWebhook{}
 	err = json.Unmarshal(bb, &jwh)
 	if err != nil {
-		return nil, err
+		normalized, ok := normalizeADFWebhook(bb)
+		if !ok {
+			return nil, err
+		}
+		jwh = &JiraWebhook{}
+		if err = json.Unmarshal(normalized, &jwh); err != nil {
+			return nil, err
+		}
 	}
 	if jwh.WebhookEvent == "" {
 		return nil, errors.New("no webhook event")
@@ -53,6 +58,9 @@ func ParseWebhook(bb []byte) (wh Webhook, err error) {
 	if jwh.Issue.Fields == nil {
 		return nil, ErrWebhookIgnored
 	}
+	if jwh.Comment.ID != "" {
+		jwh.CommentPublic = parseCommentPublic(bb)
+	}
 
 	switch jwh.WebhookEvent {
 	case "jira:issue_created":
@@ -115,12 +123,21 @@ func parseWebhookUnspecified(jwh *JiraWebhook) (Webhook, error) {
 
 func parseWebhookChangeLog(jwh *JiraWebhook) Webhook {
 	var events []*webhook
+	var changes []webhookField
 	for _, item := range jwh.ChangeLog.Items {
 		field := item.Field
 		fieldID := item.FieldID
 		if fieldID == "" {
 			fieldID = field
 		}
+		changes = append(changes, webhookField{
+			name:   field,
+			id:     fieldID,
+			from:   item.FromString,
+			to:     item.ToString,
+			fromID: item.From,
+			toID:   item.To,
+		})
 
 		from := item.FromString
 		to := item.ToString
@@ -181,15 +198,18 @@ func parseWebhookChangeLog(jwh *JiraWebhook) Webhook {
 	case 0:
 		return nil
 	case 1:
+		events[0].changes = changes
 		return events[0]
 	default:
-		return mergeWebhookEvents(events)
+		merged := mergeWebhookEvents(events)
+		merged.changes = changes
+		return merged
 	}
 }
 
 func parseWebhookCreated(jwh *JiraWebhook) Webhook {
 	wh := newWebhook(jwh, eventCreated, "**created**")
-	wh.text = preProcessText(jwh.mdIssueDescription())
+	wh.text = jwh.mdIssueDescription()
 
 	if jwh.Issue.Fields == nil {
 		return wh
@@ -259,7 +279,7 @@ func appendCommentNotifications(wh *webhook, verb string) {
 	}
 	assigneeMentioned := false
 
-	for _, u := range parseJIRAUsernamesFromText(wh.Comment.Body) {
+	for _, u := range parseJIRAUsernamesFromText(processedComment) {
 		isAccountID := false
 		if strings.HasPrefix(u, "accountid:") {
 			u = u[10:]
@@ -325,153 +345,14 @@ func quoteIssueComment(comment string) string {
 	return "> " + strings.ReplaceAll(comment, "\n", "\n> ")
 }
 
-// preProcessText processes the given string to apply various formatting transformations.
-// The purpose of the function is to convert the formatting provided by JIRA into the corresponding formatting supported by Mattermost.
-// This includes converting asterisks to bold, hyphens to strikethrough, JIRA-style headings to Markdown headings,
-// JIRA code blocks to inline code, numbered lists to Markdown lists, colored text to plain text, and JIRA links to Markdown links.
+// preProcessText converts the rich text Jira sends for descriptions and comments into Mattermost markdown.
+// Jira Cloud's v3 API uses Atlassian Document Format, while Jira Server and the v2 API use wiki markup.
 // For more reference, please visit https://github.com/mattermost/mattermost-plugin-jira/issues/1096
-func preProcessText(jiraMarkdownString string) string {
-	asteriskRegex := regexp.MustCompile(`\*(\w+)\*`)
-	hyphenRegex := regexp.MustCompile(`\B-([\w\d\s]+)-\B`)
-	headingRegex := regexp.MustCompile(`(?m)^(h[1-6]\.)\s+`)
-	langSpecificCodeBlockRegex := regexp.MustCompile(`\{code:[^}]+\}([\s\S]*?)\{code\}`)
-	numberedListRegex := regexp.MustCompile(`^#\s+`)
-	colouredTextRegex := regexp.MustCompile(`\{color:[^}]+\}(.*?)\{color\}`)
-	linkRegex := regexp.MustCompile(`\[(.*?)\|([^|\]]+)(?:\|([^|\]]+))?\]`)
-	quoteRegex := regexp.MustCompile(`\{quote\}(.*?)\{quote\}`)
-	codeBlockRegex := regexp.MustCompile(`\{\{(.+?)\}\}`)
-	noFormatRegex := regexp.MustCompile(`\{noformat\}([\s\S]*?)\{noformat\}`)
-	doubleCurlyRegex := regexp.MustCompile(`\{\{(.*?)\}\}`)
-
-	// the below code converts lines starting with "#" into a numbered list. It increments the counter if consecutive lines are numbered,
-	// otherwise resets it to 1. The "#" is replaced with the corresponding number and period. Non-numbered lines are added unchanged.
-	var counter int
-	var lastLineWasNumberedList bool
-	var result []string
-	lines := strings.Split(jiraMarkdownString, "\n")
-	for _, line := range lines {
-		if numberedListRegex.MatchString(line) {
-			if !lastLineWasNumberedList {
-				counter = 1
-			} else {
-				counter++
-			}
-			line = strconv.Itoa(counter) + ". " + strings.TrimPrefix(line, "# ")
-			lastLineWasNumberedList = true
-		} else {
-			lastLineWasNumberedList = false
-		}
-		result = append(result, line)
-	}
-	processedString := strings.Join(result, "\n")
-
-	// the below code converts links in the format "[text|url]" or "[text|url|optional]" to Markdown links. If the text is empty,
-	// the URL is used for both the text and link. If the optional part is present, it's ignored. Unrecognized patterns remain unchanged.
-	processedString = linkRegex.ReplaceAllStringFunc(processedString, func(link string) string {
-		parts := linkRegex.FindStringSubmatch(link)
-		if len(parts) == 4 {
-			if parts[1] == "" {
-				return "[" + parts[2] + "](" + parts[2] + ")"
-			}
-			if parts[3] != "" {
-				return "[" + parts[1] + "](" + parts[2] + ")"
-			}
-			return "[" + parts[1] + "](" + parts[2] + ")"
-		}
-		return link
-	})
-
-	processedString = asteriskRegex.ReplaceAllStringFunc(processedString, func(word string) string {
-		return "**" + strings.Trim(word, "*") + "**"
-	})
-
-	processedString = hyphenRegex.ReplaceAllStringFunc(processedString, func(word string) string {
-		if strings.Contains(word, "accountid:") {
-			return word
-		}
-		return "~~" + strings.Trim(word, "-") + "~~"
-	})
-
-	processedString = headingRegex.ReplaceAllStringFunc(processedString, func(heading string) string {
-		level := heading[1]
-		hashes := strings.Repeat("#", int(level-'0'))
-		return hashes + " "
-	})
-
-	processedString = codeBlockRegex.ReplaceAllStringFunc(processedString, func(match string) string {
-		curlyContent := codeBlockRegex.FindStringSubmatch(match)[1]
-		return "`" + curlyContent + "`"
-	})
-
-	processedString = colouredTextRegex.ReplaceAllString(processedString, "$1")
-
-	processedString = quoteRegex.ReplaceAllStringFunc(processedString, func(quote string) string {
-		quotedText := quote[strings.Index(quote, "}")+1 : strings.LastIndex(quote, "{quote}")]
-		return "> " + quotedText
-	})
-
-	processedString = doubleCurlyRegex.ReplaceAllStringFunc(processedString, func(match string) string {
-		content := match[2 : len(match)-2]
-		return fmt.Sprintf("`%s`", content)
-	})
-
-	// handles single and multi line language specific code blocks
-	processedString = langSpecificCodeBlockRegex.ReplaceAllStringFunc(processedString, func(langSpecificBlock string) string {
-		startIndex := strings.Index(langSpecificBlock, "{code:")
-		endIndex := strings.LastIndex(langSpecificBlock, "{code}")
-		if startIndex == -1 || endIndex == -1 || startIndex == endIndex {
-			return langSpecificBlock
-		}
-
-		langEndIndex := strings.Index(langSpecificBlock[startIndex:], "}")
-		if langEndIndex == -1 {
-			return langSpecificBlock
-		}
-
-		contentStartIndex := startIndex + langEndIndex + 1
-		content := langSpecificBlock[contentStartIndex:endIndex]
-
-		lines := strings.Split(content, "\n")
-
-		if len(lines) == 1 {
-			return "`" + lines[0] + "`"
-		}
-
-		for i := range lines {
-			if len(lines[i]) > 0 {
-				lines[i] = "`" + lines[i] + "`"
-			}
-		}
-
-		return "\n" + strings.Join(lines, "\n") + "\n"
-	})
-
-	// handles single and multi line non-language specific code blocks
-	processedString = noFormatRegex.ReplaceAllStringFunc(processedString, func(noFormatBlock string) string {
-		startIndex := strings.Index(noFormatBlock, "{noformat}")
-		endIndex := strings.LastIndex(noFormatBlock, "{noformat}")
-		if startIndex == -1 || endIndex == -1 || startIndex == endIndex {
-			return noFormatBlock
-		}
-
-		content := noFormatBlock[startIndex+len("{noformat}") : endIndex]
-
-		lines := strings.Split(content, "\n")
-
-		if len(lines) == 1 {
-			return "`" + lines[0] + "`"
-		}
-
-		for i := range lines {
-			if len(lines[i]) > 0 {
-				lines[i] = "`" + lines[i] + "`"
-			}
-		}
-
-		return "\n" + strings.Join(lines, "\n") + "\n"
-	})
-
-	return processedString
+func preProcessText(jiraText string) string {
+	if doc, ok := parseADFDocument(jiraText); ok {
+		return adfToMarkdown(doc)
+	}
+	return wikiToMarkdown(jiraText)
 }
 
 func parseWebhookCommentDeleted(jwh *JiraWebhook) (Webhook, error) {
@@ -514,7 +395,7 @@ func parseWebhookAssigned(jwh *JiraWebhook, from, to string) *webhook {
 	if toFixed == "" {
 		toFixed = Nobody
 	}
-	wh.fieldInfo = webhookField{"assignee", "assignee", fromFixed, toFixed}
+	wh.fieldInfo = webhookField{name: "assignee", id: "assignee", from: fromFixed, to: toFixed}
 
 	appendNotificationForAssignee(wh)
 
@@ -543,19 +424,19 @@ func appendNotificationForAssignee(wh *webhook) {
 
 func parseWebhookReopened(jwh *JiraWebhook, from string) *webhook {
 	wh := newWebhook(jwh, eventUpdatedReopened, "**reopened**")
-	wh.fieldInfo = webhookField{"reopened", resolutionField, from, "Open"}
+	wh.fieldInfo = webhookField{name: "reopened", id: resolutionField, from: from, to: "Open"}
 	return wh
 }
 
 func parseWebhookResolved(jwh *JiraWebhook, to string) *webhook {
 	wh := newWebhook(jwh, eventUpdatedResolved, "**resolved**")
-	wh.fieldInfo = webhookField{"resolved", resolutionField, "Open", to}
+	wh.fieldInfo = webhookField{name: "resolved", id: resolutionField, from: "Open", to: to}
 	return wh
 }
 
 func parseWebhookUpdatedField(jwh *JiraWebhook, eventType string, field, fieldID, from, to string) *webhook {
 	wh := newWebhook(jwh, eventType, "**updated** %s from %q to %q on", field, from, to)
-	wh.fieldInfo = webhookField{field, fieldID, from, to}
+	wh.fieldInfo = webhookField{name: field, id: fieldID, from: from, to: to}
 	return wh
 }
 
@@ -563,20 +444,20 @@ func parseWebhookUpdatedDescription(jwh *JiraWebhook, from, to string) *webhook
 	wh := newWebhook(jwh, eventUpdatedDescription, "**edited** the description of")
 	fromFmttd := "\n**From:** " + truncate(from, 500)
 	toFmttd := "\n**To:** " + truncate(to, 500)
-	wh.fieldInfo = webhookField{descriptionField, descriptionField, fromFmttd, toFmttd}
-	wh.text = preProcessText(jwh.mdIssueDescription())
+	wh.fieldInfo = webhookField{name: descriptionField, id: descriptionField, from: fromFmttd, to: toFmttd}
+	wh.text = jwh.mdIssueDescription()
 	return wh
 }
 
 func parseWebhookUpdatedAttachments(jwh *JiraWebhook, from, to, fromWithDefault, toWithDefault string) *webhook {
 	wh := newWebhook(jwh, eventUpdatedAttachment, "%s", mdAddRemove(from, to, "**attached**", "**removed** attachments"))
-	wh.fieldInfo = webhookField{"attachments", "attachment", from, to}
+	wh.fieldInfo = webhookField{name: "attachments", id: "attachment", from: from, to: to}
 	return wh
 }
 
 func parseWebhookUpdatedLabels(jwh *JiraWebhook, from, to, fromWithDefault, toWithDefault string) *webhook {
 	wh := newWebhook(jwh, eventUpdatedLabels, "%s", mdAddRemove(from, to, "**added** labels", "**removed** labels"))
-	wh.fieldInfo = webhookField{"labels", "labels", fromWithDefault, toWithDefault}
+	wh.fieldInfo = webhookField{name: "labels", id: "labels", from: fromWithDefault, to: toWithDefault}
 	return wh
 }
 
@@ -587,7 +468,7 @@ func isStandaloneCommentEvent(jwh *JiraWebhook) bool {
 }
 
 // mergeWebhookEvents assumes len(events) > 1
-func mergeWebhookEvents(events []*webhook) Webhook {
+func mergeWebhookEvents(events []*webhook) *webhook {
 	merged := &webhook{
 		JiraWebhook: events[0].JiraWebhook,
 		headline:    events[0].mdUser() + " **updated** " + events[0].mdKeySummaryLink(),
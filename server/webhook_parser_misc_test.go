@@ -262,6 +262,38 @@ more text data in code block.{noformat}`,
 			input:          "This is an account ID: [~accountid:712020:46403440-d0cf-4f7f-993f-1035facb10a2], and this -should be struck-",
 			expectedOutput: "This is an account ID: [~accountid:712020:46403440-d0cf-4f7f-993f-1035facb10a2], and this ~~should be struck~~",
 		},
+		"Words with inner marks are left alone": {
+			input:          "snake_case_name on 2020-01-01 costs 2*3*4",
+			expectedOutput: "snake_case_name on 2020-01-01 costs 2*3*4",
+		},
+		"Italic and bold phrases": {
+			input:          "_really_ *bold phrase*",
+			expectedOutput: "_really_ **bold phrase**",
+		},
+		"Nested lists": {
+			input:          "# one\n## one.one\n# two\n* bullet\n** nested",
+			expectedOutput: "1. one\n   1. one.one\n2. two\n* bullet\n  * nested",
+		},
+		"Table": {
+			input:          "||Key||Value||\n|a|[link|http://example.com]|",
+			expectedOutput: "| Key | Value |\n| --- | --- |\n| a | [link](http://example.com) |",
+		},
+		"Emoticons": {
+			input:          "(y) done (/) C:Documents",
+			expectedOutput: ":thumbsup: done :white_check_mark: C:Documents",
+		},
+		"Multi-line quote": {
+			input:          "{quote}first\nsecond{quote}",
+			expectedOutput: "> first\n> second",
+		},
+		"Unterminated macro": {
+			input:          "{code:go}fmt.Println()",
+			expectedOutput: "{code:go}fmt.Println()",
+		},
+		"ADF document": {
+			input:          `{"type":"doc","version":1,"content":[{"type":"paragraph","content":[{"type":"text","text":"hi","marks":[{"type":"strong"}]}]}]}`,
+			expectedOutput: "**hi**",
+		},
 	}
 
 	for name, tc := range tests {
@@ -272,6 +304,39 @@ more text data in code block.{noformat}`,
 	}
 }
 
+func TestPreProcessTextGolden(t *testing.T) {
+	for name, tc := range map[string]struct {
+		input    string
+		expected string
+	}{
+		"wiki markup": {"testdata/wiki-markup.txt", "testdata/wiki-markup.md"},
+		"ADF":         {"testdata/adf-document.json", "testdata/adf-document.md"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			input, err := os.ReadFile(tc.input)
+			require.NoError(t, err)
+			expected, err := os.ReadFile(tc.expected)
+			require.NoError(t, err)
+
+			assert.Equal(t, strings.TrimSpace(string(expected)), strings.TrimSpace(preProcessText(string(input))))
+		})
+	}
+}
+
+func TestWebhookADFComment(t *testing.T) {
+	bb, err := os.ReadFile("testdata/webhook-cloud-comment-created-adf.json")
+	require.NoError(t, err)
+
+	wh, err := ParseWebhook(bb)
+	require.NoError(t, err)
+	w := wh.(*webhook)
+	require.NotNil(t, w)
+
+	assert.Equal(t, "> Looks good to me, [~accountid:5b10a2844c20165700ede21g]!\n> \n> * **merged**", w.text)
+	require.NotEmpty(t, w.notifications)
+	assert.Equal(t, "5b10a2844c20165700ede21g", w.notifications[0].jiraAccountID)
+}
+
 func TestNotificationDedupKey(t *testing.T) {
 	makeWebhook := func(issueKey string) *webhook {
 		return &webhook{
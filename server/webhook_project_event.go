@@ -0,0 +1,138 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+
+	"github.com/mattermost/mattermost-plugin-jira/server/utils/types"
+)
+
+// projectEvent is a subscription post for a webhook event that is not about an
+// issue, like sprint, board and version events. Issue type and field filters
+// don't apply to these, subscriptions match them on the event and projects.
+type projectEvent struct {
+	eventTypes StringSet
+	projects   StringSet
+	headline   string
+	fields     []*model.SlackAttachmentField
+
+	// digest is posted as a reply to the event post, when set.
+	digest string
+}
+
+// lookupEventTypes are the subscription events whose payloads don't name the
+// project, so it has to be looked up with getForWebhook.
+var lookupEventTypes = NewStringSet(
+	eventSprintStarted, eventSprintClosed, eventSprintUpdated,
+	eventBoardCreated, eventBoardUpdated, eventBoardDeleted,
+	eventVersionCreated, eventVersionUpdated, eventVersionReleased, eventVersionUnreleased, eventVersionDeleted,
+)
+
+// validateProjectEvents rejects sprint, board and version events for instances
+// whose projects can't be looked up, since such events would never match.
+func (p *Plugin) validateProjectEvents(instanceID types.ID, events StringSet) error {
+	if events.Intersection(lookupEventTypes).Len() == 0 {
+		return nil
+	}
+	instance, err := p.instanceStore.LoadInstance(instanceID)
+	if err != nil {
+		return err
+	}
+	if !p.canGetForWebhook(instance) {
+		return errors.New("sprint, board and version events need the admin API token to be configured in the plugin settings, to look up the project of the event")
+	}
+	return nil
+}
+
+func (e *projectEvent) matchesSubscriptionFilters(filters SubscriptionFilters) bool {
+	if filters.Events.Intersection(e.eventTypes).Len() == 0 {
+		return false
+	}
+
+	if filters.Projects.Len() != 0 && filters.Projects.Intersection(e.projects).Len() == 0 {
+		return false
+	}
+
+	return true
+}
+
+func (e *projectEvent) postToChannel(p *Plugin, channelID, fromUserID, subscriptionName string) (*model.Post, error) {
+	headline := e.headline
+	if p.getConfig().DisplaySubscriptionNameInNotifications && subscriptionName != "" {
+		headline = fmt.Sprintf("%s\nSubscription: **%s**", headline, subscriptionName)
+	}
+
+	post := &model.Post{
+		ChannelId: channelID,
+		UserId:    fromUserID,
+	}
+	if len(e.fields) != 0 {
+		model.ParseSlackAttachment(post, []*model.SlackAttachment{
+			{
+				Color:    "#95b7d0",
+				Fallback: headline,
+				Pretext:  headline,
+				Fields:   e.fields,
+			},
+		})
+	} else {
+		post.Message = headline
+	}
+
+	if err := p.client.Post.CreatePost(post); err != nil {
+		return nil, err
+	}
+
+	if e.digest != "" {
+		digest := &model.Post{
+			ChannelId: channelID,
+			UserId:    fromUserID,
+			RootId:    post.Id,
+			Message:   e.digest,
+		}
+		if err := p.client.Post.CreatePost(digest); err != nil {
+			return post, errors.WithMessage(err, "failed to post the digest")
+		}
+	}
+
+	return post, nil
+}
+
+// postProjectEvent posts the event once to every channel with a matching
+// subscription.
+func (p *Plugin) postProjectEvent(instanceID types.ID, e *projectEvent) error {
+	subs, err := p.getSubscriptions(instanceID)
+	if err != nil {
+		return err
+	}
+
+	botUserID := p.getBotUserID(instanceID)
+	posted := map[string]bool{}
+	for _, sub := range subs.Channel.ByID {
+		if posted[sub.ChannelID] || !e.matchesSubscriptionFilters(sub.Filters) {
+			continue
+		}
+		posted[sub.ChannelID] = true
+
+		channel, err := p.client.Channel.Get(sub.ChannelID)
+		if err != nil {
+			p.client.Log.Warn("Error occurred while getting the channel details while posting the webhook event", "ChannelID", sub.ChannelID, "Error", err.Error())
+			continue
+		}
+		if channel.DeleteAt > 0 {
+			continue
+		}
+
+		if _, err := e.postToChannel(p, sub.ChannelID, botUserID, sub.Name); err != nil {
+			p.errorf("error posting %v to channel %s, err: %v", e.eventTypes.Elems(), sub.ChannelID, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,474 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+
+	"github.com/mattermost/mattermost-plugin-jira/server/utils/types"
+)
+
+const (
+	sprintStarted            = "sprint_started"
+	sprintClosed             = "sprint_closed"
+	sprintUpdated            = "sprint_updated"
+	boardCreated             = "board_created"
+	boardUpdated             = "board_updated"
+	boardDeleted             = "board_deleted"
+	boardConfigurationChange = "board_configuration_changed"
+
+	// sprintDigestMaxIssues caps the number of issues listed per section of
+	// the sprint report digest.
+	sprintDigestMaxIssues = 10
+
+	// + board ID, within instanceKey, the projects of the board when it was
+	// last seen, as they can not be fetched once the board is deleted
+	boardProjectsKeyPrefix = "board_projects_"
+)
+
+// AgileWebhook is the payload Jira Software sends for sprint_* and board_*
+// webhook events. These carry no issue, so they are routed separately from
+// JiraWebhook.
+type AgileWebhook struct {
+	WebhookEvent string       `json:"webhookEvent"`
+	Timestamp    int64        `json:"timestamp"`
+	Sprint       *AgileSprint `json:"sprint,omitempty"`
+	OldValue     *AgileSprint `json:"oldValue,omitempty"`
+	Board        *Board       `json:"board,omitempty"`
+}
+
+type AgileSprint struct {
+	ID            int    `json:"id"`
+	Self          string `json:"self"`
+	State         string `json:"state"`
+	Name          string `json:"name"`
+	Goal          string `json:"goal"`
+	StartDate     string `json:"startDate"`
+	EndDate       string `json:"endDate"`
+	CompleteDate  string `json:"completeDate"`
+	OriginBoardID int    `json:"originBoardId"`
+}
+
+type sprintReportIssue struct {
+	Key     string `json:"key"`
+	Summary string `json:"summary"`
+}
+
+// sprintReport is the subset of the Jira Software sprint report used for the
+// sprint summary and digest.
+type sprintReport struct {
+	Contents struct {
+		CompletedIssues                   []sprintReportIssue `json:"completedIssues"`
+		IssuesNotCompletedInCurrentSprint []sprintReportIssue `json:"issuesNotCompletedInCurrentSprint"`
+		PuntedIssues                      []sprintReportIssue `json:"puntedIssues"`
+		IssuesCompletedInAnotherSprint    []sprintReportIssue `json:"issuesCompletedInAnotherSprint"`
+		IssueKeysAddedDuringSprint        map[string]bool     `json:"issueKeysAddedDuringSprint"`
+	} `json:"contents"`
+}
+
+type sprintSummary struct {
+	committed int
+	completed int
+	added     int
+	removed   int
+}
+
+type agileWebhook struct {
+	*AgileWebhook
+	projectEvent
+	boardID int
+}
+
+func isAgileWebhookEvent(webhookEvent string) bool {
+	return strings.HasPrefix(webhookEvent, "sprint_") || strings.HasPrefix(webhookEvent, "board_")
+}
+
+func parseAgileWebhook(bb []byte) (*agileWebhook, error) {
+	awh := &AgileWebhook{}
+	if err := json.Unmarshal(bb, awh); err != nil {
+		return nil, err
+	}
+
+	wh := &agileWebhook{AgileWebhook: awh}
+	switch awh.WebhookEvent {
+	case sprintStarted:
+		wh.eventTypes = NewStringSet(eventSprintStarted)
+	case sprintClosed:
+		wh.eventTypes = NewStringSet(eventSprintClosed)
+	case sprintUpdated:
+		wh.eventTypes = NewStringSet(eventSprintUpdated)
+	case boardCreated:
+		wh.eventTypes = NewStringSet(eventBoardCreated)
+	case boardUpdated, boardConfigurationChange:
+		wh.eventTypes = NewStringSet(eventBoardUpdated)
+	case boardDeleted:
+		wh.eventTypes = NewStringSet(eventBoardDeleted)
+	default:
+		return nil, ErrWebhookIgnored
+	}
+
+	if isSprintEvent(awh.WebhookEvent) {
+		if awh.Sprint == nil {
+			return nil, errors.New("sprint webhook without a sprint")
+		}
+		wh.boardID = awh.Sprint.OriginBoardID
+	} else {
+		if awh.Board == nil {
+			return nil, errors.New("board webhook without a board")
+		}
+		wh.boardID = awh.Board.ID
+	}
+
+	return wh, nil
+}
+
+func isSprintEvent(webhookEvent string) bool {
+	return strings.HasPrefix(webhookEvent, "sprint_")
+}
+
+// build renders the post for the event. The summary and report are optional,
+// they are missing when the sprint report could not be fetched.
+func (wh *agileWebhook) build(jiraURL string, summary *sprintSummary, report *sprintReport) {
+	if !isSprintEvent(wh.WebhookEvent) {
+		boardLink := fmt.Sprintf("[%s](%s)", wh.Board.Name, boardURL(jiraURL, wh.Board.ID))
+		switch wh.WebhookEvent {
+		case boardCreated:
+			wh.headline = fmt.Sprintf("Board %s was created", boardLink)
+		case boardDeleted:
+			wh.headline = fmt.Sprintf("Board **%s** was deleted", wh.Board.Name)
+		case boardConfigurationChange:
+			wh.headline = fmt.Sprintf("The configuration of board %s was changed", boardLink)
+		default:
+			wh.headline = fmt.Sprintf("Board %s was updated", boardLink)
+		}
+		if wh.Board.Type != "" {
+			wh.fields = append(wh.fields, &model.SlackAttachmentField{Title: "Type", Value: wh.Board.Type, Short: true})
+		}
+		return
+	}
+
+	sprint := wh.Sprint
+	sprintLink := sprint.Name
+	if wh.boardID != 0 {
+		sprintLink = fmt.Sprintf("[%s](%s)", sprint.Name, sprintURL(jiraURL, wh.boardID, sprint.ID))
+	}
+
+	switch wh.WebhookEvent {
+	case sprintStarted:
+		wh.headline = fmt.Sprintf("Sprint %s started", sprintLink)
+	case sprintClosed:
+		wh.headline = fmt.Sprintf("Sprint %s was closed", sprintLink)
+	default:
+		wh.headline = fmt.Sprintf("Sprint %s was updated", sprintLink)
+	}
+
+	if sprint.Goal != "" {
+		wh.fields = append(wh.fields, &model.SlackAttachmentField{Title: "Goal", Value: sprint.Goal})
+	}
+	if dates := sprintDates(sprint); dates != "" {
+		wh.fields = append(wh.fields, &model.SlackAttachmentField{Title: "Dates", Value: dates, Short: true})
+	}
+	if wh.WebhookEvent == sprintUpdated && wh.OldValue != nil {
+		if changes := sprintChanges(wh.OldValue, sprint); changes != "" {
+			wh.fields = append(wh.fields, &model.SlackAttachmentField{Title: "Changes", Value: changes})
+		}
+	}
+
+	if summary != nil {
+		wh.fields = append(wh.fields,
+			&model.SlackAttachmentField{Title: "Committed", Value: fmt.Sprintf("%d issues", summary.committed), Short: true},
+			&model.SlackAttachmentField{Title: "Completed", Value: fmt.Sprintf("%d issues", summary.completed), Short: true},
+			&model.SlackAttachmentField{Title: "Scope change", Value: fmt.Sprintf("+%d / -%d issues", summary.added, summary.removed), Short: true},
+		)
+	}
+
+	if wh.WebhookEvent == sprintClosed && report != nil {
+		wh.digest = sprintDigest(jiraURL, sprint, summary, report)
+	}
+}
+
+func (p *Plugin) processAgileWebhook(bb []byte, instanceID types.ID) error {
+	wh, err := parseAgileWebhook(bb)
+	if err != nil {
+		return err
+	}
+
+	instance, err := p.instanceStore.LoadInstance(instanceID)
+	if err != nil {
+		return err
+	}
+
+	var summary *sprintSummary
+	var report *sprintReport
+	wh.projects = NewStringSet()
+	if wh.boardID != 0 {
+		projects, projectsErr := p.resolveBoardProjects(instance, wh)
+		if projectsErr != nil {
+			// Without the projects no subscription can match the event
+			return errors.WithMessagef(projectsErr, "failed to get the projects of board %d", wh.boardID)
+		}
+		wh.projects = projects
+
+		if isSprintEvent(wh.WebhookEvent) {
+			report, err = p.getSprintReport(instance, wh.boardID, wh.Sprint.ID)
+			if err != nil {
+				p.client.Log.Warn("Failed to get the sprint report", "SprintID", wh.Sprint.ID, "error", err.Error())
+			} else {
+				summary = report.summary()
+			}
+		}
+	}
+
+	wh.build(instance.GetJiraBaseURL(), summary, report)
+
+	return p.postProjectEvent(instanceID, &wh.projectEvent)
+}
+
+func (r *sprintReport) summary() *sprintSummary {
+	c := r.Contents
+	total := len(c.CompletedIssues) + len(c.IssuesNotCompletedInCurrentSprint) + len(c.PuntedIssues) + len(c.IssuesCompletedInAnotherSprint)
+	added := len(c.IssueKeysAddedDuringSprint)
+	return &sprintSummary{
+		committed: total - added,
+		completed: len(c.CompletedIssues),
+		added:     added,
+		removed:   len(c.PuntedIssues),
+	}
+}
+
+func sprintDigest(jiraURL string, sprint *AgileSprint, summary *sprintSummary, report *sprintReport) string {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "#### Sprint report: %s\n", sprint.Name)
+	if summary != nil && summary.committed+summary.added > 0 {
+		fmt.Fprintf(sb, "Completed %d of %d issues (%d%%).\n", summary.completed, summary.committed+summary.added,
+			summary.completed*100/(summary.committed+summary.added))
+	}
+	writeSprintDigestSection(sb, jiraURL, "Completed", report.Contents.CompletedIssues)
+	writeSprintDigestSection(sb, jiraURL, "Not completed", report.Contents.IssuesNotCompletedInCurrentSprint)
+	writeSprintDigestSection(sb, jiraURL, "Removed from the sprint", report.Contents.PuntedIssues)
+	return sb.String()
+}
+
+func writeSprintDigestSection(sb *strings.Builder, jiraURL, title string, issues []sprintReportIssue) {
+	if len(issues) == 0 {
+		return
+	}
+	fmt.Fprintf(sb, "\n**%s** (%d)\n", title, len(issues))
+	for i, issue := range issues {
+		if i == sprintDigestMaxIssues {
+			fmt.Fprintf(sb, "* ...and %d more\n", len(issues)-sprintDigestMaxIssues)
+			break
+		}
+		fmt.Fprintf(sb, "* [%s](%s/browse/%s) %s\n", issue.Key, jiraURL, issue.Key, issue.Summary)
+	}
+}
+
+func sprintDates(sprint *AgileSprint) string {
+	start := formatSprintDate(sprint.StartDate)
+	end := formatSprintDate(sprint.EndDate)
+	if sprint.CompleteDate != "" {
+		end = formatSprintDate(sprint.CompleteDate)
+	}
+	if start == "" && end == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s - %s", start, end)
+}
+
+func formatSprintDate(value string) string {
+	if value == "" {
+		return ""
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05.000Z07:00", "2006-01-02T15:04:05.000-0700"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Format("Jan 2, 2006")
+		}
+	}
+	return value
+}
+
+func sprintChanges(from, to *AgileSprint) string {
+	var changes []string
+	if from.Name != to.Name {
+		changes = append(changes, fmt.Sprintf("Name: %s → %s", from.Name, to.Name))
+	}
+	if from.Goal != to.Goal {
+		changes = append(changes, fmt.Sprintf("Goal: %s → %s", from.Goal, to.Goal))
+	}
+	if from.StartDate != to.StartDate {
+		changes = append(changes, fmt.Sprintf("Start: %s → %s", formatSprintDate(from.StartDate), formatSprintDate(to.StartDate)))
+	}
+	if from.EndDate != to.EndDate {
+		changes = append(changes, fmt.Sprintf("End: %s → %s", formatSprintDate(from.EndDate), formatSprintDate(to.EndDate)))
+	}
+	return strings.Join(changes, "\n")
+}
+
+func boardURL(jiraURL string, boardID int) string {
+	return fmt.Sprintf("%s/secure/RapidBoard.jspa?rapidView=%d", jiraURL, boardID)
+}
+
+func sprintURL(jiraURL string, boardID, sprintID int) string {
+	return fmt.Sprintf("%s/secure/RapidBoard.jspa?rapidView=%d&view=reporting&chart=sprintRetrospective&sprint=%d", jiraURL, boardID, sprintID)
+}
+
+// resolveBoardProjects returns the projects of the board of the event and
+// remembers them, so that the subscriptions of the projects also get the
+// event when the board is deleted. The projects of a board that was deleted
+// before any of its events were seen are unknown.
+func (p *Plugin) resolveBoardProjects(instance Instance, wh *agileWebhook) (StringSet, error) {
+	key := instanceKey(boardProjectsKeyPrefix, instance.GetID(), strconv.Itoa(wh.boardID))
+	if wh.WebhookEvent == boardDeleted {
+		var projects []string
+		if err := p.client.KV.Get(key, &projects); err != nil {
+			return nil, errors.WithMessage(err, "failed to load the projects of the board")
+		}
+		if err := p.client.KV.Delete(key); err != nil {
+			p.client.Log.Warn("Failed to delete the projects of a deleted board", "BoardID", wh.boardID, "error", err.Error())
+		}
+		return NewStringSet(projects...), nil
+	}
+
+	projects, err := p.getBoardProjects(instance, wh.boardID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = p.client.KV.Set(key, projects.Elems()); err != nil {
+		p.client.Log.Warn("Failed to store the projects of a board", "BoardID", wh.boardID, "error", err.Error())
+	}
+	return projects, nil
+}
+
+func (p *Plugin) getBoardProjects(instance Instance, boardID int) (StringSet, error) {
+	projects := NewStringSet()
+	for startAt := 0; ; {
+		var result struct {
+			IsLast bool `json:"isLast"`
+			Values []struct {
+				Key string `json:"key"`
+			} `json:"values"`
+		}
+		params := url.Values{}
+		params.Set("startAt", strconv.Itoa(startAt))
+		if err := p.getForWebhook(instance, fmt.Sprintf("rest/agile/1.0/board/%d/project", boardID), params, &result); err != nil {
+			return nil, err
+		}
+
+		for _, project := range result.Values {
+			projects = projects.Add(project.Key)
+		}
+		if result.IsLast || len(result.Values) == 0 {
+			return projects, nil
+		}
+		startAt += len(result.Values)
+	}
+}
+
+func (p *Plugin) getSprintReport(instance Instance, boardID, sprintID int) (*sprintReport, error) {
+	params := url.Values{}
+	params.Set("rapidViewId", fmt.Sprint(boardID))
+	params.Set("sprintId", fmt.Sprint(sprintID))
+
+	report := &sprintReport{}
+	if err := p.getForWebhook(instance, "rest/greenhopper/1.0/rapid/charts/sprintreport", params, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// canGetForWebhook reports whether getForWebhook has credentials to use for
+// the instance.
+func (p *Plugin) canGetForWebhook(instance Instance) bool {
+	return p.getConfig().AdminAPIToken != "" || webhookBotInstance(instance) != nil
+}
+
+// getForWebhook performs a GET against the Jira REST API for webhook events
+// that are not tied to a connected user. It uses the admin API token when one
+// is configured, and otherwise falls back to the app's JWT bot on Jira Cloud,
+// the same way issue webhooks do.
+func (p *Plugin) getForWebhook(instance Instance, apiPath string, params url.Values, dest interface{}) error {
+	if p.getConfig().AdminAPIToken != "" {
+		return p.getWithAPIToken(instance, apiPath, params, dest)
+	}
+
+	ci := webhookBotInstance(instance)
+	if ci == nil {
+		return errors.Errorf("fetching %s needs the admin API token to be configured", apiPath)
+	}
+	jiraClient, err := ci.getClientForBot()
+	if err != nil {
+		return err
+	}
+	if len(params) != 0 {
+		apiPath += "?" + params.Encode()
+	}
+	req, err := jiraClient.NewRequest(http.MethodGet, apiPath, nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create HTTP request for %s", apiPath)
+	}
+	if _, err = jiraClient.Do(req, dest); err != nil {
+		return errors.Wrapf(err, "failed to fetch %s", apiPath)
+	}
+	return nil
+}
+
+// webhookBotInstance returns the JWT app installation of a Jira Cloud
+// instance, or nil if it has none.
+func webhookBotInstance(instance Instance) *cloudInstance {
+	switch instance := instance.(type) {
+	case *cloudInstance:
+		return instance
+	case *cloudOAuthInstance:
+		return instance.JWTInstance
+	}
+	return nil
+}
+
+// getWithAPIToken performs a GET against the Jira REST API using the admin
+// API token, for webhook events that are not tied to a connected user.
+func (p *Plugin) getWithAPIToken(instance Instance, apiPath string, params url.Values, dest interface{}) error {
+	endpoint := fmt.Sprintf("%s/%s", strings.TrimSuffix(instance.GetURL(), "/"), apiPath)
+	if len(params) != 0 {
+		endpoint += "?" + params.Encode()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create HTTP request for %s", apiPath)
+	}
+	if err = p.SetAdminAPITokenRequestHeader(req); err != nil {
+		return err
+	}
+
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to fetch %s", apiPath)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read the response of %s", apiPath)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("unexpected status code when fetching %s. StatusCode: %d", apiPath, resp.StatusCode)
+	}
+
+	if err = json.Unmarshal(body, dest); err != nil {
+		return errors.Wrapf(err, "failed to unmarshal the response of %s", apiPath)
+	}
+	return nil
+}
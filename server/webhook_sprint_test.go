@@ -0,0 +1,161 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+
+	"github.com/mattermost/mattermost-plugin-jira/server/utils/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAgileWebhook(t *testing.T) {
+	for name, tc := range map[string]struct {
+		body            string
+		expectedEvent   string
+		expectedBoardID int
+		expectedErr     error
+	}{
+		"sprint started": {
+			body:            `{"webhookEvent": "sprint_started", "sprint": {"id": 5, "name": "Sprint 5", "originBoardId": 3}}`,
+			expectedEvent:   eventSprintStarted,
+			expectedBoardID: 3,
+		},
+		"sprint closed": {
+			body:            `{"webhookEvent": "sprint_closed", "sprint": {"id": 5, "name": "Sprint 5", "originBoardId": 3}}`,
+			expectedEvent:   eventSprintClosed,
+			expectedBoardID: 3,
+		},
+		"board configuration changed": {
+			body:            `{"webhookEvent": "board_configuration_changed", "board": {"id": 3, "name": "Team board"}}`,
+			expectedEvent:   eventBoardUpdated,
+			expectedBoardID: 3,
+		},
+		"sprint created is ignored": {
+			body:        `{"webhookEvent": "sprint_created", "sprint": {"id": 5}}`,
+			expectedErr: ErrWebhookIgnored,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			wh, err := parseAgileWebhook([]byte(tc.body))
+			if tc.expectedErr != nil {
+				assert.Equal(t, tc.expectedErr, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, wh.eventTypes.ContainsAny(tc.expectedEvent))
+			assert.Equal(t, tc.expectedBoardID, wh.boardID)
+		})
+	}
+
+	_, err := parseAgileWebhook([]byte(`{"webhookEvent": "sprint_started"}`))
+	assert.Error(t, err)
+}
+
+func TestSprintReportSummary(t *testing.T) {
+	report := &sprintReport{}
+	report.Contents.CompletedIssues = []sprintReportIssue{{Key: "TEST-1"}, {Key: "TEST-2"}, {Key: "TEST-3"}}
+	report.Contents.IssuesNotCompletedInCurrentSprint = []sprintReportIssue{{Key: "TEST-4", Summary: "Still open"}}
+	report.Contents.PuntedIssues = []sprintReportIssue{{Key: "TEST-5"}}
+	report.Contents.IssueKeysAddedDuringSprint = map[string]bool{"TEST-3": true}
+
+	assert.Equal(t, &sprintSummary{committed: 4, completed: 3, added: 1, removed: 1}, report.summary())
+
+	wh, err := parseAgileWebhook([]byte(`{"webhookEvent": "sprint_closed", "sprint": {"id": 5, "name": "Sprint 5", "goal": "Ship it", "originBoardId": 3}}`))
+	require.NoError(t, err)
+	wh.build("https://jira.example.com", report.summary(), report)
+
+	assert.Equal(t, "Sprint [Sprint 5](https://jira.example.com/secure/RapidBoard.jspa?rapidView=3&view=reporting&chart=sprintRetrospective&sprint=5) was closed", wh.headline)
+	titles := []string{}
+	for _, field := range wh.fields {
+		titles = append(titles, field.Title)
+	}
+	assert.Equal(t, []string{"Goal", "Committed", "Completed", "Scope change"}, titles)
+	assert.Equal(t, "+1 / -1 issues", wh.fields[3].Value)
+	assert.Contains(t, wh.digest, "Completed 3 of 5 issues (60%).")
+	assert.Contains(t, wh.digest, "**Not completed** (1)\n* [TEST-4](https://jira.example.com/browse/TEST-4) Still open\n")
+}
+
+func TestAgileWebhookMatchesSubscriptionFilters(t *testing.T) {
+	wh, err := parseAgileWebhook([]byte(`{"webhookEvent": "sprint_started", "sprint": {"id": 5, "originBoardId": 3}}`))
+	require.NoError(t, err)
+	wh.projects = NewStringSet("TEST")
+
+	assert.True(t, wh.matchesSubscriptionFilters(SubscriptionFilters{Events: NewStringSet(eventSprintStarted), Projects: NewStringSet("TEST")}))
+	assert.True(t, wh.matchesSubscriptionFilters(SubscriptionFilters{Events: NewStringSet(eventSprintStarted)}))
+	assert.False(t, wh.matchesSubscriptionFilters(SubscriptionFilters{Events: NewStringSet(eventSprintStarted), Projects: NewStringSet("OTHER")}))
+	assert.False(t, wh.matchesSubscriptionFilters(SubscriptionFilters{Events: NewStringSet(eventUpdatedAny), Projects: NewStringSet("TEST")}))
+}
+
+func TestValidateProjectEvents(t *testing.T) {
+	p := setupTestPlugin(&plugintest.API{})
+
+	require.NoError(t, p.validateProjectEvents(testInstance1.InstanceID, NewStringSet(eventCreated)))
+	for _, event := range []string{eventSprintStarted, eventBoardUpdated, eventVersionReleased} {
+		assert.EqualError(t, p.validateProjectEvents(testInstance1.InstanceID, NewStringSet(eventCreated, event)),
+			"sprint, board and version events need the admin API token to be configured in the plugin settings, to look up the project of the event")
+	}
+	// Jira Cloud falls back to the app's JWT bot
+	assert.True(t, p.canGetForWebhook(&cloudOAuthInstance{JWTInstance: &cloudInstance{}}))
+	assert.False(t, p.canGetForWebhook(&cloudOAuthInstance{}))
+
+	p.updateConfig(func(conf *config) {
+		conf.AdminAPIToken = "token"
+	})
+	require.NoError(t, p.validateProjectEvents(testInstance1.InstanceID, NewStringSet(eventBoardCreated)))
+}
+
+func TestResolveBoardProjects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/agile/1.0/board/3/project" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.URL.Query().Get("startAt") == "2" {
+			_, _ = w.Write([]byte(`{"isLast":true,"values":[{"key":"OPS"}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"isLast":false,"values":[{"key":"TEST"},{"key":"BUG"}]}`))
+	}))
+	defer server.Close()
+
+	api := &plugintest.API{}
+	p := setupTestPlugin(api)
+	kv := map[string][]byte{}
+	mockKVMap(api, kv)
+	tokenJSON, _ := json.Marshal("test-api-token")
+	p.updateConfig(func(conf *config) {
+		conf.AdminAPIToken = string(tokenJSON)
+		conf.AdminEmail = "admin@example.com"
+		conf.EncryptionKey = ""
+	})
+	instance := &testInstance{InstanceCommon: InstanceCommon{InstanceID: types.ID(server.URL), Type: ServerInstanceType}}
+
+	updated, err := parseAgileWebhook([]byte(`{"webhookEvent": "board_updated", "board": {"id": 3, "name": "Team board"}}`))
+	require.NoError(t, err)
+	projects, err := p.resolveBoardProjects(instance, updated)
+	require.NoError(t, err)
+	assert.Equal(t, NewStringSet("TEST", "BUG", "OPS"), projects)
+
+	// The projects of a deleted board can no longer be fetched
+	server.Close()
+	deleted, err := parseAgileWebhook([]byte(`{"webhookEvent": "board_deleted", "board": {"id": 3, "name": "Team board"}}`))
+	require.NoError(t, err)
+	projects, err = p.resolveBoardProjects(instance, deleted)
+	require.NoError(t, err)
+	assert.Equal(t, NewStringSet("TEST", "BUG", "OPS"), projects)
+	assert.NotContains(t, kv, instanceKey(boardProjectsKeyPrefix, instance.GetID(), "3"))
+
+	deleted.boardID = 4
+	projects, err = p.resolveBoardProjects(instance, deleted)
+	require.NoError(t, err)
+	assert.Empty(t, projects)
+}
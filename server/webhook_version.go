@@ -0,0 +1,139 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+
+	"github.com/mattermost/mattermost-plugin-jira/server/utils/types"
+)
+
+const (
+	versionCreated    = "jira:version_created"
+	versionUpdated    = "jira:version_updated"
+	versionMoved      = "jira:version_moved"
+	versionReleased   = "jira:version_released"
+	versionUnreleased = "jira:version_unreleased"
+	versionDeleted    = "jira:version_deleted"
+)
+
+// VersionWebhook is the payload Jira sends for jira:version_* webhook events.
+type VersionWebhook struct {
+	WebhookEvent string        `json:"webhookEvent"`
+	Timestamp    int64         `json:"timestamp"`
+	Version      *jira.Version `json:"version,omitempty"`
+}
+
+type versionWebhook struct {
+	*VersionWebhook
+	projectEvent
+	projectKey string
+}
+
+func isVersionWebhookEvent(webhookEvent string) bool {
+	return strings.HasPrefix(webhookEvent, "jira:version_")
+}
+
+func parseVersionWebhook(bb []byte) (*versionWebhook, error) {
+	vwh := &VersionWebhook{}
+	if err := json.Unmarshal(bb, vwh); err != nil {
+		return nil, err
+	}
+
+	wh := &versionWebhook{VersionWebhook: vwh}
+	switch vwh.WebhookEvent {
+	case versionCreated:
+		wh.eventTypes = NewStringSet(eventVersionCreated)
+	case versionUpdated, versionMoved:
+		wh.eventTypes = NewStringSet(eventVersionUpdated)
+	case versionReleased:
+		wh.eventTypes = NewStringSet(eventVersionReleased)
+	case versionUnreleased:
+		wh.eventTypes = NewStringSet(eventVersionUnreleased)
+	case versionDeleted:
+		wh.eventTypes = NewStringSet(eventVersionDeleted)
+	default:
+		return nil, ErrWebhookIgnored
+	}
+
+	if vwh.Version == nil {
+		return nil, errors.New("version webhook without a version")
+	}
+
+	return wh, nil
+}
+
+func (wh *versionWebhook) build(jiraURL string) {
+	v := wh.Version
+	name := fmt.Sprintf("**%s**", v.Name)
+	if wh.projectKey != "" && wh.WebhookEvent != versionDeleted {
+		name = fmt.Sprintf("[%s](%s/projects/%s/versions/%s)", v.Name, jiraURL, wh.projectKey, v.ID)
+	}
+
+	switch wh.WebhookEvent {
+	case versionCreated:
+		wh.headline = fmt.Sprintf("Version %s was created", name)
+	case versionReleased:
+		wh.headline = fmt.Sprintf("Version %s was released", name)
+	case versionUnreleased:
+		wh.headline = fmt.Sprintf("Version %s was unreleased", name)
+	case versionDeleted:
+		wh.headline = fmt.Sprintf("Version %s was deleted", name)
+	default:
+		wh.headline = fmt.Sprintf("Version %s was updated", name)
+	}
+
+	if wh.projectKey != "" {
+		wh.fields = append(wh.fields, &model.SlackAttachmentField{Title: "Project", Value: wh.projectKey, Short: true})
+	}
+	if v.ReleaseDate != "" {
+		wh.fields = append(wh.fields, &model.SlackAttachmentField{Title: "Release date", Value: v.ReleaseDate, Short: true})
+	}
+	if v.Description != "" {
+		wh.fields = append(wh.fields, &model.SlackAttachmentField{Title: "Description", Value: v.Description})
+	}
+	if wh.WebhookEvent == versionReleased && wh.projectKey != "" {
+		wh.fields = append(wh.fields, &model.SlackAttachmentField{
+			Title: "Release notes",
+			Value: fmt.Sprintf("`/jira release notes %s %s`", wh.projectKey, v.Name),
+		})
+	}
+}
+
+func (p *Plugin) processVersionWebhook(bb []byte, instanceID types.ID) error {
+	wh, err := parseVersionWebhook(bb)
+	if err != nil {
+		return err
+	}
+
+	instance, err := p.instanceStore.LoadInstance(instanceID)
+	if err != nil {
+		return err
+	}
+
+	// The payload only has the project ID, subscriptions are keyed by project key.
+	if wh.Version.ProjectID == 0 {
+		return errors.Errorf("version %s webhook without a project ID", wh.Version.ID)
+	}
+	var project struct {
+		Key string `json:"key"`
+	}
+	err = p.getForWebhook(instance, fmt.Sprintf("rest/api/2/project/%d", wh.Version.ProjectID), nil, &project)
+	if err != nil {
+		return errors.WithMessagef(err, "failed to get project %d of version %s", wh.Version.ProjectID, wh.Version.ID)
+	}
+	wh.projectKey = project.Key
+	wh.projects = NewStringSet(project.Key)
+
+	wh.build(instance.GetJiraBaseURL())
+
+	return p.postProjectEvent(instanceID, &wh.projectEvent)
+}
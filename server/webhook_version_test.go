@@ -0,0 +1,34 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionWebhook(t *testing.T) {
+	wh, err := parseVersionWebhook([]byte(`{"webhookEvent": "jira:version_released", "version": {"id": "10001", "name": "1.0", "releaseDate": "2024-03-01", "projectId": 10000}}`))
+	require.NoError(t, err)
+	assert.True(t, wh.eventTypes.ContainsAny(eventVersionReleased))
+
+	wh.projectKey = "TEST"
+	wh.projects = NewStringSet("TEST")
+	wh.build("https://jira.example.com")
+	assert.Equal(t, "Version [1.0](https://jira.example.com/projects/TEST/versions/10001) was released", wh.headline)
+	require.Len(t, wh.fields, 3)
+	assert.Equal(t, "`/jira release notes TEST 1.0`", wh.fields[2].Value)
+
+	assert.True(t, wh.matchesSubscriptionFilters(SubscriptionFilters{Events: NewStringSet(eventVersionReleased), Projects: NewStringSet("TEST")}))
+	assert.False(t, wh.matchesSubscriptionFilters(SubscriptionFilters{Events: NewStringSet(eventVersionCreated), Projects: NewStringSet("TEST")}))
+
+	moved, err := parseVersionWebhook([]byte(`{"webhookEvent": "jira:version_moved", "version": {"id": "10001", "name": "1.0"}}`))
+	require.NoError(t, err)
+	assert.True(t, moved.eventTypes.ContainsAny(eventVersionUpdated))
+
+	_, err = parseVersionWebhook([]byte(`{"webhookEvent": "jira:version_merged", "version": {"id": "10001"}}`))
+	assert.Equal(t, ErrWebhookIgnored, err)
+}
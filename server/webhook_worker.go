@@ -4,6 +4,8 @@
 package main
 
 import (
+	"encoding/json"
+
 	"github.com/pkg/errors"
 
 	"github.com/mattermost/mattermost-plugin-jira/server/utils/types"
@@ -41,6 +43,20 @@ func (ww webhookWorker) process(msg *webhookMessage) (err error) {
 		}
 	}()
 
+	var event struct {
+		WebhookEvent string `json:"webhookEvent"`
+	}
+	if err = json.Unmarshal(msg.Data, &event); err == nil {
+		switch {
+		case isAgileWebhookEvent(event.WebhookEvent):
+			return ww.p.processAgileWebhook(msg.Data, msg.InstanceID)
+		case isVersionWebhookEvent(event.WebhookEvent):
+			return ww.p.processVersionWebhook(msg.Data, msg.InstanceID)
+		case isInstanceWebhookEvent(event.WebhookEvent):
+			return ww.p.processInstanceWebhook(msg.Data, msg.InstanceID)
+		}
+	}
+
 	wh, err := ParseWebhook(msg.Data)
 	if err != nil {
 		return err
@@ -69,12 +85,14 @@ func (ww webhookWorker) process(msg *webhookMessage) (err error) {
 		ww.p.errorf("WebhookWorker id: %d, error posting notifications, err: %v", ww.id, err)
 	}
 
+	ww.p.processIncidentRules(v, msg.InstanceID)
+
 	channelsSubscribed, err := ww.p.getChannelsSubscribed(v, msg.InstanceID)
 	if err != nil {
 		return err
 	}
 
-	botUserID := ww.p.getUserID()
+	botUserID := ww.p.getBotUserID(msg.InstanceID)
 	for _, channelSubscribed := range channelsSubscribed {
 		channel, err := ww.p.client.Channel.Get(channelSubscribed.ChannelID)
 		if err != nil {
@@ -91,5 +109,7 @@ func (ww webhookWorker) process(msg *webhookMessage) (err error) {
 		}
 	}
 
+	ww.p.postSLABreachWarnings(v, msg.InstanceID)
+
 	return nil
 }
@@ -0,0 +1,498 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	wikiHeadingRegex = regexp.MustCompile(`^h([1-6])\.\s+(.*)$`)
+	wikiQuoteRegex   = regexp.MustCompile(`^bq\.\s+(.*)$`)
+	wikiListRegex    = regexp.MustCompile(`^\s*([*#-]+)\s+(.*)$`)
+	wikiRuleRegex    = regexp.MustCompile(`^\s*-{4,}\s*$`)
+	wikiTableRegex   = regexp.MustCompile(`^\s*\|`)
+)
+
+// wikiBlockMacros are the {macro}...{macro} constructs that may span several lines.
+var wikiBlockMacros = []string{"code", "noformat", "quote", "panel", "info", "tip", "note", "warning"}
+
+// wikiInlineMarks maps Jira's inline formatting characters to their markdown equivalent.
+// Underline has no markdown counterpart, so its text is kept as is.
+var wikiInlineMarks = map[byte]string{
+	'*': "**",
+	'_': "_",
+	'-': "~~",
+	'+': "",
+}
+
+var wikiEmoticons = []struct {
+	wiki  string
+	emoji string
+}{
+	{"(y)", ":thumbsup:"},
+	{"(n)", ":thumbsdown:"},
+	{"(i)", ":information_source:"},
+	{"(/)", ":white_check_mark:"},
+	{"(x)", ":x:"},
+	{"(!)", ":warning:"},
+	{"(+)", ":heavy_plus_sign:"},
+	{"(-)", ":heavy_minus_sign:"},
+	{"(?)", ":question:"},
+	{"(on)", ":bulb:"},
+	{"(off)", ":bulb:"},
+	{"(*)", ":star:"},
+	{":)", ":slightly_smiling_face:"},
+	{":(", ":slightly_frowning_face:"},
+	{":P", ":stuck_out_tongue:"},
+	{":D", ":smiley:"},
+	{";)", ":wink:"},
+}
+
+var wikiPanelIcons = map[string]string{
+	"info":    ":information_source:",
+	"tip":     ":bulb:",
+	"note":    ":memo:",
+	"warning": ":warning:",
+}
+
+// wikiToMarkdown converts Jira wiki markup, as used by Jira Server and the v2 REST API,
+// into Mattermost markdown. Block macros are handled first since they can span lines,
+// then every remaining line is classified (heading, list, table, ...) and its inline
+// formatting is rendered.
+func wikiToMarkdown(text string) string {
+	var sb strings.Builder
+	for text != "" {
+		start, end, name, params, body := findWikiBlockMacro(text)
+		if start < 0 {
+			sb.WriteString(renderWikiLines(text))
+			break
+		}
+
+		sb.WriteString(renderWikiLines(text[:start]))
+		sb.WriteString(renderWikiMacro(name, params, body))
+		text = text[end:]
+	}
+	return sb.String()
+}
+
+// findWikiBlockMacro returns the position of the first terminated block macro in text.
+// Unterminated macros are left alone so they render as plain text.
+func findWikiBlockMacro(text string) (start, end int, name, params, body string) {
+	start = -1
+	for _, macro := range wikiBlockMacros {
+		offset := 0
+		for {
+			idx := strings.Index(text[offset:], "{"+macro)
+			if idx < 0 {
+				break
+			}
+			idx += offset
+			offset = idx + 1
+
+			tagEnd := idx + 1 + len(macro)
+			if tagEnd >= len(text) || (text[tagEnd] != '}' && text[tagEnd] != ':') {
+				continue
+			}
+			closeBrace := strings.IndexByte(text[tagEnd:], '}')
+			if closeBrace < 0 {
+				break
+			}
+			closeBrace += tagEnd
+
+			closeTag := "{" + macro + "}"
+			closeIdx := strings.Index(text[closeBrace+1:], closeTag)
+			if closeIdx < 0 {
+				break
+			}
+			closeIdx += closeBrace + 1
+
+			if start < 0 || idx < start {
+				start = idx
+				end = closeIdx + len(closeTag)
+				name = macro
+				params = strings.TrimPrefix(text[tagEnd:closeBrace], ":")
+				body = text[closeBrace+1 : closeIdx]
+			}
+			break
+		}
+	}
+	return start, end, name, params, body
+}
+
+func renderWikiMacro(name, params, body string) string {
+	switch name {
+	case "code", "noformat":
+		// Code is rendered line by line as inline code, which survives being
+		// quoted with "> " when the text ends up in a comment notification.
+		lines := strings.Split(body, "\n")
+		if len(lines) == 1 {
+			return "`" + lines[0] + "`"
+		}
+		for i := range lines {
+			if len(lines[i]) > 0 {
+				lines[i] = "`" + lines[i] + "`"
+			}
+		}
+		return "\n" + strings.Join(lines, "\n") + "\n"
+
+	case "quote":
+		return prefixLines(wikiToMarkdown(strings.Trim(body, "\n")), "> ")
+
+	default:
+		content := wikiToMarkdown(strings.Trim(body, "\n"))
+		if title := wikiMacroParam(params, "title"); title != "" {
+			content = "**" + title + "**\n" + content
+		}
+		if icon := wikiPanelIcons[name]; icon != "" {
+			content = icon + " " + content
+		}
+		return prefixLines(content, "> ")
+	}
+}
+
+// wikiMacroParam extracts a named parameter from macro params such as "title=Notes|borderStyle=solid".
+func wikiMacroParam(params, key string) string {
+	for _, param := range strings.Split(params, "|") {
+		k, v, ok := strings.Cut(param, "=")
+		if ok && strings.TrimSpace(k) == key {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+func prefixLines(text, prefix string) string {
+	return prefix + strings.ReplaceAll(text, "\n", "\n"+prefix)
+}
+
+// wikiListState tracks the counters of nested lists so numbered items are renumbered for markdown.
+type wikiListState struct {
+	kinds    []byte
+	counters []int
+}
+
+func (l *wikiListState) reset() {
+	l.kinds = nil
+	l.counters = nil
+}
+
+func (l *wikiListState) render(markers, item string) string {
+	depth := len(markers)
+	kind := markers[depth-1]
+	if len(l.counters) > depth {
+		l.kinds = l.kinds[:depth]
+		l.counters = l.counters[:depth]
+	}
+	for len(l.counters) < depth {
+		l.kinds = append(l.kinds, markers[len(l.counters)])
+		l.counters = append(l.counters, 0)
+	}
+	if l.kinds[depth-1] != kind {
+		l.kinds[depth-1] = kind
+		l.counters[depth-1] = 0
+	}
+	l.counters[depth-1]++
+
+	indent := ""
+	for i := 0; i < depth-1; i++ {
+		if markers[i] == '#' {
+			indent += "   "
+		} else {
+			indent += "  "
+		}
+	}
+
+	if kind == '#' {
+		return fmt.Sprintf("%s%d. %s", indent, l.counters[depth-1], item)
+	}
+	return indent + "* " + item
+}
+
+func renderWikiLines(text string) string {
+	lines := strings.Split(text, "\n")
+	result := make([]string, 0, len(lines))
+	list := wikiListState{}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		if m := wikiListRegex.FindStringSubmatch(line); m != nil {
+			result = append(result, list.render(m[1], renderWikiInline(m[2])))
+			continue
+		}
+		list.reset()
+
+		switch {
+		case wikiTableRegex.MatchString(line):
+			var rows []string
+			for ; i < len(lines) && wikiTableRegex.MatchString(lines[i]); i++ {
+				rows = append(rows, lines[i])
+			}
+			i--
+			result = append(result, renderWikiTable(rows))
+
+		case wikiRuleRegex.MatchString(line):
+			result = append(result, "---")
+
+		default:
+			if m := wikiHeadingRegex.FindStringSubmatch(line); m != nil {
+				result = append(result, strings.Repeat("#", int(m[1][0]-'0'))+" "+renderWikiInline(m[2]))
+			} else if m := wikiQuoteRegex.FindStringSubmatch(line); m != nil {
+				result = append(result, "> "+renderWikiInline(m[1]))
+			} else {
+				result = append(result, renderWikiInline(line))
+			}
+		}
+	}
+
+	return strings.Join(result, "\n")
+}
+
+func renderWikiTable(rows []string) string {
+	var out []string
+	columns := 0
+	for i, row := range rows {
+		cells := splitWikiTableRow(row)
+		if len(cells) > columns {
+			columns = len(cells)
+		}
+		for j := range cells {
+			cells[j] = strings.ReplaceAll(renderWikiInline(strings.TrimSpace(cells[j])), "|", `\|`)
+		}
+		out = append(out, "| "+strings.Join(cells, " | ")+" |")
+		if i == 0 {
+			out = append(out, "")
+		}
+	}
+
+	// Markdown tables require a header row, so the first row always acts as one.
+	out[1] = "|" + strings.Repeat(" --- |", columns)
+	return strings.Join(out, "\n")
+}
+
+// splitWikiTableRow splits "||head||head||" and "|cell|cell|" rows into cells,
+// ignoring separators inside links and embedded images.
+func splitWikiTableRow(row string) []string {
+	row = strings.TrimSpace(row)
+	var cells []string
+	var cell strings.Builder
+	depth := 0
+	inImage := false
+	for i := 0; i < len(row); i++ {
+		c := row[i]
+		switch {
+		case c == '[':
+			depth++
+		case c == ']' && depth > 0:
+			depth--
+		case c == '!' && inImage:
+			inImage = false
+		case c == '!' && strings.TrimSpace(cell.String()) == "":
+			inImage = strings.IndexByte(row[i+1:], '!') > 0
+		case c == '|' && depth == 0 && !inImage:
+			if i > 0 && row[i-1] != '|' {
+				cells = append(cells, cell.String())
+				cell.Reset()
+			}
+			continue
+		}
+		cell.WriteByte(c)
+	}
+	if strings.TrimSpace(cell.String()) != "" {
+		cells = append(cells, cell.String())
+	}
+	return cells
+}
+
+// renderWikiInline renders the inline formatting of a single line of wiki markup.
+func renderWikiInline(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+
+		switch {
+		case c == '\\' && i+1 < len(s):
+			if s[i+1] == '\\' {
+				sb.WriteString("\n")
+			} else {
+				sb.WriteString(s[i : i+2])
+			}
+			i += 2
+			continue
+
+		case strings.HasPrefix(s[i:], "{{"):
+			if end := strings.Index(s[i+2:], "}}"); end >= 0 {
+				sb.WriteString("`" + s[i+2:i+2+end] + "`")
+				i += end + 4
+				continue
+			}
+
+		case strings.HasPrefix(s[i:], "{color"):
+			if open := strings.IndexByte(s[i:], '}'); open >= 0 {
+				rest := s[i+open+1:]
+				if end := strings.Index(rest, "{color}"); end >= 0 {
+					sb.WriteString(renderWikiInline(rest[:end]))
+					i += open + 1 + end + len("{color}")
+					continue
+				}
+			}
+
+		case c == '[':
+			if end := strings.IndexByte(s[i:], ']'); end > 0 {
+				sb.WriteString(renderWikiLink(s[i+1 : i+end]))
+				i += end + 1
+				continue
+			}
+
+		case c == '!':
+			if out, n := renderWikiImage(s, i); n > 0 {
+				sb.WriteString(out)
+				i += n
+				continue
+			}
+		}
+
+		if emoji, n := matchWikiEmoticon(s, i); n > 0 {
+			sb.WriteString(emoji)
+			i += n
+			continue
+		}
+
+		if mark, ok := wikiInlineMarks[c]; ok {
+			if end := findWikiMarkClose(s, i); end > 0 {
+				sb.WriteString(mark + renderWikiInline(s[i+1:end]) + mark)
+				i = end + 1
+				continue
+			}
+		}
+
+		sb.WriteByte(c)
+		i++
+	}
+	return sb.String()
+}
+
+func isWikiWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func runeBefore(s string, i int) rune {
+	if i <= 0 {
+		return ' '
+	}
+	r, _ := utf8.DecodeLastRuneInString(s[:i])
+	return r
+}
+
+func runeAt(s string, i int) rune {
+	if i >= len(s) {
+		return ' '
+	}
+	r, _ := utf8.DecodeRuneInString(s[i:])
+	return r
+}
+
+// findWikiMarkClose returns the index of the character closing the formatting mark opened
+// at i, or -1. Like Jira, marks only open at the start of a word and close at its end,
+// so "snake_case" and "2020-01-01" are left alone.
+func findWikiMarkClose(s string, i int) int {
+	c := s[i]
+	if isWikiWordRune(runeBefore(s, i)) {
+		return -1
+	}
+	next := runeAt(s, i+1)
+	if i+1 >= len(s) || unicode.IsSpace(next) || next == rune(c) {
+		return -1
+	}
+
+	for j := i + 2; j < len(s); j++ {
+		if s[j] != c {
+			continue
+		}
+		if unicode.IsSpace(runeBefore(s, j)) {
+			continue
+		}
+		if after := runeAt(s, j+1); j+1 < len(s) && (isWikiWordRune(after) || after == rune(c)) {
+			continue
+		}
+		return j
+	}
+	return -1
+}
+
+func matchWikiEmoticon(s string, i int) (string, int) {
+	for _, e := range wikiEmoticons {
+		if !strings.HasPrefix(s[i:], e.wiki) {
+			continue
+		}
+		if isWikiWordRune(runeBefore(s, i)) || isWikiWordRune(runeAt(s, i+len(e.wiki))) {
+			continue
+		}
+		return e.emoji, len(e.wiki)
+	}
+	return "", 0
+}
+
+// renderWikiLink renders the content of a [...] construct.
+func renderWikiLink(inner string) string {
+	switch {
+	case strings.HasPrefix(inner, "~"):
+		// User mentions are resolved later by replaceJiraAccountIds.
+		return "[" + inner + "]"
+	case strings.HasPrefix(inner, "^"):
+		return ":paperclip: " + strings.TrimPrefix(inner, "^")
+	}
+
+	parts := strings.SplitN(inner, "|", 3)
+	if len(parts) >= 2 {
+		text, link := parts[0], strings.TrimSpace(parts[1])
+		if text == "" {
+			text = link
+		}
+		return "[" + renderWikiInline(text) + "](" + link + ")"
+	}
+
+	if hasWikiLinkScheme(inner) {
+		return "[" + inner + "](" + inner + ")"
+	}
+	return "[" + inner + "]"
+}
+
+func hasWikiLinkScheme(s string) bool {
+	for _, scheme := range []string{"http://", "https://", "mailto:", "ftp://", "file://"} {
+		if strings.HasPrefix(s, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// renderWikiImage renders !image.png! and !image.png|thumbnail! embeds starting at i.
+// Attachments are only reachable with Jira credentials, so they're shown by name.
+func renderWikiImage(s string, i int) (string, int) {
+	if isWikiWordRune(runeBefore(s, i)) {
+		return "", 0
+	}
+	end := strings.IndexByte(s[i+1:], '!')
+	if end <= 0 {
+		return "", 0
+	}
+	inner := s[i+1 : i+1+end]
+	if strings.ContainsAny(inner, " \t") {
+		return "", 0
+	}
+	name, _, _ := strings.Cut(inner, "|")
+	switch {
+	case hasWikiLinkScheme(name):
+		return "![](" + name + ")", end + 2
+	case strings.Contains(name, "."):
+		return ":paperclip: " + name, end + 2
+	}
+	return "", 0
+}
@@ -18,6 +18,9 @@ export default {
     CLOSE_ATTACH_COMMENT_TO_ISSUE_MODAL: `${PluginId}_close_attach_modal`,
     OPEN_ATTACH_COMMENT_TO_ISSUE_MODAL: `${PluginId}_open_attach_modal`,
 
+    CLOSE_CREATE_CUSTOMER_REQUEST_MODAL: `${PluginId}_close_customer_request_modal`,
+    OPEN_CREATE_CUSTOMER_REQUEST_MODAL: `${PluginId}_open_customer_request_modal`,
+
     RECEIVED_CONNECTED: `${PluginId}_connected`,
     RECEIVED_INSTANCE_STATUS: `${PluginId}_instance_status`,
     RECEIVED_PLUGIN_SETTINGS: `${PluginId}_plugin_settings`,
@@ -2,7 +2,9 @@
 // See LICENSE.txt for license information.
 
 import {PostTypes} from 'mattermost-redux/action_types';
+import {Client4} from 'mattermost-redux/client';
 import {getCurrentChannelId} from 'mattermost-redux/selectors/entities/common';
+import {getCurrentTeam} from 'mattermost-redux/selectors/entities/teams';
 
 import {Action, Dispatch, Store} from 'redux';
 
@@ -16,6 +18,7 @@ import {
     AttachCommentRequest,
     AutoCompleteParams,
     ChannelSubscription,
+    CreateCustomerRequestRequest,
     CreateIssueRequest,
     InstanceType,
     ProjectMetadata,
@@ -59,6 +62,36 @@ export const openCreateModal = (postId: string) => {
     };
 };
 
+export const openCreateModalFromThread = (postId: string) => {
+    return async (dispatch: Dispatch, getState: GlobalState) => {
+        const baseUrl = getPluginServerRoute(getState());
+        const params = {
+            post_id: postId,
+            current_team: getCurrentTeam(getState())?.name || '',
+        };
+
+        let data = null;
+        try {
+            data = await doFetch(`${baseUrl}/api/v2/get-thread-transcript${buildQueryString(params)}`, {
+                method: 'get',
+            });
+        } catch (error) {
+            return {error};
+        }
+
+        dispatch({
+            type: ActionTypes.OPEN_CREATE_ISSUE_MODAL,
+            data: {
+                postId,
+                description: data.description,
+                fromThread: true,
+            },
+        });
+
+        return {data};
+    };
+};
+
 export const openCreateModalWithoutPost = (description: string, channelId: string) => (dispatch) => dispatch({
     type: ActionTypes.OPEN_CREATE_ISSUE_MODAL_WITHOUT_POST,
     data: {
@@ -88,6 +121,21 @@ export const closeAttachCommentToIssueModal = () => {
     };
 };
 
+export const openCreateCustomerRequestModal = (postId: string) => {
+    return {
+        type: ActionTypes.OPEN_CREATE_CUSTOMER_REQUEST_MODAL,
+        data: {
+            postId,
+        },
+    };
+};
+
+export const closeCreateCustomerRequestModal = () => {
+    return {
+        type: ActionTypes.CLOSE_CREATE_CUSTOMER_REQUEST_MODAL,
+    };
+};
+
 export const fetchJiraIssueMetadataForProjects = (projectKeys: string[], instanceID: string) => {
     return async (dispatch: Dispatch, getState: GlobalState) => {
         const baseUrl = getPluginServerRoute(getState());
@@ -115,7 +163,11 @@ export const fetchJiraProjectMetadata = (instanceID: string) => {
         const baseUrl = getPluginServerRoute(getState());
         let data = null;
         try {
-            data = await doFetch(`${baseUrl}/api/v2/get-jira-project-metadata?instance_id=${instanceID}`, {
+            const params = {
+                instance_id: instanceID,
+                channel_id: getCurrentChannelId(getState()),
+            };
+            data = await doFetch(`${baseUrl}/api/v2/get-jira-project-metadata${buildQueryString(params)}`, {
                 method: 'get',
             });
         } catch (error) {
@@ -130,6 +182,21 @@ export const fetchJiraProjectMetadata = (instanceID: string) => {
     };
 };
 
+export const fetchChannelDefaults = (channelId: string) => {
+    return async (dispatch: Dispatch, getState: GlobalState) => {
+        const baseUrl = getPluginServerRoute(getState());
+        try {
+            const data = await doFetch(`${baseUrl}/api/v2/get-channel-defaults${buildQueryString({channel_id: channelId})}`, {
+                method: 'get',
+            });
+
+            return {data};
+        } catch (error) {
+            return {error};
+        }
+    };
+};
+
 export const fetchJiraProjectMetadataForAllInstances = () => {
     return async (dispatch: Dispatch, getState: GlobalState) => {
         const instances = getInstalledInstances(getState());
@@ -161,7 +228,11 @@ export const fetchJiraProjectMetadataForAllInstances = () => {
 export const searchIssues = (params: SearchIssueParams) => {
     return async (dispatch: Dispatch, getState: GlobalState) => {
         const url = getPluginServerRoute(getState()) + '/api/v2/get-search-issues';
-        return doFetchWithResponse(`${url}${buildQueryString(params)}`);
+        const query = {
+            channel_id: getCurrentChannelId(getState()),
+            ...params,
+        };
+        return doFetchWithResponse(`${url}${buildQueryString(query)}`);
     };
 };
 
@@ -186,6 +257,30 @@ export const searchTeamFields = (params) => {
     };
 };
 
+export const searchMattermostUsers = (term: string) => {
+    return async () => {
+        return doFetchWithResponse(`${Client4.getUsersRoute()}/autocomplete${buildQueryString({name: term})}`);
+    };
+};
+
+export const getMattermostUsersByIds = (userIDs: string[]) => {
+    return async () => {
+        return doFetchWithResponse(`${Client4.getUsersRoute()}/ids`, {method: 'post', body: JSON.stringify(userIDs)});
+    };
+};
+
+export const searchMattermostGroups = (term: string) => {
+    return async () => {
+        return doFetchWithResponse(`${Client4.getGroupsRoute()}${buildQueryString({q: term, filter_allow_reference: true, per_page: 20})}`);
+    };
+};
+
+export const getMattermostGroup = (groupID: string) => {
+    return async () => {
+        return doFetchWithResponse(`${Client4.getGroupsRoute()}/${groupID}`);
+    };
+};
+
 export const searchSprints = (params: {instance_id: string; project_key: string}) => {
     return async (dispatch: Dispatch, getState: GlobalState) => {
         const url = `${getPluginServerRoute(getState())}/api/v2/get-sprints`;
@@ -239,6 +334,41 @@ export const attachCommentToIssue = (payload: AttachCommentRequest) => {
     };
 };
 
+export const fetchRequestTypes = (instanceID: string, projectKey: string) => {
+    return async (dispatch: Dispatch, getState: GlobalState) => {
+        const baseUrl = getPluginServerRoute(getState());
+        const params = {
+            instance_id: instanceID,
+            project_key: projectKey,
+        };
+        try {
+            const data = await doFetch(`${baseUrl}/api/v2/get-request-types${buildQueryString(params)}`, {
+                method: 'get',
+            });
+
+            return {data};
+        } catch (error) {
+            return {error};
+        }
+    };
+};
+
+export const createCustomerRequest = (payload: CreateCustomerRequestRequest) => {
+    return async (dispatch: Dispatch, getState: GlobalState) => {
+        const baseUrl = getPluginServerRoute(getState());
+        try {
+            const data = await doFetch(`${baseUrl}/api/v2/create-customer-request`, {
+                method: 'post',
+                body: JSON.stringify(payload),
+            });
+
+            return {data};
+        } catch (error) {
+            return {error};
+        }
+    };
+};
+
 export const createChannelSubscription = (subscription: ChannelSubscription) => {
     return async (dispatch: Dispatch, getState: GlobalState) => {
         const baseUrl = getPluginServerRoute(getState());
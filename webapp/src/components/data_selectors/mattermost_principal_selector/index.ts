@@ -0,0 +1,18 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+import {connect} from 'react-redux';
+import {bindActionCreators} from 'redux';
+
+import {getMattermostGroup, getMattermostUsersByIds, searchMattermostGroups, searchMattermostUsers} from 'actions';
+
+import MattermostPrincipalSelector from './mattermost_principal_selector';
+
+const mapDispatchToProps = (dispatch) => bindActionCreators({
+    searchMattermostUsers,
+    getMattermostUsersByIds,
+    searchMattermostGroups,
+    getMattermostGroup,
+}, dispatch);
+
+export default connect(null, mapDispatchToProps)(MattermostPrincipalSelector);
@@ -0,0 +1,73 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+import React from 'react';
+
+import {ReactSelectOption} from 'types/model';
+
+import BackendSelector, {Props as BackendSelectorProps} from '../backend_selector';
+
+type MattermostUser = {id: string; username: string; first_name?: string; last_name?: string};
+type MattermostGroup = {id: string; name?: string; display_name: string};
+
+type Props = Omit<BackendSelectorProps, 'fetchInitialSelectedValues' | 'search'> & {
+    groups: boolean;
+    searchMattermostUsers: (term: string) => Promise<{data: {users: MattermostUser[]}}>;
+    getMattermostUsersByIds: (userIDs: string[]) => Promise<{data: MattermostUser[]}>;
+    searchMattermostGroups: (term: string) => Promise<{data: MattermostGroup[]}>;
+    getMattermostGroup: (groupID: string) => Promise<{data: MattermostGroup}>;
+};
+
+const userOption = (user: MattermostUser): ReactSelectOption => {
+    const fullName = [user.first_name, user.last_name].filter(Boolean).join(' ');
+    return {
+        value: user.id,
+        label: fullName ? `@${user.username} - ${fullName}` : `@${user.username}`,
+    };
+};
+
+const groupOption = (group: MattermostGroup): ReactSelectOption => ({
+    value: group.id,
+    label: group.name ? `${group.display_name} (@${group.name})` : group.display_name,
+});
+
+// MattermostPrincipalSelector selects Mattermost users or groups, to match the
+// Jira users of a user field in subscription filters.
+const MattermostPrincipalSelector = (props: Props): JSX.Element => {
+    const {value, groups} = props;
+    const values = (Array.isArray(value) ? value : [value]).filter(Boolean) as string[];
+
+    const search = async (inputValue: string): Promise<ReactSelectOption[]> => {
+        if (groups) {
+            const {data} = await props.searchMattermostGroups(inputValue);
+            return (data || []).map(groupOption);
+        }
+
+        const {data} = await props.searchMattermostUsers(inputValue);
+        return ((data && data.users) || []).map(userOption);
+    };
+
+    const fetchInitialSelectedValues = async (): Promise<ReactSelectOption[]> => {
+        if (!values.length) {
+            return [];
+        }
+
+        if (groups) {
+            const responses = await Promise.all(values.map((groupID) => props.getMattermostGroup(groupID).catch(() => null)));
+            return responses.filter(Boolean).map((response) => groupOption(response.data));
+        }
+
+        const {data} = await props.getMattermostUsersByIds(values);
+        return (data || []).map(userOption);
+    };
+
+    return (
+        <BackendSelector
+            {...props}
+            fetchInitialSelectedValues={fetchInitialSelectedValues}
+            search={search}
+        />
+    );
+};
+
+export default MattermostPrincipalSelector;
@@ -4,7 +4,9 @@
 import {connect} from 'react-redux';
 import {bindActionCreators} from 'redux';
 
-import {fetchJiraProjectMetadata, getConnected} from '../../actions';
+import {getCurrentChannelId} from 'mattermost-redux/selectors/entities/common';
+
+import {fetchChannelDefaults, fetchJiraProjectMetadata, getConnected} from '../../actions';
 
 import {getDefaultUserInstanceID, getInstalledInstances, getUserConnectedInstances} from '../../selectors';
 
@@ -19,10 +21,12 @@ const mapStateToProps = (state) => {
         installedInstances,
         connectedInstances,
         defaultUserInstanceID,
+        currentChannelID: getCurrentChannelId(state),
     };
 };
 
 const mapDispatchToProps = (dispatch) => bindActionCreators({
+    fetchChannelDefaults,
     fetchJiraProjectMetadata,
     getConnected,
 }, dispatch);
@@ -28,6 +28,8 @@ describe('components/JiraInstanceAndProjectSelector', () => {
         installedInstances: [{instance_id: 'instance1', type: InstanceType.CLOUD}, {instance_id: 'instance2', type: InstanceType.SERVER}, {instance_id: 'instance3', type: InstanceType.SERVER}],
         connectedInstances: [{instance_id: 'instance1', type: InstanceType.CLOUD}, {instance_id: 'instance2', type: InstanceType.SERVER}],
         defaultUserInstanceID: '',
+        currentChannelID: 'channel1',
+        fetchChannelDefaults: jest.fn().mockResolvedValue({data: {}}),
         fetchJiraProjectMetadata: jest.fn().mockResolvedValue({data: {
             saved_field_values: {
                 project_key: 'TEST',
@@ -100,6 +102,24 @@ describe('components/JiraInstanceAndProjectSelector', () => {
         expect(ref.current).toBeDefined();
     });
 
+    test('should prefer the instance the channel is bound to', async () => {
+        const onInstanceChange = jest.fn();
+        const props = {
+            ...baseProps,
+            onInstanceChange,
+            defaultUserInstanceID: 'instance1',
+            fetchChannelDefaults: jest.fn().mockResolvedValue({data: {instance_id: 'instance2', project_key: 'TEST'}}),
+        };
+        await act(async () => {
+            renderWithRedux(
+                <JiraInstanceAndProjectSelector {...props}/>,
+            );
+        });
+
+        expect(props.fetchChannelDefaults).toHaveBeenCalledWith('channel1');
+        expect(onInstanceChange).toHaveBeenCalledWith('instance2');
+    });
+
     test('should assign the correct initial instance id', async () => {
         let onInstanceChange = jest.fn();
         let props = {
@@ -7,6 +7,7 @@ import {Theme} from 'mattermost-redux/selectors/entities/preferences';
 
 import {
     APIResponse,
+    ChannelDefaults,
     GetConnectedResponse,
     Instance,
     ProjectMetadata,
@@ -30,6 +31,8 @@ export type Props = {
     installedInstances: Instance[];
     connectedInstances: Instance[];
     defaultUserInstanceID?: string;
+    currentChannelID?: string;
+    fetchChannelDefaults: (channelID: string) => Promise<APIResponse<ChannelDefaults>>;
     fetchJiraProjectMetadata: (instanceID: string) => Promise<APIResponse<ProjectMetadata>>;
     getConnected: () => Promise<GetConnectedResponse>;
     hideProjectSelector?: boolean;
@@ -75,9 +78,13 @@ export default class JiraInstanceAndProjectSelector extends React.PureComponent<
             return;
         }
 
+        const channelInstanceID = await this.getChannelDefaultInstanceID();
+
         let instanceID = '';
         if (this.props.connectedInstances.length === 1) {
             instanceID = this.props.connectedInstances[0].instance_id;
+        } else if (channelInstanceID) {
+            instanceID = channelInstanceID;
         } else if (this.props.defaultUserInstanceID) {
             instanceID = this.props.defaultUserInstanceID;
         }
@@ -87,6 +94,20 @@ export default class JiraInstanceAndProjectSelector extends React.PureComponent<
         }
     };
 
+    // The instance the current channel is bound to, if the user is connected to it.
+    getChannelDefaultInstanceID = async (): Promise<string> => {
+        if (!this.props.currentChannelID) {
+            return '';
+        }
+
+        const {data} = await this.props.fetchChannelDefaults(this.props.currentChannelID);
+        const instanceID = data?.instance_id;
+        if (!instanceID || !this.props.connectedInstances.some((instance) => instance.instance_id === instanceID)) {
+            return '';
+        }
+        return instanceID;
+    };
+
     fetchJiraProjectMetadata = async (instanceID: string) => {
         if (!this.state.fetchingProjectMetadata) {
             this.setState({jiraProjectMetadata: null, fetchingProjectMetadata: true});
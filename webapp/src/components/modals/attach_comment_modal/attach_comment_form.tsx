@@ -60,6 +60,7 @@ type State = {
     textSearchTerms: string;
     error: string | null;
     instanceID: string;
+    includeThread: boolean;
 }
 
 export default class AttachCommentToIssueForm extends PureComponent<Props, State> {
@@ -70,6 +71,7 @@ export default class AttachCommentToIssueForm extends PureComponent<Props, State
         textSearchTerms: '',
         error: null,
         instanceID: '',
+        includeThread: false,
     } as State;
 
     handleSubmit = (e: React.FormEvent) => {
@@ -86,6 +88,7 @@ export default class AttachCommentToIssueForm extends PureComponent<Props, State
             current_team: this.props.currentTeam.name,
             issueKey: this.state.issueKey as string,
             instance_id: this.state.instanceID as string,
+            include_thread: this.state.includeThread,
         };
 
         this.setState({submitting: true});
@@ -110,6 +113,10 @@ export default class AttachCommentToIssueForm extends PureComponent<Props, State
         this.setState({issueKey});
     };
 
+    handleIncludeThreadChange = (e: React.ChangeEvent<HTMLInputElement>) => {
+        this.setState({includeThread: e.target.checked});
+    };
+
     render() {
         const {theme} = this.props;
         const {error, submitting} = this.state;
@@ -153,6 +160,16 @@ export default class AttachCommentToIssueForm extends PureComponent<Props, State
                         disabled={false}
                         readOnly={true}
                     />
+                    <div className='checkbox margin-bottom--none'>
+                        <label>
+                            <input
+                                type='checkbox'
+                                onChange={this.handleIncludeThreadChange}
+                                checked={this.state.includeThread}
+                            />
+                            {'Attach the entire thread'}
+                        </label>
+                    </div>
                 </div>
             );
         }
@@ -0,0 +1,168 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+import React from 'react';
+
+import {Theme} from 'mattermost-redux/selectors/entities/preferences';
+
+import ReactSelectSetting from 'components/react_select_setting';
+
+import {ChangeFilterValue, FilterField, ReactSelectOption} from 'types/model';
+
+export const CHANGE_VALUE_NONE = 'None';
+
+export type Props = {
+    fields: FilterField[];
+    values: ChangeFilterValue[];
+    theme: Theme;
+    onChange: (changes: ChangeFilterValue[]) => void;
+    addValidate: (isValid: () => boolean) => void;
+    removeValidate: (isValid: () => boolean) => void;
+};
+
+// Fields tracked in the changelog that are not filter fields
+const extraChangeFields: ReactSelectOption[] = [
+    {label: 'Assignee', value: 'assignee'},
+    {label: 'Reporter', value: 'reporter'},
+    {label: 'Resolution', value: 'resolution'},
+];
+
+// ChannelSubscriptionChangeFilters edits the filters on the changelog of updated issues,
+// like the status going from "In Review" to "Done".
+export default class ChannelSubscriptionChangeFilters extends React.PureComponent<Props> {
+    getFieldOptions = (): ReactSelectOption[] => {
+        const options = this.props.fields.map((field) => ({label: field.name, value: field.key}));
+        for (const extra of extraChangeFields) {
+            if (!options.find((option) => option.value === extra.value)) {
+                options.push(extra);
+            }
+        }
+        return options;
+    };
+
+    getValueOptions = (fieldKey: string): ReactSelectOption[] => {
+        const field = this.props.fields.find((f) => f.key === fieldKey);
+        const labels = ((field && field.values) || []).map((v) => v.label);
+        return [CHANGE_VALUE_NONE, ...new Set(labels)].map((label) => ({label, value: label}));
+    };
+
+    updateChange = (index: number, change: ChangeFilterValue): void => {
+        const changes = this.props.values.concat([]);
+        changes.splice(index, 1, change);
+        this.props.onChange(changes);
+    };
+
+    addChange = (): void => {
+        this.props.onChange(this.props.values.concat([{field: '', from: [], to: []}]));
+    };
+
+    removeChange = (index: number): void => {
+        const changes = this.props.values.concat([]);
+        changes.splice(index, 1);
+        this.props.onChange(changes);
+    };
+
+    toOptions = (values: string[] = []): ReactSelectOption[] => values.map((v) => ({label: v, value: v}));
+
+    render(): JSX.Element {
+        const {theme, values} = this.props;
+        const style = getStyle(theme);
+        const fieldOptions = this.getFieldOptions();
+
+        const rows = values.map((change, index) => {
+            const valueOptions = this.getValueOptions(change.field);
+            return (
+                <div
+                    className='row'
+                    key={index}
+                >
+                    <div className='col-md-11 col-sm-12'>
+                        <div className='row'>
+                            <div className='col-md-4 col-sm-12'>
+                                <ReactSelectSetting
+                                    name={'changefield'}
+                                    required={true}
+                                    hideRequiredStar={true}
+                                    options={fieldOptions}
+                                    value={fieldOptions.find((option) => option.value === change.field)}
+                                    onChange={(name: string, field: string) => this.updateChange(index, {field, from: [], to: []})}
+                                    theme={theme}
+                                    addValidate={this.props.addValidate}
+                                    removeValidate={this.props.removeValidate}
+                                />
+                            </div>
+                            <div className='col-md-4 col-sm-12'>
+                                <ReactSelectSetting
+                                    name={'changefrom'}
+                                    placeholder={'From any value'}
+                                    isMulti={true}
+                                    allowUserDefinedValue={true}
+                                    options={valueOptions}
+                                    value={this.toOptions(change.from)}
+                                    onChange={(name: string, from: string[]) => this.updateChange(index, {...change, from: from || []})}
+                                    theme={theme}
+                                />
+                            </div>
+                            <div className='col-md-4 col-sm-12'>
+                                <ReactSelectSetting
+                                    name={'changeto'}
+                                    placeholder={'To any value'}
+                                    isMulti={true}
+                                    allowUserDefinedValue={true}
+                                    options={valueOptions}
+                                    value={this.toOptions(change.to)}
+                                    onChange={(name: string, to: string[]) => this.updateChange(index, {...change, to: to || []})}
+                                    theme={theme}
+                                />
+                            </div>
+                        </div>
+                    </div>
+                    <div className='col-md-1 col-sm-12 text-center'>
+                        <button
+                            onClick={() => this.removeChange(index)}
+                            className='style--none'
+                            style={style.trashIcon}
+                            type='button'
+                        >
+                            <i className='fa fa-trash'/>
+                        </button>
+                    </div>
+                </div>
+            );
+        });
+
+        return (
+            <div className='margin-bottom'>
+                <label className='control-label margin-bottom'>
+                    {'Changes'}
+                </label>
+                <div>
+                    {rows}
+                    <button
+                        onClick={this.addChange}
+                        className='btn style--none d-flex align-items-center'
+                        type='button'
+                    >
+                        <span style={style.plusIcon}>{'+'}</span>
+                        {'Add Change Filter'}
+                    </button>
+                </div>
+                <p className='help-text'>
+                    {'Only notify for updates that change a field from or to the chosen values.'}
+                </p>
+            </div>
+        );
+    }
+}
+
+const getStyle = (theme: Theme) => ({
+    trashIcon: {
+        color: theme.errorTextColor,
+        fontSize: '20px',
+        margin: '0.5rem 0 0',
+    },
+    plusIcon: {
+        fontSize: '24px',
+        margin: '-1px 4px 0 0',
+    },
+});
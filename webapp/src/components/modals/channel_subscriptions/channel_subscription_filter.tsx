@@ -8,13 +8,18 @@ import {Theme} from 'mattermost-redux/selectors/entities/preferences';
 import ReactSelectSetting from 'components/react_select_setting';
 import JiraEpicSelector from 'components/data_selectors/jira_epic_selector';
 import JiraSprintSelector from 'components/data_selectors/jira_sprint_selector';
+import MattermostPrincipalSelector from 'components/data_selectors/mattermost_principal_selector';
 
 import {
     FIELD_KEY_STATUS,
+    getDefaultFilterValue,
     isCommentVisibilityField,
+    isDateField,
+    isDateValueType,
     isEpicLinkField,
     isLabelField,
     isMultiSelectField,
+    isNumberField,
     isSecurityLevelField,
     isSprintField,
     isTeamField,
@@ -24,6 +29,7 @@ import {
     FilterField,
     FilterFieldInclusion,
     FilterValue,
+    FilterValueType,
     IssueMetadata,
     IssueType,
     ReactSelectOption,
@@ -78,9 +84,23 @@ export default class ChannelSubscriptionFilter extends React.PureComponent<Props
     };
 
     handleFieldTypeChange = (name: string, choice: string): void => {
+        const {onChange, value, fields} = this.props;
+
+        onChange(value, getDefaultFilterValue(fields.find((f) => f.key === choice), choice));
+    };
+
+    handleUserMatchChange = (name: string, choice: string): void => {
+        const {onChange, value} = this.props;
+
+        const valueType = choice ? choice as FilterValueType : undefined;
+        onChange(value, {...value, values: [], value_type: valueType});
+    };
+
+    handleNumberValueChange = (e: React.ChangeEvent<HTMLInputElement>): void => {
         const {onChange, value} = this.props;
 
-        onChange(value, {...value, values: [], key: choice, inclusion: FilterFieldInclusion.INCLUDE_ANY});
+        const newValues = e.target.value === '' ? [] : [e.target.value];
+        onChange(value, {...value, values: newValues});
     };
 
     handleFieldValuesChange = (name: string, values: string[]): void => {
@@ -154,6 +174,12 @@ export default class ChannelSubscriptionFilter extends React.PureComponent<Props
             return false;
         }
 
+        error = this.checkNumberValueError();
+        if (error) {
+            this.setState({error});
+            return false;
+        }
+
         return true;
     };
 
@@ -167,6 +193,20 @@ export default class ChannelSubscriptionFilter extends React.PureComponent<Props
         return null;
     };
 
+    checkNumberValueError = (): string | null => {
+        const {value} = this.props;
+        if (value.value_type !== FilterValueType.NUMBER && !isDateValueType(value.value_type)) {
+            return null;
+        }
+        if (value.inclusion === FilterFieldInclusion.EMPTY) {
+            return null;
+        }
+        if (!value.values.length || isNaN(Number(value.values[0]))) {
+            return `Please enter a number for ${this.props.field.name}.`;
+        }
+        return null;
+    };
+
     checkFieldConflictError = (): string | null => {
         const conflictIssueTypes = this.getConflictingIssueTypes().map((it) => it.name);
         if (conflictIssueTypes.length) {
@@ -202,6 +242,15 @@ export default class ChannelSubscriptionFilter extends React.PureComponent<Props
         case FilterFieldInclusion.INCLUDE_OR_EMPTY:
             subtext = 'Includes the specified values or when the value is empty';
             break;
+        case FilterFieldInclusion.GREATER_THAN:
+            subtext = isDateValueType(this.props.value.value_type) ? 'Later than the number of days from today' : 'Greater than the value';
+            break;
+        case FilterFieldInclusion.LESS_THAN:
+            subtext = isDateValueType(this.props.value.value_type) ? 'Earlier than the number of days from today, use 0 for overdue' : 'Less than the value';
+            break;
+        case FilterFieldInclusion.WITHIN_DAYS:
+            subtext = 'Between today and the number of days from today';
+            break;
         }
 
         return (
@@ -248,7 +297,20 @@ export default class ChannelSubscriptionFilter extends React.PureComponent<Props
             ];
         }
 
-        if (!isMultiSelectField(field)) {
+        if (isNumberField(field)) {
+            inclusionSelectOptions = [
+                {label: 'Greater than', value: FilterFieldInclusion.GREATER_THAN},
+                {label: 'Less than', value: FilterFieldInclusion.LESS_THAN},
+                {label: 'Empty', value: FilterFieldInclusion.EMPTY},
+            ];
+        } else if (isDateField(field)) {
+            inclusionSelectOptions = [
+                {label: 'Within days', value: FilterFieldInclusion.WITHIN_DAYS},
+                {label: 'After days', value: FilterFieldInclusion.GREATER_THAN},
+                {label: 'Before days', value: FilterFieldInclusion.LESS_THAN},
+                {label: 'Empty', value: FilterFieldInclusion.EMPTY},
+            ];
+        } else if (!isMultiSelectField(field)) {
             const includeAllIndex = inclusionSelectOptions.findIndex((opt) => opt.value === FilterFieldInclusion.INCLUDE_ALL);
             inclusionSelectOptions.splice(includeAllIndex, 1);
         }
@@ -318,7 +380,18 @@ export default class ChannelSubscriptionFilter extends React.PureComponent<Props
         };
 
         let valueSelector;
-        if (isCommentVisibilityField(field)) {
+        if (isNumberField(field) || isDateField(field)) {
+            valueSelector = (
+                <input
+                    className='form-control'
+                    type='number'
+                    disabled={disableLastSelect}
+                    placeholder={isDateField(field) ? 'Days from today' : 'Value'}
+                    value={value.values[0] || ''}
+                    onChange={this.handleNumberValueChange}
+                />
+            );
+        } else if (isCommentVisibilityField(field)) {
             valueSelector = (
                 <JiraCommentVisibilitySelector
                     {...selectProps}
@@ -369,13 +442,46 @@ export default class ChannelSubscriptionFilter extends React.PureComponent<Props
                 />
             );
         } else if (isUserField(field)) {
+            const userMatchOptions = [
+                {label: 'Jira users', value: ''},
+                {label: 'Mattermost users', value: FilterValueType.MATTERMOST_USER},
+                {label: 'Mattermost groups', value: FilterValueType.MATTERMOST_GROUP},
+            ];
+            const userMatch = userMatchOptions.find((opt) => opt.value === (value.value_type || ''));
+
+            let userSelector;
+            if (value.value_type) {
+                userSelector = (
+                    <MattermostPrincipalSelector
+                        {...selectProps}
+                        key={value.value_type}
+                        groups={value.value_type === FilterValueType.MATTERMOST_GROUP}
+                        value={value.values}
+                        onChange={this.handleValueChangeWithoutName}
+                    />
+                );
+            } else {
+                userSelector = (
+                    <JiraAutoCompleteSelector
+                        {...selectProps}
+                        fieldName={field.name}
+                        value={value.values}
+                        onChange={this.handleValueChangeWithoutName}
+                    />
+                );
+            }
+
             valueSelector = (
-                <JiraAutoCompleteSelector
-                    {...selectProps}
-                    fieldName={field.name}
-                    value={value.values}
-                    onChange={this.handleValueChangeWithoutName}
-                />
+                <>
+                    <ReactSelectSetting
+                        name={'usermatch'}
+                        options={userMatchOptions}
+                        onChange={this.handleUserMatchChange}
+                        value={userMatch}
+                        theme={theme}
+                    />
+                    {userSelector}
+                </>
             );
         } else {
             valueSelector = (
@@ -468,9 +574,9 @@ type EmptyChannelSubscriptionFilterProps = {
 
 export function EmptyChannelSubscriptionFilter(props: EmptyChannelSubscriptionFilterProps): JSX.Element {
     const handleFieldTypeChange = (name: string, choice: string): void => {
-        const {onChange} = props;
+        const {onChange, fields} = props;
 
-        onChange(null, {values: [], key: choice, inclusion: FilterFieldInclusion.INCLUDE_ANY});
+        onChange(null, getDefaultFilterValue(fields.find((f) => f.key === choice), choice));
     };
 
     const {fields, theme} = props;
@@ -5,7 +5,6 @@ import React from 'react';
 
 import {
     FilterField,
-    FilterFieldInclusion,
     FilterValue,
     IssueMetadata,
 } from 'types/model';
@@ -46,7 +45,7 @@ export default class ChannelSubscriptionFilters extends React.PureComponent<Prop
         const index = newValues.findIndex((f) => f === oldValue);
 
         if (index === -1) {
-            newValues.push({...newValue, inclusion: FilterFieldInclusion.INCLUDE_ANY, values: []});
+            newValues.push({...newValue, values: []});
             this.setState({showCreateRow: false});
         } else {
             newValues.splice(index, 1, newValue);
@@ -23,6 +23,7 @@ import {
 } from 'utils/jira_issue_metadata';
 
 import {
+    ChangeFilterValue,
     ChannelSubscription,
     ChannelSubscriptionFilters as ChannelSubscriptionFiltersModel,
     FilterValue,
@@ -32,6 +33,7 @@ import {
 } from 'types/model';
 
 import ChannelSubscriptionFilters from './channel_subscription_filters';
+import ChannelSubscriptionChangeFilters from './channel_subscription_change_filters';
 import {SharedProps} from './shared_props';
 
 const JiraEventOptions: ReactSelectOption[] = [
@@ -58,6 +60,18 @@ const JiraEventOptions: ReactSelectOption[] = [
     {value: 'event_updated_status', label: 'Issue Updated: Status'},
     {value: 'event_updated_summary', label: 'Issue Updated: Summary'},
     {value: 'event_updated_components', label: 'Issue Updated: Components'},
+    {value: 'event_sla_breached', label: 'SLA Breached'},
+    {value: 'event_sprint_started', label: 'Sprint Started'},
+    {value: 'event_sprint_closed', label: 'Sprint Closed'},
+    {value: 'event_sprint_updated', label: 'Sprint Updated'},
+    {value: 'event_board_created', label: 'Board Created'},
+    {value: 'event_board_updated', label: 'Board Updated'},
+    {value: 'event_board_deleted', label: 'Board Deleted'},
+    {value: 'event_version_created', label: 'Version Created'},
+    {value: 'event_version_updated', label: 'Version Updated'},
+    {value: 'event_version_released', label: 'Version Released'},
+    {value: 'event_version_unreleased', label: 'Version Unreleased'},
+    {value: 'event_version_deleted', label: 'Version Deleted'},
 ];
 
 export type Props = SharedProps & {
@@ -375,6 +389,10 @@ export default class EditChannelSubscription extends PureComponent<Props, State>
         this.clearConflictingErrorMessage();
     };
 
+    handleChangeFiltersChange = (changes: ChangeFilterValue[]) => {
+        this.setState({filters: {...this.state.filters, changes}});
+    };
+
     handleCreate = (e?: React.FormEvent) => {
         if (e && e.preventDefault) {
             e.preventDefault();
@@ -538,6 +556,14 @@ export default class EditChannelSubscription extends PureComponent<Props, State>
                             searchTeamFields={this.props.searchTeamFields}
                             projectKey={this.state.filters.projects[0] || ''}
                         />
+                        <ChannelSubscriptionChangeFilters
+                            fields={filterFields}
+                            values={this.state.filters.changes || []}
+                            theme={this.props.theme}
+                            onChange={this.handleChangeFiltersChange}
+                            addValidate={this.validator.addComponent}
+                            removeValidate={this.validator.removeComponent}
+                        />
                         <div>
                             <label className='control-label margin-bottom'>
                                 {'Approximate JQL Output'}
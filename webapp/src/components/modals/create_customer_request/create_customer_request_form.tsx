@@ -0,0 +1,217 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+import React, {PureComponent} from 'react';
+import {Modal} from 'react-bootstrap';
+
+import {Post} from '@mattermost/types/posts';
+import {Team} from '@mattermost/types/teams';
+import {Theme} from 'mattermost-redux/selectors/entities/preferences';
+
+import {APIResponse, CreateCustomerRequestRequest, SavedFieldValues, ServiceDeskMetadata} from 'types/model';
+
+import {getModalStyles} from 'utils/styles';
+
+import FormButton from 'components/form_button';
+import Input from 'components/input';
+import ReactSelectSetting from 'components/react_select_setting';
+import Validator from 'components/validator';
+
+import JiraInstanceAndProjectSelector from 'components/jira_instance_and_project_selector';
+
+type Props = {
+    close: () => void;
+    create: (payload: CreateCustomerRequestRequest) => Promise<APIResponse<{}>>;
+    fetchRequestTypes: (instanceID: string, projectKey: string) => Promise<APIResponse<ServiceDeskMetadata>>;
+    post: Post;
+    currentTeam: Team;
+    theme: Theme;
+}
+
+type State = {
+    submitting: boolean;
+    fetchingRequestTypes: boolean;
+    error: string | null;
+    instanceID: string;
+    projectKey: string;
+    serviceDesk: ServiceDeskMetadata | null;
+    requestTypeID: string;
+    summary: string;
+}
+
+export default class CreateCustomerRequestForm extends PureComponent<Props, State> {
+    private validator = new Validator();
+    state: State = {
+        submitting: false,
+        fetchingRequestTypes: false,
+        error: null,
+        instanceID: '',
+        projectKey: '',
+        serviceDesk: null,
+        requestTypeID: '',
+        summary: this.props.post.message.split('\n')[0].substring(0, 255),
+    };
+
+    handleSubmit = (e: React.FormEvent) => {
+        if (e && e.preventDefault) {
+            e.preventDefault();
+        }
+
+        if (!this.validator.validate() || !this.state.serviceDesk) {
+            return;
+        }
+
+        const request = {
+            post_id: this.props.post.id,
+            current_team: this.props.currentTeam.name,
+            instance_id: this.state.instanceID,
+            service_desk_id: this.state.serviceDesk.id,
+            request_type_id: this.state.requestTypeID,
+            summary: this.state.summary,
+        };
+
+        this.setState({submitting: true});
+        this.props.create(request).then(({error}) => {
+            if (error) {
+                this.setState({error: error.message, submitting: false});
+            } else {
+                this.handleClose();
+            }
+        });
+    };
+
+    handleClose = (e?: Event) => {
+        if (e && e.preventDefault) {
+            e.preventDefault();
+        }
+
+        this.props.close();
+    };
+
+    handleProjectChange = async (fieldValues: SavedFieldValues) => {
+        const projectKey = fieldValues.project_key;
+        this.setState({projectKey, serviceDesk: null, requestTypeID: '', error: null});
+        if (!projectKey) {
+            return;
+        }
+
+        this.setState({fetchingRequestTypes: true});
+        const {data, error} = await this.props.fetchRequestTypes(this.state.instanceID, projectKey);
+        if (error) {
+            this.setState({fetchingRequestTypes: false, error: error.message});
+            return;
+        }
+        this.setState({fetchingRequestTypes: false, serviceDesk: data || null});
+    };
+
+    handleRequestTypeChange = (_: string, requestTypeID: string) => {
+        this.setState({requestTypeID});
+    };
+
+    handleSummaryChange = (_: string, summary: string) => {
+        this.setState({summary});
+    };
+
+    render() {
+        const {theme} = this.props;
+        const {error, submitting, serviceDesk} = this.state;
+        const style = getModalStyles(theme);
+
+        const requestTypeOptions = serviceDesk ? serviceDesk.request_types.map((requestType) => ({
+            label: requestType.name,
+            value: requestType.id,
+        })) : [];
+
+        let form;
+        if (serviceDesk) {
+            form = (
+                <div>
+                    <ReactSelectSetting
+                        name={'request_type'}
+                        label={'Request Type'}
+                        required={true}
+                        onChange={this.handleRequestTypeChange}
+                        options={requestTypeOptions}
+                        isMulti={false}
+                        theme={theme}
+                        value={requestTypeOptions.find((option) => option.value === this.state.requestTypeID)}
+                        addValidate={this.validator.addComponent}
+                        removeValidate={this.validator.removeComponent}
+                    />
+                    <Input
+                        id={'summary'}
+                        label={'Summary'}
+                        type={'input'}
+                        required={true}
+                        maxLength={255}
+                        value={this.state.summary}
+                        onChange={this.handleSummaryChange}
+                        addValidate={this.validator.addComponent}
+                        removeValidate={this.validator.removeComponent}
+                    />
+                    <Input
+                        label='Description'
+                        type='textarea'
+                        value={this.props.post.message}
+                        readOnly={true}
+                        addValidate={this.validator.addComponent}
+                        removeValidate={this.validator.removeComponent}
+                    />
+                </div>
+            );
+        }
+
+        let errorMessage;
+        if (error) {
+            errorMessage = (
+                <p className='alert alert-danger'>
+                    <i
+                        className='fa fa-warning'
+                        title='Warning Icon'
+                    />
+                    <span>{error}</span>
+                </p>
+            );
+        }
+
+        return (
+            <form
+                role='form'
+                onSubmit={this.handleSubmit}
+            >
+                <Modal.Body style={style.modalBody}>
+                    {errorMessage}
+                    <JiraInstanceAndProjectSelector
+                        selectedInstanceID={this.state.instanceID}
+                        selectedProjectID={this.state.projectKey}
+                        onInstanceChange={(instanceID: string) => this.setState({instanceID, projectKey: '', serviceDesk: null})}
+                        onProjectChange={this.handleProjectChange}
+                        theme={theme}
+                        addValidate={this.validator.addComponent}
+                        removeValidate={this.validator.removeComponent}
+                        onError={(err: string) => this.setState({error: err})}
+                    />
+                    {form}
+                </Modal.Body>
+                <Modal.Footer style={style.modalFooter}>
+                    <FormButton
+                        type='button'
+                        btnClass='btn-link'
+                        defaultMessage='Cancel'
+                        onClick={this.handleClose}
+                    />
+                    <FormButton
+                        type='submit'
+                        btnClass='btn btn-primary'
+                        saving={submitting}
+                        defaultMessage='Raise'
+                        savingMessage='Raising'
+                        disabled={!serviceDesk || !this.state.requestTypeID || this.state.fetchingRequestTypes}
+                    >
+                        {'Raise'}
+                    </FormButton>
+                </Modal.Footer>
+            </form>
+        );
+    }
+}
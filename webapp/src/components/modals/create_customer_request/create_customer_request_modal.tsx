@@ -0,0 +1,51 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+import React from 'react';
+import {Modal} from 'react-bootstrap';
+
+import {Post} from '@mattermost/types/posts';
+import {Team} from '@mattermost/types/teams';
+import {Theme} from 'mattermost-redux/selectors/entities/preferences';
+
+import {APIResponse, CreateCustomerRequestRequest, ServiceDeskMetadata} from 'types/model';
+
+import CreateCustomerRequestForm from './create_customer_request_form';
+
+type Props = {
+    visible: boolean;
+    post: Post | null;
+    currentTeam: Team;
+    theme: Theme;
+    close: () => void;
+    create: (payload: CreateCustomerRequestRequest) => Promise<APIResponse<{}>>;
+    fetchRequestTypes: (instanceID: string, projectKey: string) => Promise<APIResponse<ServiceDeskMetadata>>;
+}
+
+export default function CreateCustomerRequestModal(props: Props) {
+    const {visible, post} = props;
+    if (!visible || !post) {
+        return null;
+    }
+
+    return (
+        <Modal
+            dialogClassName='modal--scroll'
+            show={visible}
+            onHide={props.close}
+            onExited={props.close}
+            bsSize='large'
+            backdrop='static'
+        >
+            <Modal.Header closeButton={true}>
+                <Modal.Title>
+                    {'Raise Jira Service Management Request'}
+                </Modal.Title>
+            </Modal.Header>
+            <CreateCustomerRequestForm
+                {...props}
+                post={post}
+            />
+        </Modal>
+    );
+}
@@ -0,0 +1,35 @@
+// Copyright (c) 2017-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+import {connect} from 'react-redux';
+import {bindActionCreators} from 'redux';
+
+import {getPost} from 'mattermost-redux/selectors/entities/posts';
+import {getCurrentTeam} from 'mattermost-redux/selectors/entities/teams';
+
+import {closeCreateCustomerRequestModal, createCustomerRequest, fetchRequestTypes} from 'actions';
+import {getCreateCustomerRequestModalForPostId} from 'selectors';
+
+import {GlobalState} from 'types/store';
+
+import CreateCustomerRequestModal from './create_customer_request_modal';
+
+const mapStateToProps = (state: GlobalState) => {
+    const postId = getCreateCustomerRequestModalForPostId(state);
+    const post = postId ? getPost(state, postId) : null;
+    const currentTeam = getCurrentTeam(state);
+
+    return {
+        visible: Boolean(post),
+        post,
+        currentTeam,
+    };
+};
+
+const mapDispatchToProps = (dispatch) => bindActionCreators({
+    close: closeCreateCustomerRequestModal,
+    create: createCustomerRequest,
+    fetchRequestTypes,
+}, dispatch);
+
+export default connect(mapStateToProps, mapDispatchToProps)(CreateCustomerRequestModal);
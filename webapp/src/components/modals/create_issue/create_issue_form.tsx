@@ -33,6 +33,7 @@ type Props = {
     create: (issue: CreateIssueRequest) => Promise<APIResponse<{}>>;
     description?: string;
     channelId?: string;
+    fromThread?: boolean;
     currentTeam: Team;
     post?: Post;
     theme: Theme;
@@ -57,7 +58,7 @@ export default class CreateIssueForm extends React.PureComponent<Props, State> {
         super(props);
 
         let description = this.props.description || '';
-        if (props.post) {
+        if (props.post && !props.fromThread) {
             description = props.post.message;
         }
 
@@ -218,6 +219,7 @@ export default class CreateIssueForm extends React.PureComponent<Props, State> {
             channel_id: channelId as string,
             instance_id: this.state.instanceID as string,
             required_fields_not_covered: requiredFieldsNotCovered,
+            from_thread: Boolean(this.props.fromThread),
         };
 
         this.setState({submitting: true});
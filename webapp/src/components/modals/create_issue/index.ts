@@ -20,7 +20,7 @@ import {GlobalState} from 'types/store';
 import CreateIssue from './create_issue_modal';
 
 const mapStateToProps = (state: GlobalState) => {
-    const {postId, description, channelId} = getCreateModal(state);
+    const {postId, description, channelId, fromThread} = getCreateModal(state);
     const post = (postId) ? getPost(state, postId) : null;
     const currentTeam = getCurrentTeam(state);
 
@@ -29,6 +29,7 @@ const mapStateToProps = (state: GlobalState) => {
         post,
         description,
         channelId,
+        fromThread,
         currentTeam,
     };
 };
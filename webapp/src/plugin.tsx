@@ -10,6 +10,7 @@ import ConnectModal from 'components/modals/connect_modal';
 import DisconnectModal from 'components/modals/disconnect_modal';
 
 import CreateIssuePostMenuAction from 'components/post_menu_actions/create_issue';
+import CreateIssueFromThreadPostMenuAction from 'components/post_menu_actions/create_issue_from_thread';
 
 import CreateIssueModal from 'components/modals/create_issue';
 
@@ -17,6 +18,8 @@ import ChannelSubscriptionsModal from 'components/modals/channel_subscriptions';
 
 import AttachCommentToIssuePostMenuAction from 'components/post_menu_actions/attach_comment_to_issue';
 import AttachCommentToIssueModal from 'components/modals/attach_comment_modal';
+import CreateCustomerRequestPostMenuAction from 'components/post_menu_actions/create_customer_request';
+import CreateCustomerRequestModal from 'components/modals/create_customer_request';
 import SetupUI from 'components/setup_ui';
 import LinkTooltip from 'components/jira_ticket_tooltip';
 import {canUserConnect, getInstalledInstances, isUserConnected} from 'selectors';
@@ -33,7 +36,9 @@ import {
     handleConnectFlow,
     handleInstanceStatusChange,
     openAttachCommentToIssueModal,
+    openCreateCustomerRequestModal,
     openCreateModal,
+    openCreateModalFromThread,
 } from './actions';
 
 import Hooks from './hooks/hooks';
@@ -83,6 +88,27 @@ const setupUILater = (registry: any, store: Store<object, Action<object>>): () =
                     return true;
                 },
             });
+            registry.registerPostDropdownMenuAction({
+                text: CreateIssueFromThreadPostMenuAction,
+                action: (postId: string) => {
+                    const state = store.getState() as GlobalState;
+                    if (!isUserConnected(state)) {
+                        return;
+                    }
+
+                    store.dispatch<any>(openCreateModalFromThread(postId));
+                },
+                filter: (postId: string): boolean => {
+                    const state = store.getState() as GlobalState;
+                    const post = getPost(state, postId);
+                    if (!post || isSystemMessage(post) || isCombinedUserActivityPost(post)) {
+                        return false;
+                    }
+
+                    const inThread = Boolean(post.root_id) || Boolean(post.reply_count);
+                    return inThread && isUserConnected(state) && getInstalledInstances(state).length > 0;
+                },
+            });
             registry.registerRootComponent(AttachCommentToIssueModal);
             registry.registerPostDropdownMenuAction({
                 text: AttachCommentToIssuePostMenuAction,
@@ -109,6 +135,27 @@ const setupUILater = (registry: any, store: Store<object, Action<object>>): () =
                     return !systemMessage && userConnected;
                 },
             });
+            registry.registerRootComponent(CreateCustomerRequestModal);
+            registry.registerPostDropdownMenuAction({
+                text: CreateCustomerRequestPostMenuAction,
+                action: (postId: string) => {
+                    const state = store.getState() as GlobalState;
+                    if (!isUserConnected(state)) {
+                        return;
+                    }
+
+                    store.dispatch<any>(openCreateCustomerRequestModal(postId));
+                },
+                filter: (postId: string): boolean => {
+                    const state = store.getState() as GlobalState;
+                    const post = getPost(state, postId);
+                    if (!post || isSystemMessage(post) || isCombinedUserActivityPost(post)) {
+                        return false;
+                    }
+
+                    return isUserConnected(state);
+                },
+            });
             registry.registerLinkTooltipComponent(LinkTooltip);
         }
 
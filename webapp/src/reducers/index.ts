@@ -123,6 +123,7 @@ const createModal = (state = '', action = {} as AnyAction) => {
             postId: action.data.postId,
             description: action.data.description,
             channelId: action.data.channelId,
+            fromThread: Boolean(action.data.fromThread),
         };
     case ActionTypes.CLOSE_CREATE_ISSUE_MODAL:
         return {};
@@ -153,6 +154,17 @@ const attachCommentToIssueModalForPostId = (state = '', action = {} as AnyAction
     }
 };
 
+const createCustomerRequestModalForPostId = (state = '', action = {} as AnyAction) => {
+    switch (action.type) {
+    case ActionTypes.OPEN_CREATE_CUSTOMER_REQUEST_MODAL:
+        return action.data.postId;
+    case ActionTypes.CLOSE_CREATE_CUSTOMER_REQUEST_MODAL:
+        return '';
+    default:
+        return state;
+    }
+};
+
 const channelIdWithSettingsOpen = (state = '', action = {} as AnyAction) => {
     switch (action.type) {
     case ActionTypes.OPEN_CHANNEL_SETTINGS:
@@ -279,6 +291,7 @@ export default combineReducers({
     createModal,
     attachCommentToIssueModalVisible,
     attachCommentToIssueModalForPostId,
+    createCustomerRequestModalForPostId,
     channelIdWithSettingsOpen,
     subscriptionTemplates,
     subscriptionTemplatesForProjectKey,
@@ -50,6 +50,8 @@ export const isAttachCommentToIssueModalVisible = (state: GlobalState) => getPlu
 
 export const getAttachCommentToIssueModalForPostId = (state: GlobalState) => getPluginState(state).attachCommentToIssueModalForPostId;
 
+export const getCreateCustomerRequestModalForPostId = (state: GlobalState) => getPluginState(state).createCustomerRequestModalForPostId;
+
 export const getChannelIdWithSettingsOpen = (state: GlobalState) => getPluginState(state).channelIdWithSettingsOpen;
 
 export const getChannelSubscriptions = (state: GlobalState) => getPluginState(state).channelSubscriptions;
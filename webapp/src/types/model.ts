@@ -77,6 +77,19 @@ export type IssueTypeWithStatuses = {
 export type IssueMetadata = {
     projects: Project[];
     issue_types_with_statuses: IssueTypeWithStatuses[];
+    service_desks?: ServiceDeskMetadata[];
+}
+
+export type RequestType = {
+    id: string;
+    name: string;
+    description?: string;
+}
+
+export type ServiceDeskMetadata = {
+    id: string;
+    project_key: string;
+    request_types: RequestType[];
 }
 
 export type Status = {
@@ -95,6 +108,14 @@ export type SavedFieldValues = {
     issue_type?: string;
 }
 
+export type ChannelDefaults = {
+    instance_id?: string;
+    project_key?: string;
+    issue_type?: string;
+    labels?: string[];
+    components?: string[];
+}
+
 export enum JiraFieldTypeEnums {
     PROJECT = 'project',
     ISSUE_TYPE = 'issuetype',
@@ -156,19 +177,40 @@ export enum FilterFieldInclusion {
     EXCLUDE_ANY = 'exclude_any',
     EMPTY = 'empty',
     INCLUDE_OR_EMPTY = 'include_or_empty',
+    GREATER_THAN = 'greater_than',
+    LESS_THAN = 'less_than',
+    WITHIN_DAYS = 'within_days',
+}
+
+export enum FilterValueType {
+    NUMBER = 'number',
+    DATE = 'date',
+    DATETIME = 'datetime',
+    MATTERMOST_USER = 'mattermost_user',
+    MATTERMOST_GROUP = 'mattermost_group',
 }
 
 export type FilterValue = {
     key: string;
     values: string[];
     inclusion: FilterFieldInclusion;
+    value_type?: FilterValueType;
 }
 
+// ChangeFilterValue matches the updates of a field by the values it changed from and to.
+// An empty list matches any value.
+export type ChangeFilterValue = {
+    field: string;
+    from?: string[];
+    to?: string[];
+};
+
 export type ChannelSubscriptionFilters = {
     projects: string[];
     events: string[];
     issue_types: string[];
     fields: FilterValue[];
+    changes?: ChangeFilterValue[];
 };
 
 export type ChannelSubscription = {
@@ -216,6 +258,7 @@ export type CreateIssueRequest = {
     current_team: string;
     channel_id: string;
     fields: {};
+    from_thread?: boolean;
 };
 
 export type SearchIssueParams = {
@@ -242,6 +285,16 @@ export type AttachCommentRequest = {
     current_team: string;
     issueKey: string;
     instance_id: string;
+    include_thread?: boolean;
+};
+
+export type CreateCustomerRequestRequest = {
+    post_id: string;
+    current_team: string;
+    instance_id: string;
+    service_desk_id: string;
+    request_type_id: string;
+    summary: string;
 };
 
 export type AllProjectMetadata = {
@@ -9,6 +9,7 @@ import {
     ChannelSubscriptionFilters,
     FilterField,
     FilterFieldInclusion,
+    FilterValueType,
     IssueMetadata,
     JiraField,
 } from 'types/model';
@@ -69,6 +70,32 @@ describe('utils/jira_issue_metadata', () => {
         expect(actual[3].name).toBe('Team');
     });
 
+    test('should return the service desk fields for service desk projects', () => {
+        const field = {
+            hasDefaultValue: false,
+            key: 'customfield_10021',
+            name: 'Sprint',
+            operations: ['set'],
+            required: false,
+            schema: {custom: 'com.pyxis.greenhopper.jira:gh-sprint', customId: 10021, items: 'string', type: 'array'},
+        };
+        const metadata = {
+            ...useFieldForIssueMetadata(field, 'customfield_10021'),
+            service_desks: [{
+                id: '1',
+                project_key: 'TEST',
+                request_types: [{id: '11', name: 'Get IT help'}],
+            }],
+        };
+
+        const actual = getCustomFieldFiltersForProjects(metadata, ['TEST'], []);
+        expect(actual.map((f) => f.key)).toEqual(expect.arrayContaining(['requestType', 'sla', 'commentAudience']));
+        expect(actual.find((f) => f.key === 'requestType')?.values).toEqual([{label: 'Get IT help', value: '11'}]);
+
+        const other = getCustomFieldFiltersForProjects(metadata, ['OTHER'], []);
+        expect(other.map((f) => f.key)).not.toContain('sla');
+    });
+
     test('should return options for multi-select options', () => {
         const field = {
             allowedValues: [
@@ -677,6 +704,51 @@ describe('utils/jira_issue_metadata', () => {
             const actual = generateJQLStringFromSubscriptionFilters(issueMetadata, fields, filters);
             expect(actual).toEqual('Project = KT AND IssueType IN (Bug) AND Priority IS EMPTY');
         });
+
+        it('number and date comparisons chosen', () => {
+            const storyPointsField: FilterField = {
+                key: 'customfield_10016',
+                name: 'Story Points',
+                issueTypes: [{id: '10001', name: 'Bug'}],
+                schema: {
+                    type: 'number',
+                },
+            };
+            const dueDateField: FilterField = {
+                key: 'duedate',
+                name: 'Due date',
+                issueTypes: [{id: '10001', name: 'Bug'}],
+                schema: {
+                    type: 'date',
+                },
+            };
+
+            const filters: ChannelSubscriptionFilters = {
+                projects: ['KT'],
+                issue_types: ['10001'],
+                events: [],
+                fields: [
+                    {key: 'customfield_10016', values: ['3'], inclusion: FilterFieldInclusion.GREATER_THAN, value_type: FilterValueType.NUMBER},
+                    {key: 'duedate', values: ['3'], inclusion: FilterFieldInclusion.WITHIN_DAYS, value_type: FilterValueType.DATE},
+                ],
+            };
+
+            const actual = generateJQLStringFromSubscriptionFilters(issueMetadata, [storyPointsField, dueDateField], filters);
+            expect(actual).toEqual('Project = KT AND IssueType IN (Bug) AND "Story Points" > 3 AND ("Due date" >= startOfDay() AND "Due date" <= endOfDay(3d))');
+        });
+
+        it('change filters chosen', () => {
+            const filters: ChannelSubscriptionFilters = {
+                projects: ['KT'],
+                issue_types: ['10001'],
+                events: [],
+                fields: [],
+                changes: [{field: 'priority', from: ['Low'], to: ['High']}],
+            };
+
+            const actual = generateJQLStringFromSubscriptionFilters(issueMetadata, [priorityField], filters);
+            expect(actual).toEqual('Project = KT AND IssueType IN (Bug) AND Priority CHANGED FROM (Low) TO (High)');
+        });
     });
 
     describe('getJiraTicketDetails', () => {
@@ -7,6 +7,7 @@ import {
     FilterField,
     FilterFieldInclusion,
     FilterValue,
+    FilterValueType,
     IssueMetadata,
     IssueType,
     IssueTypeIdentifier,
@@ -15,6 +16,7 @@ import {
     JiraFieldTypeEnums,
     ProjectMetadata,
     ReactSelectOption,
+    RequestType,
     SelectField,
     Status,
     StringArrayField,
@@ -184,6 +186,9 @@ const allowedArrayTypes = [
 const allowedFieldTypes = [
     'user',
     'option',
+    'number',
+    'date',
+    'datetime',
 ];
 
 const jiraSystemCustomFieldTypesKey = 'com.atlassian.jira.plugin.system.customfieldtypes';
@@ -286,7 +291,17 @@ export function getCustomFieldFiltersForProjects(metadata: IssueMetadata | null,
         } as FilterField;
     });
 
-    const result = userResult.concat(userDefinedFields);
+    const typedFields = fields.filter((field) => isNumberField(field) || isDateField(field));
+    const populatedTypedFields = typedFields.map((field) => {
+        return {
+            key: field.key,
+            name: field.name,
+            schema: field.schema,
+            issueTypes: field.validIssueTypes,
+        } as FilterField;
+    });
+
+    const result = userResult.concat(userDefinedFields, populatedTypedFields);
     const epicLinkField = fields.find(isEpicLinkField);
     if (epicLinkField) {
         result.unshift({
@@ -341,9 +356,67 @@ export function getCustomFieldFiltersForProjects(metadata: IssueMetadata | null,
         result.push(statusField);
     }
 
+    result.push(...getServiceDeskFilterFields(metadata, projectKeys));
+
     return sortByName(result);
 }
 
+// getServiceDeskFilterFields returns the filters only available for Jira Service Management
+// projects. Their values are computed by the server from the webhook, see servicedesk.go.
+function getServiceDeskFilterFields(metadata: IssueMetadata | null, projectKeys: string[]): FilterField[] {
+    const serviceDesks = (metadata?.service_desks || []).filter((serviceDesk) => projectKeys.includes(serviceDesk.project_key));
+    if (!serviceDesks.length) {
+        return [];
+    }
+
+    const issueTypes = metadata && metadata.issue_types_with_statuses.map((type) => {
+        return {
+            id: type.id,
+            name: type.name,
+        };
+    });
+
+    const requestTypes = flatten(serviceDesks.map((serviceDesk) => serviceDesk.request_types)) as RequestType[];
+    return [
+        {
+            key: 'requestType',
+            name: 'Request Type',
+            schema: {
+                type: 'array',
+            },
+            values: requestTypes.map((requestType) => ({
+                label: requestType.name,
+                value: requestType.id,
+            })),
+            issueTypes,
+        },
+        {
+            key: 'sla',
+            name: 'SLA',
+            schema: {
+                type: 'array',
+            },
+            values: [
+                {label: 'Breached', value: 'breached'},
+                {label: 'Within goal', value: 'within_goal'},
+            ],
+            issueTypes,
+        },
+        {
+            key: 'commentAudience',
+            name: 'Comment Audience',
+            schema: {
+                type: 'array',
+            },
+            values: [
+                {label: 'Shared with customers', value: 'public'},
+                {label: 'Internal', value: 'internal'},
+            ],
+            issueTypes,
+        },
+    ] as FilterField[];
+}
+
 const avoidedCustomTypesForEvents: string[] = [
     JiraFieldCustomTypeEnums.RANK,
 ];
@@ -418,6 +491,34 @@ export function isTextField(field: JiraField | FilterField): boolean {
     return field.schema.type === 'string';
 }
 
+export function isNumberField(field: JiraField | FilterField): boolean {
+    return field.schema.type === 'number';
+}
+
+export function isDateField(field: JiraField | FilterField): boolean {
+    return field.schema.type === 'date' || field.schema.type === 'datetime';
+}
+
+// isDateValueType tells whether a filter compares a date or datetime field to
+// a number of days.
+export function isDateValueType(valueType?: FilterValueType): boolean {
+    return valueType === FilterValueType.DATE || valueType === FilterValueType.DATETIME;
+}
+
+// getDefaultFilterValue returns a new filter on the field, with a comparison for
+// number and date fields.
+export function getDefaultFilterValue(field: FilterField | undefined, key: string): FilterValue {
+    if (field && isNumberField(field)) {
+        return {key, values: [], inclusion: FilterFieldInclusion.GREATER_THAN, value_type: FilterValueType.NUMBER};
+    }
+    if (field && isDateField(field)) {
+        const valueType = field.schema.type === 'datetime' ? FilterValueType.DATETIME : FilterValueType.DATE;
+        return {key, values: [], inclusion: FilterFieldInclusion.WITHIN_DAYS, value_type: valueType};
+    }
+
+    return {key, values: [], inclusion: FilterFieldInclusion.INCLUDE_ANY};
+}
+
 export function isSecurityLevelField(field: JiraField | FilterField): boolean {
     return field.schema.type === 'securitylevel';
 }
@@ -446,6 +547,33 @@ function quoteGuard(s: string) {
     return s;
 }
 
+// Dates are compared in whole days relative to today, like the server does.
+function generateJQLForTypedFilter(fieldName: string, inclusion: FilterFieldInclusion, valueType: FilterValueType, values: string[]): string {
+    const value = values.length ? values[0] : '?';
+    switch (valueType) {
+    case FilterValueType.NUMBER:
+        return `${fieldName} ${inclusion === FilterFieldInclusion.LESS_THAN ? '<' : '>'} ${value}`;
+    case FilterValueType.DATE:
+    case FilterValueType.DATETIME:
+        if (inclusion === FilterFieldInclusion.LESS_THAN) {
+            return `${fieldName} < startOfDay(${value}d)`;
+        }
+        if (inclusion === FilterFieldInclusion.GREATER_THAN) {
+            return `${fieldName} > endOfDay(${value}d)`;
+        }
+        if (value.startsWith('-')) {
+            return `(${fieldName} >= startOfDay(${value}d) AND ${fieldName} <= endOfDay())`;
+        }
+        return `(${fieldName} >= startOfDay() AND ${fieldName} <= endOfDay(${value}d))`;
+    default: {
+        // Mattermost users and groups have no JQL equivalent
+        const inclusionString = inclusion === FilterFieldInclusion.EXCLUDE_ANY ? 'NOT IN' : 'IN';
+        const kind = valueType === FilterValueType.MATTERMOST_GROUP ? 'Mattermost groups' : 'Mattermost users';
+        return `${fieldName} ${inclusionString} (${values.length} ${kind})`;
+    }
+    }
+}
+
 export function generateJQLStringFromSubscriptionFilters(issueMetadata: IssueMetadata, fields: FilterField[], filters: ChannelSubscriptionFilters, securityLevelEmptyForJiraSubscriptions?: boolean) {
     const projectJQL = `Project = ${quoteGuard(filters.projects[0]) || '?'}`;
 
@@ -463,7 +591,7 @@ export function generateJQLStringFromSubscriptionFilters(issueMetadata: IssueMet
     }
     const issueTypesJQL = `IssueType IN ${issueTypeValueString}`;
 
-    let filterFieldsJQL = filters.fields.map(({key, inclusion, values}): string => {
+    let filterFieldsJQL = filters.fields.map(({key, inclusion, values, value_type: valueType}): string => {
         const field = fields.find((f) => f.key === key);
         if (!field) {
             // broken filter
@@ -476,6 +604,10 @@ export function generateJQLStringFromSubscriptionFilters(issueMetadata: IssueMet
             return `${quoteGuard(fieldName)} IS EMPTY`;
         }
 
+        if (valueType) {
+            return generateJQLForTypedFilter(quoteGuard(fieldName), inclusion, valueType, values);
+        }
+
         const inclusionString = inclusion === FilterFieldInclusion.EXCLUDE_ANY ? 'NOT IN' : 'IN';
         if (!values.length) {
             return `${quoteGuard(fieldName)} ${inclusionString} ?`;
@@ -504,6 +636,17 @@ export function generateJQLStringFromSubscriptionFilters(issueMetadata: IssueMet
         return `${quoteGuard(fieldName)} ${inclusionString} ${valueString}`;
     }).join(' AND ');
 
+    const changesJQL = (filters.changes || []).filter((change) => change.field).map((change) => {
+        const field = fields.find((f) => f.key === change.field);
+        const fieldName = quoteGuard(field ? getFieldNameForJQL(field) : change.field);
+        const from = change.from && change.from.length ? ` FROM (${change.from.map(quoteGuard).join(', ')})` : '';
+        const to = change.to && change.to.length ? ` TO (${change.to.map(quoteGuard).join(', ')})` : '';
+        return `${fieldName} CHANGED${from}${to}`;
+    }).join(' AND ');
+    if (changesJQL) {
+        filterFieldsJQL = [filterFieldsJQL, changesJQL].filter(Boolean).join(' AND ');
+    }
+
     const shouldShowEmptySecurityLevel = securityLevelEmptyForJiraSubscriptions && !filters.fields.some(filterValueIsSecurityField);
     if (shouldShowEmptySecurityLevel) {
         if (filterFieldsJQL.length) {